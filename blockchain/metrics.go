@@ -0,0 +1,46 @@
+package blockchain
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics contains the metrics exposed by the blockchain package.
+type Metrics struct {
+	// 1 while fast-syncing, 0 otherwise.
+	Syncing prometheus.Gauge
+	// Height of the next block the pool is waiting for.
+	PoolHeight prometheus.Gauge
+	// Number of block requests waiting for a peer or a response.
+	PendingRequests prometheus.Gauge
+	// Number of open block requests.
+	Requesters prometheus.Gauge
+}
+
+// PrometheusMetrics registers the fast-sync metrics with registry.
+func PrometheusMetrics(registry prometheus.Registerer) *Metrics {
+	m := NopMetrics()
+	registry.MustRegister(m.Syncing, m.PoolHeight, m.PendingRequests, m.Requesters)
+	return m
+}
+
+// NopMetrics returns fast-sync metrics which are never exported.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		Syncing: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "blockchain",
+			Name: "syncing", Help: "Whether the node is fast-syncing (1) or not (0).",
+		}),
+		PoolHeight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "blockchain",
+			Name: "pool_height", Help: "Height of the next block to sync.",
+		}),
+		PendingRequests: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "blockchain",
+			Name: "pending_requests", Help: "Number of block requests waiting for a peer or a response.",
+		}),
+		Requesters: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "blockchain",
+			Name: "requesters", Help: "Number of open block requests.",
+		}),
+	}
+}
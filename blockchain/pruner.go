@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"time"
+
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/state/txindex"
+	cmn "github.com/tendermint/tmlibs/common"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+const (
+	// how often to check if there is anything to prune
+	pruneIntervalSeconds = 10
+)
+
+// Pruner removes old blocks from the BlockStore, their txs from the
+// TxIndexer and their ABCIResponses from the state db in the background,
+// keeping the latest retainBlocks blocks and every keepEvery'th block
+// (if keepEvery > 0).
+type Pruner struct {
+	cmn.BaseService
+
+	store        *BlockStore
+	txIndexer    txindex.TxIndexer
+	stateDB      dbm.DB
+	retainBlocks int
+	keepEvery    int
+
+	quit chan struct{}
+}
+
+// NewPruner returns a new Pruner. retainBlocks must be greater than 0.
+func NewPruner(store *BlockStore, txIndexer txindex.TxIndexer, stateDB dbm.DB, retainBlocks, keepEvery int) *Pruner {
+	if retainBlocks <= 0 {
+		cmn.PanicSanity(cmn.Fmt("retainBlocks must be greater than 0, got %v", retainBlocks))
+	}
+	pr := &Pruner{
+		store:        store,
+		txIndexer:    txIndexer,
+		stateDB:      stateDB,
+		retainBlocks: retainBlocks,
+		keepEvery:    keepEvery,
+		quit:         make(chan struct{}),
+	}
+	pr.BaseService = *cmn.NewBaseService(nil, "Pruner", pr)
+	return pr
+}
+
+// OnStart implements cmn.Service.
+func (pr *Pruner) OnStart() error {
+	pr.BaseService.OnStart()
+	go pr.pruneRoutine()
+	return nil
+}
+
+// OnStop implements cmn.Service.
+func (pr *Pruner) OnStop() {
+	pr.BaseService.OnStop()
+	close(pr.quit)
+}
+
+func (pr *Pruner) pruneRoutine() {
+	ticker := time.NewTicker(pruneIntervalSeconds * time.Second)
+	defer ticker.Stop()
+
+	for {
+		pr.Prune()
+		select {
+		case <-ticker.C:
+		case <-pr.quit:
+			return
+		}
+	}
+}
+
+// Prune removes everything below the retain height, if there's anything to remove.
+func (pr *Pruner) Prune() {
+	retainHeight := pr.store.Height() - pr.retainBlocks + 1
+	base := pr.store.Base()
+	if retainHeight <= base {
+		return
+	}
+
+	// prune the txs first, so we never index txs of blocks we don't have
+	txs, err := pr.txIndexer.Prune(retainHeight, pr.keepEvery)
+	if err != nil {
+		pr.Logger.Error("Failed to prune tx index", "retainHeight", retainHeight, "err", err)
+		return
+	}
+	results := sm.PruneABCIResponses(pr.stateDB, base, retainHeight, pr.keepEvery)
+	blocks, err := pr.store.PruneBlocks(retainHeight, pr.keepEvery)
+	if err != nil {
+		pr.Logger.Error("Failed to prune blocks", "retainHeight", retainHeight, "err", err)
+		return
+	}
+	pr.Logger.Info("Pruned blocks", "blocks", blocks, "txs", txs, "results", results, "base", retainHeight)
+}
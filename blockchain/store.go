@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
 	"sync"
 
 	. "github.com/tendermint/tmlibs/common"
@@ -16,32 +17,47 @@ import (
 /*
 Simple low level store for blocks.
 
-There are three types of information stored:
+There are four types of information stored:
  - BlockMeta:   Meta information about each block
  - Block part:  Parts of each block, aggregated w/ PartSet
  - Commit:      The commit part of each block, for gossiping precommit votes
+ - Block hash:  The height of each block, by hash
 
 Currently the precommit signatures are duplicated in the Block parts as
 well as the Commit.  In the future this may change, perhaps by moving
 the Commit data outside the Block.
 
+Old blocks can be pruned (see PruneBlocks). The store then holds the
+contiguous range of blocks from Base() to Height(), plus any older blocks
+kept as checkpoints. Loading a pruned block returns nil.
+
 Panics indicate probable corruption in the data
 */
 type BlockStore struct {
 	db dbm.DB
 
 	mtx    sync.RWMutex
+	base   int
 	height int
 }
 
 func NewBlockStore(db dbm.DB) *BlockStore {
 	bsjson := LoadBlockStoreStateJSON(db)
 	return &BlockStore{
+		base:   bsjson.Base,
 		height: bsjson.Height,
 		db:     db,
 	}
 }
 
+// Base() returns the first known contiguous block height, or 0 for an empty store.
+// Blocks below it have been pruned, except those kept as checkpoints.
+func (bs *BlockStore) Base() int {
+	bs.mtx.RLock()
+	defer bs.mtx.RUnlock()
+	return bs.base
+}
+
 // Height() returns the last known contiguous block height.
 func (bs *BlockStore) Height() int {
 	bs.mtx.RLock()
@@ -80,6 +96,20 @@ func (bs *BlockStore) LoadBlock(height int) *types.Block {
 	return block
 }
 
+// LoadBlockByHash returns the block with the given hash, or nil if it's
+// not in the store.
+func (bs *BlockStore) LoadBlockByHash(hash []byte) *types.Block {
+	bytez := bs.db.Get(calcBlockHashKey(hash))
+	if bytez == nil {
+		return nil
+	}
+	height, err := strconv.Atoi(string(bytez))
+	if err != nil {
+		PanicCrisis(Fmt("Error reading height for block hash %X: %v", hash, err))
+	}
+	return bs.LoadBlock(height)
+}
+
 func (bs *BlockStore) LoadBlockPart(height int, index int) *types.Part {
 	var n int
 	var err error
@@ -157,6 +187,7 @@ func (bs *BlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, s
 	blockMeta := types.NewBlockMeta(block, blockParts)
 	metaBytes := wire.BinaryBytes(blockMeta)
 	bs.db.Set(calcBlockMetaKey(height), metaBytes)
+	bs.db.Set(calcBlockHashKey(blockMeta.BlockID.Hash), []byte(fmt.Sprintf("%d", height)))
 
 	// Save block parts
 	for i := 0; i < blockParts.Total(); i++ {
@@ -173,17 +204,86 @@ func (bs *BlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, s
 	bs.db.Set(calcSeenCommitKey(height), seenCommitBytes)
 
 	// Save new BlockStoreStateJSON descriptor
-	BlockStoreStateJSON{Height: height}.Save(bs.db)
-
-	// Done!
 	bs.mtx.Lock()
+	if bs.base == 0 {
+		bs.base = height
+	}
 	bs.height = height
+	BlockStoreStateJSON{Base: bs.base, Height: height}.Save(bs.db)
 	bs.mtx.Unlock()
 
+	// Done!
+
 	// Flush
 	bs.db.SetSync(nil, nil)
 }
 
+// PruneBlocks removes all blocks below retainHeight, except every keepEvery'th
+// block (if keepEvery > 0), and sets the base to retainHeight.
+// It returns the number of blocks pruned.
+func (bs *BlockStore) PruneBlocks(retainHeight int, keepEvery int) (int, error) {
+	if retainHeight <= 0 {
+		return 0, fmt.Errorf("Height must be greater than 0")
+	}
+	if retainHeight > bs.Height() {
+		return 0, fmt.Errorf("Cannot prune beyond the latest height %v", bs.Height())
+	}
+	base := bs.Base()
+	if retainHeight <= base {
+		return 0, nil
+	}
+
+	pruned := 0
+	batch := bs.db.NewBatch()
+	for height := base; height < retainHeight; height++ {
+		if keepEvery > 0 && height%keepEvery == 0 {
+			continue
+		}
+		blockMeta := bs.LoadBlockMeta(height)
+		if blockMeta == nil {
+			continue
+		}
+		batch.Delete(calcBlockMetaKey(height))
+		batch.Delete(calcBlockHashKey(blockMeta.BlockID.Hash))
+		for i := 0; i < blockMeta.BlockID.PartsHeader.Total; i++ {
+			batch.Delete(calcBlockPartKey(height, i))
+		}
+		batch.Delete(calcBlockCommitKey(height))
+		batch.Delete(calcSeenCommitKey(height))
+		pruned++
+	}
+	batch.Write()
+
+	bs.mtx.Lock()
+	bs.base = retainHeight
+	BlockStoreStateJSON{Base: bs.base, Height: bs.height}.Save(bs.db)
+	bs.mtx.Unlock()
+
+	return pruned, nil
+}
+
+// Bootstrap initializes an empty store at the given height, which was
+// reached without blocks (eg. by state sync). Only the seen commit for the
+// height is stored, so the next block can be saved and consensus can
+// reconstruct its LastCommit. The base is set to height+1.
+func (bs *BlockStore) Bootstrap(height int, seenCommit *types.Commit) error {
+	if height <= 0 {
+		return fmt.Errorf("Height must be greater than 0")
+	}
+	bs.mtx.Lock()
+	defer bs.mtx.Unlock()
+	if bs.height != 0 {
+		return fmt.Errorf("Cannot bootstrap a non-empty store (height %v)", bs.height)
+	}
+
+	bs.db.Set(calcSeenCommitKey(height), wire.BinaryBytes(seenCommit))
+	bs.base = height + 1
+	bs.height = height
+	BlockStoreStateJSON{Base: bs.base, Height: bs.height}.Save(bs.db)
+	bs.db.SetSync(nil, nil)
+	return nil
+}
+
 func (bs *BlockStore) saveBlockPart(height int, index int, part *types.Part) {
 	if height != bs.Height()+1 {
 		PanicSanity(Fmt("BlockStore can only save contiguous blocks. Wanted %v, got %v", bs.Height()+1, height))
@@ -210,12 +310,17 @@ func calcSeenCommitKey(height int) []byte {
 	return []byte(fmt.Sprintf("SC:%v", height))
 }
 
+func calcBlockHashKey(hash []byte) []byte {
+	return []byte(fmt.Sprintf("BH:%X", hash))
+}
+
 //-----------------------------------------------------------------------------
 
 var blockStoreKey = []byte("blockStore")
 
 type BlockStoreStateJSON struct {
-	Height int
+	Base   int // first contiguous height, 0 if the store is empty
+	Height int // last contiguous height
 }
 
 func (bsj BlockStoreStateJSON) Save(db dbm.DB) {
@@ -238,5 +343,9 @@ func LoadBlockStoreStateJSON(db dbm.DB) BlockStoreStateJSON {
 	if err != nil {
 		PanicCrisis(Fmt("Could not unmarshal bytes: %X", bytes))
 	}
+	// stores from before pruning have all the blocks from 1
+	if bsj.Base == 0 && bsj.Height > 0 {
+		bsj.Base = 1
+	}
 	return bsj
 }
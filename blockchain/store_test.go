@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+func makeStoreBlock(height int, lastCommit *types.Commit) (*types.Block, *types.PartSet) {
+	txs := []types.Tx{types.Tx([]byte{byte(height)})}
+	return types.MakeBlock(height, "test-chain", txs, nil, lastCommit, types.BlockID{}, []byte("valhash"), nil, time.Now(), 1024)
+}
+
+func TestBlockStorePruneBlocks(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	db := dbm.NewMemDB()
+	bs := NewBlockStore(db)
+	assert.Equal(0, bs.Base())
+	assert.Equal(0, bs.Height())
+
+	commit := &types.Commit{}
+	for h := 1; h <= 100; h++ {
+		block, parts := makeStoreBlock(h, commit)
+		bs.SaveBlock(block, parts, commit)
+	}
+	assert.Equal(1, bs.Base())
+	assert.Equal(100, bs.Height())
+
+	// blocks can be found by hash
+	hash1 := bs.LoadBlockMeta(1).BlockID.Hash
+	hash60 := bs.LoadBlockMeta(60).BlockID.Hash
+	if block := bs.LoadBlockByHash(hash60); assert.NotNil(block) {
+		assert.Equal(60, block.Height)
+	}
+	assert.Nil(bs.LoadBlockByHash([]byte("nope")))
+
+	// can't prune past the latest block
+	_, err := bs.PruneBlocks(101, 0)
+	assert.NotNil(err)
+
+	// prune everything below 60, keeping every 25th block
+	pruned, err := bs.PruneBlocks(60, 25)
+	require.Nil(err)
+	assert.Equal(57, pruned)
+	assert.Equal(60, bs.Base())
+	assert.Equal(100, bs.Height())
+
+	assert.Nil(bs.LoadBlock(1))
+	assert.Nil(bs.LoadBlockMeta(59))
+	assert.Nil(bs.LoadSeenCommit(59))
+	assert.NotNil(bs.LoadBlock(25))
+	assert.NotNil(bs.LoadBlock(50))
+	assert.NotNil(bs.LoadBlock(60))
+	assert.NotNil(bs.LoadBlock(100))
+	assert.Nil(bs.LoadBlockByHash(hash1))
+	assert.NotNil(bs.LoadBlockByHash(hash60))
+
+	// pruning below the base is a no-op
+	pruned, err = bs.PruneBlocks(50, 0)
+	require.Nil(err)
+	assert.Equal(0, pruned)
+	assert.Equal(60, bs.Base())
+
+	// the base survives a restart
+	bs = NewBlockStore(db)
+	assert.Equal(60, bs.Base())
+	assert.Equal(100, bs.Height())
+}
@@ -0,0 +1,67 @@
+// priv_val_server is a stand-in remote signer. It holds a priv_validator.json
+// and signs requests from a tendermint node started with priv_validator_addr,
+// keeping its own double signing protection. Over TCP, only the nodes with
+// one of the -authorized_keys can connect, and they must pin the signer key
+// it logs on start with priv_validator_signer_key.
+//
+// It is intended for testing: a production signer daemon would keep the key
+// in an HSM or on an isolated host.
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	crypto "github.com/tendermint/go-crypto"
+	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tmlibs/log"
+
+	"github.com/tendermint/tendermint/node"
+	"github.com/tendermint/tendermint/privval"
+	"github.com/tendermint/tendermint/types"
+)
+
+func main() {
+	var (
+		addr        = flag.String("addr", "tcp://127.0.0.1:46659", "Address to listen on for signing requests (tcp:// or unix://)")
+		privValPath = flag.String("priv", "priv_validator.json", "Path to the priv_validator.json file; generated if it does not exist")
+		nodeKeyPath = flag.String("node_key", "signer_key.json", "Path to the key the signer authenticates with over TCP; generated if it does not exist")
+		authorized  = flag.String("authorized_keys", "", "Comma separated, hex encoded node keys of the nodes allowed to connect over TCP (see tendermint show_node_key)")
+	)
+	flag.Parse()
+
+	logger := log.NewTMLogger(log.NewSyncWriter(os.Stdout)).With("module", "priv_val_server")
+
+	privVal := types.LoadOrGenPrivValidator(*privValPath, logger)
+
+	signerID, err := node.LoadOrGenPrivNodeID(*nodeKeyPath, "signer", logger)
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to load the signer key: %v", err))
+	}
+	var authorizedKeys []crypto.PubKeyEd25519
+	for _, hexKey := range strings.Split(*authorized, ",") {
+		if hexKey == "" {
+			continue
+		}
+		pubKey, err := privval.ParsePubKey(strings.TrimSpace(hexKey))
+		if err != nil {
+			cmn.Exit(err.Error())
+		}
+		authorizedKeys = append(authorizedKeys, pubKey)
+	}
+
+	signerKey := signerID.PrivKey.Unwrap().(crypto.PrivKeyEd25519)
+	server := privval.NewSocketServer(*addr, signerKey, authorizedKeys, privVal)
+	server.SetLogger(logger)
+	if _, err := server.Start(); err != nil {
+		cmn.Exit(cmn.Fmt("Failed to start signer: %v", err))
+	}
+	signerPubKey := signerKey.PubKey().Unwrap().(crypto.PubKeyEd25519)
+	logger.Info("Listening for signing requests", "addr", *addr, "pubKey", privVal.PubKey,
+		"signerKey", cmn.Fmt("%X", signerPubKey[:]))
+
+	cmn.TrapSignal(func() {
+		server.Stop()
+	})
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	bc "github.com/tendermint/tendermint/blockchain"
+	tmdb "github.com/tendermint/tendermint/db"
+	sm "github.com/tendermint/tendermint/state"
+	cmn "github.com/tendermint/tmlibs/common"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the genesis of a new chain which starts from the state after a block. The node must be stopped",
+	RunE:  exportGenesis,
+}
+
+// flags
+var (
+	exportHeight  int
+	exportChainID string
+	exportOut     string
+)
+
+func init() {
+	exportCmd.Flags().IntVar(&exportHeight, "height", 0,
+		"Height of the last block of the old chain (defaults to the latest block)")
+	exportCmd.Flags().StringVar(&exportChainID, "chain_id", "",
+		"Chain ID of the new chain (defaults to the old one with its number bumped, eg. test-chain-1 to test-chain-2)")
+	exportCmd.Flags().StringVar(&exportOut, "out", "",
+		"File to write the genesis to (defaults to stdout)")
+
+	RootCmd.AddCommand(exportCmd)
+}
+
+func exportGenesis(cmd *cobra.Command, args []string) error {
+	blockStoreDB := tmdb.NewDB("blockstore", config.DBBackendFor("blockstore"), config.DBDir())
+	defer blockStoreDB.Close()
+	blockStore := bc.NewBlockStore(blockStoreDB)
+
+	stateDB := tmdb.NewDB("state", config.DBBackendFor("state"), config.DBDir())
+	defer stateDB.Close()
+
+	genDoc, err := sm.ExportGenesisDoc(stateDB, blockStore, exportHeight)
+	if err != nil {
+		return err
+	}
+	if exportChainID != "" {
+		genDoc.ChainID = exportChainID
+	} else {
+		genDoc.ChainID = nextChainID(genDoc.ChainID)
+	}
+
+	genDocBytes, err := json.MarshalIndent(genDoc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if exportOut == "" {
+		fmt.Println(string(genDocBytes))
+		return nil
+	}
+	if err := cmn.WriteFile(exportOut, genDocBytes, 0644); err != nil {
+		return err
+	}
+	logger.Info("Exported genesis", "chainID", genDoc.ChainID, "file", exportOut)
+	return nil
+}
+
+var chainIDNumberRegexp = regexp.MustCompile(`^(.*-)([0-9]+)$`)
+
+// nextChainID bumps the number at the end of the chain ID, or appends -1
+// if it has none.
+func nextChainID(chainID string) string {
+	m := chainIDNumberRegexp.FindStringSubmatch(chainID)
+	if m == nil {
+		return chainID + "-1"
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil {
+		return chainID + "-1"
+	}
+	return m[1] + strconv.Itoa(n+1)
+}
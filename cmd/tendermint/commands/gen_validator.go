@@ -12,16 +12,30 @@ import (
 var genValidatorCmd = &cobra.Command{
 	Use:   "gen_validator",
 	Short: "Generate new validator keypair",
-	Run:   genValidator,
+	RunE:  genValidator,
 }
 
+var encryptValidator bool
+
 func init() {
+	genValidatorCmd.Flags().BoolVar(&encryptValidator, "encrypt", false,
+		"Encrypt the private key with the passphrase from $"+types.PrivValidatorPassphraseEnv+" or $"+types.PrivValidatorPassphraseFDEnv)
 	RootCmd.AddCommand(genValidatorCmd)
 }
 
-func genValidator(cmd *cobra.Command, args []string) {
+func genValidator(cmd *cobra.Command, args []string) error {
 	privValidator := types.GenPrivValidator()
+	if encryptValidator {
+		passphrase, err := types.PrivValidatorPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := privValidator.Encrypt(passphrase); err != nil {
+			return err
+		}
+	}
 	privValidatorJSONBytes, _ := json.MarshalIndent(privValidator, "", "\t")
 	fmt.Printf(`%v
 `, string(privValidatorJSONBytes))
+	return nil
 }
@@ -2,9 +2,11 @@ package commands
 
 import (
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/tendermint/tendermint/node"
 	"github.com/tendermint/tendermint/types"
 	cmn "github.com/tendermint/tmlibs/common"
 )
@@ -30,7 +32,8 @@ func initFiles(cmd *cobra.Command, args []string) {
 
 		if _, err := os.Stat(genFile); os.IsNotExist(err) {
 			genDoc := types.GenesisDoc{
-				ChainID: cmn.Fmt("test-chain-%v", cmn.RandStr(6)),
+				GenesisTime: time.Now(),
+				ChainID:     cmn.Fmt("test-chain-%v", cmn.RandStr(6)),
 			}
 			genDoc.Validators = []types.GenesisValidator{types.GenesisValidator{
 				PubKey: privValidator.PubKey,
@@ -44,4 +47,16 @@ func initFiles(cmd *cobra.Command, args []string) {
 	} else {
 		logger.Info("Already initialized", "priv_validator", config.PrivValidatorFile())
 	}
+
+	nodeKeyFile := config.NodeKeyFile()
+	if _, err := os.Stat(nodeKeyFile); os.IsNotExist(err) {
+		privNodeID := node.GenPrivNodeID(config.Moniker)
+		privNodeID.SetFile(nodeKeyFile)
+		if err := privNodeID.Save(); err != nil {
+			cmn.Exit(err.Error())
+		}
+		logger.Info("Generated node key", "node_key", nodeKeyFile)
+	} else {
+		logger.Info("Found node key", "node_key", nodeKeyFile)
+	}
 }
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/tendermint/tendermint/types"
+	cmn "github.com/tendermint/tmlibs/common"
+)
+
+var lockPrivValidatorCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Encrypt this node's validator private key with a passphrase",
+	Long: `Encrypt this node's validator private key with a passphrase.
+The passphrase is read from $` + types.PrivValidatorPassphraseEnv + `, or from the file descriptor
+in $` + types.PrivValidatorPassphraseFDEnv + `, and is needed to start the node afterwards.`,
+	RunE: lockPrivValidator,
+}
+
+var unlockPrivValidatorCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Decrypt this node's validator private key, saving it in plaintext",
+	RunE:  unlockPrivValidator,
+}
+
+func init() {
+	RootCmd.AddCommand(lockPrivValidatorCmd)
+	RootCmd.AddCommand(unlockPrivValidatorCmd)
+}
+
+func lockPrivValidator(cmd *cobra.Command, args []string) error {
+	privValFile := config.PrivValidatorFile()
+	if !cmn.FileExists(privValFile) {
+		return errors.Errorf("No PrivValidator file %v", privValFile)
+	}
+	privValidator := types.LoadPrivValidator(privValFile)
+	if privValidator.IsEncrypted() {
+		return errors.Errorf("PrivValidator key in %v is already encrypted", privValFile)
+	}
+	passphrase, err := types.PrivValidatorPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := privValidator.Encrypt(passphrase); err != nil {
+		return err
+	}
+	privValidator.Save()
+	logger.Info("Encrypted PrivValidator key", "file", privValFile)
+	return nil
+}
+
+func unlockPrivValidator(cmd *cobra.Command, args []string) error {
+	privValFile := config.PrivValidatorFile()
+	if !cmn.FileExists(privValFile) {
+		return errors.Errorf("No PrivValidator file %v", privValFile)
+	}
+	// exits if the passphrase is wrong
+	privValidator := types.LoadPrivValidator(privValFile)
+	if !privValidator.IsEncrypted() {
+		return errors.Errorf("PrivValidator key in %v is not encrypted", privValFile)
+	}
+	privValidator.RemoveEncryption()
+	privValidator.Save()
+	logger.Info("Decrypted PrivValidator key", "file", privValFile)
+	return nil
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tendermint/tendermint/mempool"
+)
+
+var mempoolCmd = &cobra.Command{
+	Use:   "mempool",
+	Short: "Inspect the mempool",
+}
+
+var mempoolDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the txs in the mempool WAL",
+	RunE:  dumpMempoolWAL,
+}
+
+func init() {
+	mempoolCmd.AddCommand(mempoolDumpCmd)
+	RootCmd.AddCommand(mempoolCmd)
+}
+
+func dumpMempoolWAL(cmd *cobra.Command, args []string) error {
+	height, txs, err := mempool.ReadWAL(mempool.WALFile(config.Mempool.WalDir()))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Height: %v\n", height)
+	for _, tx := range txs {
+		fmt.Printf("%X\n", []byte(tx))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	tmdb "github.com/tendermint/tendermint/db"
+)
+
+var migrateDBCmd = &cobra.Command{
+	Use:   "migrate-db",
+	Short: "Copy the blockstore, state, tx_index and evidence databases to another backend",
+	RunE:  migrateDB,
+}
+
+// flags
+var (
+	migrateFrom string
+	migrateTo   string
+)
+
+// the databases which are copied
+var migrateDBNames = []string{"blockstore", "state", "tx_index", "evidence"}
+
+func init() {
+	migrateDBCmd.Flags().StringVar(&migrateFrom, "from", "",
+		"Backend to copy from (defaults to the configured backend of each database)")
+	migrateDBCmd.Flags().StringVar(&migrateTo, "to", "",
+		"Backend to copy to")
+
+	RootCmd.AddCommand(migrateDBCmd)
+}
+
+func migrateDB(cmd *cobra.Command, args []string) error {
+	if migrateTo == "" {
+		return errors.New("--to is required")
+	}
+	if migrateTo == "memdb" {
+		return errors.New("Can't migrate to memdb, it isn't persisted")
+	}
+
+	// check them all before copying anything
+	for _, name := range migrateDBNames {
+		from := migrateFrom
+		if from == "" {
+			from = config.DBBackendFor(name)
+		}
+		if tmdb.SameFiles(from, migrateTo) {
+			return errors.Errorf("%v and %v use the same files, there's nothing to migrate for %v", from, migrateTo, name)
+		}
+	}
+
+	for _, name := range migrateDBNames {
+		from := migrateFrom
+		if from == "" {
+			from = config.DBBackendFor(name)
+		}
+		src := tmdb.NewDB(name, from, config.DBDir())
+		dst := tmdb.NewDB(name, migrateTo, config.DBDir())
+		if !tmdb.IsEmpty(dst) {
+			src.Close()
+			dst.Close()
+			return errors.Errorf("The %v database already has data in %v", name, migrateTo)
+		}
+		n := tmdb.Copy(dst, src, 1000)
+		src.Close()
+		dst.Close()
+		logger.Info("Migrated database", "name", name, "from", from, "to", migrateTo, "keys", n)
+	}
+
+	fmt.Printf("Done. Set db_backend = \"%v\" in config.toml to use the migrated databases.\n", migrateTo)
+	return nil
+}
@@ -30,6 +30,9 @@ func ParseConfig() (*cfg.Config, error) {
 	}
 	conf.SetRoot(conf.RootDir)
 	cfg.EnsureRoot(conf.RootDir)
+	if err := conf.Mempool.ValidateBasic(); err != nil {
+		return nil, err
+	}
 	return conf, err
 }
 
@@ -103,3 +103,15 @@ func TestRootConfig(t *testing.T) {
 	}
 
 }
+
+func TestNextChainID(t *testing.T) {
+	cases := map[string]string{
+		"test-chain-1":  "test-chain-2",
+		"test-chain-9":  "test-chain-10",
+		"test-chain":    "test-chain-1",
+		"test-chain-v2": "test-chain-v2-1",
+	}
+	for chainID, expected := range cases {
+		assert.Equal(t, expected, nextChainID(chainID), chainID)
+	}
+}
@@ -28,6 +28,10 @@ func AddNodeFlags(cmd *cobra.Command) {
 	// bind flags
 	cmd.Flags().String("moniker", config.Moniker, "Node Name")
 
+	// priv val flags
+	cmd.Flags().String("priv_validator_addr", config.PrivValidatorAddr, "Socket address of a remote signer to use instead of the priv_validator_file")
+	cmd.Flags().String("priv_validator_signer_key", config.PrivValidatorSignerKey, "Hex encoded public key of the remote signer, required for TCP addresses")
+
 	// node flags
 	cmd.Flags().Bool("fast_sync", config.FastSync, "Fast blockchain syncing")
 
@@ -48,6 +52,8 @@ func AddNodeFlags(cmd *cobra.Command) {
 
 	// consensus flags
 	cmd.Flags().Bool("consensus.create_empty_blocks", config.Consensus.CreateEmptyBlocks, "Set this to false to only produce blocks when there are txs or when the AppHash changes")
+	cmd.Flags().Int("consensus.halt_height", config.Consensus.HaltHeight, "Stop after committing the block at this height, eg. for an upgrade")
+	cmd.Flags().Int64("consensus.halt_time", config.Consensus.HaltTime, "Stop after committing the first block at or after this time, in seconds since the epoch")
 }
 
 // Users wishing to:
@@ -73,6 +79,9 @@ func runNode(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	config.ChainID = genDoc.ChainID
+	if genDoc.GenesisTime.IsZero() {
+		logger.Error("Genesis doc has no genesis_time, so the time of the first block isn't checked. Set it for new chains")
+	}
 
 	// Create & start node
 	n := node.NewNodeDefault(config, logger.With("module", "node"))
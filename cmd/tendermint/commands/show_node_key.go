@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/tendermint/node"
+	cmn "github.com/tendermint/tmlibs/common"
+)
+
+var showNodeKeyCmd = &cobra.Command{
+	Use:   "show_node_key",
+	Short: "Show the hex encoded public key of this node's node_key.json, eg. to authorize it on a remote signer",
+	Run:   showNodeKey,
+}
+
+func init() {
+	RootCmd.AddCommand(showNodeKeyCmd)
+}
+
+func showNodeKey(cmd *cobra.Command, args []string) {
+	privNodeID, err := node.LoadOrGenPrivNodeID(config.NodeKeyFile(), config.Moniker, logger)
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to load node key: %v", err))
+	}
+	pubKey := privNodeID.PubKey.Unwrap().(crypto.PubKeyEd25519)
+	fmt.Printf("%X\n", pubKey[:])
+}
@@ -7,8 +7,9 @@ import (
 
 	"github.com/spf13/cobra"
 
-	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tendermint/node"
 	"github.com/tendermint/tendermint/types"
+	cmn "github.com/tendermint/tmlibs/common"
 )
 
 var testnetFilesCmd = &cobra.Command{
@@ -79,7 +80,9 @@ func initMachCoreDirectory(base, mach string) error {
 
 	// Create priv_validator.json file if not present
 	ensurePrivValidator(path.Join(dir, "priv_validator.json"))
-	return nil
+
+	// Create node_key.json file if not present
+	return ensureNodeKey(path.Join(dir, "node_key.json"), mach)
 
 }
 
@@ -91,3 +94,12 @@ func ensurePrivValidator(file string) {
 	privValidator.SetFile(file)
 	privValidator.Save()
 }
+
+func ensureNodeKey(file, name string) error {
+	if cmn.FileExists(file) {
+		return nil
+	}
+	privNodeID := node.GenPrivNodeID(name)
+	privNodeID.SetFile(file)
+	return privNodeID.Save()
+}
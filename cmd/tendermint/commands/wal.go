@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	wire "github.com/tendermint/go-wire"
+	"github.com/tendermint/tendermint/consensus"
+)
+
+var walCmd = &cobra.Command{
+	Use:   "wal",
+	Short: "Inspect and repair the consensus WAL",
+}
+
+var walDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the messages in the consensus WAL, one JSON message per line",
+	RunE:  dumpConsensusWAL,
+}
+
+var walRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Remove corrupted data from the consensus WAL. The node must be stopped",
+	RunE:  repairConsensusWAL,
+}
+
+func init() {
+	walCmd.AddCommand(walDumpCmd)
+	walCmd.AddCommand(walRepairCmd)
+	RootCmd.AddCommand(walCmd)
+}
+
+func dumpConsensusWAL(cmd *cobra.Command, args []string) error {
+	rd, err := consensus.OpenWALReader(config.Consensus.WalFile())
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	for {
+		msg, err := rd.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%v. Repair the WAL with `tendermint wal repair`", err)
+		}
+		if m, ok := msg.Msg.(consensus.EndHeightMessage); ok {
+			fmt.Printf("#ENDHEIGHT: %v\n", m.Height)
+			continue
+		}
+		fmt.Println(string(wire.JSONBytes(*msg)))
+	}
+}
+
+func repairConsensusWAL(cmd *cobra.Command, args []string) error {
+	walFile := config.Consensus.WalFile()
+	removed, err := consensus.RepairWAL(walFile)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		logger.Info("Consensus WAL is intact", "wal", walFile)
+		return nil
+	}
+	logger.Info("Repaired consensus WAL. The originals of the repaired files are kept as <file>.corrupted",
+		"wal", walFile, "removedBytes", removed)
+	return nil
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"path/filepath"
 	"time"
-
-	"github.com/tendermint/tendermint/types" // TODO: remove
 )
 
 // Config defines the top level configuration for a Tendermint node
@@ -14,31 +12,37 @@ type Config struct {
 	BaseConfig `mapstructure:",squash"`
 
 	// Options for services
-	RPC       *RPCConfig       `mapstructure:"rpc"`
-	P2P       *P2PConfig       `mapstructure:"p2p"`
-	Mempool   *MempoolConfig   `mapstructure:"mempool"`
-	Consensus *ConsensusConfig `mapstructure:"consensus"`
+	RPC             *RPCConfig             `mapstructure:"rpc"`
+	P2P             *P2PConfig             `mapstructure:"p2p"`
+	Mempool         *MempoolConfig         `mapstructure:"mempool"`
+	Consensus       *ConsensusConfig       `mapstructure:"consensus"`
+	StateSync       *StateSyncConfig       `mapstructure:"statesync"`
+	Instrumentation *InstrumentationConfig `mapstructure:"instrumentation"`
 }
 
 // DefaultConfig returns a default configuration for a Tendermint node
 func DefaultConfig() *Config {
 	return &Config{
-		BaseConfig: DefaultBaseConfig(),
-		RPC:        DefaultRPCConfig(),
-		P2P:        DefaultP2PConfig(),
-		Mempool:    DefaultMempoolConfig(),
-		Consensus:  DefaultConsensusConfig(),
+		BaseConfig:      DefaultBaseConfig(),
+		RPC:             DefaultRPCConfig(),
+		P2P:             DefaultP2PConfig(),
+		Mempool:         DefaultMempoolConfig(),
+		Consensus:       DefaultConsensusConfig(),
+		StateSync:       DefaultStateSyncConfig(),
+		Instrumentation: DefaultInstrumentationConfig(),
 	}
 }
 
 // TestConfig returns a configuration that can be used for testing
 func TestConfig() *Config {
 	return &Config{
-		BaseConfig: TestBaseConfig(),
-		RPC:        TestRPCConfig(),
-		P2P:        TestP2PConfig(),
-		Mempool:    DefaultMempoolConfig(),
-		Consensus:  TestConsensusConfig(),
+		BaseConfig:      TestBaseConfig(),
+		RPC:             TestRPCConfig(),
+		P2P:             TestP2PConfig(),
+		Mempool:         DefaultMempoolConfig(),
+		Consensus:       TestConsensusConfig(),
+		StateSync:       DefaultStateSyncConfig(),
+		Instrumentation: DefaultInstrumentationConfig(),
 	}
 }
 
@@ -49,6 +53,7 @@ func (cfg *Config) SetRoot(root string) *Config {
 	cfg.P2P.RootDir = root
 	cfg.Mempool.RootDir = root
 	cfg.Consensus.RootDir = root
+	cfg.StateSync.RootDir = root
 	return cfg
 }
 
@@ -70,6 +75,17 @@ type BaseConfig struct {
 	// A JSON file containing the private key to use as a validator in the consensus protocol
 	PrivValidator string `mapstructure:"priv_validator_file"`
 
+	// TCP or UNIX socket address of a remote signer. If set, votes and proposals
+	// are signed by the remote signer instead of the priv_validator_file
+	PrivValidatorAddr string `mapstructure:"priv_validator_addr"`
+
+	// Hex encoded ed25519 public key the remote signer authenticates with,
+	// required for TCP addresses
+	PrivValidatorSignerKey string `mapstructure:"priv_validator_signer_key"`
+
+	// A JSON file containing the private key to use for p2p authenticated encryption
+	NodeKey string `mapstructure:"node_key_file"`
+
 	// A custom human readable name for this node
 	Moniker string `mapstructure:"moniker"`
 
@@ -98,11 +114,25 @@ type BaseConfig struct {
 	// What indexer to use for transactions
 	TxIndex string `mapstructure:"tx_index"`
 
-	// Database backend: leveldb | memdb
+	// Database backend: leveldb | goleveldb | cleveldb | boltdb | memdb
 	DBBackend string `mapstructure:"db_backend"`
 
+	// Database backends for the blockstore, state and tx_index databases,
+	// if different from DBBackend
+	BlockStoreDBBackend string `mapstructure:"blockstore_db_backend"`
+	StateDBBackend      string `mapstructure:"state_db_backend"`
+	TxIndexDBBackend    string `mapstructure:"tx_index_db_backend"`
+
 	// Database directory
 	DBPath string `mapstructure:"db_dir"`
+
+	// If greater than 0, only the latest RetainBlocks blocks are kept,
+	// older blocks and their txs are pruned in the background
+	RetainBlocks int `mapstructure:"retain_blocks"`
+
+	// If greater than 0 and pruning is enabled, every KeepEvery'th block
+	// is kept when pruning
+	KeepEvery int `mapstructure:"keep_every"`
 }
 
 // DefaultBaseConfig returns a default base configuration for a Tendermint node
@@ -110,6 +140,7 @@ func DefaultBaseConfig() BaseConfig {
 	return BaseConfig{
 		Genesis:           "genesis.json",
 		PrivValidator:     "priv_validator.json",
+		NodeKey:           "node_key.json",
 		Moniker:           "anonymous",
 		ProxyApp:          "tcp://127.0.0.1:46658",
 		ABCI:              "socket",
@@ -120,6 +151,8 @@ func DefaultBaseConfig() BaseConfig {
 		TxIndex:           "kv",
 		DBBackend:         "leveldb",
 		DBPath:            "data",
+		RetainBlocks:      0,
+		KeepEvery:         0,
 	}
 }
 
@@ -133,6 +166,25 @@ func TestBaseConfig() BaseConfig {
 	return conf
 }
 
+// DBBackendFor returns the backend of the named database
+// (blockstore, state or tx_index). Other databases, like evidence,
+// use DBBackend.
+func (b BaseConfig) DBBackendFor(name string) string {
+	backend := ""
+	switch name {
+	case "blockstore":
+		backend = b.BlockStoreDBBackend
+	case "state":
+		backend = b.StateDBBackend
+	case "tx_index":
+		backend = b.TxIndexDBBackend
+	}
+	if backend == "" {
+		return b.DBBackend
+	}
+	return backend
+}
+
 // GenesisFile returns the full path to the genesis.json file
 func (b BaseConfig) GenesisFile() string {
 	return rootify(b.Genesis, b.RootDir)
@@ -143,6 +195,11 @@ func (b BaseConfig) PrivValidatorFile() string {
 	return rootify(b.PrivValidator, b.RootDir)
 }
 
+// NodeKeyFile returns the full path to the node_key.json file
+func (b BaseConfig) NodeKeyFile() string {
+	return rootify(b.NodeKey, b.RootDir)
+}
+
 // DBDir returns the full path to the database directory
 func (b BaseConfig) DBDir() string {
 	return rootify(b.DBPath, b.RootDir)
@@ -260,6 +317,16 @@ type MempoolConfig struct {
 	RecheckEmpty bool   `mapstructure:"recheck_empty"`
 	Broadcast    bool   `mapstructure:"broadcast"`
 	WalPath      string `mapstructure:"wal_dir"`
+
+	// Maximum number of txs in the mempool
+	Size int `mapstructure:"size"`
+
+	// Maximum total size of the txs in the mempool, in bytes
+	MaxTxsBytes int64 `mapstructure:"max_txs_bytes"`
+
+	// Number of recently seen txs to remember, so they aren't checked again.
+	// 0 disables the cache.
+	CacheSize int `mapstructure:"cache_size"`
 }
 
 // DefaultMempoolConfig returns a default configuration for the Tendermint mempool
@@ -269,6 +336,9 @@ func DefaultMempoolConfig() *MempoolConfig {
 		RecheckEmpty: true,
 		Broadcast:    true,
 		WalPath:      "data/mempool.wal",
+		Size:         5000,
+		MaxTxsBytes:  1024 * 1024 * 1024, // 1GB
+		CacheSize:    100000,
 	}
 }
 
@@ -277,6 +347,20 @@ func (m *MempoolConfig) WalDir() string {
 	return rootify(m.WalPath, m.RootDir)
 }
 
+// ValidateBasic returns an error if the limits of the mempool are invalid.
+func (m *MempoolConfig) ValidateBasic() error {
+	if m.Size <= 0 {
+		return fmt.Errorf("mempool.size must be positive, got %v", m.Size)
+	}
+	if m.MaxTxsBytes <= 0 {
+		return fmt.Errorf("mempool.max_txs_bytes must be positive, got %v", m.MaxTxsBytes)
+	}
+	if m.CacheSize < 0 {
+		return fmt.Errorf("mempool.cache_size can't be negative, got %v", m.CacheSize)
+	}
+	return nil
+}
+
 //-----------------------------------------------------------------------------
 // ConsensusConfig
 
@@ -300,21 +384,19 @@ type ConsensusConfig struct {
 	// Make progress as soon as we have all the precommits (as if TimeoutCommit = 0)
 	SkipTimeoutCommit bool `mapstructure:"skip_timeout_commit"`
 
-	// BlockSize
-	MaxBlockSizeTxs   int `mapstructure:"max_block_size_txs"`
-	MaxBlockSizeBytes int `mapstructure:"max_block_size_bytes"`
-
 	// EmptyBlocks mode and possible interval between empty blocks in seconds
 	CreateEmptyBlocks         bool `mapstructure:"create_empty_blocks"`
 	CreateEmptyBlocksInterval int  `mapstructure:"create_empty_blocks_interval"`
 
-	// TODO: This probably shouldn't be exposed but it makes it
-	// easy to write tests for the wal/replay
-	BlockPartSize int `mapstructure:"block_part_size"`
-
 	// Reactor sleep duration parameters are in ms
 	PeerGossipSleepDuration     int `mapstructure:"peer_gossip_sleep_duration"`
 	PeerQueryMaj23SleepDuration int `mapstructure:"peer_query_maj23_sleep_duration"`
+
+	// If greater than 0, stop after committing the block at HaltHeight,
+	// or the first block with a time at or after HaltTime (in seconds since
+	// the epoch), eg. so every node stops at the same block for an upgrade
+	HaltHeight int   `mapstructure:"halt_height"`
+	HaltTime   int64 `mapstructure:"halt_time"`
 }
 
 // WaitForTxs returns true if the consensus should wait for transactions before entering the propose step
@@ -347,6 +429,18 @@ func (cfg *ConsensusConfig) Commit(t time.Time) time.Time {
 	return t.Add(time.Duration(cfg.TimeoutCommit) * time.Millisecond)
 }
 
+// HaltAfter returns true if consensus should stop after committing the block
+// at the given height with the given time.
+func (cfg *ConsensusConfig) HaltAfter(height int, blockTime time.Time) bool {
+	if cfg.HaltHeight > 0 && height >= cfg.HaltHeight {
+		return true
+	}
+	if cfg.HaltTime > 0 && !blockTime.Before(time.Unix(cfg.HaltTime, 0)) {
+		return true
+	}
+	return false
+}
+
 // PeerGossipSleep returns the amount of time to sleep if there is nothing to send from the ConsensusReactor
 func (cfg *ConsensusConfig) PeerGossipSleep() time.Duration {
 	return time.Duration(cfg.PeerGossipSleepDuration) * time.Millisecond
@@ -370,11 +464,8 @@ func DefaultConsensusConfig() *ConsensusConfig {
 		TimeoutPrecommitDelta:       500,
 		TimeoutCommit:               1000,
 		SkipTimeoutCommit:           false,
-		MaxBlockSizeTxs:             10000,
-		MaxBlockSizeBytes:           1, // TODO
 		CreateEmptyBlocks:           true,
 		CreateEmptyBlocksInterval:   0,
-		BlockPartSize:               types.DefaultBlockPartSize, // TODO: we shouldnt be importing types
 		PeerGossipSleepDuration:     100,
 		PeerQueryMaj23SleepDuration: 2000,
 	}
@@ -407,6 +498,67 @@ func (c *ConsensusConfig) SetWalFile(walFile string) {
 	c.walFile = walFile
 }
 
+//-----------------------------------------------------------------------------
+// StateSyncConfig
+
+// StateSyncConfig defines the configuration for state sync, which lets a new
+// node restore the app from a snapshot offered by peers instead of replaying
+// all blocks. The snapshot is verified with a light client, starting from a
+// trusted header.
+type StateSyncConfig struct {
+	RootDir string `mapstructure:"home"`
+
+	// Set true to state sync when the node has no blocks yet
+	Enable bool `mapstructure:"enable"`
+
+	// RPC address of a node to get light client headers from
+	RPCServer string `mapstructure:"rpc_server"`
+
+	// Height and hash of a header we trust, eg. from a block explorer
+	TrustHeight int    `mapstructure:"trust_height"`
+	TrustHash   string `mapstructure:"trust_hash"`
+
+	// Time to wait for peers to offer snapshots before picking one, in ms
+	DiscoveryTime int `mapstructure:"discovery_time"`
+
+	// Number of chunks to fetch in parallel
+	ChunkFetchers int `mapstructure:"chunk_fetchers"`
+}
+
+// DefaultStateSyncConfig returns a default configuration for state sync
+func DefaultStateSyncConfig() *StateSyncConfig {
+	return &StateSyncConfig{
+		Enable:        false,
+		DiscoveryTime: 15000,
+		ChunkFetchers: 4,
+	}
+}
+
+// Discovery returns the amount of time to wait for snapshots
+func (cfg *StateSyncConfig) Discovery() time.Duration {
+	return time.Duration(cfg.DiscoveryTime) * time.Millisecond
+}
+
+//-----------------------------------------------------------------------------
+// InstrumentationConfig
+
+// InstrumentationConfig defines the configuration for metrics reporting
+type InstrumentationConfig struct {
+	// Set true to expose Prometheus metrics on /metrics
+	Prometheus bool `mapstructure:"prometheus"`
+
+	// TCP address for the metrics server to listen on
+	PrometheusListenAddr string `mapstructure:"prometheus_laddr"`
+}
+
+// DefaultInstrumentationConfig returns a default configuration for metrics reporting
+func DefaultInstrumentationConfig() *InstrumentationConfig {
+	return &InstrumentationConfig{
+		Prometheus:           false,
+		PrometheusListenAddr: ":46660",
+	}
+}
+
 //-----------------------------------------------------------------------------
 // Utils
 
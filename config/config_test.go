@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,3 +27,41 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal("/foo/wal/mem", cfg.Mempool.WalDir())
 
 }
+
+func TestConsensusHaltAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := DefaultConsensusConfig()
+	now := time.Now()
+	assert.False(cfg.HaltAfter(100, now), "nodes don't halt by default")
+
+	cfg.HaltHeight = 10
+	assert.False(cfg.HaltAfter(9, now))
+	assert.True(cfg.HaltAfter(10, now))
+
+	cfg.HaltHeight = 0
+	cfg.HaltTime = now.Unix()
+	assert.False(cfg.HaltAfter(9, now.Add(-time.Second)))
+	assert.True(cfg.HaltAfter(9, time.Unix(now.Unix(), 0)))
+	assert.True(cfg.HaltAfter(9, now.Add(time.Second)))
+}
+
+func TestMempoolConfigValidateBasic(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := DefaultMempoolConfig()
+	assert.Nil(cfg.ValidateBasic())
+
+	cfg.CacheSize = 0
+	assert.Nil(cfg.ValidateBasic(), "0 disables the cache")
+	cfg.CacheSize = -1
+	assert.NotNil(cfg.ValidateBasic())
+
+	cfg = DefaultMempoolConfig()
+	cfg.Size = 0
+	assert.NotNil(cfg.ValidateBasic())
+
+	cfg = DefaultMempoolConfig()
+	cfg.MaxTxsBytes = 0
+	assert.NotNil(cfg.ValidateBasic())
+}
@@ -111,7 +111,7 @@ func testConfig(moniker string) (testConfig string) {
 }
 
 var testGenesis = `{
-  "genesis_time": "0001-01-01T00:00:00.000Z",
+  "genesis_time": "2017-01-01T00:00:00.000Z",
   "chain_id": "tendermint_test",
   "validators": [
     {
@@ -11,12 +11,12 @@ import (
 	"testing"
 	"time"
 
-	abcicli "github.com/tendermint/abci/client"
 	abci "github.com/tendermint/abci/types"
 	bc "github.com/tendermint/tendermint/blockchain"
 	cfg "github.com/tendermint/tendermint/config"
 	mempl "github.com/tendermint/tendermint/mempool"
 	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/proxy"
 	sm "github.com/tendermint/tendermint/state"
 	"github.com/tendermint/tendermint/types"
 	. "github.com/tendermint/tmlibs/common"
@@ -51,6 +51,8 @@ type validatorStub struct {
 	Height int
 	Round  int
 	*types.PrivValidator
+
+	lastVoteTime time.Time
 }
 
 var testMinPower = 10
@@ -70,11 +72,23 @@ func (vs *validatorStub) signVote(voteType byte, hash []byte, header types.PartS
 		Round:            vs.Round,
 		Type:             voteType,
 		BlockID:          types.BlockID{hash, header},
+		Timestamp:        vs.voteTime(),
 	}
 	err := vs.PrivValidator.SignVote(config.ChainID, vote)
 	return vote, err
 }
 
+// voteTime keeps the vote timestamps of the stub increasing,
+// so the block times derived from them do too.
+func (vs *validatorStub) voteTime() time.Time {
+	now := time.Now().Truncate(time.Millisecond)
+	if minTime := vs.lastVoteTime.Add(time.Millisecond); now.Before(minTime) {
+		now = minTime
+	}
+	vs.lastVoteTime = now
+	return now
+}
+
 // Sign vote for type/hash/header
 func signVote(vs *validatorStub, voteType byte, hash []byte, header types.PartSetHeader) *types.Vote {
 	v, err := vs.signVote(voteType, hash, header)
@@ -235,9 +249,19 @@ func newConsensusStateWithConfig(thisConfig *cfg.Config, state *sm.State, pv *ty
 	blockStore := bc.NewBlockStore(blockDB)
 
 	// one for mempool, one for consensus
-	mtx := new(sync.Mutex)
-	proxyAppConnMem := abcicli.NewLocalClient(mtx, app)
-	proxyAppConnCon := abcicli.NewLocalClient(mtx, app)
+	clientCreator := proxy.NewLocalClientCreator(app)
+	proxyAppConnMem, _ := clientCreator.NewABCIClient()
+	concli, _ := clientCreator.NewABCIClient()
+	proxyAppConnCon := proxy.NewAppConnConsensus(concli)
+	if initializer, ok := proxy.NewGenesisInitializer(clientCreator); ok {
+		proxyAppConnCon.SetGenesisInitializer(initializer)
+	}
+	if preparer, ok := proxy.NewProposalPreparer(clientCreator); ok {
+		proxyAppConnCon.SetProposalPreparer(preparer)
+	}
+	if processor, ok := proxy.NewProposalProcessor(clientCreator); ok {
+		proxyAppConnCon.SetProposalProcessor(processor)
+	}
 
 	// Make Mempool
 	mempool := mempl.NewMempool(thisConfig.Mempool, proxyAppConnMem, 0)
@@ -246,8 +270,11 @@ func newConsensusStateWithConfig(thisConfig *cfg.Config, state *sm.State, pv *ty
 		mempool.EnableTxsAvailable()
 	}
 
+	// mock the evidence pool
+	evpool := types.MockEvidencePool{}
+
 	// Make ConsensusReactor
-	cs := NewConsensusState(thisConfig.Consensus, state, proxyAppConnCon, blockStore, mempool)
+	cs := NewConsensusState(thisConfig.Consensus, state, proxyAppConnCon, blockStore, mempool, evpool)
 	cs.SetLogger(log.TestingLogger())
 	cs.SetPrivValidator(pv)
 
@@ -0,0 +1,86 @@
+package consensus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics contains the metrics exposed by the consensus package.
+type Metrics struct {
+	// Height of the chain.
+	Height prometheus.Gauge
+	// Round and step of the consensus state machine.
+	Round prometheus.Gauge
+	Step  prometheus.Gauge
+
+	// Number of validators, and their total voting power.
+	Validators      prometheus.Gauge
+	ValidatorsPower prometheus.Gauge
+	// Number of validators missing from the last commit, and their voting power.
+	MissingValidators      prometheus.Gauge
+	MissingValidatorsPower prometheus.Gauge
+
+	// Time between this and the last block, in seconds.
+	BlockIntervalSeconds prometheus.Histogram
+	// Number of txs in the last block, and in total.
+	NumTxs   prometheus.Gauge
+	TotalTxs prometheus.Counter
+}
+
+// PrometheusMetrics registers the consensus metrics with registry.
+func PrometheusMetrics(registry prometheus.Registerer) *Metrics {
+	m := NopMetrics()
+	registry.MustRegister(
+		m.Height, m.Round, m.Step,
+		m.Validators, m.ValidatorsPower,
+		m.MissingValidators, m.MissingValidatorsPower,
+		m.BlockIntervalSeconds, m.NumTxs, m.TotalTxs,
+	)
+	return m
+}
+
+// NopMetrics returns unregistered consensus metrics.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		Height: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "height", Help: "Height of the chain.",
+		}),
+		Round: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "round", Help: "Round of the consensus state machine.",
+		}),
+		Step: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "step", Help: "Step of the consensus state machine (see RoundStepType).",
+		}),
+		Validators: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "validators", Help: "Number of validators.",
+		}),
+		ValidatorsPower: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "validators_power", Help: "Total voting power of the validators.",
+		}),
+		MissingValidators: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "missing_validators", Help: "Number of validators who didn't sign the last commit.",
+		}),
+		MissingValidatorsPower: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "missing_validators_power", Help: "Voting power of the validators who didn't sign the last commit.",
+		}),
+		BlockIntervalSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "block_interval_seconds", Help: "Time between this and the last block.",
+			Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+		}),
+		NumTxs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "num_txs", Help: "Number of txs in the last block.",
+		}),
+		TotalTxs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "tendermint", Subsystem: "consensus",
+			Name: "total_txs", Help: "Total number of txs committed.",
+		}),
+	}
+}
@@ -6,13 +6,9 @@ import (
 	"fmt"
 	"io"
 	"reflect"
-	"strconv"
-	"strings"
 	"time"
 
 	abci "github.com/tendermint/abci/types"
-	wire "github.com/tendermint/go-wire"
-	auto "github.com/tendermint/tmlibs/autofile"
 	cmn "github.com/tendermint/tmlibs/common"
 	"github.com/tendermint/tmlibs/log"
 
@@ -30,25 +26,20 @@ import (
 // recover from failure during consensus
 // by replaying messages from the WAL
 
-// Unmarshal and apply a single message to the consensus state
+// Apply a single message to the consensus state
 // as if it were received in receiveRoutine
-// Lines that start with "#" are ignored.
+// EndHeightMessages are ignored.
 // NOTE: receiveRoutine should not be running
-func (cs *ConsensusState) readReplayMessage(msgBytes []byte, newStepCh chan interface{}) error {
-	// Skip over empty and meta lines
-	if len(msgBytes) == 0 || msgBytes[0] == '#' {
-		return nil
-	}
-	var err error
-	var msg TimedWALMessage
-	wire.ReadJSON(&msg, msgBytes, &err)
-	if err != nil {
-		fmt.Println("MsgBytes:", msgBytes, string(msgBytes))
-		return fmt.Errorf("Error reading json data: %v", err)
-	}
-
+func (cs *ConsensusState) readReplayMessage(msg *TimedWALMessage, newStepCh chan interface{}) error {
 	// for logging
 	switch m := msg.Msg.(type) {
+	case EndHeightMessage:
+		return nil
+	case ProcessedProposalMessage:
+		// read ahead by catchupReplay
+		cs.Logger.Info("Replay: Processed proposal", "height", m.Height, "round", m.Round,
+			"hash", m.BlockHash, "accepted", m.Accepted)
+		return nil
 	case types.EventDataRoundState:
 		cs.Logger.Info("Replay: New Step", "height", m.Height, "round", m.Round, "step", m.Step)
 		// these are playback checks
@@ -103,64 +94,47 @@ func (cs *ConsensusState) catchupReplay(csHeight int) error {
 	// Ensure that ENDHEIGHT for this height doesn't exist
 	// NOTE: This is just a sanity check. As far as we know things work fine without it,
 	// and Handshake could reuse ConsensusState if it weren't for this check (since we can crash after writing ENDHEIGHT).
-	gr, found, err := cs.wal.group.Search("#ENDHEIGHT: ", makeHeightSearchFunc(csHeight))
-	if gr != nil {
-		gr.Close()
+	rd, found, err := cs.wal.SearchForEndHeight(csHeight)
+	if err != nil {
+		return err
 	}
 	if found {
+		rd.Close()
 		return errors.New(cmn.Fmt("WAL should not contain #ENDHEIGHT %d.", csHeight))
 	}
 
 	// Search for last height marker
-	gr, found, err = cs.wal.group.Search("#ENDHEIGHT: ", makeHeightSearchFunc(csHeight-1))
-	if err == io.EOF {
-		cs.Logger.Error("Replay: wal.group.Search returned EOF", "#ENDHEIGHT", csHeight-1)
-		// if we upgraded from 0.9 to 0.9.1, we may have #HEIGHT instead
-		// TODO (0.10.0): remove this
-		gr, found, err = cs.wal.group.Search("#HEIGHT: ", makeHeightSearchFunc(csHeight))
-		if err == io.EOF {
-			cs.Logger.Error("Replay: wal.group.Search returned EOF", "#HEIGHT", csHeight)
-			return nil
-		} else if err != nil {
-			return err
-		}
-	} else if err != nil {
+	rd, found, err = cs.wal.SearchForEndHeight(csHeight - 1)
+	if err != nil {
 		return err
-	} else {
-		defer gr.Close()
 	}
 	if !found {
-		// if we upgraded from 0.9 to 0.9.1, we may have #HEIGHT instead
-		// TODO (0.10.0): remove this
-		gr, _, err = cs.wal.group.Search("#HEIGHT: ", makeHeightSearchFunc(csHeight))
-		if err == io.EOF {
-			cs.Logger.Error("Replay: wal.group.Search returned EOF", "#HEIGHT", csHeight)
-			return nil
-		} else if err != nil {
-			return err
-		} else {
-			defer gr.Close()
-		}
+		return errors.New(cmn.Fmt("Cannot replay height %d. WAL does not contain #ENDHEIGHT for %d.", csHeight, csHeight-1))
+	}
+	defer rd.Close()
 
-		// TODO (0.10.0): uncomment
-		// return errors.New(cmn.Fmt("Cannot replay height %d. WAL does not contain #ENDHEIGHT for %d.", csHeight, csHeight-1))
+	// the app's decisions on proposals are written after the messages that
+	// completed them, so we need them before we replay those
+	processed, err := cs.readProcessedProposals(csHeight)
+	if err != nil {
+		return err
 	}
+	cs.processedProposals = processed
+	defer func() { cs.processedProposals = nil }()
 
 	cs.Logger.Info("Catchup by replaying consensus messages", "height", csHeight)
 
 	for {
-		line, err := gr.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return err
-			}
+		msg, err := rd.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
 		}
 		// NOTE: since the priv key is set when the msgs are received
 		// it will attempt to eg double sign but we can just ignore it
 		// since the votes will be replayed and we'll get to the next step
-		if err := cs.readReplayMessage([]byte(line), nil); err != nil {
+		if err := cs.readReplayMessage(msg, nil); err != nil {
 			return err
 		}
 	}
@@ -168,27 +142,28 @@ func (cs *ConsensusState) catchupReplay(csHeight int) error {
 	return nil
 }
 
-//--------------------------------------------------------------------------------
+// readProcessedProposals returns the app's decisions on the proposal blocks
+// of the height, by block hash, from the WAL.
+func (cs *ConsensusState) readProcessedProposals(csHeight int) (map[string]bool, error) {
+	rd, found, err := cs.wal.SearchForEndHeight(csHeight - 1)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New(cmn.Fmt("WAL does not contain #ENDHEIGHT for %d.", csHeight-1))
+	}
+	defer rd.Close()
 
-// Parses marker lines of the form:
-// #ENDHEIGHT: 12345
-func makeHeightSearchFunc(height int) auto.SearchFunc {
-	return func(line string) (int, error) {
-		line = strings.TrimRight(line, "\n")
-		parts := strings.Split(line, " ")
-		if len(parts) != 2 {
-			return -1, errors.New("Line did not have 2 parts")
-		}
-		i, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return -1, errors.New("Failed to parse INFO: " + err.Error())
+	processed := make(map[string]bool)
+	for {
+		msg, err := rd.Decode()
+		if err == io.EOF {
+			return processed, nil
+		} else if err != nil {
+			return nil, err
 		}
-		if height < i {
-			return 1, nil
-		} else if height == i {
-			return 0, nil
-		} else {
-			return -1, nil
+		if m, ok := msg.Msg.(ProcessedProposalMessage); ok {
+			processed[string(m.BlockHash)] = m.Accepted
 		}
 	}
 }
@@ -256,8 +231,19 @@ func (h *Handshaker) ReplayBlocks(appHash []byte, appBlockHeight int, proxyApp p
 
 	// If appBlockHeight == 0 it means that we are at genesis and hence should send InitChain
 	if appBlockHeight == 0 {
+		genDoc := h.state.GenesisDoc
 		validators := types.TM2PB.Validators(h.state.Validators)
-		proxyApp.Consensus().InitChainSync(validators)
+		res, err := proxyApp.Consensus().InitChainSync(genDoc.ChainID, genDoc.GenesisTime, validators, genDoc.AppState)
+		if err != nil {
+			return nil, err
+		}
+
+		// The app hash after loading the app_state is the one of the genesis
+		if stateBlockHeight == 0 && len(res) > 0 {
+			h.state.AppHash = res
+			h.state.Save()
+			appHash = res
+		}
 	}
 
 	// First handle edge cases and constraints on the storeBlockHeight
@@ -356,12 +342,13 @@ func (h *Handshaker) replayBlocks(proxyApp proxy.AppConns, appBlockHeight, store
 // ApplyBlock on the proxyApp with the last block.
 func (h *Handshaker) replayBlock(height int, proxyApp proxy.AppConnConsensus) ([]byte, error) {
 	mempool := types.MockMempool{}
+	evpool := types.MockEvidencePool{}
 
 	var eventCache types.Fireable // nil
 	block := h.store.LoadBlock(height)
 	meta := h.store.LoadBlockMeta(height)
 
-	if err := h.state.ApplyBlock(eventCache, proxyApp, block, meta.BlockID.PartsHeader, mempool); err != nil {
+	if err := h.state.ApplyBlock(eventCache, proxyApp, block, meta.BlockID.PartsHeader, mempool, evpool); err != nil {
 		return nil, err
 	}
 
@@ -389,7 +376,14 @@ func newMockProxyApp(appHash []byte, abciResponses *sm.ABCIResponses) proxy.AppC
 	})
 	cli, _ := clientCreator.NewABCIClient()
 	cli.Start()
-	return proxy.NewAppConnConsensus(cli)
+	appConn := proxy.NewAppConnConsensus(cli)
+	if updater, ok := proxy.NewParamsUpdater(clientCreator); ok {
+		appConn.SetParamsUpdater(updater)
+	}
+	if tagger, ok := proxy.NewTxTagger(clientCreator); ok {
+		appConn.SetTxTagger(tagger)
+	}
+	return appConn
 }
 
 type mockProxyApp struct {
@@ -410,11 +404,19 @@ func (mock *mockProxyApp) DeliverTx(tx []byte) abci.Result {
 	}
 }
 
+func (mock *mockProxyApp) TxTags(tx []byte, res *abci.ResponseDeliverTx) []*types.TxTag {
+	return mock.abciResponses.DeliverTxTags[mock.txCount-1]
+}
+
 func (mock *mockProxyApp) EndBlock(height uint64) abci.ResponseEndBlock {
 	mock.txCount = 0
 	return mock.abciResponses.EndBlock
 }
 
+func (mock *mockProxyApp) UpdateConsensusParams(height int) *types.ConsensusParams {
+	return mock.abciResponses.ConsensusParamUpdates
+}
+
 func (mock *mockProxyApp) Commit() abci.Result {
 	return abci.NewResultOK(mock.appHash, "")
 }
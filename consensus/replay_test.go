@@ -2,17 +2,18 @@ package consensus
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/tendermint/abci/example/dummy"
+	abci "github.com/tendermint/abci/types"
 	crypto "github.com/tendermint/go-crypto"
 	wire "github.com/tendermint/go-wire"
 	cmn "github.com/tendermint/tmlibs/common"
@@ -53,13 +54,13 @@ var data_dir = path.Join(cmn.GoPath, "src/github.com/tendermint/tendermint/conse
 // wal writer when we need to, instead of with every message.
 
 // the priv validator changes step at these lines for a block with 1 val and 1 part
-var baseStepChanges = []int{3, 6, 8}
+var baseStepChanges = []int{3, 7, 9}
 
 // test recovery from each line in each testCase
 var testCases = []*testCase{
-	newTestCase("empty_block", baseStepChanges),   // empty block (has 1 block part)
-	newTestCase("small_block1", baseStepChanges),  // small block with txs in 1 block part
-	newTestCase("small_block2", []int{3, 11, 13}), // small block with txs across 6 smaller block parts
+	newTestCase("empty_block", baseStepChanges),  // empty block (has 1 block part)
+	newTestCase("small_block1", baseStepChanges), // small block with txs in 1 block part
+	newTestCase("small_block2", []int{3, 8, 10}), // small block with txs across 2 smaller block parts
 }
 
 type testCase struct {
@@ -107,6 +108,8 @@ func readWAL(p string) string {
 	return string(b)
 }
 
+// writeWAL writes the msgs, in the text format of the test data (a JSON
+// TimedWALMessage per line and #ENDHEIGHT lines), to a new WAL file.
 func writeWAL(walMsgs string) string {
 	tempDir := os.TempDir()
 	walDir := path.Join(tempDir, "/wal"+cmn.RandStr(12))
@@ -116,14 +119,51 @@ func writeWAL(walMsgs string) string {
 	if err != nil {
 		panic(err)
 	}
+	// Encode the msgs
+	buf := new(bytes.Buffer)
+	enc := NewWALEncoder(buf)
+	for _, line := range strings.Split(walMsgs, "\n") {
+		msg, err := readWALLine(line)
+		if err != nil {
+			panic(err)
+		}
+		if msg == nil {
+			continue
+		}
+		if err := enc.Encode(msg); err != nil {
+			panic(err)
+		}
+	}
 	// Write the needed WAL to file
-	err = cmn.WriteFile(walFile, []byte(walMsgs), 0600)
+	err = cmn.WriteFile(walFile, buf.Bytes(), 0600)
 	if err != nil {
 		panic(err)
 	}
 	return walFile
 }
 
+// readWALLine reads a line of the text format, or returns nil for an
+// empty line.
+func readWALLine(line string) (*TimedWALMessage, error) {
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if strings.HasPrefix(line, "#ENDHEIGHT: ") {
+		var height int
+		if _, err := fmt.Sscanf(line, "#ENDHEIGHT: %d", &height); err != nil {
+			return nil, err
+		}
+		return &TimedWALMessage{time.Now(), EndHeightMessage{height}}, nil
+	}
+	var err error
+	var msg TimedWALMessage
+	wire.ReadJSON(&msg, []byte(line), &err)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading json data: %v", err)
+	}
+	return &msg, nil
+}
+
 func waitForBlock(newBlockCh chan interface{}, thisCase *testCase, i int) {
 	after := time.After(time.Second * 10)
 	select {
@@ -195,13 +235,11 @@ func setupReplayTest(t *testing.T, thisCase *testCase, nLines int, crashAfter bo
 }
 
 func readTimedWALMessage(t *testing.T, walMsg string) TimedWALMessage {
-	var err error
-	var msg TimedWALMessage
-	wire.ReadJSON(&msg, []byte(walMsg), &err)
+	msg, err := readWALLine(walMsg)
 	if err != nil {
-		t.Fatalf("Error reading json data: %v", err)
+		t.Fatal(err)
 	}
-	return msg
+	return *msg
 }
 
 //-----------------------------------------------
@@ -261,12 +299,61 @@ func testReplayCrashBeforeWriteVote(t *testing.T, thisCase *testCase, lineNum in
 	runReplayTest(t, cs, walFile, newBlockCh, thisCase, lineNum)
 }
 
+//-----------------------------------------------
+// Test that the app's decisions on proposals are read from the log
+// before the height is replayed
+
+func TestWALReadProcessedProposals(t *testing.T) {
+	msgs := []WALMessage{
+		EndHeightMessage{1},
+		ProcessedProposalMessage{2, 0, []byte("rejected"), false},
+		ProcessedProposalMessage{2, 1, []byte("accepted"), true},
+	}
+	buf := new(bytes.Buffer)
+	enc := NewWALEncoder(buf)
+	for _, msg := range msgs {
+		if err := enc.Encode(&TimedWALMessage{time.Now(), msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	walDir := path.Join(os.TempDir(), "/wal"+cmn.RandStr(12))
+	walFile := path.Join(walDir, "wal")
+	if err := cmn.EnsureDir(walDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(walDir)
+	if err := cmn.WriteFile(walFile, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	wal, err := NewWAL(walFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.SetLogger(log.TestingLogger())
+	if _, err := wal.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Stop()
+
+	cs := &ConsensusState{wal: wal}
+	processed, err := cs.readProcessedProposals(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"rejected": false, "accepted": true}
+	if !reflect.DeepEqual(processed, expected) {
+		t.Errorf("Expected processed proposals %v, got %v", expected, processed)
+	}
+}
+
 //------------------------------------------------------------------------------------------
 // Handshake Tests
 
 var (
-	NUM_BLOCKS = 6 // number of blocks in the test_data/many_blocks.cswal
+	NUM_BLOCKS = 6 // number of blocks in the test chain
 	mempool    = types.MockMempool{}
+	evpool     = types.MockEvidencePool{}
 
 	testPartSize int
 )
@@ -307,33 +394,75 @@ func TestHandshakeReplayNone(t *testing.T) {
 	}
 }
 
+// genesisApp records the genesis it is initialized with
+type genesisApp struct {
+	*dummy.DummyApplication
+	chainID  string
+	appState []byte
+}
+
+func (app *genesisApp) InitGenesis(chainID string, genesisTime time.Time, validators []*abci.Validator, appState []byte) []byte {
+	app.chainID = chainID
+	app.appState = appState
+	return []byte("genesis_app_hash")
+}
+
+// The app gets the app_state at genesis, and its app hash becomes the one of the state
+func TestHandshakeInitGenesis(t *testing.T) {
+	config := ResetConfig("handshake_genesis_test")
+
+	stateDB := dbm.NewMemDB()
+	state := sm.MakeGenesisStateFromFile(stateDB, config.GenesisFile())
+	state.SetLogger(log.TestingLogger().With("module", "state"))
+	state.GenesisDoc.AppState = []byte(`{"balances":{"alice":100}}`)
+	store := NewMockBlockStore(config)
+
+	app := &genesisApp{DummyApplication: dummy.NewDummyApplication()}
+	proxyApp := proxy.NewAppConns(proxy.NewLocalClientCreator(app), NewHandshaker(state, store), proxy.NopMetrics())
+	if _, err := proxyApp.Start(); err != nil {
+		t.Fatalf("Error starting proxy app connections: %v", err)
+	}
+	defer proxyApp.Stop()
+
+	if app.chainID != state.ChainID {
+		t.Errorf("Expected the app to get chain ID %v, got %v", state.ChainID, app.chainID)
+	}
+	if !bytes.Equal(app.appState, state.GenesisDoc.AppState) {
+		t.Errorf("Expected the app to get app state %s, got %s", state.GenesisDoc.AppState, app.appState)
+	}
+	if appHash := sm.LoadState(stateDB).AppHash; !bytes.Equal(appHash, []byte("genesis_app_hash")) {
+		t.Errorf("Expected the saved state to have the app hash of the app, got %X", appHash)
+	}
+}
+
+// An app which can't load the app_state of the genesis doesn't start without it
+func TestHandshakeAppStateNotLoaded(t *testing.T) {
+	config := ResetConfig("handshake_genesis_test")
+
+	stateDB := dbm.NewMemDB()
+	state := sm.MakeGenesisStateFromFile(stateDB, config.GenesisFile())
+	state.SetLogger(log.TestingLogger().With("module", "state"))
+	state.GenesisDoc.AppState = []byte(`{"balances":{"alice":100}}`)
+	store := NewMockBlockStore(config)
+
+	proxyApp := proxy.NewAppConns(proxy.NewLocalClientCreator(dummy.NewDummyApplication()), NewHandshaker(state, store), proxy.NopMetrics())
+	if _, err := proxyApp.Start(); err == nil {
+		proxyApp.Stop()
+		t.Fatal("Expected an error starting the proxy app connections with an app_state the app can't load")
+	}
+}
+
 // Make some blocks. Start a fresh app and apply nBlocks blocks. Then restart the app and sync it up with the remaining blocks
 func testHandshakeReplay(t *testing.T, nBlocks int, mode uint) {
 	config := ResetConfig("proxy_test_")
 
-	// copy the many_blocks file
-	walBody, err := cmn.ReadFile(path.Join(data_dir, "many_blocks.cswal"))
-	if err != nil {
-		t.Fatal(err)
-	}
-	walFile := writeWAL(string(walBody))
-	config.Consensus.SetWalFile(walFile)
-
 	privVal := types.LoadPrivValidator(config.PrivValidatorFile())
-	testPartSize = config.Consensus.BlockPartSize
+	testPartSize = types.DefaultBlockPartSize
 
-	wal, err := NewWAL(walFile, false)
+	chain, commits, err := makeBlockchain(config, NUM_BLOCKS)
 	if err != nil {
 		t.Fatal(err)
 	}
-	wal.SetLogger(log.TestingLogger())
-	if _, err := wal.Start(); err != nil {
-		t.Fatal(err)
-	}
-	chain, commits, err := makeBlockchainFromWAL(wal)
-	if err != nil {
-		t.Fatalf(err.Error())
-	}
 
 	state, store := stateAndStore(config, privVal.PubKey)
 	store.chain = chain
@@ -348,14 +477,14 @@ func testHandshakeReplay(t *testing.T, nBlocks int, mode uint) {
 	if nBlocks > 0 {
 		// run nBlocks against a new client to build up the app state.
 		// use a throwaway tendermint state
-		proxyApp := proxy.NewAppConns(clientCreator2, nil)
+		proxyApp := proxy.NewAppConns(clientCreator2, nil, proxy.NopMetrics())
 		state, _ := stateAndStore(config, privVal.PubKey)
 		buildAppStateFromChain(proxyApp, state, chain, nBlocks, mode)
 	}
 
 	// now start the app using the handshake - it should sync
 	handshaker := NewHandshaker(state, store)
-	proxyApp := proxy.NewAppConns(clientCreator2, handshaker)
+	proxyApp := proxy.NewAppConns(clientCreator2, handshaker, proxy.NopMetrics())
 	if _, err := proxyApp.Start(); err != nil {
 		t.Fatalf("Error starting proxy app connections: %v", err)
 	}
@@ -384,7 +513,7 @@ func testHandshakeReplay(t *testing.T, nBlocks int, mode uint) {
 }
 
 func applyBlock(st *sm.State, blk *types.Block, proxyApp proxy.AppConns) {
-	err := st.ApplyBlock(nil, proxyApp.Consensus(), blk, blk.MakePartSet(testPartSize).Header(), mempool)
+	err := st.ApplyBlock(nil, proxyApp.Consensus(), blk, blk.MakePartSet(testPartSize).Header(), mempool, evpool)
 	if err != nil {
 		panic(err)
 	}
@@ -398,7 +527,7 @@ func buildAppStateFromChain(proxyApp proxy.AppConns,
 	}
 
 	validators := types.TM2PB.Validators(state.Validators)
-	proxyApp.Consensus().InitChainSync(validators)
+	proxyApp.Consensus().InitChainSync(state.ChainID, state.LastBlockTime, validators, nil)
 
 	defer proxyApp.Stop()
 	switch mode {
@@ -425,14 +554,14 @@ func buildAppStateFromChain(proxyApp proxy.AppConns,
 func buildTMStateFromChain(config *cfg.Config, state *sm.State, chain []*types.Block, mode uint) []byte {
 	// run the whole chain against this client to build up the tendermint state
 	clientCreator := proxy.NewLocalClientCreator(dummy.NewPersistentDummyApplication(path.Join(config.DBDir(), "1")))
-	proxyApp := proxy.NewAppConns(clientCreator, nil) // sm.NewHandshaker(config, state, store, ReplayLastBlock))
+	proxyApp := proxy.NewAppConns(clientCreator, nil, proxy.NopMetrics()) // sm.NewHandshaker(config, state, store, ReplayLastBlock))
 	if _, err := proxyApp.Start(); err != nil {
 		panic(err)
 	}
 	defer proxyApp.Stop()
 
 	validators := types.TM2PB.Validators(state.Validators)
-	proxyApp.Consensus().InitChainSync(validators)
+	proxyApp.Consensus().InitChainSync(state.ChainID, state.LastBlockTime, validators, nil)
 
 	var latestAppHash []byte
 
@@ -464,54 +593,88 @@ func buildTMStateFromChain(config *cfg.Config, state *sm.State, chain []*types.B
 //--------------------------
 // utils for making blocks
 
-func makeBlockchainFromWAL(wal *WAL) ([]*types.Block, []*types.Commit, error) {
-	// Search for height marker
-	gr, found, err := wal.group.Search("#ENDHEIGHT: ", makeHeightSearchFunc(0))
+// makeBlockchain commits nBlocks blocks with a tx each, with the validator of
+// the config, recording the WAL. It returns the blocks and commits read from
+// the recorded WAL.
+func makeBlockchain(config *cfg.Config, nBlocks int) ([]*types.Block, []*types.Commit, error) {
+	stateDB := dbm.NewMemDB()
+	state := sm.MakeGenesisStateFromFile(stateDB, config.GenesisFile())
+	state.SetLogger(log.TestingLogger().With("module", "state"))
+	privVal := types.LoadPrivValidator(config.PrivValidatorFile())
+	cs := newConsensusStateWithConfig(config, state, privVal, newPersistentDummy())
+	walFile := config.Consensus.WalFile()
+	if err := cs.OpenWAL(walFile); err != nil {
+		return nil, nil, err
+	}
+
+	// like the handshake does
+	validators := types.TM2PB.Validators(state.Validators)
+	cs.proxyAppConn.InitChainSync(state.ChainID, state.LastBlockTime, validators, nil)
+
+	sim := NewSimulation([]*ConsensusState{cs}, 1)
+	for height := 1; height <= nBlocks; height++ {
+		tx := []byte(cmn.Fmt("height%d=%d", height, height))
+		if err := cs.mempool.CheckTx(tx, nil); err != nil {
+			return nil, nil, err
+		}
+		if err := sim.Run(height, time.Minute); err != nil {
+			return nil, nil, err
+		}
+	}
+	cs.wal.Stop()
+
+	blocks, commits, err := makeBlockchainFromWAL(walFile)
 	if err != nil {
 		return nil, nil, err
 	}
-	if !found {
-		return nil, nil, errors.New(cmn.Fmt("WAL does not contain height %d.", 1))
+	if len(blocks) < nBlocks || len(commits) < nBlocks {
+		return nil, nil, fmt.Errorf("Expected %d blocks and commits in the WAL, got %d and %d", nBlocks, len(blocks), len(commits))
 	}
-	defer gr.Close()
+	return blocks[:nBlocks], commits[:nBlocks], nil
+}
 
-	// log.Notice("Build a blockchain by reading from the WAL")
+// makeBlockchainFromWAL reads the blocks we proposed, and the commits of our
+// precommits, from the WAL of a single validator.
+func makeBlockchainFromWAL(walFile string) ([]*types.Block, []*types.Commit, error) {
+	rd, found, err := SearchForEndHeight(walFile, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("WAL does not contain height %d", 1)
+	}
+	defer rd.Close()
 
 	var blockParts *types.PartSet
 	var blocks []*types.Block
 	var commits []*types.Commit
 	for {
-		line, err := gr.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return nil, nil, err
-			}
-		}
-
-		piece, err := readPieceFromWAL([]byte(line))
-		if err != nil {
+		msg, err := rd.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
 			return nil, nil, err
 		}
-		if piece == nil {
-			continue
-		}
 
-		switch p := piece.(type) {
+		switch p := readPieceFromWAL(msg).(type) {
 		case *types.PartSetHeader:
-			// if its not the first one, we have a full block
-			if blockParts != nil {
-				var n int
-				block := wire.ReadBinary(&types.Block{}, blockParts.GetReader(), types.MaxBlockSize, &n, &err).(*types.Block)
-				blocks = append(blocks, block)
-			}
 			blockParts = types.NewPartSetFromHeader(*p)
 		case *types.Part:
-			_, err := blockParts.AddPart(p, false)
-			if err != nil {
+			if blockParts == nil {
+				continue
+			}
+			if _, err := blockParts.AddPart(p, false); err != nil {
 				return nil, nil, err
 			}
+			if blockParts.IsComplete() {
+				var n int
+				block := wire.ReadBinary(&types.Block{}, blockParts.GetReader(), types.MaxBlockSize, &n, &err).(*types.Block)
+				if err != nil {
+					return nil, nil, err
+				}
+				blocks = append(blocks, block)
+				blockParts = nil
+			}
 		case *types.Vote:
 			if p.Type == types.VoteTypePrecommit {
 				commit := &types.Commit{
@@ -522,39 +685,21 @@ func makeBlockchainFromWAL(wal *WAL) ([]*types.Block, []*types.Commit, error) {
 			}
 		}
 	}
-	// grab the last block too
-	var n int
-	block := wire.ReadBinary(&types.Block{}, blockParts.GetReader(), types.MaxBlockSize, &n, &err).(*types.Block)
-	blocks = append(blocks, block)
 	return blocks, commits, nil
 }
 
-func readPieceFromWAL(msgBytes []byte) (interface{}, error) {
-	// Skip over empty and meta lines
-	if len(msgBytes) == 0 || msgBytes[0] == '#' {
-		return nil, nil
-	}
-	var err error
-	var msg TimedWALMessage
-	wire.ReadJSON(&msg, msgBytes, &err)
-	if err != nil {
-		fmt.Println("MsgBytes:", msgBytes, string(msgBytes))
-		return nil, fmt.Errorf("Error reading json data: %v", err)
-	}
-
-	// for logging
-	switch m := msg.Msg.(type) {
-	case msgInfo:
+func readPieceFromWAL(msg *TimedWALMessage) interface{} {
+	if m, ok := msg.Msg.(msgInfo); ok {
 		switch msg := m.Msg.(type) {
 		case *ProposalMessage:
-			return &msg.Proposal.BlockPartsHeader, nil
+			return &msg.Proposal.BlockPartsHeader
 		case *BlockPartMessage:
-			return msg.Part, nil
+			return msg.Part
 		case *VoteMessage:
-			return msg.Vote, nil
+			return msg.Vote
 		}
 	}
-	return nil, nil
+	return nil
 }
 
 // fresh state and mock store
@@ -581,15 +726,24 @@ func NewMockBlockStore(config *cfg.Config) *mockBlockStore {
 	return &mockBlockStore{config, nil, nil}
 }
 
+func (bs *mockBlockStore) Base() int                         { return 1 }
 func (bs *mockBlockStore) Height() int                       { return len(bs.chain) }
 func (bs *mockBlockStore) LoadBlock(height int) *types.Block { return bs.chain[height-1] }
 func (bs *mockBlockStore) LoadBlockMeta(height int) *types.BlockMeta {
 	block := bs.chain[height-1]
 	return &types.BlockMeta{
-		BlockID: types.BlockID{block.Hash(), block.MakePartSet(bs.config.Consensus.BlockPartSize).Header()},
+		BlockID: types.BlockID{block.Hash(), block.MakePartSet(types.DefaultBlockPartSize).Header()},
 		Header:  block.Header,
 	}
 }
+func (bs *mockBlockStore) LoadBlockByHash(hash []byte) *types.Block {
+	for _, block := range bs.chain {
+		if bytes.Equal(block.Hash(), hash) {
+			return block
+		}
+	}
+	return nil
+}
 func (bs *mockBlockStore) LoadBlockPart(height int, index int) *types.Part { return nil }
 func (bs *mockBlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, seenCommit *types.Commit) {
 }
@@ -0,0 +1,523 @@
+package consensus
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"time"
+
+	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tmlibs/log"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+const (
+	defaultSimGossipInterval = 500 * time.Millisecond
+)
+
+// Simulation runs a network of ConsensusStates in a single goroutine against a
+// virtual clock. Their timeouts are scheduled on the virtual clock instead of
+// timers, and their proposals, block parts and votes are routed through a
+// SimNetwork, which may delay, drop, reorder or partition them.
+// Since all the randomness comes from the seed, running the same seed (with
+// the same network changes) processes the same messages and timeouts in the
+// same order, so a failing run can be replayed.
+//
+// The Simulation checks safety - all the nodes commit the same block at each
+// height - and Run checks liveness - all the nodes reach a height in time.
+//
+// If a ConsensusState has its WAL open (see OpenWAL), its inputs are written
+// to it, so what a node did can be looked at with the replay console.
+//
+// The ConsensusStates must not be started, the Simulation drives them.
+// Only the consensus messages are simulated: the reactor isn't, and neither
+// are txs, so the mempools should stay empty.
+type Simulation struct {
+	Network *SimNetwork
+
+	nodes          []*simNode
+	now            time.Duration
+	events         simEventQueue
+	seq            int
+	started        bool
+	gossipInterval time.Duration
+
+	committed map[int]types.BlockID // by height
+	commits   []SimCommit
+
+	logger log.Logger
+}
+
+// SimCommit is a block committed by a node during a Simulation.
+type SimCommit struct {
+	Time   time.Duration // virtual time since the start
+	Node   int
+	Height int
+	Round  int
+}
+
+// NewSimulation returns a Simulation of the given ConsensusStates, with all
+// the randomness taken from seed. It replaces their TimeoutTickers.
+func NewSimulation(css []*ConsensusState, seed int64) *Simulation {
+	sim := &Simulation{
+		Network:        NewSimNetwork(seed),
+		gossipInterval: defaultSimGossipInterval,
+		committed:      make(map[int]types.BlockID),
+		logger:         log.NewNopLogger(),
+	}
+	for i, cs := range css {
+		node := &simNode{
+			index:     i,
+			peerKey:   cmn.Fmt("sim_node_%d", i),
+			cs:        cs,
+			height:    cs.Height,
+			committed: cs.state.LastBlockHeight,
+			logged:    make(map[string]bool),
+		}
+		node.ticker = &simTicker{
+			sim:           sim,
+			node:          i,
+			commitTimeout: time.Duration(cs.config.TimeoutCommit) * time.Millisecond,
+			c:             make(chan timeoutInfo),
+		}
+		cs.SetTimeoutTicker(node.ticker)
+		sim.nodes = append(sim.nodes, node)
+	}
+	return sim
+}
+
+// SetLogger sets the logger of the Simulation.
+func (sim *Simulation) SetLogger(l log.Logger) {
+	sim.logger = l
+}
+
+// SetGossipInterval sets how often each node resends the messages of its
+// current height to the peers at the same height (from the round before
+// theirs on), and helps the peers that are behind catch up with its commits.
+// It should be set before Run.
+func (sim *Simulation) SetGossipInterval(interval time.Duration) {
+	sim.gossipInterval = interval
+}
+
+// Now returns the virtual time since the start of the Simulation.
+func (sim *Simulation) Now() time.Duration {
+	return sim.now
+}
+
+// At calls fn at the virtual time t, eg. to partition or heal the Network.
+func (sim *Simulation) At(t time.Duration, fn func()) {
+	sim.schedule(&simEvent{at: t, fn: fn})
+}
+
+// Commits returns all the blocks committed so far, in the order they were
+// committed.
+func (sim *Simulation) Commits() []SimCommit {
+	return sim.commits
+}
+
+// Run runs the Simulation until all the nodes have committed the block at
+// height. It returns an error if they haven't within timeout of virtual
+// time, if two nodes commit different blocks, or if a node panics.
+func (sim *Simulation) Run(height int, timeout time.Duration) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Panic at %v: %v", sim.now, r)
+		}
+	}()
+
+	if !sim.started {
+		sim.start()
+	}
+	deadline := sim.now + timeout
+	for !sim.allCommitted(height) {
+		if sim.events.Len() == 0 {
+			return fmt.Errorf("No more events at %v. Heights: %v", sim.now, sim.heights())
+		}
+		if sim.events[0].at > deadline {
+			sim.now = deadline
+			return fmt.Errorf("Height %d not committed by %v. Heights: %v", height, deadline, sim.heights())
+		}
+		ev := heap.Pop(&sim.events).(*simEvent)
+		sim.now = ev.at
+		if err := sim.process(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sim *Simulation) start() {
+	sim.started = true
+	for _, node := range sim.nodes {
+		node.cs.scheduleRound0(&node.cs.RoundState)
+	}
+	sim.At(sim.now+sim.gossipInterval, sim.gossip)
+}
+
+func (sim *Simulation) process(ev *simEvent) error {
+	if ev.fn != nil {
+		ev.fn()
+		return nil
+	}
+
+	// like the receiveRoutine
+	node := sim.nodes[ev.node]
+	switch {
+	case ev.msg != nil:
+		node.logMsg(ev.msg.Msg)
+		node.cs.wal.Save(*ev.msg)
+		node.cs.handleMsg(*ev.msg)
+	case ev.ti != nil:
+		if ev.gen != node.ticker.gen {
+			// replaced by a later timeout
+			return nil
+		}
+		node.cs.wal.Save(*ev.ti)
+		node.cs.handleTimeout(*ev.ti, node.cs.RoundState)
+	}
+
+	// our own proposals, block parts and votes are handled as the next
+	// events, so Run can stop between them, eg. when a single validator that
+	// skips the commit timeout proposes the next block right away
+	for len(node.cs.internalMsgQueue) > 0 {
+		mi := <-node.cs.internalMsgQueue
+		sim.schedule(&simEvent{at: sim.now, node: node.index, msg: &mi})
+		for _, peer := range sim.nodes {
+			if peer != node {
+				sim.send(node, peer, mi.Msg)
+			}
+		}
+	}
+
+	return sim.checkCommits(node)
+}
+
+// checkCommits records the blocks the node committed since the last call,
+// and checks they're the same as the other nodes committed.
+func (sim *Simulation) checkCommits(node *simNode) error {
+	cs := node.cs
+	for node.committed < cs.state.LastBlockHeight {
+		node.committed++
+		height := node.committed
+		blockID := cs.blockStore.LoadBlockMeta(height).BlockID
+		commit := cs.blockStore.LoadSeenCommit(height)
+		sim.commits = append(sim.commits, SimCommit{sim.now, node.index, height, commit.Round()})
+		sim.logger.Info("Committed block", "node", node.index, "height", height, "round", commit.Round(), "time", sim.now)
+
+		if committed, ok := sim.committed[height]; !ok {
+			sim.committed[height] = blockID
+		} else if !committed.Equals(blockID) {
+			return fmt.Errorf("Node %d committed %v at height %d, but another node committed %v", node.index, blockID, height, committed)
+		}
+	}
+	if node.height != cs.Height {
+		node.height = cs.Height
+		node.log = nil
+		node.logged = make(map[string]bool)
+	}
+	return nil
+}
+
+// gossip resends to each peer what it may have missed, like the reactor's
+// gossip routines would.
+func (sim *Simulation) gossip() {
+	for _, node := range sim.nodes {
+		for _, peer := range sim.nodes {
+			if peer == node {
+				continue
+			}
+			switch {
+			case peer.cs.Height < node.cs.Height:
+				sim.sendCommit(node, peer, peer.cs.Height)
+			case peer.cs.Height == node.cs.Height:
+				// only what the peer may still need for its round
+				for _, lm := range node.log {
+					if lm.round >= peer.cs.Round-1 {
+						sim.send(node, peer, lm.msg)
+					}
+				}
+			}
+		}
+	}
+	sim.At(sim.now+sim.gossipInterval, sim.gossip)
+}
+
+// sendCommit sends the precommits and the parts of the block node committed
+// at height to peer.
+func (sim *Simulation) sendCommit(node, peer *simNode, height int) {
+	commit := node.cs.blockStore.LoadSeenCommit(height)
+	for _, vote := range commit.Precommits {
+		if vote != nil {
+			sim.send(node, peer, &VoteMessage{vote})
+		}
+	}
+	blockMeta := node.cs.blockStore.LoadBlockMeta(height)
+	for i := 0; i < blockMeta.BlockID.PartsHeader.Total; i++ {
+		part := node.cs.blockStore.LoadBlockPart(height, i)
+		sim.send(node, peer, &BlockPartMessage{height, commit.Round(), part})
+	}
+}
+
+func (sim *Simulation) send(from, to *simNode, msg ConsensusMessage) {
+	delay, ok := sim.Network.route(from.index, to.index, msg)
+	if !ok {
+		return
+	}
+	sim.schedule(&simEvent{
+		at:   sim.now + delay,
+		node: to.index,
+		msg:  &msgInfo{msg, from.peerKey},
+	})
+}
+
+func (sim *Simulation) schedule(ev *simEvent) {
+	ev.seq = sim.seq
+	sim.seq++
+	heap.Push(&sim.events, ev)
+}
+
+func (sim *Simulation) allCommitted(height int) bool {
+	for _, node := range sim.nodes {
+		if node.committed < height {
+			return false
+		}
+	}
+	return true
+}
+
+func (sim *Simulation) heights() []int {
+	heights := make([]int, len(sim.nodes))
+	for i, node := range sim.nodes {
+		heights[i] = node.cs.Height
+	}
+	return heights
+}
+
+//-----------------------------------------------------------------------------
+
+type simNode struct {
+	index   int
+	peerKey string
+	cs      *ConsensusState
+	ticker  *simTicker
+
+	height    int
+	committed int
+
+	// the messages of the current height, to gossip
+	log    []simLoggedMsg
+	logged map[string]bool
+}
+
+type simLoggedMsg struct {
+	round int
+	msg   ConsensusMessage
+}
+
+func (node *simNode) logMsg(msg ConsensusMessage) {
+	var height, round int
+	var key string
+	switch msg := msg.(type) {
+	case *ProposalMessage:
+		height, round = msg.Proposal.Height, msg.Proposal.Round
+		key = cmn.Fmt("proposal/%d", round)
+	case *BlockPartMessage:
+		height, round = msg.Height, msg.Round
+		key = cmn.Fmt("part/%d/%d", round, msg.Part.Index)
+	case *VoteMessage:
+		height, round = msg.Vote.Height, msg.Vote.Round
+		key = cmn.Fmt("vote/%d/%d/%d", round, msg.Vote.Type, msg.Vote.ValidatorIndex)
+	default:
+		return
+	}
+	if height != node.cs.Height || node.logged[key] {
+		return
+	}
+	node.logged[key] = true
+	node.log = append(node.log, simLoggedMsg{round, msg})
+}
+
+//-----------------------------------------------------------------------------
+
+// simTicker is a TimeoutTicker on the virtual clock of a Simulation.
+// Like the timeoutTicker, a new timeout replaces the previous one, and
+// timeouts for earlier height/round/steps are ignored.
+// The NewHeight timeout is always the commit timeout, since the start time
+// it's computed from is taken from the real clock.
+type simTicker struct {
+	sim           *Simulation
+	node          int
+	commitTimeout time.Duration
+
+	ti  timeoutInfo
+	gen int // incremented for every scheduled timeout
+
+	c chan timeoutInfo // never used, the Simulation handles the timeouts
+}
+
+func (t *simTicker) Start() (bool, error) {
+	return true, nil
+}
+
+func (t *simTicker) Stop() bool {
+	return true
+}
+
+func (t *simTicker) Chan() <-chan timeoutInfo {
+	return t.c
+}
+
+func (t *simTicker) ScheduleTimeout(ti timeoutInfo) {
+	if ti.Height < t.ti.Height {
+		return
+	} else if ti.Height == t.ti.Height {
+		if ti.Round < t.ti.Round {
+			return
+		} else if ti.Round == t.ti.Round {
+			if t.ti.Step > 0 && ti.Step <= t.ti.Step {
+				return
+			}
+		}
+	}
+
+	if ti.Step == RoundStepNewHeight {
+		ti.Duration = t.commitTimeout
+	}
+	if ti.Duration < 0 {
+		ti.Duration = 0
+	}
+	t.ti = ti
+	t.gen++
+	t.sim.schedule(&simEvent{
+		at:   t.sim.now + ti.Duration,
+		node: t.node,
+		ti:   &ti,
+		gen:  t.gen,
+	})
+}
+
+func (t *simTicker) SetLogger(log.Logger) {
+}
+
+//-----------------------------------------------------------------------------
+
+// SimNetwork routes the messages of a Simulation. Each message is dropped
+// with probability DropRate, and otherwise delivered after a random delay
+// between MinDelay and MaxDelay, so messages may be reordered.
+// Messages between partitioned nodes are dropped.
+type SimNetwork struct {
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	DropRate float64
+
+	// Filter, if set, is called for every message that isn't dropped,
+	// and drops it if it returns false.
+	Filter func(from, to int, msg ConsensusMessage) bool
+
+	rng       *rand.Rand
+	partition map[int]int // node -> group
+}
+
+// NewSimNetwork returns a SimNetwork delivering all messages after 10ms.
+func NewSimNetwork(seed int64) *SimNetwork {
+	return &SimNetwork{
+		MinDelay: 10 * time.Millisecond,
+		MaxDelay: 10 * time.Millisecond,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Rand returns the source of randomness of the network, eg. to pick faults
+// deterministically.
+func (net *SimNetwork) Rand() *rand.Rand {
+	return net.rng
+}
+
+// Partition splits the nodes into the groups, which can't communicate with
+// each other. Nodes not in any group can't communicate with anyone.
+func (net *SimNetwork) Partition(groups ...[]int) {
+	net.partition = make(map[int]int)
+	for i, group := range groups {
+		for _, node := range group {
+			net.partition[node] = i
+		}
+	}
+}
+
+// Heal removes the partition.
+func (net *SimNetwork) Heal() {
+	net.partition = nil
+}
+
+// Connected returns whether from can send messages to to.
+func (net *SimNetwork) Connected(from, to int) bool {
+	if net.partition == nil {
+		return true
+	}
+	fromGroup, ok := net.partition[from]
+	if !ok {
+		return false
+	}
+	toGroup, ok := net.partition[to]
+	return ok && fromGroup == toGroup
+}
+
+// route returns the delay of the message, or false if it's dropped.
+func (net *SimNetwork) route(from, to int, msg ConsensusMessage) (time.Duration, bool) {
+	// always make the same draws, so changing the drop rate doesn't
+	// change the delays
+	drop := net.rng.Float64() < net.DropRate
+	delay := net.MinDelay
+	if net.MaxDelay > net.MinDelay {
+		delay += time.Duration(net.rng.Int63n(int64(net.MaxDelay - net.MinDelay + 1)))
+	}
+
+	if drop || !net.Connected(from, to) {
+		return 0, false
+	}
+	if net.Filter != nil && !net.Filter(from, to, msg) {
+		return 0, false
+	}
+	return delay, true
+}
+
+//-----------------------------------------------------------------------------
+
+type simEvent struct {
+	at  time.Duration
+	seq int // to break ties in order of scheduling
+
+	node int
+	msg  *msgInfo
+	ti   *timeoutInfo
+	gen  int
+
+	fn func()
+}
+
+// simEventQueue is a heap of events by time.
+type simEventQueue []*simEvent
+
+func (q simEventQueue) Len() int { return len(q) }
+
+func (q simEventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q simEventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *simEventQueue) Push(x interface{}) {
+	*q = append(*q, x.(*simEvent))
+}
+
+func (q *simEventQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	ev := old[n-1]
+	*q = old[:n-1]
+	return ev
+}
@@ -0,0 +1,123 @@
+package consensus
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	simSeed = flag.Int64("sim.seed", 1, "seed of the first simulation run by TestSimulationFuzz (random if 0)")
+	simRuns = flag.Int("sim.runs", 5, "number of simulations run by TestSimulationFuzz")
+)
+
+func init() {
+	config = ResetConfig("consensus_simulation_test")
+}
+
+func newTestSimulation(nValidators int, seed int64) *Simulation {
+	css := randConsensusNet(nValidators, "consensus_simulation_test", newMockTickerFunc(false), newCounter)
+	return NewSimulation(css, seed)
+}
+
+func TestSimulation(t *testing.T) {
+	css := randConsensusNet(4, "consensus_simulation_test", newMockTickerFunc(false), newCounter)
+	walFile := css[0].config.WalFile()
+	require.Nil(t, css[0].OpenWAL(walFile))
+	sim := NewSimulation(css, 1)
+	require.Nil(t, sim.Run(5, time.Minute))
+	css[0].wal.Stop()
+
+	// with no faults, every height is committed in round 0
+	commits := sim.Commits()
+	assert.Len(t, commits, 5*4)
+	for _, commit := range commits {
+		assert.Equal(t, 0, commit.Round)
+	}
+
+	// and the WAL of the first node has them all
+	rd, found, err := SearchForEndHeight(walFile, 5)
+	require.Nil(t, err, "%+v", err)
+	assert.True(t, found)
+	if rd != nil {
+		rd.Close()
+	}
+}
+
+func TestSimulationDeterministic(t *testing.T) {
+	run := func() []SimCommit {
+		sim := newTestSimulation(4, 42)
+		sim.Network.MinDelay = time.Millisecond
+		sim.Network.MaxDelay = time.Second
+		sim.Network.DropRate = 0.2
+		require.Nil(t, sim.Run(3, 10*time.Minute))
+		return sim.Commits()
+	}
+	assert.Equal(t, run(), run())
+}
+
+func TestSimulationPartition(t *testing.T) {
+	sim := newTestSimulation(4, 1)
+	require.Nil(t, sim.Run(1, time.Minute))
+
+	// neither half has +2/3 of the voting power
+	sim.Network.Partition([]int{0, 1}, []int{2, 3})
+	assert.NotNil(t, sim.Run(2, time.Minute))
+
+	// but they make progress once it's healed
+	sim.Network.Heal()
+	require.Nil(t, sim.Run(3, time.Minute))
+}
+
+func TestSimulationCatchup(t *testing.T) {
+	sim := newTestSimulation(4, 1)
+
+	// 3 out of 4 validators are enough to make progress,
+	// and the last one catches up once it's back
+	sim.Network.Partition([]int{0, 1, 2})
+	sim.At(10*time.Second, sim.Network.Heal)
+	require.Nil(t, sim.Run(3, time.Minute))
+	assert.True(t, sim.Now() >= 10*time.Second)
+}
+
+// TestSimulationFuzz runs simulations with random delays, drops and
+// partitions. The delays stay below the propose timeout of the test config,
+// so the nodes should always make progress once the partition is healed.
+// The seeds are fixed, so the test is reproducible; -sim.seed=0 picks random
+// ones. A failing seed can be replayed with -sim.seed=<seed> -sim.runs=1.
+func TestSimulationFuzz(t *testing.T) {
+	seed := *simSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+		t.Logf("Running simulations from the random seed %d", seed)
+	}
+	for i := 0; i < *simRuns; i++ {
+		if err := runFuzzSimulation(seed + int64(i)); err != nil {
+			t.Fatalf("Simulation with seed %d failed: %v", seed+int64(i), err)
+		}
+	}
+}
+
+func runFuzzSimulation(seed int64) error {
+	sim := newTestSimulation(4, seed)
+	net := sim.Network
+	rng := net.Rand()
+	net.MinDelay = time.Duration(rng.Int63n(int64(100 * time.Millisecond)))
+	net.MaxDelay = net.MinDelay + time.Duration(rng.Int63n(int64(500*time.Millisecond)))
+	net.DropRate = rng.Float64() * 0.3
+
+	// a random partition for a while
+	start := time.Duration(rng.Int63n(int64(30 * time.Second)))
+	groups := [][]int{{}, {}}
+	for i := 0; i < 4; i++ {
+		group := rng.Intn(2)
+		groups[group] = append(groups[group], i)
+	}
+	sim.At(start, func() { net.Partition(groups...) })
+	sim.At(start+time.Duration(rng.Int63n(int64(30*time.Second))), net.Heal)
+
+	return sim.Run(5, 30*time.Minute)
+}
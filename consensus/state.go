@@ -203,6 +203,7 @@ type ConsensusState struct {
 	proxyAppConn proxy.AppConnConsensus
 	blockStore   types.BlockStore
 	mempool      types.Mempool
+	evpool       types.EvidencePool
 
 	// internal state
 	mtx sync.Mutex
@@ -224,6 +225,10 @@ type ConsensusState struct {
 	wal        *WAL
 	replayMode bool // so we don't log signing errors during replay
 
+	// the app's decisions on proposal blocks, by block hash, read from the
+	// WAL when we replay a height
+	processedProposals map[string]bool
+
 	// for tests where we want to limit the number of transitions the state makes
 	nSteps int
 
@@ -234,19 +239,29 @@ type ConsensusState struct {
 
 	// closed when we finish shutting down
 	done chan struct{}
+
+	// once we committed the block to halt after (see the halt_height and
+	// halt_time config), we stop making progress and close haltedCh
+	halted   bool
+	haltedCh chan struct{}
+
+	metrics *Metrics
 }
 
 // NewConsensusState returns a new ConsensusState.
-func NewConsensusState(config *cfg.ConsensusConfig, state *sm.State, proxyAppConn proxy.AppConnConsensus, blockStore types.BlockStore, mempool types.Mempool) *ConsensusState {
+func NewConsensusState(config *cfg.ConsensusConfig, state *sm.State, proxyAppConn proxy.AppConnConsensus, blockStore types.BlockStore, mempool types.Mempool, evpool types.EvidencePool) *ConsensusState {
 	cs := &ConsensusState{
 		config:           config,
 		proxyAppConn:     proxyAppConn,
 		blockStore:       blockStore,
 		mempool:          mempool,
+		evpool:           evpool,
 		peerMsgQueue:     make(chan msgInfo, msgQueueSize),
 		internalMsgQueue: make(chan msgInfo, msgQueueSize),
 		timeoutTicker:    NewTimeoutTicker(),
 		done:             make(chan struct{}),
+		haltedCh:         make(chan struct{}),
+		metrics:          NopMetrics(),
 	}
 	// set function defaults (may be overwritten before calling Start)
 	cs.decideProposal = cs.defaultDecideProposal
@@ -270,6 +285,17 @@ func (cs *ConsensusState) SetLogger(l log.Logger) {
 	cs.timeoutTicker.SetLogger(l)
 }
 
+// SetMetrics sets the metrics to report to.
+func (cs *ConsensusState) SetMetrics(metrics *Metrics) {
+	cs.metrics = metrics
+}
+
+// Halted returns a channel which is closed once consensus halts after
+// committing the block at the configured halt height or time.
+func (cs *ConsensusState) Halted() <-chan struct{} {
+	return cs.haltedCh
+}
+
 // SetEventSwitch implements events.Eventable
 func (cs *ConsensusState) SetEventSwitch(evsw types.EventSwitch) {
 	cs.evsw = evsw
@@ -341,6 +367,12 @@ func (cs *ConsensusState) OnStart() error {
 		return err
 	}
 
+	// we may have halted before a restart
+	if cs.config.HaltAfter(cs.state.LastBlockHeight, cs.state.LastBlockTime) {
+		cs.Logger.Info("Already committed the block to halt after", "height", cs.state.LastBlockHeight)
+		cs.halt()
+	}
+
 	// we need the timeoutRoutine for replay so
 	//  we don't block on the tick chan.
 	// NOTE: we will get a build up of garbage go routines
@@ -361,7 +393,9 @@ func (cs *ConsensusState) OnStart() error {
 
 	// schedule the first round!
 	// use GetRoundState so we don't race the receiveRoutine for access
-	cs.scheduleRound0(cs.GetRoundState())
+	if rs := cs.GetRoundState(); !cs.isHalted() {
+		cs.scheduleRound0(rs)
+	}
 
 	return nil
 }
@@ -578,6 +612,10 @@ func (cs *ConsensusState) updateToState(state *sm.State) {
 	} else {
 		cs.StartTime = cs.config.Commit(cs.CommitTime)
 	}
+	if height == 1 && cs.StartTime.Before(state.LastBlockTime) {
+		// The chain starts at the genesis time.
+		cs.StartTime = state.LastBlockTime
+	}
 	cs.Validators = validators
 	cs.Proposal = nil
 	cs.ProposalBlock = nil
@@ -600,6 +638,9 @@ func (cs *ConsensusState) newStep() {
 	rs := cs.RoundStateEvent()
 	cs.wal.Save(rs)
 	cs.nSteps += 1
+	cs.metrics.Height.Set(float64(cs.Height))
+	cs.metrics.Round.Set(float64(cs.Round))
+	cs.metrics.Step.Set(float64(cs.Step))
 	// newStep is called by updateToStep in NewConsensusState before the evsw is set!
 	if cs.evsw != nil {
 		types.FireEventNewRoundStep(cs.evsw, rs)
@@ -664,6 +705,9 @@ func (cs *ConsensusState) receiveRoutine(maxSteps int) {
 func (cs *ConsensusState) handleMsg(mi msgInfo) {
 	cs.mtx.Lock()
 	defer cs.mtx.Unlock()
+	if cs.halted {
+		return
+	}
 
 	var err error
 	msg, peerKey := mi.Msg, mi.PeerKey
@@ -712,6 +756,9 @@ func (cs *ConsensusState) handleTimeout(ti timeoutInfo, rs RoundState) {
 	// the timeout will now cause a state transition
 	cs.mtx.Lock()
 	defer cs.mtx.Unlock()
+	if cs.halted {
+		return
+	}
 
 	switch ti.Step {
 	case RoundStepNewHeight:
@@ -738,6 +785,9 @@ func (cs *ConsensusState) handleTimeout(ti timeoutInfo, rs RoundState) {
 func (cs *ConsensusState) handleTxsAvailable(height int) {
 	cs.mtx.Lock()
 	defer cs.mtx.Unlock()
+	if cs.halted {
+		return
+	}
 	// we only need to do this for round 0
 	cs.enterPropose(height, 0)
 }
@@ -811,6 +861,10 @@ func (cs *ConsensusState) needProofBlock(height int) bool {
 	}
 
 	lastBlockMeta := cs.blockStore.LoadBlockMeta(height - 1)
+	if lastBlockMeta == nil {
+		// we don't have the last block, eg. after state sync
+		return true
+	}
 	if !bytes.Equal(cs.state.AppHash, lastBlockMeta.Header.AppHash) {
 		return true
 	}
@@ -960,24 +1014,86 @@ func (cs *ConsensusState) isProposalComplete() bool {
 // NOTE: keep it side-effect free for clarity.
 func (cs *ConsensusState) createProposalBlock() (block *types.Block, blockParts *types.PartSet) {
 	var commit *types.Commit
+	var blockTime time.Time
 	if cs.Height == 1 {
 		// We're creating a proposal for the first block.
 		// The commit is empty, but not nil.
 		commit = &types.Commit{}
+		// Its time is ours, but not before the genesis time.
+		blockTime = time.Now()
+		if genesisTime := cs.state.LastBlockTime; blockTime.Before(genesisTime) {
+			blockTime = genesisTime
+		}
+		blockTime = blockTime.Truncate(time.Millisecond)
 	} else if cs.LastCommit.HasTwoThirdsMajority() {
 		// Make the commit from LastCommit
 		commit = cs.LastCommit.MakeCommit()
+		blockTime = sm.MedianTime(commit, cs.state.LastValidators)
 	} else {
 		// This shouldn't happen.
 		cs.Logger.Error("enterPropose: Cannot propose anything: No commit for the previous block.")
 		return
 	}
 
-	// Mempool validated transactions
-	txs := cs.mempool.Reap(cs.config.MaxBlockSizeTxs)
+	params := cs.state.ConsensusParams
+
+	// Mempool validated transactions, as the app wants them in the block
+	txs, err := cs.prepareProposalTxs(cs.mempool.Reap(params.BlockSize.MaxTxs), params.BlockSize.MaxTxs)
+	if err != nil {
+		cs.Logger.Error("enterPropose: Cannot propose anything: Error preparing the proposal", "err", err)
+		return
+	}
+
+	// Verified, not yet committed evidence, highest priority first
+	evidence := cs.evpool.PendingEvidence()
+	if len(evidence) > params.Evidence.MaxNum {
+		evidence = evidence[:params.Evidence.MaxNum]
+	}
 
-	return types.MakeBlock(cs.Height, cs.state.ChainID, txs, commit,
-		cs.state.LastBlockID, cs.state.Validators.Hash(), cs.state.AppHash, cs.config.BlockPartSize)
+	for {
+		block, blockParts = types.MakeBlock(cs.Height, cs.state.ChainID, txs, evidence, commit,
+			cs.state.LastBlockID, cs.state.Validators.Hash(), cs.state.AppHash, blockTime, params.BlockGossip.BlockPartSizeBytes)
+
+		// drop txs from the end until the block fits,
+		// then the lowest priority evidence
+		excess := len(wire.BinaryBytes(block)) - params.BlockSize.MaxBytes
+		switch {
+		case excess <= 0:
+			return block, blockParts
+		case len(txs) > 0:
+			for excess > 0 && len(txs) > 0 {
+				excess -= len(txs[len(txs)-1])
+				txs = txs[:len(txs)-1]
+			}
+		case len(evidence) > 0:
+			evidence = evidence[:len(evidence)-1]
+		default:
+			cs.Logger.Error("enterPropose: Proposal block is too big without txs and evidence", "excess", excess)
+			return block, blockParts
+		}
+	}
+}
+
+// prepareProposalTxs lets the app reorder, drop or add to the txs of our
+// proposal. The app can't make the block have more than maxTxs txs.
+func (cs *ConsensusState) prepareProposalTxs(txs types.Txs, maxTxs int) (types.Txs, error) {
+	txsBytes := make([][]byte, len(txs))
+	for i, tx := range txs {
+		txsBytes[i] = tx
+	}
+	txsBytes, err := cs.proxyAppConn.PrepareProposalSync(cs.Height, txsBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(txsBytes) > maxTxs {
+		cs.Logger.Info("The app prepared too many txs for the proposal", "txs", len(txsBytes), "max", maxTxs)
+		txsBytes = txsBytes[:maxTxs]
+	}
+	prepared := make(types.Txs, len(txsBytes))
+	for i, tx := range txsBytes {
+		prepared[i] = tx
+	}
+	return prepared, nil
 }
 
 // Enter: `timeoutPropose` after entering Propose.
@@ -1039,6 +1155,14 @@ func (cs *ConsensusState) defaultDoPrevote(height int, round int) {
 		return
 	}
 
+	// Let the app check the proposal block
+	if !cs.processProposal(height, round) {
+		// ProposalBlock is rejected by the app, prevote nil.
+		logger.Error("enterPrevote: ProposalBlock was rejected by the app")
+		cs.signAddVote(types.VoteTypePrevote, nil, types.PartSetHeader{})
+		return
+	}
+
 	// Prevote cs.ProposalBlock
 	// NOTE: the proposal signature is validated when it is received,
 	// and the proposal block parts are validated as they are received (against the merkle hash in the proposal)
@@ -1046,6 +1170,29 @@ func (cs *ConsensusState) defaultDoPrevote(height int, round int) {
 	cs.signAddVote(types.VoteTypePrevote, cs.ProposalBlock.Hash(), cs.ProposalBlockParts.Header())
 }
 
+// processProposal returns whether the app accepts the ProposalBlock, and
+// records it in the WAL. When we replay a height, the recorded decision is
+// used instead, so we prevote the same way as before.
+func (cs *ConsensusState) processProposal(height int, round int) bool {
+	block := cs.ProposalBlock
+	hash := block.Hash()
+	if accepted, ok := cs.processedProposals[string(hash)]; ok {
+		return accepted
+	}
+
+	txs := make([][]byte, len(block.Data.Txs))
+	for i, tx := range block.Data.Txs {
+		txs[i] = tx
+	}
+	accepted, err := cs.proxyAppConn.ProcessProposalSync(hash, types.TM2PB.Header(block.Header), txs)
+	if err != nil {
+		cs.Logger.Error("Error processing the proposal block", "err", err)
+		accepted = false
+	}
+	cs.wal.Save(ProcessedProposalMessage{height, round, hash, accepted})
+	return accepted
+}
+
 // Enter: any +2/3 prevotes at next round.
 func (cs *ConsensusState) enterPrevoteWait(height int, round int) {
 	if cs.Height != height || round < cs.Round || (cs.Round == round && RoundStepPrevoteWait <= cs.Step) {
@@ -1319,7 +1466,7 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 	// Execute and commit the block, update and save the state, and update the mempool.
 	// All calls to the proxyAppConn come here.
 	// NOTE: the block.AppHash wont reflect these txs until the next block
-	err := stateCopy.ApplyBlock(eventCache, cs.proxyAppConn, block, blockParts.Header(), cs.mempool)
+	err := stateCopy.ApplyBlock(eventCache, cs.proxyAppConn, block, blockParts.Header(), cs.mempool, cs.evpool)
 	if err != nil {
 		cs.Logger.Error("Error on ApplyBlock. Did the application crash? Please restart tendermint", "err", err)
 		return
@@ -1340,11 +1487,20 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 
 	fail.Fail() // XXX
 
+	cs.recordMetrics(height, block)
+
 	// NewHeightStep!
 	cs.updateToState(stateCopy)
 
 	fail.Fail() // XXX
 
+	// Stop here if this is the block to halt after
+	if cs.config.HaltAfter(height, block.Time) {
+		cs.Logger.Info("Halting after committing the block", "height", height, "time", block.Time)
+		cs.halt()
+		return
+	}
+
 	// cs.StartTime is already set.
 	// Schedule Round0 to start soon.
 	cs.scheduleRound0(&cs.RoundState)
@@ -1355,6 +1511,51 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 	// * cs.StartTime is set to when we will start round0.
 }
 
+// halt stops us from making progress, and closes haltedCh.
+// The caller must hold mtx, if the receiveRoutine is running.
+func (cs *ConsensusState) halt() {
+	if cs.halted {
+		return
+	}
+	cs.halted = true
+	close(cs.haltedCh)
+}
+
+func (cs *ConsensusState) isHalted() bool {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	return cs.halted
+}
+
+// recordMetrics updates the metrics for a committed block.
+// It must be called before updating to the next height.
+func (cs *ConsensusState) recordMetrics(height int, block *types.Block) {
+	cs.metrics.Validators.Set(float64(cs.Validators.Size()))
+	cs.metrics.ValidatorsPower.Set(float64(cs.Validators.TotalVotingPower()))
+
+	// the validators which didn't sign the last block
+	missing, missingPower := 0, int64(0)
+	if height > 1 && block.LastCommit != nil {
+		for i, precommit := range block.LastCommit.Precommits {
+			if precommit != nil {
+				continue
+			}
+			missing++
+			if _, val := cs.LastValidators.GetByIndex(i); val != nil {
+				missingPower += val.VotingPower
+			}
+		}
+		if lastBlockMeta := cs.blockStore.LoadBlockMeta(height - 1); lastBlockMeta != nil {
+			cs.metrics.BlockIntervalSeconds.Observe(block.Time.Sub(lastBlockMeta.Header.Time).Seconds())
+		}
+	}
+	cs.metrics.MissingValidators.Set(float64(missing))
+	cs.metrics.MissingValidatorsPower.Set(float64(missingPower))
+
+	cs.metrics.NumTxs.Set(float64(block.NumTxs))
+	cs.metrics.TotalTxs.Add(float64(block.NumTxs))
+}
+
 //-----------------------------------------------------------------------------
 
 func (cs *ConsensusState) defaultSetProposal(proposal *types.Proposal) error {
@@ -1411,7 +1612,7 @@ func (cs *ConsensusState) addProposalBlockPart(height int, part *types.Part, ver
 		// Added and completed!
 		var n int
 		var err error
-		cs.ProposalBlock = wire.ReadBinary(&types.Block{}, cs.ProposalBlockParts.GetReader(), types.MaxBlockSize, &n, &err).(*types.Block)
+		cs.ProposalBlock = wire.ReadBinary(&types.Block{}, cs.ProposalBlockParts.GetReader(), cs.state.ConsensusParams.BlockSize.MaxBytes, &n, &err).(*types.Block)
 		// NOTE: it's possible to receive complete proposal blocks for future rounds without having the proposal
 		cs.Logger.Info("Received complete proposal block", "height", cs.ProposalBlock.Height, "hash", cs.ProposalBlock.Hash())
 		if cs.Step == RoundStepPropose && cs.isProposalComplete() {
@@ -1440,9 +1641,18 @@ func (cs *ConsensusState) tryAddVote(vote *types.Vote, peerKey string) error {
 				cs.Logger.Error("Found conflicting vote from ourselves. Did you unsafe_reset a validator?", "height", vote.Height, "round", vote.Round, "type", vote.Type)
 				return err
 			}
-			cs.Logger.Error("Found conflicting vote. Publish evidence (TODO)", "height", vote.Height, "round", vote.Round, "type", vote.Type, "valAddr", vote.ValidatorAddress, "valIndex", vote.ValidatorIndex)
+			cs.Logger.Error("Found conflicting vote. Publishing evidence", "height", vote.Height, "round", vote.Round, "type", vote.Type, "valAddr", vote.ValidatorAddress, "valIndex", vote.ValidatorIndex)
 
-			// TODO: track evidence for inclusion in a block
+			// the vote is either for the current height or a LastCommit straggler
+			valSet := cs.Validators
+			if vote.Height+1 == cs.Height {
+				valSet = cs.LastValidators
+			}
+			_, val := valSet.GetByIndex(vote.ValidatorIndex)
+			evidence := types.NewDuplicateVoteEvidence(val.PubKey, err.(*types.ErrVoteConflictingVotes))
+			if err := cs.evpool.AddEvidence(evidence); err != nil {
+				cs.Logger.Error("Failed to add evidence to the pool", "evidence", evidence, "err", err)
+			}
 
 			return err
 		} else {
@@ -1536,7 +1746,7 @@ func (cs *ConsensusState) addVote(vote *types.Vote, peerKey string) (added bool,
 						cs.enterPrecommit(height, vote.Round)
 						cs.enterCommit(height, vote.Round)
 
-						if cs.config.SkipTimeoutCommit && precommits.HasAll() {
+						if cs.config.SkipTimeoutCommit && precommits.HasAll() && !cs.halted {
 							// if we have all the votes now,
 							// go straight to new round (skip timeout commit)
 							// cs.scheduleTimeout(time.Duration(0), cs.Height, 0, RoundStepNewHeight)
@@ -1572,6 +1782,7 @@ func (cs *ConsensusState) signVote(type_ byte, hash []byte, header types.PartSet
 		ValidatorIndex:   valIndex,
 		Height:           cs.Height,
 		Round:            cs.Round,
+		Timestamp:        cs.voteTime(),
 		Type:             type_,
 		BlockID:          types.BlockID{hash, header},
 	}
@@ -1579,6 +1790,24 @@ func (cs *ConsensusState) signVote(type_ byte, hash []byte, header types.PartSet
 	return vote, err
 }
 
+// voteTime returns the timestamp for our vote: the current time, but always
+// after the time of the block we're voting on, so the median time of the
+// precommits for a block, the time of the next block, is after it.
+// It has millisecond precision, like the encoded votes.
+func (cs *ConsensusState) voteTime() time.Time {
+	now := time.Now().Truncate(time.Millisecond)
+	minVoteTime := now
+	if cs.LockedBlock != nil {
+		minVoteTime = cs.LockedBlock.Time.Add(time.Millisecond)
+	} else if cs.ProposalBlock != nil {
+		minVoteTime = cs.ProposalBlock.Time.Add(time.Millisecond)
+	}
+	if now.After(minVoteTime) {
+		return now
+	}
+	return minVoteTime.Truncate(time.Millisecond)
+}
+
 // sign the vote and publish on internalMsgQueue
 func (cs *ConsensusState) signAddVote(type_ byte, hash []byte, header types.PartSetHeader) *types.Vote {
 	// if we don't have a key or we're not in the validator set, do nothing
@@ -3,9 +3,14 @@ package consensus
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/tendermint/abci/example/dummy"
+	abci "github.com/tendermint/abci/types"
+	crypto "github.com/tendermint/go-crypto"
+	wire "github.com/tendermint/go-wire"
 	"github.com/tendermint/tendermint/types"
 	. "github.com/tendermint/tmlibs/common"
 )
@@ -25,6 +30,8 @@ x * TestProposerSelection0 - round robin ordering, round 0
 x * TestProposerSelection2 - round robin ordering, round 2++
 x * TestEnterProposeNoValidator - timeout into prevote round
 x * TestEnterPropose - finish propose without timing out (we have the proposal)
+x * TestPrepareProposal - the app orders and adds to the txs of our proposal
+x * TestProcessProposal - the app rejects the proposal, so prevote nil
 x * TestBadProposal - 2 vals, bad proposal (bad block state hash), should prevote and precommit nil
 FullRoundSuite
 x * TestFullRound1 - 1 val, full successful round
@@ -45,6 +52,7 @@ CatchupSuite
   * TestCatchup - if we might be behind and we've seen any 2/3 prevotes, round skip to new round, precommit, or prevote
 HaltSuite
 x * TestHalt1 - if we see +2/3 precommits after timing out into new round, we should still commit
+x * TestHaltHeight - stop after committing the block at the configured halt height
 
 */
 
@@ -175,12 +183,150 @@ func TestEnterProposeYesPrivValidator(t *testing.T) {
 	}
 }
 
+func TestGenesisTime(t *testing.T) {
+	state, privVals := randGenesisState(1, false, 10)
+	genesisTime := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	state.LastBlockTime = genesisTime
+	cs := newConsensusState(state, privVals[0], dummy.NewDummyApplication())
+
+	// round 0 of the first height doesn't start before the genesis time
+	rs := cs.GetRoundState()
+	if rs.StartTime.Before(genesisTime) {
+		t.Errorf("Expected the first height to start at the genesis time %v, got %v", genesisTime, rs.StartTime)
+	}
+
+	// and neither does the first block
+	block, _ := cs.createProposalBlock()
+	if block.Time.Before(genesisTime) {
+		t.Errorf("Expected the first block time to be at least the genesis time %v, got %v", genesisTime, block.Time)
+	}
+	if err := cs.state.ValidateBlock(block); err != nil {
+		t.Error(err)
+	}
+}
+
+// pendingEvidencePool always has the same pending evidence
+type pendingEvidencePool struct {
+	types.MockEvidencePool
+	evidence []types.Evidence
+}
+
+func (p pendingEvidencePool) PendingEvidence() []types.Evidence { return p.evidence }
+
+func TestProposalBlockMaxBytes(t *testing.T) {
+	state, privVals := randGenesisState(1, false, 10)
+	cs := newConsensusState(state, privVals[0], dummy.NewDummyApplication())
+
+	privKey := crypto.GenPrivKeyEd25519()
+	makeVote := func(hash string) *types.Vote {
+		vote := &types.Vote{
+			ValidatorAddress: privKey.PubKey().Address(),
+			Height:           1,
+			Type:             types.VoteTypePrevote,
+			BlockID:          types.BlockID{Hash: []byte(hash)},
+			Timestamp:        time.Now(),
+		}
+		vote.Signature = privKey.Sign(types.SignBytes(config.ChainID, vote))
+		return vote
+	}
+	var evidence []types.Evidence
+	for i := 0; i < 3; i++ {
+		conflict := &types.ErrVoteConflictingVotes{VoteA: makeVote(Fmt("A%d", i)), VoteB: makeVote(Fmt("B%d", i))}
+		evidence = append(evidence, types.NewDuplicateVoteEvidence(privKey.PubKey(), conflict))
+	}
+
+	// the size of a block with only the first evidence
+	cs.evpool = pendingEvidencePool{evidence: evidence[:1]}
+	block, _ := cs.createProposalBlock()
+	cs.state.ConsensusParams.BlockSize.MaxBytes = len(wire.BinaryBytes(block))
+
+	// txs are dropped first, then the lowest priority evidence
+	if err := cs.mempool.CheckTx([]byte("a=1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	cs.evpool = pendingEvidencePool{evidence: evidence}
+	block, _ = cs.createProposalBlock()
+	if len(block.Data.Txs) != 0 || !reflect.DeepEqual([]types.Evidence(block.Evidence.Evidence), evidence[:1]) {
+		t.Errorf("Expected a block with only the first evidence, got %d txs and evidence %v", len(block.Data.Txs), block.Evidence.Evidence)
+	}
+}
+
+// preparerApp reverses the txs of the proposals and adds an oracle tx
+type preparerApp struct {
+	*dummy.DummyApplication
+}
+
+func (app preparerApp) PrepareProposal(height int, txs [][]byte) [][]byte {
+	prepared := [][]byte{}
+	for i := len(txs) - 1; i >= 0; i-- {
+		prepared = append(prepared, txs[i])
+	}
+	return append(prepared, []byte(Fmt("oracle=%d", height)))
+}
+
+func TestPrepareProposal(t *testing.T) {
+	state, privVals := randGenesisState(1, false, 10)
+	cs := newConsensusState(state, privVals[0], preparerApp{dummy.NewDummyApplication()})
+	height, round := cs.Height, cs.Round
+
+	for _, tx := range []string{"a=1", "b=2"} {
+		if err := cs.mempool.CheckTx([]byte(tx), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	proposalCh := subscribeToEvent(cs.evsw, "tester", types.EventStringCompleteProposal(), 1)
+
+	cs.enterNewRound(height, round)
+	cs.startRoutines(3)
+
+	<-proposalCh
+
+	expected := types.Txs{types.Tx("b=2"), types.Tx("a=1"), types.Tx("oracle=1")}
+	rs := cs.GetRoundState()
+	if !reflect.DeepEqual(rs.ProposalBlock.Data.Txs, expected) {
+		t.Errorf("Expected the prepared txs %v, got %v", expected, rs.ProposalBlock.Data.Txs)
+	}
+}
+
+// processorApp rejects the proposals with a "bad" tx
+type processorApp struct {
+	*dummy.DummyApplication
+}
+
+func (app processorApp) ProcessProposal(hash []byte, header *abci.Header, txs [][]byte) bool {
+	for _, tx := range txs {
+		if bytes.HasPrefix(tx, []byte("bad")) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProcessProposal(t *testing.T) {
+	state, privVals := randGenesisState(1, false, 10)
+	cs := newConsensusState(state, privVals[0], processorApp{dummy.NewDummyApplication()})
+	vs := NewValidatorStub(privVals[0], 0)
+	height, round := cs.Height, cs.Round
+
+	if err := cs.mempool.CheckTx([]byte("bad=1"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	voteCh := subscribeToEvent(cs.evsw, "tester", types.EventStringVote(), 0)
+
+	startTestRound(cs, height, round)
+
+	<-voteCh // wait for prevote
+	validatePrevote(t, cs, round, vs, nil)
+}
+
 func TestBadProposal(t *testing.T) {
 	cs1, vss := randConsensusState(2)
 	height, round := cs1.Height, cs1.Round
 	vs2 := vss[1]
 
-	partSize := config.Consensus.BlockPartSize
+	partSize := types.DefaultBlockPartSize
 
 	proposalCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringCompleteProposal(), 1)
 	voteCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringVote(), 1)
@@ -327,7 +473,7 @@ func TestLockNoPOL(t *testing.T) {
 	vs2 := vss[1]
 	height := cs1.Height
 
-	partSize := config.Consensus.BlockPartSize
+	partSize := types.DefaultBlockPartSize
 
 	timeoutProposeCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringTimeoutPropose(), 1)
 	timeoutWaitCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringTimeoutWait(), 1)
@@ -493,7 +639,7 @@ func TestLockPOLRelock(t *testing.T) {
 	cs1, vss := randConsensusState(4)
 	vs2, vs3, vs4 := vss[1], vss[2], vss[3]
 
-	partSize := config.Consensus.BlockPartSize
+	partSize := types.DefaultBlockPartSize
 
 	timeoutProposeCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringTimeoutPropose(), 1)
 	timeoutWaitCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringTimeoutWait(), 1)
@@ -608,7 +754,7 @@ func TestLockPOLUnlock(t *testing.T) {
 	cs1, vss := randConsensusState(4)
 	vs2, vs3, vs4 := vss[1], vss[2], vss[3]
 
-	partSize := config.Consensus.BlockPartSize
+	partSize := types.DefaultBlockPartSize
 
 	proposalCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringCompleteProposal(), 1)
 	timeoutProposeCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringTimeoutPropose(), 1)
@@ -703,7 +849,7 @@ func TestLockPOLSafety1(t *testing.T) {
 	cs1, vss := randConsensusState(4)
 	vs2, vs3, vs4 := vss[1], vss[2], vss[3]
 
-	partSize := config.Consensus.BlockPartSize
+	partSize := types.DefaultBlockPartSize
 
 	proposalCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringCompleteProposal(), 1)
 	timeoutProposeCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringTimeoutPropose(), 1)
@@ -824,7 +970,7 @@ func TestLockPOLSafety2(t *testing.T) {
 	cs1, vss := randConsensusState(4)
 	vs2, vs3, vs4 := vss[1], vss[2], vss[3]
 
-	partSize := config.Consensus.BlockPartSize
+	partSize := types.DefaultBlockPartSize
 
 	proposalCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringCompleteProposal(), 1)
 	timeoutProposeCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringTimeoutPropose(), 1)
@@ -999,7 +1145,7 @@ func TestHalt1(t *testing.T) {
 	cs1, vss := randConsensusState(4)
 	vs2, vs3, vs4 := vss[1], vss[2], vss[3]
 
-	partSize := config.Consensus.BlockPartSize
+	partSize := types.DefaultBlockPartSize
 
 	proposalCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringCompleteProposal(), 1)
 	timeoutWaitCh := subscribeToEvent(cs1.evsw, "tester", types.EventStringTimeoutWait(), 1)
@@ -1058,3 +1204,28 @@ func TestHalt1(t *testing.T) {
 		panic("expected height to increment")
 	}
 }
+
+func TestHaltHeight(t *testing.T) {
+	cs, _ := randConsensusState(1)
+	csConfig := *cs.config
+	csConfig.HaltHeight = 1
+	cs.config = &csConfig
+	height, round := cs.Height, cs.Round
+
+	newRoundCh := subscribeToEvent(cs.evsw, "tester", types.EventStringNewRound(), 1)
+
+	startTestRound(cs, height, round)
+	<-newRoundCh
+
+	select {
+	case <-cs.Halted():
+	case <-time.After(ensureTimeout):
+		t.Fatal("Expected consensus to halt after committing height 1")
+	}
+
+	// and not start the next height
+	ensureNoNewStep(newRoundCh)
+	if rs := cs.GetRoundState(); rs.Height != 2 || rs.Step != RoundStepNewHeight {
+		t.Errorf("Expected to stay at the start of height 2, got %v/%v/%v", rs.Height, rs.Round, rs.Step)
+	}
+}
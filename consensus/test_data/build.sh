@@ -27,43 +27,28 @@ reset
 
 # empty block
 function empty_block(){
-tendermint node --proxy_app=persistent_dummy &> /dev/null &
+tendermint node --proxy_app=persistent_dummy --p2p.skip_upnp &> /dev/null &
 sleep 5
 killall tendermint
 
 # /q would print up to and including the match, then quit.
 # /Q doesn't include the match.
 # http://unix.stackexchange.com/questions/11305/grep-show-all-the-file-up-to-the-match
-sed '/ENDHEIGHT: 1/Q' ~/.tendermint/data/cs.wal/wal  > consensus/test_data/empty_block.cswal
+tendermint wal dump | sed '/ENDHEIGHT: 1/Q' > consensus/test_data/empty_block.cswal
 
 reset
 }
 
-# many blocks
-function many_blocks(){
-bash scripts/txs/random.sh 1000 36657 &> /dev/null &
-PID=$!
-tendermint node --proxy_app=persistent_dummy &> /dev/null &
-sleep 7
-killall tendermint
-kill -9 $PID
-
-sed '/ENDHEIGHT: 6/Q' ~/.tendermint/data/cs.wal/wal  > consensus/test_data/many_blocks.cswal
-
-reset
-}
-
-
 # small block 1
 function small_block1(){
 bash scripts/txs/random.sh 1000 36657 &> /dev/null &
 PID=$!
-tendermint node --proxy_app=persistent_dummy &> /dev/null &
+tendermint node --proxy_app=persistent_dummy --p2p.skip_upnp &> /dev/null &
 sleep 10
 killall tendermint
 kill -9 $PID
 
-sed '/ENDHEIGHT: 1/Q' ~/.tendermint/data/cs.wal/wal  > consensus/test_data/small_block1.cswal
+tendermint wal dump | sed '/ENDHEIGHT: 1/Q' > consensus/test_data/small_block1.cswal
 
 reset
 }
@@ -71,16 +56,17 @@ reset
 
 # small block 2 (part size = 512)
 function small_block2(){
-echo "" >> ~/.tendermint/config.toml
-echo "block_part_size = 512" >> ~/.tendermint/config.toml
+jq '.consensus_params = {"block_size_params": {"max_bytes": 22020096, "max_txs": 10000}, "block_gossip_params": {"block_part_size_bytes": 512}}' \
+	~/.tendermint/genesis.json > ~/.tendermint/genesis.json.new
+mv ~/.tendermint/genesis.json.new ~/.tendermint/genesis.json
 bash scripts/txs/random.sh 1000 36657 &> /dev/null &
 PID=$!
-tendermint node --proxy_app=persistent_dummy &> /dev/null &
+tendermint node --proxy_app=persistent_dummy --p2p.skip_upnp &> /dev/null &
 sleep 5
 killall tendermint
 kill -9 $PID
 
-sed '/ENDHEIGHT: 1/Q' ~/.tendermint/data/cs.wal/wal  > consensus/test_data/small_block2.cswal
+tendermint wal dump | sed '/ENDHEIGHT: 1/Q' > consensus/test_data/small_block2.cswal
 
 reset
 }
@@ -97,14 +83,10 @@ case "$1" in
 	"empty_block")
 		empty_block
 		;;
-	"many_blocks")
-		many_blocks
-		;;
 	*)
 		small_block1
 		small_block2
 		empty_block
-		many_blocks
 esac
 
 
@@ -1,14 +1,32 @@
 package consensus
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	wire "github.com/tendermint/go-wire"
+	"github.com/tendermint/go-wire/data"
 	"github.com/tendermint/tendermint/types"
 	auto "github.com/tendermint/tmlibs/autofile"
 	. "github.com/tendermint/tmlibs/common"
 )
 
+const (
+	// must be greater than the size of a block part message
+	maxWALMsgSizeBytes = types.MaxBlockSize
+)
+
 //--------------------------------------------------------
 // types and functions for savings consensus messages
 
@@ -17,6 +35,20 @@ type TimedWALMessage struct {
 	Msg  WALMessage `json:"msg"`
 }
 
+// EndHeightMessage marks the end of the given height in the WAL.
+type EndHeightMessage struct {
+	Height int `json:"height"`
+}
+
+// ProcessedProposalMessage records whether the app accepted a proposal
+// block, so it's prevoted the same way when the height is replayed.
+type ProcessedProposalMessage struct {
+	Height    int        `json:"height"`
+	Round     int        `json:"round"`
+	BlockHash data.Bytes `json:"block_hash"`
+	Accepted  bool       `json:"accepted"`
+}
+
 type WALMessage interface{}
 
 var _ = wire.RegisterInterface(
@@ -24,6 +56,8 @@ var _ = wire.RegisterInterface(
 	wire.ConcreteType{types.EventDataRoundState{}, 0x01},
 	wire.ConcreteType{msgInfo{}, 0x02},
 	wire.ConcreteType{timeoutInfo{}, 0x03},
+	wire.ConcreteType{EndHeightMessage{}, 0x04},
+	wire.ConcreteType{ProcessedProposalMessage{}, 0x05},
 )
 
 //--------------------------------------------------------
@@ -31,6 +65,8 @@ var _ = wire.RegisterInterface(
 
 // Write ahead logger writes msgs to disk before they are processed.
 // Can be used for crash-recovery and deterministic replay
+// Each message is written with its length and CRC (see WALEncoder), and the
+// WAL is synced to disk at the end of every height.
 // TODO: currently the wal is overwritten during replay catchup
 //   give it a mode so it's either reading or appending - must read to end to start appending again
 type WAL struct {
@@ -38,6 +74,7 @@ type WAL struct {
 
 	group *auto.Group
 	light bool // ignore block parts
+	enc   *WALEncoder
 }
 
 func NewWAL(walFile string, light bool) (*WAL, error) {
@@ -48,12 +85,23 @@ func NewWAL(walFile string, light bool) (*WAL, error) {
 	wal := &WAL{
 		group: group,
 		light: light,
+		// NOTE: messages are written straight to the head, not through the
+		// group's line buffer, so each one is written at once
+		enc: NewWALEncoder(group.Head),
 	}
 	wal.BaseService = *NewBaseService(nil, "WAL", wal)
 	return wal, nil
 }
 
 func (wal *WAL) OnStart() error {
+	// a crash can leave a partly written message at the end
+	truncated, err := truncateCorruptedTail(wal.group.Head.Path)
+	if err != nil {
+		return err
+	} else if truncated > 0 {
+		wal.Logger.Error("Truncated corrupted end of WAL", "bytes", truncated)
+	}
+
 	size, err := wal.group.Head.Size()
 	if err != nil {
 		return err
@@ -83,22 +131,388 @@ func (wal *WAL) Save(wmsg WALMessage) {
 		}
 	}
 	// Write the wal message
-	var wmsgBytes = wire.JSONBytes(TimedWALMessage{time.Now(), wmsg})
-	err := wal.group.WriteLine(string(wmsgBytes))
-	if err != nil {
+	if err := wal.enc.Encode(&TimedWALMessage{time.Now(), wmsg}); err != nil {
 		PanicQ(Fmt("Error writing msg to consensus wal. Error: %v \n\nMessage: %v", err, wmsg))
 	}
-	// TODO: only flush when necessary
-	if err := wal.group.Flush(); err != nil {
-		PanicQ(Fmt("Error flushing consensus wal buf to file. Error: %v \n", err))
-	}
 }
 
 func (wal *WAL) writeEndHeight(height int) {
-	wal.group.WriteLine(Fmt("#ENDHEIGHT: %v", height))
+	if err := wal.enc.Encode(&TimedWALMessage{time.Now(), EndHeightMessage{height}}); err != nil {
+		PanicQ(Fmt("Error writing end height to consensus wal. Error: %v \n", err))
+	}
+
+	// everything needed to recover the height must be on disk before we move on
+	if err := wal.group.Head.Sync(); err != nil {
+		PanicQ(Fmt("Error syncing consensus wal to disk. Error: %v \n", err))
+	}
+}
+
+// SearchForEndHeight returns a WALReader for the messages after the
+// EndHeightMessage for the height, and whether it was found.
+// CONTRACT: the caller must close the reader if it was found.
+func (wal *WAL) SearchForEndHeight(height int) (*WALReader, bool, error) {
+	return SearchForEndHeight(wal.group.Head.Path, height)
+}
+
+//--------------------------------------------------------
+// Encoding
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// WALEncoder writes WAL messages, each as:
+//
+//	4 bytes CRC32 (Castagnoli) of the data
+//	4 bytes length of the data
+//	the data (go-wire encoded TimedWALMessage)
+//
+// All big endian.
+type WALEncoder struct {
+	wr io.Writer
+}
+
+// NewWALEncoder returns a new WALEncoder writing to wr.
+func NewWALEncoder(wr io.Writer) *WALEncoder {
+	return &WALEncoder{wr}
+}
+
+// Encode writes the message with a single call to Write.
+func (enc *WALEncoder) Encode(msg *TimedWALMessage) error {
+	data := wire.BinaryBytes(msg)
+
+	frame := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint32(frame[0:4], crc32.Checksum(data, crc32c))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(data)))
+	copy(frame[8:], data)
+
+	_, err := enc.wr.Write(frame)
+	return err
+}
+
+// DataCorruptionError is returned by the WALDecoder for a message which
+// doesn't match its checksum or can't be decoded.
+type DataCorruptionError struct {
+	cause error
+}
+
+func (e DataCorruptionError) Error() string {
+	return Fmt("DataCorruptionError[%v]", e.cause)
+}
+
+// IsDataCorruptionError returns true if the error is a DataCorruptionError.
+func IsDataCorruptionError(err error) bool {
+	_, ok := err.(DataCorruptionError)
+	return ok
+}
+
+// WALDecoder reads the WAL messages written by a WALEncoder.
+type WALDecoder struct {
+	rd     io.Reader
+	offset int64
+}
+
+// NewWALDecoder returns a new WALDecoder reading from rd.
+func NewWALDecoder(rd io.Reader) *WALDecoder {
+	return &WALDecoder{rd: rd}
+}
+
+// Decode returns the next message. It returns io.EOF if there are no more
+// messages, io.ErrUnexpectedEOF if the last one was only partly written,
+// and a DataCorruptionError if the message is corrupted.
+func (dec *WALDecoder) Decode() (*TimedWALMessage, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(dec.rd, header); err != nil {
+		return nil, err
+	}
+	crc := binary.BigEndian.Uint32(header[0:4])
+	length := binary.BigEndian.Uint32(header[4:8])
+	if length > maxWALMsgSizeBytes {
+		return nil, DataCorruptionError{fmt.Errorf("Length %d exceeds the maximum of %d bytes", length, maxWALMsgSizeBytes)}
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(dec.rd, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if actualCRC := crc32.Checksum(data, crc32c); actualCRC != crc {
+		return nil, DataCorruptionError{fmt.Errorf("Checksums do not match (read: %v, actual: %v)", crc, actualCRC)}
+	}
+
+	var n int
+	var err error
+	msg := wire.ReadBinary(&TimedWALMessage{}, bytes.NewReader(data), int(length), &n, &err).(*TimedWALMessage)
+	if err != nil {
+		return nil, DataCorruptionError{fmt.Errorf("Failed to decode message: %v", err)}
+	}
+	dec.offset += int64(len(header) + len(data))
+	return msg, nil
+}
+
+// Offset returns the number of bytes read by successful calls to Decode.
+func (dec *WALDecoder) Offset() int64 {
+	return dec.offset
+}
+
+//--------------------------------------------------------
+// Reading the files
+
+// WALReader reads the messages of all the files of a WAL, oldest first.
+type WALReader struct {
+	*WALDecoder
+	files []*os.File
+}
+
+// OpenWALReader opens all the files of the WAL at walFile for reading.
+func OpenWALReader(walFile string) (*WALReader, error) {
+	paths, err := walFiles(walFile)
+	if err != nil {
+		return nil, err
+	}
+	return openWALReader(paths, 0)
+}
+
+// openWALReader reads the files from the offset in the first one.
+func openWALReader(paths []string, offset int64) (*WALReader, error) {
+	rd := &WALReader{}
+	readers := make([]io.Reader, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			rd.Close()
+			return nil, err
+		}
+		rd.files = append(rd.files, f)
+		readers = append(readers, f)
+	}
+	if offset > 0 {
+		if _, err := rd.files[0].Seek(offset, io.SeekStart); err != nil {
+			rd.Close()
+			return nil, err
+		}
+	}
+	rd.WALDecoder = NewWALDecoder(bufio.NewReader(io.MultiReader(readers...)))
+	return rd, nil
+}
 
-	// TODO: only flush when necessary
-	if err := wal.group.Flush(); err != nil {
-		PanicQ(Fmt("Error flushing consensus wal buf to file. Error: %v \n", err))
+// Close closes the files.
+func (rd *WALReader) Close() error {
+	var err error
+	for _, f := range rd.files {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// SearchForEndHeight returns a WALReader for the messages after the
+// EndHeightMessage for the height in the WAL at walFile, and whether it was
+// found.
+// CONTRACT: the caller must close the reader if it was found.
+func SearchForEndHeight(walFile string, height int) (*WALReader, bool, error) {
+	paths, err := walFiles(walFile)
+	if err != nil {
+		return nil, false, err
+	}
+	// we're usually looking for the last height, so start from the head
+	for i := len(paths) - 1; i >= 0; i-- {
+		offset, found, err := findEndHeight(paths[i], height)
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			rd, err := openWALReader(paths[i:], offset)
+			if err != nil {
+				return nil, false, err
+			}
+			return rd, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// findEndHeight returns the offset right after the EndHeightMessage for the
+// height in the file, if it's there.
+func findEndHeight(path string, height int) (int64, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+
+	dec := NewWALDecoder(bufio.NewReader(f))
+	for {
+		msg, err := dec.Decode()
+		if err == io.EOF {
+			return 0, false, nil
+		} else if err != nil {
+			return 0, false, fmt.Errorf("Error reading WAL file %v at offset %d: %v", path, dec.Offset(), err)
+		}
+		if m, ok := msg.Msg.(EndHeightMessage); ok && m.Height == height {
+			return dec.Offset(), true, nil
+		}
+	}
+}
+
+// walFiles returns the paths of the files of the WAL at walFile, oldest
+// first: the rotated files (walFile.000, walFile.001, ...), then the head.
+func walFiles(walFile string) ([]string, error) {
+	matches, err := filepath.Glob(walFile + ".*")
+	if err != nil {
+		return nil, err
+	}
+	indexes := []int{}
+	for _, match := range matches {
+		index, err := strconv.Atoi(strings.TrimPrefix(match, walFile+"."))
+		if err != nil {
+			// not a rotated file, eg. a backup
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	paths := make([]string, 0, len(indexes)+1)
+	for _, index := range indexes {
+		paths = append(paths, Fmt("%v.%03d", walFile, index))
+	}
+	if _, err := os.Stat(walFile); err == nil {
+		paths = append(paths, walFile)
+	}
+	return paths, nil
+}
+
+//--------------------------------------------------------
+// Repair
+
+// WALs written before messages were framed were text, one JSON message per
+// line, with "#ENDHEIGHT: " and "#HEIGHT: " lines in between.
+func checkWALFormat(path string, data []byte) error {
+	if len(data) == 0 || (data[0] != '{' && data[0] != '#') {
+		return nil
+	}
+	// the checksum of a framed message may start with the same byte
+	if validMessageAt(data) > 0 {
+		return nil
+	}
+	return fmt.Errorf("WAL file %v has the old text format. Remove the WAL after a clean shutdown", path)
+}
+
+// truncateCorruptedTail cuts off a corrupted or partly written message at
+// the end of the file, as left by a crash in the middle of a write.
+// It returns the number of bytes cut off. Corrupted messages followed by an
+// intact one are an error; RepairWAL can remove them.
+func truncateCorruptedTail(path string) (int64, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if err := checkWALFormat(path, data); err != nil {
+		return 0, err
+	}
+
+	dec := NewWALDecoder(bytes.NewReader(data))
+	for {
+		_, err := dec.Decode()
+		if err == nil {
+			continue
+		} else if err == io.EOF {
+			return 0, nil
+		}
+
+		offset := dec.Offset()
+		if !IsDataCorruptionError(err) && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		// only the last message can be torn by a crash. We can't trust its
+		// length, so look for an intact message anywhere after it.
+		for next := offset + 1; next < int64(len(data)); next++ {
+			if validMessageAt(data[next:]) > 0 {
+				return 0, fmt.Errorf("WAL file %v is corrupted at offset %d: %v. Repair it with `tendermint wal repair`",
+					path, offset, err)
+			}
+		}
+
+		if err := os.Truncate(path, offset); err != nil {
+			return 0, err
+		}
+		return int64(len(data)) - offset, nil
+	}
+}
+
+// RepairWAL removes the corrupted data from all the files of the WAL at
+// walFile, keeping every message that's intact. The original of a repaired
+// file is kept as <file>.corrupted.
+// It returns the number of bytes removed.
+// The WAL must not be in use.
+func RepairWAL(walFile string) (int64, error) {
+	paths, err := walFiles(walFile)
+	if err != nil {
+		return 0, err
+	}
+	var removed int64
+	for _, path := range paths {
+		n, err := repairWALFile(path)
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+func repairWALFile(path string) (int64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkWALFormat(path, data); err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	repaired := make([]byte, 0, len(data))
+	for offset := 0; offset < len(data); {
+		if n := validMessageAt(data[offset:]); n > 0 {
+			repaired = append(repaired, data[offset:offset+n]...)
+			offset += n
+			continue
+		}
+		// skip a byte, until we find the next intact message
+		offset++
+		removed++
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if err := WriteFileAtomic(path+".corrupted", data, 0600); err != nil {
+		return 0, err
+	}
+	if err := WriteFileAtomic(path, repaired, 0600); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// validMessageAt returns the length of the intact message at the start of
+// data, or 0 if there isn't one.
+func validMessageAt(data []byte) int {
+	if len(data) < 8 {
+		return 0
+	}
+	// checked first, so we don't try to read lengths out of random bytes
+	length := binary.BigEndian.Uint32(data[4:8])
+	if uint64(length) > uint64(len(data)-8) {
+		return 0
+	}
+	dec := NewWALDecoder(bytes.NewReader(data[:8+length]))
+	if _, err := dec.Decode(); err != nil {
+		return 0
 	}
+	return int(dec.Offset())
 }
@@ -0,0 +1,206 @@
+package consensus
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestWAL writes a WAL with a timeout for every height from 1 to
+// nHeights, each followed by its end height, and returns the file and the
+// offset of the end of each message.
+func writeTestWAL(t *testing.T, nHeights int) (string, []int) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.Nil(t, err, "%+v", err)
+	walFile := filepath.Join(dir, "wal")
+
+	buf := new(bytes.Buffer)
+	enc := NewWALEncoder(buf)
+	ends := []int{}
+	for height := 1; height <= nHeights; height++ {
+		require.Nil(t, enc.Encode(&TimedWALMessage{time.Now(), timeoutInfo{time.Second, height, 0, RoundStepPropose}}))
+		ends = append(ends, buf.Len())
+		require.Nil(t, enc.Encode(&TimedWALMessage{time.Now(), EndHeightMessage{height}}))
+		ends = append(ends, buf.Len())
+	}
+	require.Nil(t, ioutil.WriteFile(walFile, buf.Bytes(), 0600))
+	return walFile, ends
+}
+
+func readAllWAL(t *testing.T, walFile string) []*TimedWALMessage {
+	rd, err := OpenWALReader(walFile)
+	require.Nil(t, err, "%+v", err)
+	defer rd.Close()
+
+	msgs := []*TimedWALMessage{}
+	for {
+		msg, err := rd.Decode()
+		if err == io.EOF {
+			return msgs
+		}
+		require.Nil(t, err, "%+v", err)
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestWALEncoderDecoder(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	now := time.Now().Round(time.Millisecond)
+	msgs := []*TimedWALMessage{
+		{now, EndHeightMessage{0}},
+		{now, timeoutInfo{time.Second, 1, 2, RoundStepPrevote}},
+	}
+
+	buf := new(bytes.Buffer)
+	enc := NewWALEncoder(buf)
+	for _, msg := range msgs {
+		require.Nil(enc.Encode(msg))
+	}
+
+	dec := NewWALDecoder(bytes.NewReader(buf.Bytes()))
+	for _, msg := range msgs {
+		decoded, err := dec.Decode()
+		require.Nil(err, "%+v", err)
+		assert.Equal(msg.Msg, decoded.Msg)
+		assert.True(msg.Time.Equal(decoded.Time))
+	}
+	_, err := dec.Decode()
+	assert.Equal(io.EOF, err)
+	assert.EqualValues(buf.Len(), dec.Offset())
+
+	// a flipped bit is caught by the checksum
+	data := buf.Bytes()
+	data[10] ^= 0x01
+	_, err = NewWALDecoder(bytes.NewReader(data)).Decode()
+	assert.True(IsDataCorruptionError(err), "%v", err)
+
+	// and a partial message is reported as such
+	_, err = NewWALDecoder(bytes.NewReader(data[:9])).Decode()
+	assert.Equal(io.ErrUnexpectedEOF, err)
+}
+
+func TestWALSearchForEndHeight(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	walFile, _ := writeTestWAL(t, 3)
+	defer os.RemoveAll(filepath.Dir(walFile))
+
+	rd, found, err := SearchForEndHeight(walFile, 1)
+	require.Nil(err, "%+v", err)
+	require.True(found)
+	defer rd.Close()
+	msg, err := rd.Decode()
+	require.Nil(err, "%+v", err)
+	assert.Equal(2, msg.Msg.(timeoutInfo).Height)
+
+	_, found, err = SearchForEndHeight(walFile, 4)
+	require.Nil(err, "%+v", err)
+	assert.False(found)
+}
+
+func TestWALTruncateCorruptedTail(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	walFile, ends := writeTestWAL(t, 3)
+	defer os.RemoveAll(filepath.Dir(walFile))
+	data, err := ioutil.ReadFile(walFile)
+	require.Nil(err, "%+v", err)
+
+	// a torn last write is cut off
+	require.Nil(ioutil.WriteFile(walFile, data[:len(data)-3], 0600))
+	truncated, err := truncateCorruptedTail(walFile)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(len(data)-3-ends[4], truncated)
+	assert.Len(readAllWAL(t, walFile), 5)
+
+	// and so is a last message with the wrong checksum
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1] ^= 0x01
+	require.Nil(ioutil.WriteFile(walFile, corrupted, 0600))
+	truncated, err = truncateCorruptedTail(walFile)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(len(data)-ends[4], truncated)
+
+	// an intact WAL is left alone
+	require.Nil(ioutil.WriteFile(walFile, data, 0600))
+	truncated, err = truncateCorruptedTail(walFile)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(0, truncated)
+	assert.Len(readAllWAL(t, walFile), 6)
+
+	// but corruption before the last message needs a repair
+	corrupted = append([]byte{}, data...)
+	corrupted[ends[1]+10] ^= 0x01
+	require.Nil(ioutil.WriteFile(walFile, corrupted, 0600))
+	_, err = truncateCorruptedTail(walFile)
+	assert.NotNil(err)
+
+	// even if its length runs past the end of the file
+	corrupted = append([]byte{}, data...)
+	corrupted[ends[1]+4] = 0xff
+	require.Nil(ioutil.WriteFile(walFile, corrupted, 0600))
+	_, err = truncateCorruptedTail(walFile)
+	assert.NotNil(err)
+	assert.Equal(corrupted, readFile(t, walFile), "nothing is truncated")
+}
+
+func TestWALTextFormat(t *testing.T) {
+	walFile, _ := writeTestWAL(t, 1)
+	defer os.RemoveAll(filepath.Dir(walFile))
+
+	for _, text := range []string{"#ENDHEIGHT: 0\n", `{"time":"2017-10-01T00:00:00Z","msg":[3,{}]}` + "\n"} {
+		require.Nil(t, ioutil.WriteFile(walFile, []byte(text), 0600))
+		_, err := truncateCorruptedTail(walFile)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err, "%+v", err)
+	return data
+}
+
+func TestWALRepair(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	walFile, ends := writeTestWAL(t, 3)
+	defer os.RemoveAll(filepath.Dir(walFile))
+	data, err := ioutil.ReadFile(walFile)
+	require.Nil(err, "%+v", err)
+
+	// corrupt the second height's timeout, and add some garbage
+	corrupted := append([]byte{}, data[:ends[3]]...)
+	corrupted[ends[1]+10] ^= 0x01
+	corrupted = append(corrupted, []byte("garbage")...)
+	corrupted = append(corrupted, data[ends[3]:]...)
+	require.Nil(ioutil.WriteFile(walFile, corrupted, 0600))
+
+	removed, err := RepairWAL(walFile)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(ends[2]-ends[1]+len("garbage"), removed)
+
+	// everything else is kept
+	msgs := readAllWAL(t, walFile)
+	require.Len(msgs, 5)
+	assert.Equal(EndHeightMessage{2}, msgs[2].Msg)
+	assert.Equal(3, msgs[3].Msg.(timeoutInfo).Height)
+
+	// and the original is backed up
+	backup, err := ioutil.ReadFile(walFile + ".corrupted")
+	require.Nil(err, "%+v", err)
+	assert.Equal(corrupted, backup)
+
+	// the backup isn't read as part of the WAL
+	removed, err = RepairWAL(walFile)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues(0, removed)
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+// all keys are in one bucket
+var boltBucket = []byte("tm")
+
+var _ dbm.DB = (*BoltDB)(nil)
+var _ RangeIterator = (*BoltDB)(nil)
+
+// BoltDB is a dbm.DB stored in a BoltDB file.
+// Every write is a bolt transaction, so Set and SetSync are the same.
+type BoltDB struct {
+	db *bolt.DB
+}
+
+// NewBoltDB opens or creates the BoltDB file.
+func NewBoltDB(file string) (*BoltDB, error) {
+	db, err := bolt.Open(file, 0600, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error opening BoltDB %v", file)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "Error creating bucket in BoltDB %v", file)
+	}
+	return &BoltDB{db: db}, nil
+}
+
+// Get implements dbm.DB. It returns nil if the key doesn't exist.
+func (bdb *BoltDB) Get(key []byte) []byte {
+	var value []byte
+	bdb.db.View(func(tx *bolt.Tx) error {
+		// the value is only valid during the transaction
+		if v := tx.Bucket(boltBucket).Get(key); v != nil {
+			value = append([]byte{}, v...)
+		}
+		return nil
+	})
+	return value
+}
+
+// Set implements dbm.DB.
+func (bdb *BoltDB) Set(key []byte, value []byte) {
+	bdb.update(func(b *bolt.Bucket) error {
+		return b.Put(key, value)
+	})
+}
+
+// SetSync implements dbm.DB.
+func (bdb *BoltDB) SetSync(key []byte, value []byte) {
+	bdb.Set(key, value)
+}
+
+// Delete implements dbm.DB.
+func (bdb *BoltDB) Delete(key []byte) {
+	bdb.update(func(b *bolt.Bucket) error {
+		return b.Delete(key)
+	})
+}
+
+// DeleteSync implements dbm.DB.
+func (bdb *BoltDB) DeleteSync(key []byte) {
+	bdb.Delete(key)
+}
+
+func (bdb *BoltDB) update(fn func(b *bolt.Bucket) error) {
+	err := bdb.db.Update(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(boltBucket))
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Close implements dbm.DB.
+func (bdb *BoltDB) Close() {
+	bdb.db.Close()
+}
+
+// Print implements dbm.DB.
+func (bdb *BoltDB) Print() {
+	it := bdb.Iterator()
+	defer Release(it)
+	for it.Next() {
+		fmt.Printf("[%X]:\t[%X]\n", it.Key(), it.Value())
+	}
+}
+
+// Stats implements dbm.DB.
+func (bdb *BoltDB) Stats() map[string]string {
+	stats := bdb.db.Stats()
+	return map[string]string{
+		"bolt.freepages":     fmt.Sprintf("%v", stats.FreePageN),
+		"bolt.pendingpages":  fmt.Sprintf("%v", stats.PendingPageN),
+		"bolt.freealloc":     fmt.Sprintf("%v", stats.FreeAlloc),
+		"bolt.freelistinuse": fmt.Sprintf("%v", stats.FreelistInuse),
+		"bolt.txn":           fmt.Sprintf("%v", stats.TxN),
+		"bolt.opentxn":       fmt.Sprintf("%v", stats.OpenTxN),
+	}
+}
+
+// NewBatch implements dbm.DB.
+func (bdb *BoltDB) NewBatch() dbm.Batch {
+	return &boltBatch{db: bdb}
+}
+
+// Iterator implements dbm.DB. It iterates over a cursor in a read-only
+// transaction, which stays open until the iterator is released.
+// NOTE: don't write to the database from the same goroutine before then,
+// bolt can't grow the file while a read-only transaction is open.
+func (bdb *BoltDB) Iterator() dbm.Iterator {
+	return bdb.IteratorRange(nil, nil)
+}
+
+// IteratorRange implements RangeIterator, with the same caveat as Iterator.
+func (bdb *BoltDB) IteratorRange(start, end []byte) dbm.Iterator {
+	tx, err := bdb.db.Begin(false)
+	if err != nil {
+		return &boltIterator{err: err}
+	}
+	return &boltIterator{
+		tx:     tx,
+		cursor: tx.Bucket(boltBucket).Cursor(),
+		start:  start,
+		end:    end,
+	}
+}
+
+//----------------------------------------
+
+type boltOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// boltBatch writes all its operations in one transaction.
+type boltBatch struct {
+	db  *BoltDB
+	ops []boltOp
+}
+
+func (bb *boltBatch) Set(key, value []byte) {
+	bb.ops = append(bb.ops, boltOp{key: key, value: value})
+}
+
+func (bb *boltBatch) Delete(key []byte) {
+	bb.ops = append(bb.ops, boltOp{key: key, delete: true})
+}
+
+func (bb *boltBatch) Write() {
+	bb.db.update(func(b *bolt.Bucket) error {
+		for _, op := range bb.ops {
+			var err error
+			if op.delete {
+				err = b.Delete(op.key)
+			} else {
+				err = b.Put(op.key, op.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//----------------------------------------
+
+type boltIterator struct {
+	tx         *bolt.Tx
+	cursor     *bolt.Cursor
+	start, end []byte
+	started    bool
+	key, value []byte
+	err        error
+}
+
+func (it *boltIterator) Next() bool {
+	if it.cursor == nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		if it.start == nil {
+			it.key, it.value = it.cursor.First()
+		} else {
+			it.key, it.value = it.cursor.Seek(it.start)
+		}
+	} else {
+		it.key, it.value = it.cursor.Next()
+	}
+	if it.key == nil || (it.end != nil && bytes.Compare(it.key, it.end) >= 0) {
+		it.Release()
+		return false
+	}
+	return true
+}
+
+// Key returns the current key. It's only valid until the iterator moves.
+func (it *boltIterator) Key() []byte {
+	return it.key
+}
+
+// Value returns the current value. It's only valid until the iterator moves.
+func (it *boltIterator) Value() []byte {
+	return it.value
+}
+
+func (it *boltIterator) Release() {
+	if it.tx != nil {
+		it.tx.Rollback()
+		it.tx, it.cursor = nil, nil
+	}
+	it.key, it.value = nil, nil
+}
+
+func (it *boltIterator) Error() error {
+	return it.err
+}
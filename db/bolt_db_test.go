@@ -0,0 +1,105 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+func TestBoltDB(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	dir, err := ioutil.TempDir("", "boltdb_test")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	db, err := NewBoltDB(filepath.Join(dir, "test.bolt"))
+	require.Nil(err, "%+v", err)
+	defer db.Close()
+
+	assert.Nil(db.Get([]byte("a")))
+	db.Set([]byte("a"), []byte("1"))
+	db.SetSync([]byte("b"), []byte("2"))
+	assert.Equal([]byte("1"), db.Get([]byte("a")))
+	db.Delete([]byte("a"))
+	assert.Nil(db.Get([]byte("a")))
+
+	batch := db.NewBatch()
+	batch.Set([]byte("c"), []byte("3"))
+	batch.Set([]byte("d"), []byte("4"))
+	batch.Delete([]byte("b"))
+	assert.Nil(db.Get([]byte("c")), "batch shouldn't be written yet")
+	batch.Write()
+
+	// keys are iterated in order
+	it := db.Iterator().(*boltIterator)
+	keys := []string{}
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Release()
+	assert.Nil(it.Error())
+	assert.Equal([]string{"c", "d"}, keys)
+
+	// and can be written once the iterator is released
+	db.Delete([]byte("c"))
+	db.Delete([]byte("d"))
+	assert.True(IsEmpty(db))
+}
+
+func TestIteratorRange(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	dir, err := ioutil.TempDir("", "boltdb_test")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	boltDB, err := NewBoltDB(filepath.Join(dir, "test.bolt"))
+	require.Nil(err, "%+v", err)
+	defer boltDB.Close()
+
+	for _, db := range []dbm.DB{boltDB, dbm.NewMemDB()} {
+		for _, k := range []string{"a/1", "a/2", "b/1", "b/2", "c"} {
+			db.Set([]byte(k), []byte(k))
+		}
+		keys := map[string]bool{}
+		it := IteratorPrefix(db, []byte("b/"))
+		for it.Next() {
+			keys[string(it.Key())] = true
+		}
+		Release(it)
+		assert.Equal(map[string]bool{"b/1": true, "b/2": true}, keys)
+
+		keys = map[string]bool{}
+		it = IteratorRange(db, []byte("a/2"), nil)
+		for it.Next() {
+			keys[string(it.Key())] = true
+		}
+		Release(it)
+		assert.Equal(map[string]bool{"a/2": true, "b/1": true, "b/2": true, "c": true}, keys)
+	}
+
+	assert.Equal([]byte("b0"), PrefixEnd([]byte("b/")))
+	assert.Equal([]byte("c"), PrefixEnd([]byte{'b', 0xff}))
+	assert.Nil(PrefixEnd([]byte{0xff}))
+}
+
+func TestCopy(t *testing.T) {
+	assert := assert.New(t)
+
+	src, dst := dbm.NewMemDB(), dbm.NewMemDB()
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		src.Set([]byte(k), []byte(k+k))
+	}
+	assert.Equal(5, Copy(dst, src, 2))
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		assert.Equal([]byte(k+k), dst.Get([]byte(k)))
+	}
+
+	assert.True(SameFiles("leveldb", "goleveldb"))
+	assert.False(SameFiles("leveldb", BoltDBBackend))
+}
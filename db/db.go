@@ -0,0 +1,82 @@
+/*
+Package db opens the databases of a node with the backend configured for
+each of them. It adds Go-native backends to the ones tmlibs/db provides.
+*/
+package db
+
+import (
+	"path/filepath"
+
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+const (
+	// BoltDBBackend is a Go-native B+tree store in a single file.
+	BoltDBBackend = "boltdb"
+)
+
+// NewDB opens the named database in dir with the given backend.
+// It panics if the database can't be opened, like dbm.NewDB.
+func NewDB(name, backend, dir string) dbm.DB {
+	switch backend {
+	case BoltDBBackend:
+		db, err := NewBoltDB(filepath.Join(dir, name+".bolt"))
+		if err != nil {
+			panic(err)
+		}
+		return db
+	default:
+		return dbm.NewDB(name, backend, dir)
+	}
+}
+
+// SameFiles returns true if the two backends store their data in the same
+// files, so a database can't be copied from one to the other.
+func SameFiles(backend1, backend2 string) bool {
+	return family(backend1) == family(backend2)
+}
+
+func family(backend string) string {
+	switch backend {
+	case "leveldb", "goleveldb", "cleveldb":
+		return "leveldb"
+	default:
+		return backend
+	}
+}
+
+// Copy copies all the keys and values of src to dst, in batches of
+// batchSize. It returns the number of keys copied.
+func Copy(dst, src dbm.DB, batchSize int) int {
+	n := 0
+	batch := dst.NewBatch()
+	it := IteratorRange(src, nil, nil)
+	defer Release(it)
+	for it.Next() {
+		batch.Set(append([]byte{}, it.Key()...), append([]byte{}, it.Value()...))
+		n++
+		if n%batchSize == 0 {
+			batch.Write()
+			batch = dst.NewBatch()
+		}
+	}
+	batch.Write()
+	return n
+}
+
+// IsEmpty returns true if the database has no keys.
+func IsEmpty(db dbm.DB) bool {
+	it := IteratorRange(db, nil, nil)
+	defer Release(it)
+	return !it.Next()
+}
+
+// Release releases the iterator, if it holds resources, like the goleveldb
+// and BoltDB ones. The dbm.Iterator interface has no Release.
+func Release(it dbm.Iterator) {
+	if it, ok := it.(interface {
+		Release()
+	}); ok {
+		it.Release()
+	}
+}
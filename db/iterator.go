@@ -0,0 +1,117 @@
+package db
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+// RangeIterator is implemented by databases which can iterate over a range
+// of keys without going through all of them.
+type RangeIterator interface {
+	// IteratorRange iterates over the keys in [start, end), in order.
+	// A nil end means there is no upper bound.
+	IteratorRange(start, end []byte) dbm.Iterator
+}
+
+// IteratorRange returns an iterator over the keys of db in [start, end).
+// A nil end means there is no upper bound. Backends which can't seek
+// iterate over all the keys and skip the ones out of range, in which case
+// the keys aren't necessarily in order.
+func IteratorRange(db dbm.DB, start, end []byte) dbm.Iterator {
+	switch db := db.(type) {
+	case RangeIterator:
+		return db.IteratorRange(start, end)
+	case *dbm.GoLevelDB:
+		return db.DB().NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	case *dbm.MemDB:
+		return newMemDBIterator(db, start, end)
+	}
+	return &filterIterator{Iterator: db.Iterator(), start: start, end: end}
+}
+
+// IteratorPrefix returns an iterator over the keys of db starting with prefix.
+func IteratorPrefix(db dbm.DB, prefix []byte) dbm.Iterator {
+	return IteratorRange(db, prefix, PrefixEnd(prefix))
+}
+
+// PrefixEnd returns the first key after all the keys starting with prefix,
+// or nil if there is none.
+func PrefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// filterIterator skips the keys of the underlying iterator which are out of
+// [start, end).
+type filterIterator struct {
+	dbm.Iterator
+	start, end []byte
+}
+
+func (it *filterIterator) Next() bool {
+	for it.Iterator.Next() {
+		key := it.Key()
+		if bytes.Compare(key, it.start) >= 0 && (it.end == nil || bytes.Compare(key, it.end) < 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// memDBIterator iterates over a copy of the keys of a MemDB in [start, end),
+// in order.
+type memDBIterator struct {
+	db   *dbm.MemDB
+	keys []string
+	cur  int
+}
+
+func newMemDBIterator(db *dbm.MemDB, start, end []byte) *memDBIterator {
+	keys := []string{}
+	for _, key := range memDBKeys(db) {
+		if key >= string(start) && (end == nil || key < string(end)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return &memDBIterator{db: db, keys: keys, cur: -1}
+}
+
+// memDBKeys returns the keys of the MemDB. Its own iterator says there is
+// one more key after the last one, and panics when it's read, so we stop
+// there.
+func memDBKeys(db *dbm.MemDB) (keys []string) {
+	defer func() {
+		recover()
+	}()
+	it := db.Iterator()
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	return keys
+}
+
+func (it *memDBIterator) Next() bool {
+	if it.cur+1 >= len(it.keys) {
+		return false
+	}
+	it.cur++
+	return true
+}
+
+func (it *memDBIterator) Key() []byte {
+	return []byte(it.keys[it.cur])
+}
+
+func (it *memDBIterator) Value() []byte {
+	return it.db.Get(it.Key())
+}
@@ -0,0 +1,139 @@
+package evidence
+
+import (
+	"fmt"
+	"sync"
+
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tendermint/tmlibs/clist"
+	dbm "github.com/tendermint/tmlibs/db"
+	"github.com/tendermint/tmlibs/log"
+)
+
+// EvidencePool maintains a pool of valid evidence
+// in an EvidenceStore.
+type EvidencePool struct {
+	logger log.Logger
+
+	evidenceStore *EvidenceStore
+
+	// the latest state is used to verify evidence
+	stateDB dbm.DB
+
+	// pending evidence, in the order it was added, for the reactor to gossip
+	mtx          sync.Mutex
+	evidenceList *clist.CList
+}
+
+// NewEvidencePool returns a new EvidencePool. Evidence is verified against
+// the latest state in the given stateDB. Pending evidence is loaded from the
+// store, so it keeps being gossiped after a restart.
+func NewEvidencePool(stateDB dbm.DB, evidenceStore *EvidenceStore) *EvidencePool {
+	evpool := &EvidencePool{
+		logger:        log.NewNopLogger(),
+		evidenceStore: evidenceStore,
+		stateDB:       stateDB,
+		evidenceList:  clist.New(),
+	}
+	for _, ev := range evidenceStore.PendingEvidence() {
+		evpool.evidenceList.PushBack(ev)
+	}
+	return evpool
+}
+
+// SetLogger sets the Logger.
+func (evpool *EvidencePool) SetLogger(l log.Logger) {
+	evpool.logger = l
+}
+
+// EvidenceFrontWait returns the first pending evidence for peer goroutines
+// to call .NextWait() on. It blocks until there is some.
+func (evpool *EvidencePool) EvidenceFrontWait() *clist.CElement {
+	return evpool.evidenceList.FrontWait()
+}
+
+// PendingEvidence returns all uncommitted evidence, highest priority first.
+func (evpool *EvidencePool) PendingEvidence() []types.Evidence {
+	return evpool.evidenceStore.PendingEvidence()
+}
+
+// AddEvidence checks the evidence is valid and adds it to the pool.
+// Evidence we've seen before is ignored.
+func (evpool *EvidencePool) AddEvidence(evidence types.Evidence) error {
+	evpool.mtx.Lock()
+	defer evpool.mtx.Unlock()
+
+	if err := evidence.ValidateBasic(); err != nil {
+		return types.NewEvidenceInvalidErr(evidence, err)
+	}
+
+	// check if we have seen it before
+	if ei := evpool.evidenceStore.GetEvidence(evidence.Height(), evidence.Hash()); ei != nil {
+		return nil
+	}
+
+	state, err := evpool.loadState()
+	if err != nil {
+		return err
+	}
+
+	priority, err := state.VerifyEvidence(evidence)
+	if err != nil {
+		return types.NewEvidenceInvalidErr(evidence, err)
+	}
+
+	if !evpool.evidenceStore.AddNewEvidence(evidence, priority) {
+		return nil
+	}
+
+	evpool.logger.Info("Verified new evidence of byzantine behaviour", "evidence", evidence)
+
+	// add evidence to be gossiped with peers
+	evpool.evidenceList.PushBack(evidence)
+
+	return nil
+}
+
+// Update marks all the evidence in the block as committed and removes it
+// from the pool, along with any evidence that can no longer be verified
+// (and thus never be committed) at the new height.
+// NOTE: it must be called after the state for the block was saved.
+func (evpool *EvidencePool) Update(block *types.Block) {
+	evpool.mtx.Lock()
+	defer evpool.mtx.Unlock()
+
+	committed := block.Evidence.Evidence
+	for _, ev := range committed {
+		evpool.evidenceStore.MarkEvidenceAsCommitted(ev)
+	}
+
+	state, err := evpool.loadState()
+	if err != nil {
+		evpool.logger.Error("Failed to update the evidence pool", "err", err)
+		return
+	}
+
+	for e := evpool.evidenceList.Front(); e != nil; e = e.Next() {
+		ev := e.Value.(types.Evidence)
+		if committed.Has(ev) {
+			evpool.evidenceList.Remove(e)
+			e.DetachPrev()
+			continue
+		}
+		if _, err := state.VerifyEvidence(ev); err != nil {
+			evpool.logger.Info("Dropping expired evidence", "evidence", ev, "err", err)
+			evpool.evidenceStore.RemovePendingEvidence(ev)
+			evpool.evidenceList.Remove(e)
+			e.DetachPrev()
+		}
+	}
+}
+
+func (evpool *EvidencePool) loadState() (*sm.State, error) {
+	state := sm.LoadState(evpool.stateDB)
+	if state == nil {
+		return nil, fmt.Errorf("No state to verify evidence against")
+	}
+	return state, nil
+}
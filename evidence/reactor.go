@@ -0,0 +1,178 @@
+package evidence
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"time"
+
+	wire "github.com/tendermint/go-wire"
+	"github.com/tendermint/tmlibs/clist"
+
+	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/types"
+)
+
+const (
+	EvidenceChannel = byte(0x38)
+
+	maxEvidenceMessageSize     = 1048576 // 1MB TODO make it configurable
+	peerCatchupSleepIntervalMS = 100     // If peer is behind, sleep this amount
+)
+
+// EvidenceReactor handles evidence gossiping amongst peers.
+type EvidenceReactor struct {
+	p2p.BaseReactor
+	evpool *EvidencePool
+	evsw   types.EventSwitch
+}
+
+// NewEvidenceReactor returns a new EvidenceReactor with the given pool.
+func NewEvidenceReactor(evpool *EvidencePool) *EvidenceReactor {
+	evR := &EvidenceReactor{
+		evpool: evpool,
+	}
+	evR.BaseReactor = *p2p.NewBaseReactor("EvidenceReactor", evR)
+	return evR
+}
+
+// EvidencePool returns the reactor's pool.
+func (evR *EvidenceReactor) EvidencePool() *EvidencePool {
+	return evR.evpool
+}
+
+// GetChannels implements Reactor.
+// It returns the list of channels for this reactor.
+func (evR *EvidenceReactor) GetChannels() []*p2p.ChannelDescriptor {
+	return []*p2p.ChannelDescriptor{
+		&p2p.ChannelDescriptor{
+			ID:       EvidenceChannel,
+			Priority: 5,
+		},
+	}
+}
+
+// AddPeer implements Reactor.
+// It starts a broadcast routine ensuring all pending evidence is forwarded to the given peer.
+func (evR *EvidenceReactor) AddPeer(peer *p2p.Peer) {
+	go evR.broadcastEvidenceRoutine(peer)
+}
+
+// RemovePeer implements Reactor.
+func (evR *EvidenceReactor) RemovePeer(peer *p2p.Peer, reason interface{}) {
+	// broadcast routine checks if peer is gone and returns
+}
+
+// Receive implements Reactor.
+// It adds any received evidence to the pool.
+func (evR *EvidenceReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte) {
+	_, msg, err := DecodeMessage(msgBytes)
+	if err != nil {
+		evR.Logger.Error("Error decoding message", "err", err)
+		return
+	}
+	evR.Logger.Debug("Receive", "src", src, "chId", chID, "msg", msg)
+
+	switch msg := msg.(type) {
+	case *EvidenceListMessage:
+		for _, ev := range msg.Evidence {
+			// malformed evidence can't be explained by the peer's height
+			if err := ev.ValidateBasic(); err != nil {
+				evR.Logger.Error("Peer sent malformed evidence", "peer", src, "err", err)
+				evR.Switch.StopPeerForError(src, err)
+				return
+			}
+			err := evR.evpool.AddEvidence(ev)
+			if err != nil {
+				// NOTE: we don't punish the peer, as we may simply be at a
+				// height where the evidence can't be verified.
+				evR.Logger.Info("Could not add evidence", "evidence", ev, "err", err)
+			}
+		}
+	default:
+		evR.Logger.Error(fmt.Sprintf("Unknown message type %v", reflect.TypeOf(msg)))
+	}
+}
+
+// SetEventSwitch implements events.Eventable.
+func (evR *EvidenceReactor) SetEventSwitch(evsw types.EventSwitch) {
+	evR.evsw = evsw
+}
+
+// PeerState describes the state of a peer.
+type PeerState interface {
+	GetHeight() int
+}
+
+// Send pending evidence to peer.
+// As with the mempool, this routine may block forever if no new evidence comes in.
+func (evR *EvidenceReactor) broadcastEvidenceRoutine(peer *p2p.Peer) {
+	var next *clist.CElement
+	for {
+		if !evR.IsRunning() || !peer.IsRunning() {
+			return // Quit!
+		}
+		if next == nil {
+			// This happens because the CElement we were looking at got
+			// garbage collected (removed).  That is, .NextWait() returned nil.
+			// Go ahead and start from the beginning.
+			next = evR.evpool.EvidenceFrontWait() // Wait until evidence is available
+		}
+		ev := next.Value.(types.Evidence)
+
+		// make sure the peer is up to date, so it can verify the evidence
+		if peerState_i := peer.Get(types.PeerStateKey); peerState_i != nil {
+			peerState := peerState_i.(PeerState)
+			if peerState.GetHeight() < ev.Height()-1 {
+				time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
+				continue
+			}
+		}
+
+		// send evidence
+		msg := &EvidenceListMessage{[]types.Evidence{ev}}
+		success := peer.Send(EvidenceChannel, struct{ EvidenceMessage }{msg})
+		if !success {
+			time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
+			continue
+		}
+
+		next = next.NextWait()
+	}
+}
+
+//-----------------------------------------------------------------------------
+// Messages
+
+const (
+	msgTypeEvidence = byte(0x01)
+)
+
+// EvidenceMessage is a message sent or received by the EvidenceReactor.
+type EvidenceMessage interface{}
+
+var _ = wire.RegisterInterface(
+	struct{ EvidenceMessage }{},
+	wire.ConcreteType{&EvidenceListMessage{}, msgTypeEvidence},
+)
+
+// DecodeMessage decodes a byte-array into a EvidenceMessage.
+func DecodeMessage(bz []byte) (msgType byte, msg EvidenceMessage, err error) {
+	msgType = bz[0]
+	n := new(int)
+	r := bytes.NewReader(bz)
+	msg = wire.ReadBinary(struct{ EvidenceMessage }{}, r, maxEvidenceMessageSize, n, &err).(struct{ EvidenceMessage }).EvidenceMessage
+	return
+}
+
+//-------------------------------------
+
+// EvidenceListMessage contains a list of evidence.
+type EvidenceListMessage struct {
+	Evidence []types.Evidence
+}
+
+// String returns a string representation of the EvidenceListMessage.
+func (m *EvidenceListMessage) String() string {
+	return fmt.Sprintf("[EvidenceListMessage %v]", m.Evidence)
+}
@@ -0,0 +1,166 @@
+package evidence
+
+import (
+	"fmt"
+	"sort"
+
+	wire "github.com/tendermint/go-wire"
+	tmdb "github.com/tendermint/tendermint/db"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+/*
+Requirements:
+	- Valid new evidence must be persisted immediately and never forgotten
+	- Uncommitted evidence must be continuously broadcast
+	- Uncommitted evidence has a partial order, the evidence's priority
+
+Impl:
+	- First commit atomically in pending and lookup.
+	- Once committed, atomically remove from pending and update lookup.
+
+Schema for indexing evidence (note you need both height and hash to find a piece of evidence):
+
+"evidence-lookup"/<evidence-height>/<evidence-hash> -> EvidenceInfo
+"evidence-pending"/<evidence-height>/<evidence-hash> -> EvidenceInfo
+*/
+
+const (
+	baseKeyLookup  = "evidence-lookup"  // all evidence
+	baseKeyPending = "evidence-pending" // evidence that has not been committed
+)
+
+// EvidenceInfo is the value stored for each piece of evidence.
+type EvidenceInfo struct {
+	Committed bool
+	Priority  int64
+	Evidence  types.Evidence
+}
+
+func keyLookup(evidence types.Evidence) []byte {
+	return _key(baseKeyLookup, evidence)
+}
+
+func keyPending(evidence types.Evidence) []byte {
+	return _key(baseKeyPending, evidence)
+}
+
+func _key(prefix string, evidence types.Evidence) []byte {
+	return []byte(fmt.Sprintf("%s/%010d/%X", prefix, evidence.Height(), evidence.Hash()))
+}
+
+// EvidenceStore is a store of all the evidence we've seen, including
+// evidence that has been committed and evidence that is pending.
+type EvidenceStore struct {
+	db dbm.DB
+}
+
+// NewEvidenceStore returns a new EvidenceStore backed by the given db.
+func NewEvidenceStore(db dbm.DB) *EvidenceStore {
+	return &EvidenceStore{
+		db: db,
+	}
+}
+
+// PendingEvidence returns all known uncommitted evidence,
+// ordered by priority (highest first).
+func (store *EvidenceStore) PendingEvidence() (evidence []types.Evidence) {
+	infos := store.listEvidence(baseKeyPending)
+	sort.Sort(evidenceInfos(infos))
+	for _, ei := range infos {
+		evidence = append(evidence, ei.Evidence)
+	}
+	return evidence
+}
+
+// listEvidence lists the EvidenceInfo stored under the given prefix.
+func (store *EvidenceStore) listEvidence(prefix string) []EvidenceInfo {
+	var infos []EvidenceInfo
+	prefixBytes := []byte(prefix + "/")
+
+	iter := tmdb.IteratorPrefix(store.db, prefixBytes)
+	defer tmdb.Release(iter)
+	for iter.Next() {
+		var ei EvidenceInfo
+		if err := wire.ReadBinaryBytes(iter.Value(), &ei); err != nil {
+			panic(fmt.Sprintf("Corrupted evidence under key %s: %v", iter.Key(), err))
+		}
+		infos = append(infos, ei)
+	}
+	return infos
+}
+
+// GetEvidence fetches the evidence with the given height and hash.
+// It returns nil if the evidence is unknown.
+func (store *EvidenceStore) GetEvidence(height int, hash []byte) *EvidenceInfo {
+	key := []byte(fmt.Sprintf("%s/%010d/%X", baseKeyLookup, height, hash))
+	val := store.db.Get(key)
+	if len(val) == 0 {
+		return nil
+	}
+	ei := new(EvidenceInfo)
+	if err := wire.ReadBinaryBytes(val, ei); err != nil {
+		panic(fmt.Sprintf("Corrupted evidence under key %s: %v", key, err))
+	}
+	return ei
+}
+
+// AddNewEvidence adds the given evidence to the database.
+// It returns false if the evidence is already stored.
+func (store *EvidenceStore) AddNewEvidence(evidence types.Evidence, priority int64) bool {
+	// check if we already have seen it
+	if ei := store.GetEvidence(evidence.Height(), evidence.Hash()); ei != nil {
+		return false
+	}
+
+	ei := EvidenceInfo{
+		Committed: false,
+		Priority:  priority,
+		Evidence:  evidence,
+	}
+	eiBytes := wire.BinaryBytes(ei)
+
+	batch := store.db.NewBatch()
+	batch.Set(keyPending(evidence), eiBytes)
+	batch.Set(keyLookup(evidence), eiBytes)
+	batch.Write()
+
+	return true
+}
+
+// MarkEvidenceAsCommitted removes evidence from pending and sets the lookup to committed.
+func (store *EvidenceStore) MarkEvidenceAsCommitted(evidence types.Evidence) {
+	// if its committed, its been broadcast
+	ei := store.GetEvidence(evidence.Height(), evidence.Hash())
+	if ei == nil {
+		// we never saw it before; still remember it was committed
+		ei = &EvidenceInfo{Evidence: evidence}
+	}
+	ei.Committed = true
+
+	batch := store.db.NewBatch()
+	batch.Delete(keyPending(evidence))
+	batch.Set(keyLookup(evidence), wire.BinaryBytes(*ei))
+	batch.Write()
+}
+
+// RemovePendingEvidence removes evidence which can no longer be committed
+// (eg. because it is too old) from pending. It is kept in the lookup.
+func (store *EvidenceStore) RemovePendingEvidence(evidence types.Evidence) {
+	store.db.DeleteSync(keyPending(evidence))
+}
+
+//---------------------------------------------------
+
+// evidenceInfos sorts EvidenceInfo by priority, highest first
+type evidenceInfos []EvidenceInfo
+
+func (eis evidenceInfos) Len() int      { return len(eis) }
+func (eis evidenceInfos) Swap(i, j int) { eis[i], eis[j] = eis[j], eis[i] }
+func (eis evidenceInfos) Less(i, j int) bool {
+	if eis[i].Priority != eis[j].Priority {
+		return eis[i].Priority > eis[j].Priority
+	}
+	return eis[i].Evidence.Height() < eis[j].Evidence.Height()
+}
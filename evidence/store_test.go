@@ -0,0 +1,123 @@
+package evidence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+//-------------------------------------------
+
+func newEvidence(val *types.PrivValidator, height int, chainID string) types.Evidence {
+	vote1 := &types.Vote{
+		ValidatorAddress: val.GetAddress(),
+		ValidatorIndex:   0,
+		Height:           height,
+		Round:            2,
+		Timestamp:        time.Now(),
+		Type:             types.VoteTypePrevote,
+		BlockID:          types.BlockID{Hash: []byte("blockhash1")},
+	}
+	vote2 := &types.Vote{
+		ValidatorAddress: val.GetAddress(),
+		ValidatorIndex:   0,
+		Height:           height,
+		Round:            2,
+		Timestamp:        time.Now(),
+		Type:             types.VoteTypePrevote,
+		BlockID:          types.BlockID{Hash: []byte("blockhash2")},
+	}
+	// sign directly, as the PrivValidator refuses to double sign
+	vote1.Signature = val.Sign(types.SignBytes(chainID, vote1))
+	vote2.Signature = val.Sign(types.SignBytes(chainID, vote2))
+	return types.NewDuplicateVoteEvidence(val.PubKey, &types.ErrVoteConflictingVotes{VoteA: vote1, VoteB: vote2})
+}
+
+func TestStoreAddDuplicate(t *testing.T) {
+	assert := assert.New(t)
+
+	db := dbm.NewMemDB()
+	store := NewEvidenceStore(db)
+
+	priority := int64(10)
+	ev := newEvidence(types.GenPrivValidator(), 1, "chain")
+
+	added := store.AddNewEvidence(ev, priority)
+	assert.True(added)
+
+	// cant add twice
+	added = store.AddNewEvidence(ev, priority)
+	assert.False(added)
+}
+
+func TestStoreMark(t *testing.T) {
+	assert := assert.New(t)
+
+	db := dbm.NewMemDB()
+	store := NewEvidenceStore(db)
+
+	// before we do anything, pending is empty
+	assert.Equal(0, len(store.PendingEvidence()))
+
+	priority := int64(10)
+	ev := newEvidence(types.GenPrivValidator(), 1, "chain")
+
+	added := store.AddNewEvidence(ev, priority)
+	assert.True(added)
+
+	// get the evidence. verify. should be uncommitted
+	ei := store.GetEvidence(ev.Height(), ev.Hash())
+	assert.True(ev.Equal(ei.Evidence))
+	assert.Equal(priority, ei.Priority)
+	assert.False(ei.Committed)
+
+	// new evidence should be returns in pending
+	pendingEv := store.PendingEvidence()
+	assert.Equal(1, len(pendingEv))
+	assert.True(ev.Equal(pendingEv[0]))
+
+	// mark the evidence committed
+	store.MarkEvidenceAsCommitted(ev)
+
+	// evidence should get removed from pending
+	assert.Equal(0, len(store.PendingEvidence()))
+
+	// evidence should show committed
+	ei = store.GetEvidence(ev.Height(), ev.Hash())
+	assert.True(ev.Equal(ei.Evidence))
+	assert.Equal(priority, ei.Priority)
+	assert.True(ei.Committed)
+}
+
+func TestStorePriority(t *testing.T) {
+	assert := assert.New(t)
+
+	db := dbm.NewMemDB()
+	store := NewEvidenceStore(db)
+
+	// sorted by priority
+	cases := []struct {
+		ev       types.Evidence
+		priority int64
+	}{
+		{newEvidence(types.GenPrivValidator(), 1, "chain"), 17},
+		{newEvidence(types.GenPrivValidator(), 2, "chain"), 15},
+		{newEvidence(types.GenPrivValidator(), 3, "chain"), 100},
+		{newEvidence(types.GenPrivValidator(), 4, "chain"), 42},
+	}
+	expected := []int{2, 3, 0, 1}
+
+	for _, c := range cases {
+		added := store.AddNewEvidence(c.ev, c.priority)
+		assert.True(added)
+	}
+
+	evList := store.PendingEvidence()
+	for i, ev := range evList {
+		assert.True(cases[expected[i]].ev.Equal(ev), "case %d", i)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Package lite allows you to securely validate headers without a full node.
+
+It starts from a trusted validator set and header (a FullCommit, eg. taken
+from the genesis or from a source you trust), and from there certifies any
+(Header, Commit) pair signed by +2/3 of the trusted validators.
+
+When the validator set changes, the Certifier learns the new set from a
+Provider. As long as +2/3 of the trusted voting power signed for the new
+set, it can be trusted in one step. If more than 1/3 of the power changed,
+the Certifier bisects the range of heights, trusting one intermediate set
+after another, until it reaches the new one.
+
+Once you trust a header, you can verify anything committed to in it, eg.
+the app state through merkle proofs against the AppHash (see lite/proxy).
+*/
+package lite
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Provider is a source of FullCommits, eg. a full node (see lite/client).
+// The commits it returns don't need to be trusted, the Certifier checks them.
+type Provider interface {
+	// GetByHeight returns the FullCommit for the given height.
+	GetByHeight(height int) (FullCommit, error)
+	// LatestCommit returns the newest FullCommit.
+	LatestCommit() (FullCommit, error)
+}
+
+// Certifier holds a trusted validator set and header, and uses them to
+// certify new headers. It is safe for concurrent use.
+type Certifier struct {
+	chainID string
+	source  Provider
+
+	mtx     sync.Mutex
+	trusted FullCommit
+}
+
+// NewCertifier returns a Certifier for the chain which trusts the given
+// FullCommit. Validator set changes are followed with commits from source.
+// The trusted commit must be valid on its own, but where it comes from is up
+// to the caller.
+func NewCertifier(chainID string, trusted FullCommit, source Provider) (*Certifier, error) {
+	if err := trusted.Verify(chainID); err != nil {
+		return nil, errors.Wrap(err, "Invalid trusted commit")
+	}
+	return &Certifier{
+		chainID: chainID,
+		source:  source,
+		trusted: trusted,
+	}, nil
+}
+
+// ChainID returns the chain the Certifier certifies headers for.
+func (c *Certifier) ChainID() string {
+	return c.chainID
+}
+
+// LastHeight returns the height of the latest trusted commit.
+func (c *Certifier) LastHeight() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.trusted.Height()
+}
+
+// Trusted returns the latest trusted FullCommit.
+func (c *Certifier) Trusted() FullCommit {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.trusted
+}
+
+// Certify checks that the commit was signed by +2/3 of the trusted validators.
+// If it was signed by a different validator set, the Certifier first tries
+// to update its trusted validators up to the commit's height.
+func (c *Certifier) Certify(commit Commit) error {
+	err := c.certifyStatic(commit)
+	if !IsValidatorsChangedErr(err) {
+		return err
+	}
+
+	// the validators changed, see if we can follow them
+	if commit.Height() <= c.LastHeight() {
+		return errors.Wrap(err, "Can't certify commits before the trusted height with another validator set")
+	}
+	if err := c.UpdateToHeight(commit.Height()); err != nil {
+		return err
+	}
+	return c.certifyStatic(commit)
+}
+
+// certifyStatic certifies the commit with the trusted validators only.
+func (c *Certifier) certifyStatic(commit Commit) error {
+	if err := commit.ValidateBasic(c.chainID); err != nil {
+		return err
+	}
+
+	trusted := c.Trusted()
+	if !bytes.Equal(commit.ValidatorsHash(), trusted.ValidatorsHash()) {
+		return ErrValidatorsChanged()
+	}
+	return trusted.Validators.VerifyCommit(c.chainID, commit.Commit.BlockID, commit.Height(), commit.Commit)
+}
+
+// Update trusts the given FullCommit, if it was signed by +2/3 of the
+// trusted voting power. It returns ErrTooMuchChange if the validators changed
+// too much to trust it in one step.
+func (c *Certifier) Update(fc FullCommit) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if fc.Height() <= c.trusted.Height() {
+		return errors.Errorf("Can't update to height %d, already trusting height %d", fc.Height(), c.trusted.Height())
+	}
+
+	// the new validators must have signed it...
+	if err := fc.Verify(c.chainID); err != nil {
+		return err
+	}
+
+	// ...and so must +2/3 of the ones we trust
+	if !bytes.Equal(fc.ValidatorsHash(), c.trusted.ValidatorsHash()) {
+		err := c.trusted.Validators.VerifyCommitAny(c.chainID, fc.Commit.Commit.BlockID, fc.Height(), fc.Commit.Commit)
+		if err != nil {
+			// the signatures were checked by fc.Verify, so not enough of the
+			// voting power we trust signed it
+			return errors.Wrap(ErrTooMuchChange(), err.Error())
+		}
+	}
+
+	c.trusted = fc
+	return nil
+}
+
+// UpdateToHeight fetches the FullCommit at the given height from the source
+// and trusts it. If the validator set changed too much since the trusted
+// height, it bisects the range and trusts the validator sets in between first.
+func (c *Certifier) UpdateToHeight(height int) error {
+	fc, err := c.source.GetByHeight(height)
+	if err != nil {
+		return err
+	}
+	if fc.Height() != height {
+		return errors.Errorf("Source returned a commit for height %d, expected %d", fc.Height(), height)
+	}
+
+	err = c.Update(fc)
+	if !IsTooMuchChangeErr(err) {
+		return err
+	}
+
+	// too much changed, so trust a commit half way there first
+	mid := (c.LastHeight() + height) / 2
+	if mid == c.LastHeight() {
+		// the validators changed too much in a single block
+		return err
+	}
+	if err := c.UpdateToHeight(mid); err != nil {
+		return err
+	}
+	return c.UpdateToHeight(height)
+}
@@ -0,0 +1,172 @@
+package lite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+// testChain is a Provider for a chain where one of four validators is
+// replaced every 10 blocks.
+type testChain struct {
+	chainID  string
+	privVals []*types.PrivValidator
+	height   int
+}
+
+func newTestChain(chainID string, height int) *testChain {
+	privVals := make([]*types.PrivValidator, height/10+4)
+	for i := range privVals {
+		privVals[i] = types.GenPrivValidator()
+	}
+	return &testChain{chainID, privVals, height}
+}
+
+func (tc *testChain) signers(height int) []*types.PrivValidator {
+	step := height / 10
+	return tc.privVals[step : step+4]
+}
+
+func (tc *testChain) validators(height int) *types.ValidatorSet {
+	vals := []*types.Validator{}
+	for _, pv := range tc.signers(height) {
+		vals = append(vals, types.NewValidator(pv.PubKey, 10))
+	}
+	return types.NewValidatorSet(vals)
+}
+
+func (tc *testChain) makeFullCommit(height int, appHash []byte) FullCommit {
+	vals := tc.validators(height)
+	header := &types.Header{
+		ChainID:        tc.chainID,
+		Height:         height,
+		Time:           time.Now(),
+		ValidatorsHash: vals.Hash(),
+		AppHash:        appHash,
+	}
+	blockID := types.BlockID{Hash: header.Hash()}
+
+	precommits := make([]*types.Vote, vals.Size())
+	for _, pv := range tc.signers(height) {
+		idx, _ := vals.GetByAddress(pv.Address)
+		vote := &types.Vote{
+			ValidatorAddress: pv.Address,
+			ValidatorIndex:   idx,
+			Height:           height,
+			Round:            0,
+			Type:             types.VoteTypePrecommit,
+			BlockID:          blockID,
+		}
+		vote.Signature = pv.Sign(types.SignBytes(tc.chainID, vote))
+		precommits[idx] = vote
+	}
+	commit := &types.Commit{BlockID: blockID, Precommits: precommits}
+	return NewFullCommit(header, commit, vals)
+}
+
+func (tc *testChain) GetByHeight(height int) (FullCommit, error) {
+	if height < 1 || height > tc.height {
+		return FullCommit{}, ErrCommitNotFound()
+	}
+	return tc.makeFullCommit(height, []byte("apphash")), nil
+}
+
+func (tc *testChain) LatestCommit() (FullCommit, error) {
+	return tc.GetByHeight(tc.height)
+}
+
+func TestCertifyStatic(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	chainID := "test-static"
+	chain := newTestChain(chainID, 9)
+	trusted, err := chain.GetByHeight(1)
+	require.Nil(err)
+
+	cert, err := NewCertifier(chainID, trusted, chain)
+	require.Nil(err, "%+v", err)
+	assert.Equal(1, cert.LastHeight())
+
+	// a commit by the same validators is fine
+	fc, err := chain.GetByHeight(5)
+	require.Nil(err)
+	assert.Nil(cert.Certify(fc.Commit))
+
+	// but not for another chain
+	_, err = NewCertifier("other-chain", trusted, chain)
+	assert.NotNil(err)
+
+	// or if the header was tampered with
+	bad := chain.makeFullCommit(6, []byte("apphash"))
+	bad.Header.AppHash = []byte("evil")
+	assert.NotNil(cert.Certify(bad.Commit))
+
+	// or if signatures are missing
+	bad = chain.makeFullCommit(7, []byte("apphash"))
+	bad.Commit.Commit.Precommits[0] = nil
+	bad.Commit.Commit.Precommits[1] = nil
+	assert.NotNil(cert.Certify(bad.Commit))
+
+	// certifying doesn't change what we trust
+	assert.Equal(1, cert.LastHeight())
+}
+
+func TestCertifyUpdate(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	chainID := "test-update"
+	chain := newTestChain(chainID, 15)
+	trusted, err := chain.GetByHeight(5)
+	require.Nil(err)
+	cert, err := NewCertifier(chainID, trusted, chain)
+	require.Nil(err, "%+v", err)
+
+	// one of the four validators changed at height 10
+	fc, err := chain.GetByHeight(12)
+	require.Nil(err)
+	err = cert.certifyStatic(fc.Commit)
+	assert.True(IsValidatorsChangedErr(err), "%+v", err)
+
+	// Certify follows the change
+	assert.Nil(cert.Certify(fc.Commit))
+	assert.Equal(12, cert.LastHeight())
+
+	// we can't go back to the old validators
+	old, err := chain.GetByHeight(7)
+	require.Nil(err)
+	assert.NotNil(cert.Update(old))
+}
+
+func TestCertifyBisection(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	chainID := "test-bisect"
+	chain := newTestChain(chainID, 50)
+	trusted, err := chain.GetByHeight(1)
+	require.Nil(err)
+	cert, err := NewCertifier(chainID, trusted, chain)
+	require.Nil(err, "%+v", err)
+
+	// by height 45, all validators have been replaced
+	fc, err := chain.GetByHeight(45)
+	require.Nil(err)
+
+	// so we can't trust the new set in one step
+	err = cert.Update(fc)
+	assert.True(IsTooMuchChangeErr(err), "%+v", err)
+	assert.Equal(1, cert.LastHeight())
+
+	// but we can by bisection
+	assert.Nil(cert.Certify(fc.Commit))
+	assert.Equal(45, cert.LastHeight())
+
+	// and keep going
+	latest, err := chain.LatestCommit()
+	require.Nil(err)
+	assert.Nil(cert.Certify(latest.Commit))
+	assert.Equal(50, cert.LastHeight())
+}
@@ -0,0 +1,105 @@
+/*
+Package client defines a lite.Provider which gets its commits
+from a tendermint node over rpc.
+*/
+package client
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+
+	"github.com/tendermint/tendermint/lite"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	rpcclient "github.com/tendermint/tendermint/rpc/lib/client"
+	"github.com/tendermint/tendermint/types"
+)
+
+// SignStatusClient is the part of the rpc client.SignClient and
+// client.StatusClient the Provider needs. It's declared here, as the rpc
+// client package imports the node, which uses the Provider for state sync.
+type SignStatusClient interface {
+	Commit(height int) (*ctypes.ResultCommit, error)
+	Validators(height int) (*ctypes.ResultValidators, error)
+	Status() (*ctypes.ResultStatus, error)
+}
+
+// Provider is a lite.Provider backed by a node's rpc.
+type Provider struct {
+	node SignStatusClient
+}
+
+var _ lite.Provider = Provider{}
+
+// NewProvider returns a Provider which queries the given node.
+func NewProvider(node SignStatusClient) Provider {
+	return Provider{node: node}
+}
+
+// NewHTTPProvider returns a Provider which queries the node at the given address.
+func NewHTTPProvider(remote string) Provider {
+	return NewProvider(httpClient{rpc: rpcclient.NewJSONRPCClient(remote)})
+}
+
+// GetByHeight implements lite.Provider.
+func (p Provider) GetByHeight(height int) (lite.FullCommit, error) {
+	res, err := p.node.Commit(height)
+	if err != nil {
+		return lite.FullCommit{}, errors.Wrap(lite.ErrCommitNotFound(), err.Error())
+	}
+	return p.withValidators(lite.Commit{Header: res.Header, Commit: res.Commit})
+}
+
+// LatestCommit implements lite.Provider.
+func (p Provider) LatestCommit() (lite.FullCommit, error) {
+	status, err := p.node.Status()
+	if err != nil {
+		return lite.FullCommit{}, err
+	}
+	return p.GetByHeight(status.LatestBlockHeight)
+}
+
+// withValidators adds the validator set which signed the commit.
+func (p Provider) withValidators(commit lite.Commit) (lite.FullCommit, error) {
+	res, err := p.node.Validators(commit.Height())
+	if err != nil {
+		return lite.FullCommit{}, err
+	}
+	fc := lite.NewFullCommit(commit.Header, commit.Commit, types.NewValidatorSet(res.Validators))
+	if !bytes.Equal(fc.Validators.Hash(), commit.ValidatorsHash()) {
+		return lite.FullCommit{}, errors.Errorf("Validators for height %d are not available", commit.Height())
+	}
+	return fc, nil
+}
+
+// httpClient is a SignStatusClient over json rpc, like the rpc client.HTTP.
+type httpClient struct {
+	rpc *rpcclient.JSONRPCClient
+}
+
+func (c httpClient) Commit(height int) (*ctypes.ResultCommit, error) {
+	result := new(ctypes.ResultCommit)
+	_, err := c.rpc.Call("commit", map[string]interface{}{"height": height}, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "Commit")
+	}
+	return result, nil
+}
+
+func (c httpClient) Validators(height int) (*ctypes.ResultValidators, error) {
+	result := new(ctypes.ResultValidators)
+	_, err := c.rpc.Call("validators", map[string]interface{}{"height": height}, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "Validators")
+	}
+	return result, nil
+}
+
+func (c httpClient) Status() (*ctypes.ResultStatus, error) {
+	result := new(ctypes.ResultStatus)
+	_, err := c.rpc.Call("status", map[string]interface{}{}, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "Status")
+	}
+	return result, nil
+}
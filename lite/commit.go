@@ -0,0 +1,98 @@
+package lite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+// Commit is a signed header: a Header together with the Commit which signs it.
+// This is the minimal information needed to prove a block was committed,
+// provided we already trust the validators who signed it.
+type Commit struct {
+	Header *types.Header `json:"header"`
+	Commit *types.Commit `json:"commit"`
+}
+
+// Height returns the height of the header.
+func (c Commit) Height() int {
+	if c.Header == nil {
+		return 0
+	}
+	return c.Header.Height
+}
+
+// ValidatorsHash returns the hash of the validators which signed the commit.
+func (c Commit) ValidatorsHash() []byte {
+	if c.Header == nil {
+		return nil
+	}
+	return c.Header.ValidatorsHash
+}
+
+// ValidateBasic does basic consistency checks: the header is for the given
+// chain and the commit is for this header.
+// It does not check the signatures, for that we need a validator set.
+func (c Commit) ValidateBasic(chainID string) error {
+	if c.Header == nil {
+		return errors.New("Commit missing header")
+	}
+	if c.Commit == nil {
+		return errors.New("Commit missing signatures")
+	}
+	if c.Header.ChainID != chainID {
+		return fmt.Errorf("Header belongs to another chain: %v", c.Header.ChainID)
+	}
+	if c.Commit.Size() == 0 {
+		return errors.New("Commit has no precommits")
+	}
+	if c.Commit.Height() != c.Header.Height {
+		return fmt.Errorf("Commit is for height %d, header is for height %d", c.Commit.Height(), c.Header.Height)
+	}
+	if !bytes.Equal(c.Commit.BlockID.Hash, c.Header.Hash()) {
+		return fmt.Errorf("Commit signs block %X, header is block %X", c.Commit.BlockID.Hash, c.Header.Hash())
+	}
+	return nil
+}
+
+// FullCommit is a Commit and the validator set which signed it.
+// With a FullCommit we can learn about a new validator set.
+type FullCommit struct {
+	Commit     `json:"commit"`
+	Validators *types.ValidatorSet `json:"validator_set"`
+}
+
+// NewFullCommit returns a new FullCommit.
+func NewFullCommit(header *types.Header, commit *types.Commit, vals *types.ValidatorSet) FullCommit {
+	return FullCommit{
+		Commit:     Commit{Header: header, Commit: commit},
+		Validators: vals,
+	}
+}
+
+// ValidateBasic checks the Commit, and that the validator set
+// matches the hash in the header.
+func (fc FullCommit) ValidateBasic(chainID string) error {
+	if err := fc.Commit.ValidateBasic(chainID); err != nil {
+		return err
+	}
+	if fc.Validators == nil {
+		return errors.New("FullCommit missing validators")
+	}
+	if !bytes.Equal(fc.Validators.Hash(), fc.ValidatorsHash()) {
+		return fmt.Errorf("Validators don't match header: got %X, expected %X", fc.Validators.Hash(), fc.ValidatorsHash())
+	}
+	return nil
+}
+
+// Verify checks the FullCommit is valid on its own: the commit was signed by
+// +2/3 of the validators it carries. It says nothing about whether those
+// validators can be trusted.
+func (fc FullCommit) Verify(chainID string) error {
+	if err := fc.ValidateBasic(chainID); err != nil {
+		return err
+	}
+	return fc.Validators.VerifyCommit(chainID, fc.Commit.Commit.BlockID, fc.Height(), fc.Commit.Commit)
+}
@@ -0,0 +1,47 @@
+package lite
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errValidatorsChanged = fmt.Errorf("Validators differ from the trusted ones")
+	errTooMuchChange     = fmt.Errorf("Validators changed by more than 1/3 of the voting power")
+	errCommitNotFound    = fmt.Errorf("Commit not found")
+)
+
+// IsValidatorsChangedErr checks whether an error is due to a validator set
+// different from the trusted one.
+func IsValidatorsChangedErr(err error) bool {
+	return errors.Cause(err) == errValidatorsChanged
+}
+
+// ErrValidatorsChanged indicates that the validator set was changed between
+// the trusted commit and the new one.
+func ErrValidatorsChanged() error {
+	return errors.WithStack(errValidatorsChanged)
+}
+
+// IsTooMuchChangeErr checks whether an error is due to too much change
+// between the trusted and the new validator set.
+func IsTooMuchChangeErr(err error) bool {
+	return errors.Cause(err) == errTooMuchChange
+}
+
+// ErrTooMuchChange indicates that the new validator set was not signed by
+// +2/3 of the trusted validators, so we can't trust it directly.
+func ErrTooMuchChange() error {
+	return errors.WithStack(errTooMuchChange)
+}
+
+// IsCommitNotFoundErr checks whether an error is due to a missing commit.
+func IsCommitNotFoundErr(err error) bool {
+	return errors.Cause(err) == errCommitNotFound
+}
+
+// ErrCommitNotFound indicates that the requested commit was not found.
+func ErrCommitNotFound() error {
+	return errors.WithStack(errCommitNotFound)
+}
@@ -0,0 +1,134 @@
+/*
+Package proxy provides a rpc client which only returns data it could verify
+with a lite.Certifier, so it can be used to talk to an untrusted node.
+*/
+package proxy
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/go-wire/data"
+	"github.com/tendermint/merkleeyes/iavl"
+
+	"github.com/tendermint/tendermint/lite"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+var _ rpcclient.Client = Wrapper{}
+
+// Wrapper wraps a rpcclient.Client with a lite.Certifier.
+// Headers, commits and blocks are certified before they are returned, and
+// abci queries are checked with merkle proofs against the certified AppHash.
+// All other calls are passed through unverified.
+type Wrapper struct {
+	rpcclient.Client
+	cert *lite.Certifier
+}
+
+// SecureClient returns a Wrapper which verifies the responses of the given
+// client with the certifier.
+func SecureClient(c rpcclient.Client, cert *lite.Certifier) Wrapper {
+	return Wrapper{c, cert}
+}
+
+// ABCIQuery always asks for a proof, and checks it against the AppHash of a
+// certified header, and that it is for the key which was asked for.
+// Responses with an error code carry no proof and are returned as is.
+func (w Wrapper) ABCIQuery(path string, data data.Bytes, prove bool) (*ctypes.ResultABCIQuery, error) {
+	res, err := w.Client.ABCIQuery(path, data, true)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Code.IsOK() {
+		return res, nil
+	}
+	if !bytes.Equal(res.Key, data) {
+		return nil, errors.Errorf("Query response is for key %X, not %X", res.Key, data)
+	}
+	if len(res.Proof) == 0 {
+		return nil, errors.New("Query response has no proof")
+	}
+	proof, err := iavl.ReadProof(res.Proof)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid proof")
+	}
+
+	// the app state at Height is committed to in the next header
+	commit, err := w.certifiedCommit(int(res.Height) + 1)
+	if err != nil {
+		return nil, err
+	}
+	if !proof.Verify(res.Key, res.Value, commit.Header.AppHash) {
+		return nil, errors.Errorf("Proof doesn't match the AppHash %X at height %d", commit.Header.AppHash, commit.Height())
+	}
+	return res, nil
+}
+
+// Commit returns the commit at the given height, if it can be certified.
+func (w Wrapper) Commit(height int) (*ctypes.ResultCommit, error) {
+	res, err := w.Client.Commit(height)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.cert.Certify(lite.Commit{Header: res.Header, Commit: res.Commit}); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Block returns the block at the given height, if its header can be certified.
+func (w Wrapper) Block(height int) (*ctypes.ResultBlock, error) {
+	res, err := w.Client.Block(height)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := w.certifiedCommit(height)
+	if err != nil {
+		return nil, err
+	}
+
+	// the block must match the certified header
+	certified := commit.Header.Hash()
+	if !bytes.Equal(res.BlockMeta.Header.Hash(), certified) {
+		return nil, errors.Errorf("BlockMeta header %X doesn't match the certified header %X", res.BlockMeta.Header.Hash(), certified)
+	}
+	if !bytes.Equal(res.Block.Hash(), certified) {
+		return nil, errors.Errorf("Block %X doesn't match the certified header %X", res.Block.Hash(), certified)
+	}
+	return res, nil
+}
+
+// BlockByHash returns the block with the given hash, if its header can be certified.
+func (w Wrapper) BlockByHash(hash []byte) (*ctypes.ResultBlock, error) {
+	res, err := w.Client.BlockByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	// certify the block at that height, which must have the requested hash
+	res, err = w.Block(res.Block.Height)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(res.Block.Hash(), hash) {
+		return nil, errors.Errorf("Block %X doesn't have the requested hash %X", res.Block.Hash(), hash)
+	}
+	return res, nil
+}
+
+// certifiedCommit waits for the commit at the given height and certifies it.
+func (w Wrapper) certifiedCommit(height int) (lite.Commit, error) {
+	if err := rpcclient.WaitForHeight(w.Client, height, nil); err != nil {
+		return lite.Commit{}, err
+	}
+	res, err := w.Client.Commit(height)
+	if err != nil {
+		return lite.Commit{}, err
+	}
+	commit := lite.Commit{Header: res.Header, Commit: res.Commit}
+	if err := w.cert.Certify(commit); err != nil {
+		return lite.Commit{}, err
+	}
+	return commit, nil
+}
@@ -0,0 +1,91 @@
+package proxy_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/go-wire/data"
+	meapp "github.com/tendermint/merkleeyes/app"
+	merktest "github.com/tendermint/merkleeyes/testutil"
+
+	"github.com/tendermint/tendermint/lite"
+	liteclient "github.com/tendermint/tendermint/lite/client"
+	"github.com/tendermint/tendermint/lite/proxy"
+	nm "github.com/tendermint/tendermint/node"
+	"github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	rpctest "github.com/tendermint/tendermint/rpc/test"
+)
+
+var node *nm.Node
+
+func TestMain(m *testing.M) {
+	// start a tendermint node (and merkleeyes) in the background to test against
+	app := meapp.NewMerkleEyesApp("", 100)
+	node = rpctest.StartTendermint(app)
+	code := m.Run()
+
+	// and shut down proper at the end
+	node.Stop()
+	node.Wait()
+	os.Exit(code)
+}
+
+func TestSecureClient(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	cl := client.NewLocal(node)
+	gen, err := cl.Genesis()
+	require.Nil(err, "%+v", err)
+	chainID := gen.Genesis.ChainID
+
+	// trust whatever the node says now
+	require.Nil(client.WaitForHeight(cl, 1, nil))
+	provider := liteclient.NewProvider(cl)
+	trusted, err := provider.LatestCommit()
+	require.Nil(err, "%+v", err)
+	cert, err := lite.NewCertifier(chainID, trusted, provider)
+	require.Nil(err, "%+v", err)
+
+	sc := proxy.SecureClient(cl, cert)
+
+	// write something
+	k, v, tx := merktest.MakeTxKV()
+	bres, err := sc.BroadcastTxCommit(tx)
+	require.Nil(err, "%+v", err)
+	require.True(bres.DeliverTx.Code.IsOK())
+
+	// and read it back with a proof
+	qres, err := sc.ABCIQuery("/key", k, false)
+	require.Nil(err, "%+v", err)
+	if assert.True(qres.Code.IsOK()) {
+		assert.EqualValues(k, qres.Key)
+		assert.EqualValues(v, qres.Value)
+	}
+
+	// a valid proof for another key is rejected
+	wrong := proxy.SecureClient(keyClient{cl, k}, cert)
+	_, err = wrong.ABCIQuery("/key", data.Bytes("other key"), false)
+	assert.NotNil(err)
+
+	// the block with the tx is certified too
+	block, err := sc.Block(bres.Height)
+	require.Nil(err, "%+v", err)
+	assert.Equal(bres.Height, block.Block.Height)
+
+	commit, err := sc.Commit(bres.Height)
+	require.Nil(err, "%+v", err)
+	assert.Equal(block.BlockMeta.Header.Hash(), commit.Header.Hash())
+}
+
+// keyClient always queries the same key, whichever was asked for.
+type keyClient struct {
+	client.Client
+	key data.Bytes
+}
+
+func (c keyClient) ABCIQuery(path string, _ data.Bytes, prove bool) (*ctypes.ResultABCIQuery, error) {
+	return c.Client.ABCIQuery(path, c.key, prove)
+}
@@ -3,12 +3,11 @@ package mempool
 import (
 	"bytes"
 	"container/list"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/pkg/errors"
-
 	abci "github.com/tendermint/abci/types"
 	auto "github.com/tendermint/tmlibs/autofile"
 	"github.com/tendermint/tmlibs/clist"
@@ -48,33 +47,56 @@ TODO: Better handle abci client errors. (make it automatically handle connection
 
 */
 
-const cacheSize = 100000
+// ErrMempoolIsFull is returned by CheckTx when the mempool has reached its
+// size or max_txs_bytes limit.
+type ErrMempoolIsFull struct {
+	numTxs      int
+	maxTxs      int
+	txsBytes    int64
+	maxTxsBytes int64
+}
+
+func (e ErrMempoolIsFull) Error() string {
+	return fmt.Sprintf("Mempool is full: number of txs %d (max: %d), total txs bytes %d (max: %d)",
+		e.numTxs, e.maxTxs, e.txsBytes, e.maxTxsBytes)
+}
 
 // Mempool is an ordered in-memory pool for transactions before they are proposed in a consensus round.
 // Transaction validity is checked using the CheckTx abci message before the transaction is added to the pool.
 // The Mempool uses a concurrent list structure for storing transactions that can be efficiently accessed by multiple concurrent readers.
+// Txs are gossiped in arrival order, but reaped in order of priority (see SetPriorityFunc).
 type Mempool struct {
 	config *cfg.MempoolConfig
 
 	proxyMtx             sync.Mutex
 	proxyAppConn         proxy.AppConnMempool
 	txs                  *clist.CList    // concurrent linked-list of good txs
+	txsBytes             int64           // total size of the txs in txs, in bytes
 	counter              int64           // simple incrementing counter
 	height               int             // the last block Update()'d to
 	rechecking           int32           // for re-checking filtered txs on Update()
 	recheckCursor        *clist.CElement // next expected response
 	recheckEnd           *clist.CElement // re-checking stops here
+	recheckStart         time.Time       // when re-checking started, for metrics
 	notifiedTxsAvailable bool            // true if fired on txsAvailable for this height
 	txsAvailable         chan int        // fires the next height once for each height, when the mempool is not empty
 
+	// Priorities of the txs, if set by the app
+	priorityFn    PriorityFunc
+	priorityIndex *txPriorityIndex
+
 	// Keep a cache of already-seen txs.
 	// This reduces the pressure on the proxyApp.
 	cache *txCache
 
 	// A log of mempool txs
-	wal *auto.AutoFile
+	wal         *auto.AutoFile
+	walMtx      sync.Mutex // the WAL is rotated in the background
+	walHeight   int        // the height the WAL was last rotated at
+	walRotating int32      // 1 while the WAL is being rotated
 
-	logger log.Logger
+	logger  log.Logger
+	metrics *Metrics
 }
 
 // NewMempool returns a new Mempool with the given configuration and connection to an application.
@@ -85,11 +107,14 @@ func NewMempool(config *cfg.MempoolConfig, proxyAppConn proxy.AppConnMempool, he
 		txs:           clist.New(),
 		counter:       0,
 		height:        height,
+		walHeight:     height,
 		rechecking:    0,
 		recheckCursor: nil,
 		recheckEnd:    nil,
 		logger:        log.NewNopLogger(),
-		cache:         newTxCache(cacheSize),
+		metrics:       NopMetrics(),
+		cache:         newTxCache(config.CacheSize),
+		priorityIndex: newTxPriorityIndex(),
 	}
 	mempool.initWAL()
 	proxyAppConn.SetResponseCallback(mempool.resCb)
@@ -103,24 +128,25 @@ func (mem *Mempool) EnableTxsAvailable() {
 	mem.txsAvailable = make(chan int, 1)
 }
 
+// SetMetrics sets the metrics to report to.
+func (mem *Mempool) SetMetrics(metrics *Metrics) {
+	mem.metrics = metrics
+}
+
 // SetLogger sets the Logger.
 func (mem *Mempool) SetLogger(l log.Logger) {
 	mem.logger = l
 }
 
-func (mem *Mempool) initWAL() {
-	walDir := mem.config.WalDir()
-	if walDir != "" {
-		err := cmn.EnsureDir(walDir, 0700)
-		if err != nil {
-			cmn.PanicSanity(errors.Wrap(err, "Error ensuring Mempool wal dir"))
-		}
-		af, err := auto.OpenAutoFile(walDir + "/wal")
-		if err != nil {
-			cmn.PanicSanity(errors.Wrap(err, "Error opening Mempool wal file"))
-		}
-		mem.wal = af
-	}
+// SetPriorityFunc sets the function which gives the priority and sender of
+// txs from their CheckTx response. Without it, all txs have the same
+// priority and are reaped in arrival order.
+// The node sets it when the app is a proxy.TxPrioritizer.
+// TODO: read the priority and sender from ResponseCheckTx once abci has them.
+// NOTE: not thread safe - should only be called once, before the node starts,
+// which replays the WAL with it
+func (mem *Mempool) SetPriorityFunc(fn PriorityFunc) {
+	mem.priorityFn = fn
 }
 
 // Lock locks the mempool. The consensus must be able to hold lock to safely update.
@@ -138,6 +164,59 @@ func (mem *Mempool) Size() int {
 	return mem.txs.Len()
 }
 
+// TxsBytes returns the total size of the transactions in the mempool, in bytes.
+func (mem *Mempool) TxsBytes() int64 {
+	return atomic.LoadInt64(&mem.txsBytes)
+}
+
+// isFull returns an ErrMempoolIsFull if adding a tx of the given size
+// would exceed the mempool limits.
+func (mem *Mempool) isFull(txSize int) error {
+	numTxs, txsBytes := mem.Size(), mem.TxsBytes()
+	if numTxs >= mem.config.Size || txsBytes+int64(txSize) > mem.config.MaxTxsBytes {
+		return ErrMempoolIsFull{numTxs, mem.config.Size, txsBytes, mem.config.MaxTxsBytes}
+	}
+	return nil
+}
+
+// makeRoom evicts txs with a lower priority than memTx until it fits in the
+// mempool. It returns an ErrMempoolIsFull, and evicts nothing, if it can't.
+func (mem *Mempool) makeRoom(memTx *mempoolTx) error {
+	err := mem.isFull(len(memTx.tx))
+	if err == nil || mem.priorityFn == nil {
+		return err
+	}
+
+	victims := mem.priorityIndex.Lowest(memTx.priority)
+	numTxs, txsBytes := mem.Size(), mem.TxsBytes()
+	n := 0
+	for numTxs >= mem.config.Size || txsBytes+int64(len(memTx.tx)) > mem.config.MaxTxsBytes {
+		if n == len(victims) {
+			return err
+		}
+		numTxs--
+		txsBytes -= int64(len(victims[n].Value.(*mempoolTx).tx))
+		n++
+	}
+	for _, e := range victims[:n] {
+		evicted := e.Value.(*mempoolTx)
+		mem.removeTx(e)
+		// it can come back when there's room again
+		mem.cache.Remove(evicted.tx)
+		mem.logger.Info("Evicted tx", "tx", evicted.tx, "priority", evicted.priority, "sender", evicted.sender)
+	}
+	return nil
+}
+
+// removeTx removes the tx element from the list.
+func (mem *Mempool) removeTx(e *clist.CElement) {
+	memTx := e.Value.(*mempoolTx)
+	mem.txs.Remove(e)
+	e.DetachPrev()
+	mem.priorityIndex.Remove(e)
+	atomic.AddInt64(&mem.txsBytes, -int64(len(memTx.tx)))
+}
+
 // Flush removes all transactions from the mempool and cache
 func (mem *Mempool) Flush() {
 	mem.proxyMtx.Lock()
@@ -146,8 +225,7 @@ func (mem *Mempool) Flush() {
 	mem.cache.Reset()
 
 	for e := mem.txs.Front(); e != nil; e = e.Next() {
-		mem.txs.Remove(e)
-		e.DetachPrev()
+		mem.removeTx(e)
 	}
 }
 
@@ -163,10 +241,19 @@ func (mem *Mempool) TxsFrontWait() *clist.CElement {
 // cb: A callback from the CheckTx command.
 //     It gets called from another goroutine.
 // CONTRACT: Either cb will get called, or err returned.
+// It returns an ErrMempoolIsFull if the mempool can't take any more txs.
 func (mem *Mempool) CheckTx(tx types.Tx, cb func(*abci.Response)) (err error) {
 	mem.proxyMtx.Lock()
 	defer mem.proxyMtx.Unlock()
 
+	// with priorities, a full mempool may still evict
+	// lower priority txs to make room for this one
+	if mem.priorityFn == nil {
+		if err := mem.isFull(len(tx)); err != nil {
+			return err
+		}
+	}
+
 	// CACHE
 	if mem.cache.Exists(tx) {
 		if cb != nil {
@@ -185,11 +272,7 @@ func (mem *Mempool) CheckTx(tx types.Tx, cb func(*abci.Response)) (err error) {
 	// END CACHE
 
 	// WAL
-	if mem.wal != nil {
-		// TODO: Notify administrators when WAL fails
-		mem.wal.Write([]byte(tx))
-		mem.wal.Write([]byte("\n"))
-	}
+	mem.writeWAL(tx)
 	// END WAL
 
 	// NOTE: proxyAppConn may error if tx buffer is full
@@ -216,21 +299,35 @@ func (mem *Mempool) resCb(req *abci.Request, res *abci.Response) {
 func (mem *Mempool) resCbNormal(req *abci.Request, res *abci.Response) {
 	switch r := res.Value.(type) {
 	case *abci.Response_CheckTx:
+		tx := req.GetCheckTx().Tx
 		if r.CheckTx.Code == abci.CodeType_OK {
-			mem.counter++
 			memTx := &mempoolTx{
-				counter: mem.counter,
+				counter: mem.counter + 1,
 				height:  int64(mem.height),
-				tx:      req.GetCheckTx().Tx,
+				tx:      tx,
+			}
+			if mem.priorityFn != nil {
+				memTx.priority, memTx.sender = mem.priorityFn(tx, r.CheckTx)
+			}
+			// other txs may have filled the mempool while this one was checked
+			if err := mem.makeRoom(memTx); err != nil {
+				mem.logger.Info("Dropping valid tx", "sender", memTx.sender, "err", err)
+				mem.cache.Remove(tx)
+				return
 			}
-			mem.txs.PushBack(memTx)
+			mem.counter++
+			e := mem.txs.PushBack(memTx)
+			mem.priorityIndex.Add(e)
+			atomic.AddInt64(&mem.txsBytes, int64(len(tx)))
+			mem.metrics.Size.Set(float64(mem.Size()))
 			mem.notifyTxsAvailable()
 		} else {
 			// ignore bad transaction
 			mem.logger.Info("Bad Transaction", "res", r)
+			mem.metrics.FailedTxs.Inc()
 
 			// remove from cache (it might be good later)
-			mem.cache.Remove(req.GetCheckTx().Tx)
+			mem.cache.Remove(tx)
 
 			// TODO: handle other retcodes
 		}
@@ -248,11 +345,15 @@ func (mem *Mempool) resCbRecheck(req *abci.Request, res *abci.Response) {
 				"Expected %X, got %X", r.CheckTx.Data, memTx.tx))
 		}
 		if r.CheckTx.Code == abci.CodeType_OK {
-			// Good, nothing to do.
+			// Good, but its priority may have changed.
+			if mem.priorityFn != nil {
+				priority, _ := mem.priorityFn(memTx.tx, r.CheckTx)
+				mem.priorityIndex.Update(mem.recheckCursor, priority)
+			}
 		} else {
 			// Tx became invalidated due to newly committed block.
-			mem.txs.Remove(mem.recheckCursor)
-			mem.recheckCursor.DetachPrev()
+			mem.removeTx(mem.recheckCursor)
+			mem.metrics.FailedTxs.Inc()
 
 			// remove from cache (it might be good later)
 			mem.cache.Remove(req.GetCheckTx().Tx)
@@ -266,6 +367,8 @@ func (mem *Mempool) resCbRecheck(req *abci.Request, res *abci.Response) {
 			// Done!
 			atomic.StoreInt32(&mem.rechecking, 0)
 			mem.logger.Info("Done rechecking txs")
+			mem.metrics.Size.Set(float64(mem.Size()))
+			mem.metrics.RecheckTimeSeconds.Observe(time.Since(mem.recheckStart).Seconds())
 
 			mem.notifyTxsAvailable()
 		}
@@ -293,7 +396,7 @@ func (mem *Mempool) notifyTxsAvailable() {
 	}
 }
 
-// Reap returns a list of transactions currently in the mempool.
+// Reap returns a list of transactions currently in the mempool, highest priority first.
 // If maxTxs is -1, there is no cap on the number of returned transactions.
 func (mem *Mempool) Reap(maxTxs int) types.Txs {
 	mem.proxyMtx.Lock()
@@ -315,12 +418,7 @@ func (mem *Mempool) collectTxs(maxTxs int) types.Txs {
 	} else if maxTxs < 0 {
 		maxTxs = mem.txs.Len()
 	}
-	txs := make([]types.Tx, 0, cmn.MinInt(mem.txs.Len(), maxTxs))
-	for e := mem.txs.Front(); e != nil && len(txs) < maxTxs; e = e.Next() {
-		memTx := e.Value.(*mempoolTx)
-		txs = append(txs, memTx.tx)
-	}
-	return txs
+	return mem.priorityIndex.Txs(maxTxs)
 }
 
 // Update informs the mempool that the given txs were committed and can be discarded.
@@ -342,6 +440,9 @@ func (mem *Mempool) Update(height int, txs types.Txs) {
 
 	// Remove transactions that are already in txs.
 	goodTxs := mem.filterTxs(txsMap)
+	mem.metrics.Size.Set(float64(mem.Size()))
+	// Only keep the remaining txs in the WAL, from time to time
+	mem.maybeRotateWAL(height, goodTxs)
 	// Recheck mempool txs if any txs were committed in the block
 	// NOTE/XXX: in some apps a tx could be invalidated due to EndBlock,
 	//	so we really still do need to recheck, but this is for debugging
@@ -361,8 +462,7 @@ func (mem *Mempool) filterTxs(blockTxsMap map[string]struct{}) []types.Tx {
 		// Remove the tx if it's alredy in a block.
 		if _, ok := blockTxsMap[string(memTx.tx)]; ok {
 			// remove from clist
-			mem.txs.Remove(e)
-			e.DetachPrev()
+			mem.removeTx(e)
 
 			// NOTE: we don't remove committed txs from the cache.
 			continue
@@ -379,6 +479,7 @@ func (mem *Mempool) recheckTxs(goodTxs []types.Tx) {
 		return
 	}
 	atomic.StoreInt32(&mem.rechecking, 1)
+	mem.recheckStart = time.Now()
 	mem.recheckCursor = mem.txs.Front()
 	mem.recheckEnd = mem.txs.Back()
 
@@ -394,9 +495,11 @@ func (mem *Mempool) recheckTxs(goodTxs []types.Tx) {
 
 // mempoolTx is a transaction that successfully ran
 type mempoolTx struct {
-	counter int64    // a simple incrementing counter
-	height  int64    // height that this tx had been validated in
-	tx      types.Tx //
+	counter  int64    // a simple incrementing counter
+	height   int64    // height that this tx had been validated in
+	tx       types.Tx //
+	priority int64    // from the PriorityFunc, if any
+	sender   string   // from the PriorityFunc, if any
 }
 
 // Height returns the height for this transaction
@@ -406,7 +509,8 @@ func (memTx *mempoolTx) Height() int {
 
 //--------------------------------------------------------------------------------
 
-// txCache maintains a cache of transactions.
+// txCache maintains a cache of transactions. A cache of size 0 caches
+// nothing.
 type txCache struct {
 	mtx  sync.Mutex
 	size int
@@ -426,7 +530,7 @@ func newTxCache(cacheSize int) *txCache {
 // Reset resets the txCache to empty.
 func (cache *txCache) Reset() {
 	cache.mtx.Lock()
-	cache.map_ = make(map[string]struct{}, cacheSize)
+	cache.map_ = make(map[string]struct{}, cache.size)
 	cache.list.Init()
 	cache.mtx.Unlock()
 }
@@ -447,6 +551,9 @@ func (cache *txCache) Push(tx types.Tx) bool {
 	if _, exists := cache.map_[string(tx)]; exists {
 		return false
 	}
+	if cache.size <= 0 {
+		return true
+	}
 
 	if cache.list.Len() >= cache.size {
 		popped := cache.list.Front()
@@ -1,13 +1,16 @@
 package mempool
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/binary"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/tendermint/abci/example/counter"
 	"github.com/tendermint/abci/example/dummy"
+	abci "github.com/tendermint/abci/types"
 	"github.com/tendermint/tmlibs/log"
 
 	cfg "github.com/tendermint/tendermint/config"
@@ -202,3 +205,175 @@ func TestSerialReap(t *testing.T) {
 	// We should have 600 now.
 	reapCheck(600)
 }
+
+func TestMempoolIsFull(t *testing.T) {
+	app := dummy.NewDummyApplication()
+	cc := proxy.NewLocalClientCreator(app)
+	mempool := newMempoolWithApp(t, cc)
+	mempool.config.Size = 10
+
+	sendTxs(t, mempool, 10)
+	if mempool.Size() != 10 {
+		t.Fatalf("Expected 10 txs in the mempool, got %d", mempool.Size())
+	}
+	if mempool.TxsBytes() != 10*20 {
+		t.Fatalf("Expected 200 bytes of txs in the mempool, got %d", mempool.TxsBytes())
+	}
+
+	// no room for another tx
+	err := mempool.CheckTx(types.Tx("full"), nil)
+	if _, ok := err.(ErrMempoolIsFull); !ok {
+		t.Fatalf("Expected ErrMempoolIsFull, got %v", err)
+	}
+
+	// flushing makes room again
+	mempool.Flush()
+	if mempool.TxsBytes() != 0 {
+		t.Fatalf("Expected no bytes of txs after Flush, got %d", mempool.TxsBytes())
+	}
+	mempool.config.MaxTxsBytes = 10
+	if err := mempool.CheckTx(types.Tx("tiny"), nil); err != nil {
+		t.Fatalf("Error after CheckTx: %v", err)
+	}
+	// but not for a tx bigger than max_txs_bytes
+	err = mempool.CheckTx(types.Tx("too big for the mempool"), nil)
+	if _, ok := err.(ErrMempoolIsFull); !ok {
+		t.Fatalf("Expected ErrMempoolIsFull, got %v", err)
+	}
+}
+
+func TestTxCacheDisabled(t *testing.T) {
+	cache := newTxCache(0)
+	tx := types.Tx("tx")
+	if !cache.Push(tx) || !cache.Push(tx) {
+		t.Fatal("Expected a cache of size 0 to accept the tx again")
+	}
+	if cache.Exists(tx) {
+		t.Fatal("Expected a cache of size 0 to be empty")
+	}
+}
+
+func TestMempoolPriority(t *testing.T) {
+	app := dummy.NewDummyApplication()
+	cc := proxy.NewLocalClientCreator(app)
+	mempool := newMempoolWithApp(t, cc)
+	mempool.config.Size = 3
+	// txs are "<sender><priority>"
+	mempool.SetPriorityFunc(func(tx types.Tx, res *abci.ResponseCheckTx) (int64, string) {
+		return int64(tx[1] - '0'), string(tx[:1])
+	})
+
+	for _, tx := range []string{"a2", "b5", "c1"} {
+		if err := mempool.CheckTx(types.Tx(tx), nil); err != nil {
+			t.Fatalf("Error after CheckTx: %v", err)
+		}
+	}
+	checkReap := func(expected ...string) {
+		txs := mempool.Reap(-1)
+		if len(txs) != len(expected) {
+			t.Fatalf("Expected to reap %v, got %v", expected, txs)
+		}
+		for i, tx := range txs {
+			if string(tx) != expected[i] {
+				t.Fatalf("Expected to reap %v, got %v", expected, txs)
+			}
+		}
+	}
+	checkReap("b5", "a2", "c1")
+
+	// a higher priority tx evicts the lowest one
+	if err := mempool.CheckTx(types.Tx("d3"), nil); err != nil {
+		t.Fatalf("Error after CheckTx: %v", err)
+	}
+	checkReap("b5", "d3", "a2")
+
+	// but a lower priority tx is dropped
+	if err := mempool.CheckTx(types.Tx("e1"), nil); err != nil {
+		t.Fatalf("Error after CheckTx: %v", err)
+	}
+	checkReap("b5", "d3", "a2")
+
+	// a sender can have several txs, which are reaped in arrival order
+	mempool.config.Size = 10
+	if err := mempool.CheckTx(types.Tx("b9"), nil); err != nil {
+		t.Fatalf("Error after CheckTx: %v", err)
+	}
+	checkReap("b5", "b9", "d3", "a2")
+	if err := mempool.CheckTx(types.Tx("a4"), nil); err != nil {
+		t.Fatalf("Error after CheckTx: %v", err)
+	}
+	checkReap("b5", "b9", "d3", "a2", "a4")
+	if txs := mempool.Reap(2); len(txs) != 2 || string(txs[0]) != "b5" || string(txs[1]) != "b9" {
+		t.Fatalf("Expected to reap [b5 b9], got %v", txs)
+	}
+
+	// committed txs leave the index
+	mempool.Lock()
+	mempool.Update(1, types.Txs{types.Tx("d3")})
+	mempool.Unlock()
+	checkReap("b5", "b9", "a2", "a4")
+}
+
+// mockBlockStore only serves the blocks it was given.
+type mockBlockStore struct {
+	types.BlockStoreRPC
+	blocks map[int]*types.Block
+}
+
+func (bs mockBlockStore) Base() int                         { return 1 }
+func (bs mockBlockStore) LoadBlock(height int) *types.Block { return bs.blocks[height] }
+
+func TestMempoolWALReplay(t *testing.T) {
+	app := dummy.NewDummyApplication()
+	cc := proxy.NewLocalClientCreator(app)
+	mempool := newMempoolWithApp(t, cc)
+	txs := sendTxs(t, mempool, 3)
+
+	// the WAL is only rotated every walRotateInterval blocks
+	mempool.Lock()
+	mempool.Update(1, txs[:1])
+	mempool.Unlock()
+	walFile := WALFile(mempool.config.WalDir())
+	height, walTxs, err := ReadWAL(walFile)
+	if err != nil {
+		t.Fatalf("Error reading WAL: %v", err)
+	}
+	if height != 0 || len(walTxs) != 3 {
+		t.Fatalf("Expected 3 txs at height 0 in the WAL, got %d at height %d", len(walTxs), height)
+	}
+
+	// then it only keeps the txs left after the update,
+	// and the ones received while it's rotated
+	mempool.Lock()
+	mempool.Update(walRotateInterval, nil)
+	mempool.Unlock()
+	txs = append(txs, sendTxs(t, mempool, 1)...)
+	for atomic.LoadInt32(&mempool.walRotating) == 1 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	height, walTxs, err = ReadWAL(walFile)
+	if err != nil {
+		t.Fatalf("Error reading WAL: %v", err)
+	}
+	if height != walRotateInterval || len(walTxs) != 3 {
+		t.Fatalf("Expected 3 txs at height %d in the WAL, got %d at height %d", walRotateInterval, len(walTxs), height)
+	}
+
+	// restart after txs[1] was committed in the next block
+	appConnMem, _ := cc.NewABCIClient()
+	if _, err := appConnMem.Start(); err != nil {
+		t.Fatalf("Error starting ABCI client: %v", err.Error())
+	}
+	restarted := NewMempool(mempool.config, appConnMem, walRotateInterval+1)
+	restarted.SetLogger(log.TestingLogger())
+	block := &types.Block{Data: &types.Data{Txs: txs[1:2]}}
+	blockStore := mockBlockStore{blocks: map[int]*types.Block{walRotateInterval + 1: block}}
+	if err := restarted.ReplayWAL(blockStore); err != nil {
+		t.Fatalf("Error replaying WAL: %v", err)
+	}
+
+	reaped := restarted.Reap(-1)
+	if len(reaped) != 2 || !bytes.Equal(reaped[0], txs[2]) || !bytes.Equal(reaped[1], txs[3]) {
+		t.Fatalf("Expected to replay %v, got %v", txs[2:], reaped)
+	}
+}
@@ -0,0 +1,41 @@
+package mempool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics contains the metrics exposed by the mempool package.
+type Metrics struct {
+	// Number of txs in the mempool.
+	Size prometheus.Gauge
+	// Number of txs rejected by CheckTx, including on recheck.
+	FailedTxs prometheus.Counter
+	// Time it takes to recheck the mempool after a block, in seconds.
+	RecheckTimeSeconds prometheus.Histogram
+}
+
+// PrometheusMetrics registers the mempool metrics with registry.
+func PrometheusMetrics(registry prometheus.Registerer) *Metrics {
+	m := NopMetrics()
+	registry.MustRegister(m.Size, m.FailedTxs, m.RecheckTimeSeconds)
+	return m
+}
+
+// NopMetrics returns unregistered mempool metrics.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		Size: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "mempool",
+			Name: "size", Help: "Number of txs in the mempool.",
+		}),
+		FailedTxs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "tendermint", Subsystem: "mempool",
+			Name: "failed_txs", Help: "Number of txs rejected by CheckTx.",
+		}),
+		RecheckTimeSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "tendermint", Subsystem: "mempool",
+			Name: "recheck_time_seconds", Help: "Time it takes to recheck the mempool after a block.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+		}),
+	}
+}
@@ -0,0 +1,197 @@
+package mempool
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/tmlibs/clist"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+// PriorityFunc returns the priority of a tx which passed CheckTx, and
+// optionally the key of its sender, which is logged when the tx is dropped.
+// Txs with a higher priority are reaped first, and may evict txs with a lower
+// priority when the mempool is full. The txs of a sender are still reaped in
+// arrival order, eg. so their nonces stay in sequence.
+type PriorityFunc func(tx types.Tx, res *abci.ResponseCheckTx) (priority int64, sender string)
+
+// txPriorityIndex keeps the elements of the mempool's tx list sorted by
+// priority (highest first), and by arrival for equal priorities.
+type txPriorityIndex struct {
+	mtx   sync.Mutex
+	elems []*clist.CElement
+}
+
+func newTxPriorityIndex() *txPriorityIndex {
+	return &txPriorityIndex{}
+}
+
+// search returns the position of the first element which should come
+// after memTx.
+func (idx *txPriorityIndex) search(memTx *mempoolTx) int {
+	return sort.Search(len(idx.elems), func(i int) bool {
+		other := idx.elems[i].Value.(*mempoolTx)
+		if other.priority != memTx.priority {
+			return other.priority < memTx.priority
+		}
+		return other.counter > memTx.counter
+	})
+}
+
+// Add adds the element to the index.
+func (idx *txPriorityIndex) Add(e *clist.CElement) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	memTx := e.Value.(*mempoolTx)
+	i := idx.search(memTx)
+	idx.elems = append(idx.elems, nil)
+	copy(idx.elems[i+1:], idx.elems[i:])
+	idx.elems[i] = e
+}
+
+// Remove removes the element from the index, if it's there.
+func (idx *txPriorityIndex) Remove(e *clist.CElement) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	idx.remove(e)
+}
+
+func (idx *txPriorityIndex) remove(e *clist.CElement) {
+	memTx := e.Value.(*mempoolTx)
+	// elements with the same priority are sorted by counter, so we can
+	// find it right before where it would be inserted
+	i := idx.search(memTx) - 1
+	if i < 0 || idx.elems[i] != e {
+		return
+	}
+	idx.elems = append(idx.elems[:i], idx.elems[i+1:]...)
+}
+
+// Update changes the priority of the element's tx and moves it accordingly.
+func (idx *txPriorityIndex) Update(e *clist.CElement, priority int64) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	memTx := e.Value.(*mempoolTx)
+	if memTx.priority == priority {
+		return
+	}
+	idx.remove(e)
+	memTx.priority = priority
+	i := idx.search(memTx)
+	idx.elems = append(idx.elems, nil)
+	copy(idx.elems[i+1:], idx.elems[i:])
+	idx.elems[i] = e
+}
+
+// Lowest returns the elements with a priority lower than the given one,
+// lowest priority first.
+func (idx *txPriorityIndex) Lowest(priority int64) []*clist.CElement {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	elems := []*clist.CElement{}
+	for i := len(idx.elems) - 1; i >= 0; i-- {
+		if idx.elems[i].Value.(*mempoolTx).priority >= priority {
+			break
+		}
+		elems = append(elems, idx.elems[i])
+	}
+	return elems
+}
+
+// Txs returns up to maxTxs txs, highest priority first, but with the txs
+// of each sender in arrival order: a tx waits for the earlier txs of its
+// sender, even if they have a lower priority.
+func (idx *txPriorityIndex) Txs(maxTxs int) types.Txs {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	if maxTxs > len(idx.elems) {
+		maxTxs = len(idx.elems)
+	}
+
+	// queue the txs of each sender in arrival order.
+	// txs without a sender are on their own
+	queues := senderQueues{}
+	bySender := make(map[string]int)
+	for _, e := range idx.elems {
+		memTx := e.Value.(*mempoolTx)
+		if memTx.sender == "" {
+			queues = append(queues, []*mempoolTx{memTx})
+			continue
+		}
+		i, ok := bySender[memTx.sender]
+		if !ok {
+			i = len(queues)
+			bySender[memTx.sender] = i
+			queues = append(queues, nil)
+		}
+		queues[i] = append(queues[i], memTx)
+	}
+	for _, queue := range queues {
+		sort.Sort(byCounter(queue))
+	}
+
+	// take the best tx at the head of a queue, until we have enough
+	heap.Init(&queues)
+	txs := make([]types.Tx, 0, maxTxs)
+	for len(txs) < maxTxs {
+		queue := queues[0]
+		txs = append(txs, queue[0].tx)
+		if len(queue) == 1 {
+			heap.Pop(&queues)
+		} else {
+			queues[0] = queue[1:]
+			heap.Fix(&queues, 0)
+		}
+	}
+	return txs
+}
+
+// byCounter sorts txs by arrival.
+type byCounter []*mempoolTx
+
+func (txs byCounter) Len() int           { return len(txs) }
+func (txs byCounter) Less(i, j int) bool { return txs[i].counter < txs[j].counter }
+func (txs byCounter) Swap(i, j int)      { txs[i], txs[j] = txs[j], txs[i] }
+
+// senderQueues is a heap of non-empty tx queues, by the priority of the tx
+// at their head (highest first), and by its arrival for equal priorities.
+type senderQueues [][]*mempoolTx
+
+func (qs senderQueues) Len() int { return len(qs) }
+
+func (qs senderQueues) Less(i, j int) bool {
+	a, b := qs[i][0], qs[j][0]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.counter < b.counter
+}
+
+func (qs senderQueues) Swap(i, j int) { qs[i], qs[j] = qs[j], qs[i] }
+
+func (qs *senderQueues) Push(x interface{}) {
+	*qs = append(*qs, x.([]*mempoolTx))
+}
+
+func (qs *senderQueues) Pop() interface{} {
+	old := *qs
+	queue := old[len(old)-1]
+	*qs = old[:len(old)-1]
+	return queue
+}
+
+// Reset removes all elements from the index.
+func (idx *txPriorityIndex) Reset() {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+
+	idx.elems = nil
+}
@@ -0,0 +1,259 @@
+package mempool
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	auto "github.com/tendermint/tmlibs/autofile"
+	cmn "github.com/tendermint/tmlibs/common"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+// The mempool WAL starts with the height the mempool was last updated to,
+// followed by the hex encoded txs received since, one per line:
+//
+//	#HEIGHT: 10
+//	0A0B0C
+//	0D0E0F
+//
+// Every walRotateInterval blocks, it's rewritten in the background with the
+// txs left after the Update, followed by the txs received since.
+
+const (
+	walHeightPrefix   = "#HEIGHT: "
+	walRotateInterval = 100
+)
+
+// WALFile returns the path to the WAL file in the given WAL dir.
+func WALFile(walDir string) string {
+	return filepath.Join(walDir, "wal")
+}
+
+// ReadWAL reads the height and txs from the WAL file.
+func ReadWAL(walFile string) (height int, txs types.Txs, err error) {
+	f, err := os.Open(walFile)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for lineNum := 1; ; lineNum++ {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			// a partial last line is a write that didn't make it
+			return height, txs, nil
+		} else if err != nil {
+			return 0, nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if strings.HasPrefix(line, walHeightPrefix) {
+			if _, err := fmt.Sscanf(line[len(walHeightPrefix):], "%d", &height); err != nil {
+				return 0, nil, errors.Wrapf(err, "Invalid height on line %d", lineNum)
+			}
+			continue
+		}
+		tx, err := hex.DecodeString(line)
+		if err != nil {
+			return 0, nil, errors.Wrapf(err, "Invalid tx on line %d", lineNum)
+		}
+		txs = append(txs, tx)
+	}
+}
+
+func (mem *Mempool) initWAL() {
+	walDir := mem.config.WalDir()
+	if walDir != "" {
+		err := cmn.EnsureDir(walDir, 0700)
+		if err != nil {
+			cmn.PanicSanity(errors.Wrap(err, "Error ensuring Mempool wal dir"))
+		}
+		af, err := auto.OpenAutoFile(WALFile(walDir))
+		if err != nil {
+			cmn.PanicSanity(errors.Wrap(err, "Error opening Mempool wal file"))
+		}
+		mem.wal = af
+	}
+}
+
+// hasWAL returns true if the mempool has a WAL.
+func (mem *Mempool) hasWAL() bool {
+	mem.walMtx.Lock()
+	defer mem.walMtx.Unlock()
+	return mem.wal != nil
+}
+
+// writeWAL appends the tx to the WAL, if there is one.
+func (mem *Mempool) writeWAL(tx types.Tx) {
+	mem.walMtx.Lock()
+	defer mem.walMtx.Unlock()
+	if mem.wal == nil {
+		return
+	}
+	// TODO: Notify administrators when WAL fails
+	mem.wal.Write([]byte(cmn.Fmt("%X\n", []byte(tx))))
+}
+
+// walSize returns the size of the WAL, in bytes.
+func (mem *Mempool) walSize() (int64, error) {
+	mem.walMtx.Lock()
+	defer mem.walMtx.Unlock()
+	return mem.wal.Size()
+}
+
+// maybeRotateWAL rotates the WAL in the background if walRotateInterval
+// blocks were committed since the last rotation, and it isn't being rotated
+// already. txs are the txs left after the Update at height.
+// NOTE: unsafe; Lock/Unlock must be managed by caller
+func (mem *Mempool) maybeRotateWAL(height int, txs types.Txs) {
+	if height-mem.walHeight < walRotateInterval || !mem.hasWAL() {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&mem.walRotating, 0, 1) {
+		return
+	}
+	offset, err := mem.walSize()
+	if err != nil {
+		atomic.StoreInt32(&mem.walRotating, 0)
+		mem.logger.Error("Error rotating Mempool wal", "err", err)
+		return
+	}
+	mem.walHeight = height
+	go func() {
+		defer atomic.StoreInt32(&mem.walRotating, 0)
+		if err := mem.rotateWAL(height, txs, offset); err != nil {
+			mem.logger.Error("Error rotating Mempool wal", "err", err)
+		}
+	}()
+}
+
+// rotateWAL replaces the WAL with one holding the given height and txs,
+// followed by whatever was written to the WAL after offset, so the txs
+// received since the txs were collected aren't lost.
+func (mem *Mempool) rotateWAL(height int, txs types.Txs, offset int64) error {
+	if !mem.hasWAL() {
+		return nil
+	}
+	walFile := WALFile(mem.config.WalDir())
+
+	lines := make([]string, 0, len(txs)+1)
+	lines = append(lines, cmn.Fmt("%v%v", walHeightPrefix, height))
+	for _, tx := range txs {
+		lines = append(lines, cmn.Fmt("%X", []byte(tx)))
+	}
+	tmpFile := walFile + ".tmp"
+	if err := cmn.WriteFile(tmpFile, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return errors.Wrap(err, "Error writing Mempool wal file")
+	}
+
+	mem.walMtx.Lock()
+	defer mem.walMtx.Unlock()
+	if err := copyFileFrom(tmpFile, walFile, offset); err != nil {
+		return errors.Wrap(err, "Error writing Mempool wal file")
+	}
+	if err := mem.wal.Close(); err != nil {
+		return errors.Wrap(err, "Error closing Mempool wal file")
+	}
+	if err := os.Rename(tmpFile, walFile); err != nil {
+		return errors.Wrap(err, "Error replacing Mempool wal file")
+	}
+	af, err := auto.OpenAutoFile(walFile)
+	if err != nil {
+		return errors.Wrap(err, "Error opening Mempool wal file")
+	}
+	mem.wal = af
+	return nil
+}
+
+// resetWAL replaces the WAL with an empty one at the given height.
+func (mem *Mempool) resetWAL(height int) error {
+	offset, err := mem.walSize()
+	if err != nil {
+		return errors.Wrap(err, "Error reading Mempool wal size")
+	}
+	mem.walHeight = height
+	return mem.rotateWAL(height, nil, offset)
+}
+
+// copyFileFrom appends the content of src after offset to dst.
+func copyFileFrom(dst, src string, offset int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ReplayWAL runs the txs in the WAL through CheckTx again, so the txs received
+// before a restart aren't lost. Txs committed in the blocks from the WAL's
+// height up to the mempool's height are skipped.
+// It should be called once, on startup, after the app is synced with the blockStore.
+func (mem *Mempool) ReplayWAL(blockStore types.BlockStoreRPC) error {
+	if !mem.hasWAL() {
+		return nil
+	}
+	walFile := WALFile(mem.config.WalDir())
+	walHeight, txs, err := ReadWAL(walFile)
+	if err != nil {
+		// we can't trust any of it, so start over
+		mem.logger.Error("Error reading Mempool wal, discarding it", "err", err)
+		return mem.resetWAL(mem.height)
+	}
+
+	if len(txs) == 0 {
+		return mem.resetWAL(mem.height)
+	}
+
+	committed := make(map[string]struct{})
+	for h := cmn.MaxInt(walHeight+1, blockStore.Base()); h <= mem.height; h++ {
+		block := blockStore.LoadBlock(h)
+		if block == nil {
+			continue
+		}
+		for _, tx := range block.Data.Txs {
+			committed[string(tx)] = struct{}{}
+		}
+	}
+
+	// CheckTx writes the txs back
+	if err := mem.resetWAL(mem.height); err != nil {
+		return err
+	}
+	replayed := 0
+	for _, tx := range txs {
+		if _, ok := committed[string(tx)]; ok {
+			continue
+		}
+		if err := mem.CheckTx(tx, nil); err != nil {
+			mem.logger.Error("Error replaying tx from Mempool wal", "err", err)
+			if _, ok := err.(ErrMempoolIsFull); ok {
+				break
+			}
+			continue
+		}
+		replayed++
+	}
+	mem.logger.Info("Replayed Mempool wal", "txs", replayed, "skipped", len(txs)-replayed)
+	return nil
+}
@@ -1,34 +1,158 @@
 package node
 
 import (
-	"github.com/tendermint/go-crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"time"
+
+	crypto "github.com/tendermint/go-crypto"
+	wire "github.com/tendermint/go-wire"
+	cmn "github.com/tendermint/tmlibs/common"
+	"github.com/tendermint/tmlibs/log"
 )
 
+// NodeID is the public identity of a node. Its PubKey is advertised in the
+// p2p.NodeInfo and authenticated by the secret connection handshake.
 type NodeID struct {
-	Name   string
-	PubKey crypto.PubKey
+	Name   string        `json:"name"`
+	PubKey crypto.PubKey `json:"pub_key"`
 }
 
+// PrivNodeID is the persistent identity of a node, stored in node_key.json.
+// It is distinct from the PrivValidator: every node has one, validator or not.
 type PrivNodeID struct {
 	NodeID
-	PrivKey crypto.PrivKey
+	PrivKey crypto.PrivKey `json:"priv_key"`
+
+	filePath string
 }
 
+// NodeGreeting is a message a node can sign to prove its identity.
 type NodeGreeting struct {
 	NodeID
-	Version string
-	ChainID string
-	Message string
-	Time    time.Time
+	Version string    `json:"version"`
+	ChainID string    `json:"chain_id"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
 }
 
+// SignedNodeGreeting is a NodeGreeting signed by the node's private key.
 type SignedNodeGreeting struct {
 	NodeGreeting
-	Signature crypto.Signature
+	Signature crypto.Signature `json:"signature"`
+}
+
+// GenPrivNodeID generates a new node identity with an ed25519 key.
+func GenPrivNodeID(name string) *PrivNodeID {
+	privKey := crypto.GenPrivKeyEd25519().Wrap()
+	return &PrivNodeID{
+		NodeID: NodeID{
+			Name:   name,
+			PubKey: privKey.PubKey(),
+		},
+		PrivKey: privKey,
+	}
+}
+
+// LoadPrivNodeID reads a node identity from the given file. The key must be
+// an ed25519 key, as required by the p2p layer.
+func LoadPrivNodeID(filePath string) (*PrivNodeID, error) {
+	jsonBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	pnid := new(PrivNodeID)
+	if err := json.Unmarshal(jsonBytes, pnid); err != nil {
+		return nil, fmt.Errorf("Error reading PrivNodeID from %v: %v", filePath, err)
+	}
+	if _, ok := pnid.PrivKey.Unwrap().(crypto.PrivKeyEd25519); !ok {
+		return nil, fmt.Errorf("Error reading PrivNodeID from %v: expected an ed25519 key", filePath)
+	}
+	// the pubkey is always derived from the private key
+	pnid.PubKey = pnid.PrivKey.PubKey()
+	pnid.filePath = filePath
+	return pnid, nil
+}
+
+// LoadOrGenPrivNodeID loads the node identity from the given file, or
+// generates and saves a new one if the file does not exist.
+func LoadOrGenPrivNodeID(filePath, name string, logger log.Logger) (*PrivNodeID, error) {
+	if _, err := os.Stat(filePath); err == nil {
+		pnid, err := LoadPrivNodeID(filePath)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Loaded PrivNodeID", "file", filePath, "pubKey", pnid.PubKey)
+		return pnid, nil
+	}
+
+	pnid := GenPrivNodeID(name)
+	pnid.SetFile(filePath)
+	if err := pnid.Save(); err != nil {
+		return nil, err
+	}
+	logger.Info("Generated PrivNodeID", "file", filePath, "pubKey", pnid.PubKey)
+	return pnid, nil
+}
+
+// SetFile sets the file the identity is saved to.
+func (pnid *PrivNodeID) SetFile(filePath string) {
+	pnid.filePath = filePath
+}
+
+// Save writes the identity to its file. The file is only readable by the owner.
+func (pnid *PrivNodeID) Save() error {
+	if pnid.filePath == "" {
+		return errors.New("Cannot save PrivNodeID: filePath not set")
+	}
+	jsonBytes, err := json.Marshal(pnid)
+	if err != nil {
+		return err
+	}
+	return cmn.WriteFileAtomic(pnid.filePath, jsonBytes, 0600)
+}
+
+// PrivKeyEd25519 returns the private key as used by the p2p.Switch.
+func (pnid *PrivNodeID) PrivKeyEd25519() crypto.PrivKeyEd25519 {
+	return pnid.PrivKey.Unwrap().(crypto.PrivKeyEd25519)
+}
+
+// PubKeyEd25519 returns the public key as advertised in the p2p.NodeInfo.
+func (pnid *PrivNodeID) PubKeyEd25519() crypto.PubKeyEd25519 {
+	return pnid.PubKey.Unwrap().(crypto.PubKeyEd25519)
+}
+
+// SignGreeting returns a greeting for the given chain signed by the node's
+// private key.
+func (pnid *PrivNodeID) SignGreeting(chainID, version, message string) *SignedNodeGreeting {
+	greeting := NodeGreeting{
+		NodeID:  pnid.NodeID,
+		Version: version,
+		ChainID: chainID,
+		Message: message,
+		Time:    time.Now(),
+	}
+	return &SignedNodeGreeting{
+		NodeGreeting: greeting,
+		Signature:    pnid.PrivKey.Sign(greeting.SignBytes()),
+	}
+}
+
+// SignBytes returns the bytes a greeting is signed over.
+func (ng NodeGreeting) SignBytes() []byte {
+	return wire.BinaryBytes(ng)
 }
 
-func (pnid *PrivNodeID) SignGreeting() *SignedNodeGreeting {
-	//greeting := NodeGreeting{}
+// Verify returns an error if the greeting was not signed by the node's key.
+func (sng *SignedNodeGreeting) Verify() error {
+	if sng.PubKey.Empty() {
+		return errors.New("SignedNodeGreeting has no PubKey")
+	}
+	if !sng.PubKey.VerifyBytes(sng.NodeGreeting.SignBytes(), sng.Signature) {
+		return errors.New("Invalid SignedNodeGreeting signature")
+	}
 	return nil
 }
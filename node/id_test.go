@@ -0,0 +1,54 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tmlibs/log"
+)
+
+func TestLoadOrGenPrivNodeID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node_id_test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "node_key.json")
+
+	// first call generates and saves the key
+	pnid, err := LoadOrGenPrivNodeID(file, "alice", log.TestingLogger())
+	require.Nil(t, err)
+	assert.Equal(t, "alice", pnid.Name)
+
+	// second call loads the same key
+	loaded, err := LoadOrGenPrivNodeID(file, "bob", log.TestingLogger())
+	require.Nil(t, err)
+	assert.Equal(t, pnid.PrivKey, loaded.PrivKey)
+	assert.Equal(t, pnid.PubKey, loaded.PubKey)
+	assert.Equal(t, "alice", loaded.Name)
+	assert.Equal(t, pnid.PubKeyEd25519(), loaded.PrivKeyEd25519().PubKey().Unwrap())
+
+	// the key file must not be readable by others
+	fi, err := os.Stat(file)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+}
+
+func TestSignGreeting(t *testing.T) {
+	pnid := GenPrivNodeID("alice")
+
+	sng := pnid.SignGreeting("test-chain", "0.10.3", "hello")
+	assert.Nil(t, sng.Verify())
+	assert.Equal(t, pnid.NodeID, sng.NodeID)
+
+	// tampering with the greeting invalidates the signature
+	sng.ChainID = "other-chain"
+	assert.NotNil(t, sng.Verify())
+
+	// a greeting signed by another node doesn't verify
+	sng = pnid.SignGreeting("test-chain", "0.10.3", "hello")
+	sng.PubKey = GenPrivNodeID("eve").PubKey
+	assert.NotNil(t, sng.Verify())
+}
@@ -2,19 +2,28 @@ package node
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	abci "github.com/tendermint/abci/types"
 	crypto "github.com/tendermint/go-crypto"
 	wire "github.com/tendermint/go-wire"
 	bc "github.com/tendermint/tendermint/blockchain"
 	cfg "github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/consensus"
+	tmdb "github.com/tendermint/tendermint/db"
+	"github.com/tendermint/tendermint/evidence"
 	mempl "github.com/tendermint/tendermint/mempool"
 	p2p "github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/privval"
 	"github.com/tendermint/tendermint/proxy"
 	rpccore "github.com/tendermint/tendermint/rpc/core"
 	grpccore "github.com/tendermint/tendermint/rpc/grpc"
@@ -24,6 +33,7 @@ import (
 	"github.com/tendermint/tendermint/state/txindex"
 	"github.com/tendermint/tendermint/state/txindex/kv"
 	"github.com/tendermint/tendermint/state/txindex/null"
+	"github.com/tendermint/tendermint/statesync"
 	"github.com/tendermint/tendermint/types"
 	"github.com/tendermint/tendermint/version"
 	cmn "github.com/tendermint/tmlibs/common"
@@ -38,51 +48,85 @@ type Node struct {
 
 	// config
 	config        *cfg.Config
-	genesisDoc    *types.GenesisDoc    // initial validator set
-	privValidator *types.PrivValidator // local node's validator key
+	genesisDoc    *types.GenesisDoc     // initial validator set
+	privValidator types.ValidatorSigner // local node's validator key
 
 	// network
-	privKey  crypto.PrivKeyEd25519 // local node's p2p key
-	sw       *p2p.Switch           // p2p connections
-	addrBook *p2p.AddrBook         // known peers
+	privNodeID *PrivNodeID   // local node's persistent p2p identity
+	sw         *p2p.Switch   // p2p connections
+	addrBook   *p2p.AddrBook // known peers
 
 	// services
 	evsw             types.EventSwitch           // pub/sub for services
+	stateDB          dbm.DB                      // for bootstrapping the state after state sync
 	blockStore       *bc.BlockStore              // store the blockchain to disk
+	stateSync        bool                        // whether to state sync on start
+	stateSyncReactor *statesync.Reactor          // for serving snapshots and state-syncing, if the app supports it
+	fastSync         bool                        // whether to fast-sync after state sync
 	bcReactor        *bc.BlockchainReactor       // for fast-syncing
+	pruner           *bc.Pruner                  // for pruning old blocks, if enabled
 	mempoolReactor   *mempl.MempoolReactor       // for gossipping transactions
+	evidencePool     *evidence.EvidencePool      // tracking evidence
 	consensusState   *consensus.ConsensusState   // latest consensus state
 	consensusReactor *consensus.ConsensusReactor // for participating in the consensus
 	proxyApp         proxy.AppConns              // connection to the application
 	rpcListeners     []net.Listener              // rpc servers
+	metricsRegistry  *prometheus.Registry        // metrics of this node
+	metricsServer    *http.Server                // serves prometheus metrics, if enabled
 	txIndexer        txindex.TxIndexer
 }
 
 func NewNodeDefault(config *cfg.Config, logger log.Logger) *Node {
 	// Get PrivValidator
-	privValidator := types.LoadOrGenPrivValidator(config.PrivValidatorFile(), logger)
+	var privValidator types.ValidatorSigner
+	if config.PrivValidatorAddr != "" {
+		// Sign with a remote signer, authenticating with the node key
+		privNodeID, err := LoadOrGenPrivNodeID(config.NodeKeyFile(), config.Moniker, logger.With("module", "p2p"))
+		if err != nil {
+			cmn.Exit(cmn.Fmt("Failed to load node key: %v", err))
+		}
+		var signerKey crypto.PubKeyEd25519
+		if !strings.HasPrefix(config.PrivValidatorAddr, "unix://") {
+			if signerKey, err = privval.ParsePubKey(config.PrivValidatorSignerKey); err != nil {
+				cmn.Exit(cmn.Fmt("A TCP remote signer needs a valid priv_validator_signer_key: %v", err))
+			}
+		}
+		signer := privval.NewSocketClient(config.PrivValidatorAddr,
+			privNodeID.PrivKey.Unwrap().(crypto.PrivKeyEd25519), signerKey)
+		signer.SetLogger(logger.With("module", "privval"))
+		if _, err := signer.Start(); err != nil {
+			cmn.Exit(cmn.Fmt("Failed to connect to remote signer: %v", err))
+		}
+		privValidator = signer
+	} else {
+		privValidator = types.LoadOrGenPrivValidator(config.PrivValidatorFile(), logger)
+	}
 	return NewNode(config, privValidator,
 		proxy.DefaultClientCreator(config.ProxyApp, config.ABCI, config.DBDir()), logger)
 }
 
-func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreator proxy.ClientCreator, logger log.Logger) *Node {
+func NewNode(config *cfg.Config, privValidator types.ValidatorSigner, clientCreator proxy.ClientCreator, logger log.Logger) *Node {
 	// Get BlockStore
-	blockStoreDB := dbm.NewDB("blockstore", config.DBBackend, config.DBDir())
+	blockStoreDB := tmdb.NewDB("blockstore", config.DBBackendFor("blockstore"), config.DBDir())
 	blockStore := bc.NewBlockStore(blockStoreDB)
 
 	consensusLogger := logger.With("module", "consensus")
 	stateLogger := logger.With("module", "state")
 
 	// Get State
-	stateDB := dbm.NewDB("state", config.DBBackend, config.DBDir())
+	stateDB := tmdb.NewDB("state", config.DBBackendFor("state"), config.DBDir())
 	state := sm.GetState(stateDB, config.GenesisFile())
 	state.SetLogger(stateLogger)
 
+	// Make metrics, which are only exposed if enabled
+	metricsRegistry := prometheus.NewRegistry()
+	csMetrics, p2pMetrics, memplMetrics, bcMetrics, proxyMetrics := makeMetrics(config.Instrumentation, metricsRegistry)
+
 	// Create the proxyApp, which manages connections (consensus, mempool, query)
 	// and sync tendermint and the app by replaying any necessary blocks
 	handshaker := consensus.NewHandshaker(state, blockStore)
 	handshaker.SetLogger(consensusLogger)
-	proxyApp := proxy.NewAppConns(clientCreator, handshaker)
+	proxyApp := proxy.NewAppConns(clientCreator, handshaker, proxyMetrics)
 	proxyApp.SetLogger(logger.With("module", "proxy"))
 	if _, err := proxyApp.Start(); err != nil {
 		cmn.Exit(cmn.Fmt("Error starting proxy app connections: %v", err))
@@ -96,20 +140,23 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 	var txIndexer txindex.TxIndexer
 	switch config.TxIndex {
 	case "kv":
-		store := dbm.NewDB("tx_index", config.DBBackend, config.DBDir())
+		store := tmdb.NewDB("tx_index", config.DBBackendFor("tx_index"), config.DBDir())
 		txIndexer = kv.NewTxIndex(store)
 	default:
 		txIndexer = &null.TxIndex{}
 	}
 	state.TxIndexer = txIndexer
 
-	// Generate node PrivKey
-	privKey := crypto.GenPrivKeyEd25519()
+	// Load or generate the node's p2p identity
+	privNodeID, err := LoadOrGenPrivNodeID(config.NodeKeyFile(), config.Moniker, logger.With("module", "p2p"))
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to load node key: %v", err))
+	}
 
 	// Make event switch
 	eventSwitch := types.NewEventSwitch()
 	eventSwitch.SetLogger(logger.With("module", "types"))
-	_, err := eventSwitch.Start()
+	_, err = eventSwitch.Start()
 	if err != nil {
 		cmn.Exit(cmn.Fmt("Failed to start switch: %v", err))
 	}
@@ -119,26 +166,57 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 	fastSync := config.FastSync
 	if state.Validators.Size() == 1 {
 		addr, _ := state.Validators.GetByIndex(0)
-		if bytes.Equal(privValidator.Address, addr) {
+		if bytes.Equal(privValidator.GetAddress(), addr) {
 			fastSync = false
 		}
 	}
 
+	// Decide whether to state sync or not
+	// We only state sync when we have no blocks yet, and the app supports snapshots.
+	snapshotter, hasSnapshots := proxy.NewSnapshotter(clientCreator)
+	stateSync := config.StateSync.Enable && state.LastBlockHeight == 0
+	if stateSync && !hasSnapshots {
+		cmn.Exit("State sync is enabled, but the app does not support snapshots")
+	}
+	// TODO: verify the validator accums and consensus params at the snapshot
+	// height (see statesync.liteStateProvider). They're not in the headers, so
+	// the light client can't, and we would start with the ones of an untrusted
+	// rpc node.
+	if stateSync {
+		cmn.Exit("State sync can't verify the validator accums and consensus params of snapshots yet. Disable it to fast sync instead")
+	}
+
 	// Log whether this node is a validator or an observer
-	if state.Validators.HasAddress(privValidator.Address) {
+	if state.Validators.HasAddress(privValidator.GetAddress()) {
 		consensusLogger.Info("This node is a validator")
 	} else {
 		consensusLogger.Info("This node is not a validator")
 	}
 
 	// Make BlockchainReactor
-	bcReactor := bc.NewBlockchainReactor(state.Copy(), proxyApp.Consensus(), blockStore, fastSync)
+	bcReactor := bc.NewBlockchainReactor(state.Copy(), proxyApp.Consensus(), blockStore, fastSync && !stateSync)
 	bcReactor.SetLogger(logger.With("module", "blockchain"))
+	bcReactor.SetMetrics(bcMetrics)
+
+	// Make Pruner
+	var pruner *bc.Pruner
+	if config.RetainBlocks > 0 {
+		pruner = bc.NewPruner(blockStore, txIndexer, stateDB, config.RetainBlocks, config.KeepEvery)
+		pruner.SetLogger(logger.With("module", "pruner"))
+	}
 
 	// Make MempoolReactor
 	mempoolLogger := logger.With("module", "mempool")
 	mempool := mempl.NewMempool(config.Mempool, proxyApp.Mempool(), state.LastBlockHeight)
 	mempool.SetLogger(mempoolLogger)
+	mempool.SetMetrics(memplMetrics)
+	if prioritizer, ok := proxy.NewTxPrioritizer(clientCreator); ok {
+		mempool.SetPriorityFunc(func(tx types.Tx, res *abci.ResponseCheckTx) (int64, string) {
+			return prioritizer.TxPriority(tx, res)
+		})
+	} else {
+		mempoolLogger.Info("The app doesn't prioritize txs, only in-process apps implementing TxPrioritizer can. Txs are reaped in arrival order")
+	}
 	mempoolReactor := mempl.NewMempoolReactor(config.Mempool, mempool)
 	mempoolReactor.SetLogger(mempoolLogger)
 
@@ -146,22 +224,44 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 		mempool.EnableTxsAvailable()
 	}
 
+	// Make Evidence Reactor
+	evidenceDB := tmdb.NewDB("evidence", config.DBBackendFor("evidence"), config.DBDir())
+	evidenceStore := evidence.NewEvidenceStore(evidenceDB)
+	evidencePool := evidence.NewEvidencePool(stateDB, evidenceStore)
+	evidenceLogger := logger.With("module", "evidence")
+	evidencePool.SetLogger(evidenceLogger)
+	evidenceReactor := evidence.NewEvidenceReactor(evidencePool)
+	evidenceReactor.SetLogger(evidenceLogger)
+
 	// Make ConsensusReactor
-	consensusState := consensus.NewConsensusState(config.Consensus, state.Copy(), proxyApp.Consensus(), blockStore, mempool)
+	consensusState := consensus.NewConsensusState(config.Consensus, state.Copy(), proxyApp.Consensus(), blockStore, mempool, evidencePool)
 	consensusState.SetLogger(consensusLogger)
+	consensusState.SetMetrics(csMetrics)
 	if privValidator != nil {
 		consensusState.SetPrivValidator(privValidator)
 	}
-	consensusReactor := consensus.NewConsensusReactor(consensusState, fastSync)
+	consensusReactor := consensus.NewConsensusReactor(consensusState, fastSync || stateSync)
 	consensusReactor.SetLogger(consensusLogger)
 
+	// Make StateSyncReactor
+	var stateSyncReactor *statesync.Reactor
+	if hasSnapshots {
+		stateSyncReactor = statesync.NewReactor(snapshotter, proxyApp.Query())
+		stateSyncReactor.SetLogger(logger.With("module", "statesync"))
+	}
+
 	p2pLogger := logger.With("module", "p2p")
 
 	sw := p2p.NewSwitch(config.P2P)
 	sw.SetLogger(p2pLogger)
+	sw.SetMetrics(p2pMetrics)
 	sw.AddReactor("MEMPOOL", mempoolReactor)
 	sw.AddReactor("BLOCKCHAIN", bcReactor)
 	sw.AddReactor("CONSENSUS", consensusReactor)
+	sw.AddReactor("EVIDENCE", evidenceReactor)
+	if stateSyncReactor != nil {
+		sw.AddReactor("STATESYNC", stateSyncReactor)
+	}
 
 	// Optionally, start the pex reactor
 	var addrBook *p2p.AddrBook
@@ -202,7 +302,7 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 
 	// add the event switch to all services
 	// they should all satisfy events.Eventable
-	SetEventSwitch(eventSwitch, bcReactor, mempoolReactor, consensusReactor)
+	SetEventSwitch(eventSwitch, bcReactor, mempoolReactor, consensusReactor, evidenceReactor)
 
 	// run the profile server
 	profileHost := config.ProfListenAddress
@@ -218,17 +318,24 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 		genesisDoc:    state.GenesisDoc,
 		privValidator: privValidator,
 
-		privKey:  privKey,
-		sw:       sw,
-		addrBook: addrBook,
+		privNodeID: privNodeID,
+		sw:         sw,
+		addrBook:   addrBook,
 
 		evsw:             eventSwitch,
+		stateDB:          stateDB,
 		blockStore:       blockStore,
+		stateSync:        stateSync,
+		stateSyncReactor: stateSyncReactor,
+		fastSync:         fastSync,
 		bcReactor:        bcReactor,
+		pruner:           pruner,
 		mempoolReactor:   mempoolReactor,
+		evidencePool:     evidencePool,
 		consensusState:   consensusState,
 		consensusReactor: consensusReactor,
 		proxyApp:         proxyApp,
+		metricsRegistry:  metricsRegistry,
 		txIndexer:        txIndexer,
 	}
 	node.BaseService = *cmn.NewBaseService(logger, "Node", node)
@@ -236,6 +343,15 @@ func NewNode(config *cfg.Config, privValidator *types.PrivValidator, clientCreat
 }
 
 func (n *Node) OnStart() error {
+	// Replay the mempool WAL here rather than in NewNode, so the txs get the
+	// priority of the PriorityFunc set in between. The app doesn't have a
+	// state to check txs against until it's synced.
+	if !n.stateSync {
+		if err := n.mempoolReactor.Mempool.ReplayWAL(n.blockStore); err != nil {
+			return fmt.Errorf("Failed to replay mempool wal: %v", err)
+		}
+	}
+
 	// Create & add listener
 	protocol, address := ProtocolAndAddress(n.config.P2P.ListenAddress)
 	l := p2p.NewDefaultListener(protocol, address, n.config.P2P.SkipUPNP, n.Logger.With("module", "p2p"))
@@ -243,7 +359,7 @@ func (n *Node) OnStart() error {
 
 	// Start the switch
 	n.sw.SetNodeInfo(n.makeNodeInfo())
-	n.sw.SetNodePrivKey(n.privKey)
+	n.sw.SetNodePrivKey(n.privNodeID.PrivKeyEd25519())
 	_, err := n.sw.Start()
 	if err != nil {
 		return err
@@ -258,6 +374,22 @@ func (n *Node) OnStart() error {
 		}
 	}
 
+	// Restore the app from a snapshot, then fast-sync or join consensus
+	if n.stateSync {
+		stateProvider, err := n.makeStateProvider()
+		if err != nil {
+			return err
+		}
+		go n.startStateSync(stateProvider)
+	}
+
+	// Start pruning old blocks
+	if n.pruner != nil {
+		if _, err := n.pruner.Start(); err != nil {
+			return err
+		}
+	}
+
 	// Run the RPC server
 	if n.config.RPC.ListenAddress != "" {
 		listeners, err := n.startRPC()
@@ -267,6 +399,11 @@ func (n *Node) OnStart() error {
 		n.rpcListeners = listeners
 	}
 
+	// Run the metrics server
+	if n.config.Instrumentation.Prometheus {
+		n.metricsServer = n.startMetricsServer(n.config.Instrumentation.PrometheusListenAddr)
+	}
+
 	return nil
 }
 
@@ -277,15 +414,93 @@ func (n *Node) OnStop() {
 	// TODO: gracefully disconnect from peers.
 	n.sw.Stop()
 
+	if n.pruner != nil {
+		n.pruner.Stop()
+	}
+
 	for _, l := range n.rpcListeners {
 		n.Logger.Info("Closing rpc listener", "listener", l)
 		if err := l.Close(); err != nil {
 			n.Logger.Error("Error closing listener", "listener", l, "err", err)
 		}
 	}
+
+	if n.metricsServer != nil {
+		if err := n.metricsServer.Shutdown(context.Background()); err != nil {
+			n.Logger.Error("Error stopping metrics server", "err", err)
+		}
+	}
+
+	// close the connection to the remote signer, if any
+	if signer, ok := n.privValidator.(*privval.SocketClient); ok {
+		signer.Stop()
+	}
+}
+
+// makeStateProvider returns a light client backed StateProvider for state
+// sync, which trusts the header configured in config.StateSync.
+func (n *Node) makeStateProvider() (statesync.StateProvider, error) {
+	config := n.config.StateSync
+	if config.RPCServer == "" {
+		return nil, errors.New("State sync needs an rpc_server for light client verification")
+	}
+	trustHash, err := hex.DecodeString(config.TrustHash)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid trust_hash: %v", err)
+	}
+	genesis := sm.LoadState(n.stateDB)
+	return statesync.NewLiteStateProvider(config.RPCServer, config.TrustHeight, trustHash, genesis)
+}
+
+// startStateSync restores the app from a snapshot, bootstraps the state and
+// block store at the snapshot height, and hands off to fast-sync or consensus.
+func (n *Node) startStateSync(stateProvider statesync.StateProvider) {
+	config := n.config.StateSync
+	state, commit, err := n.stateSyncReactor.Sync(stateProvider, config.Discovery(), config.ChunkFetchers)
+	if err != nil {
+		n.exitStateSync("State sync failed", err)
+		return
+	}
+	state.SetLogger(n.Logger.With("module", "state"))
+	state.TxIndexer = n.txIndexer
+	state.Save()
+	if err := n.blockStore.Bootstrap(state.LastBlockHeight, commit); err != nil {
+		n.exitStateSync("Failed to bootstrap block store", err)
+		return
+	}
+
+	if n.fastSync {
+		if err := n.bcReactor.SwitchToFastSync(state.Copy()); err != nil {
+			n.exitStateSync("Failed to switch to fast-sync", err)
+		}
+		return
+	}
+	n.consensusReactor.SwitchToConsensus(state.Copy())
+}
+
+// exitStateSync stops the node and exits after state sync failed: the
+// consensus reactor waits for it, so the node could never make progress.
+// The app may have been partially restored, so it can't just sync from
+// genesis instead.
+func (n *Node) exitStateSync(msg string, err error) {
+	n.Logger.Error(msg, "err", err)
+	n.Stop()
+	cmn.Exit(cmn.Fmt("%v: %v", msg, err))
 }
 
+// HaltExitCode is the exit code of RunForever when consensus halts at the
+// configured halt height or time, so it can be told apart from a crash.
+const HaltExitCode = 3
+
 func (n *Node) RunForever() {
+	// Stop once consensus halts, eg. for an upgrade
+	go func() {
+		<-n.consensusState.Halted()
+		n.Logger.Info("Consensus halted, stopping the node", "exitCode", HaltExitCode)
+		n.Stop()
+		os.Exit(HaltExitCode)
+	}()
+
 	// Sleep forever and then...
 	cmn.TrapSignal(func() {
 		n.Stop()
@@ -314,11 +529,12 @@ func (n *Node) ConfigureRPC() {
 	rpccore.SetConsensusState(n.consensusState)
 	rpccore.SetMempool(n.mempoolReactor.Mempool)
 	rpccore.SetSwitch(n.sw)
-	rpccore.SetPubKey(n.privValidator.PubKey)
+	rpccore.SetPubKey(n.privValidator.GetPubKey())
 	rpccore.SetGenesisDoc(n.genesisDoc)
 	rpccore.SetAddrBook(n.addrBook)
 	rpccore.SetProxyAppQuery(n.proxyApp.Query())
 	rpccore.SetTxIndexer(n.txIndexer)
+	rpccore.SetStateDB(n.stateDB)
 	rpccore.SetConsensusReactor(n.consensusReactor)
 	rpccore.SetLogger(n.Logger.With("module", "rpc"))
 }
@@ -360,6 +576,36 @@ func (n *Node) startRPC() ([]net.Listener, error) {
 	return listeners, nil
 }
 
+// startMetricsServer serves the prometheus metrics of the node on /metrics.
+func (n *Node) startMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(n.metricsRegistry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			n.Logger.Error("Metrics server", "err", err)
+		}
+	}()
+	return srv
+}
+
+// makeMetrics returns the metrics for each package, registered with the
+// node's registry if prometheus is enabled. Each node has its own registry,
+// so several nodes can run in one process.
+func makeMetrics(config *cfg.InstrumentationConfig, registry *prometheus.Registry) (*consensus.Metrics, *p2p.Metrics, *mempl.Metrics, *bc.Metrics, *proxy.Metrics) {
+	if config.Prometheus {
+		return consensus.PrometheusMetrics(registry), p2p.PrometheusMetrics(registry), mempl.PrometheusMetrics(registry),
+			bc.PrometheusMetrics(registry), proxy.PrometheusMetrics(registry)
+	}
+	return consensus.NopMetrics(), p2p.NopMetrics(), mempl.NopMetrics(), bc.NopMetrics(), proxy.NopMetrics()
+}
+
+// MetricsRegistry returns the registry of the node's prometheus metrics.
+// It's empty unless prometheus is enabled.
+func (n *Node) MetricsRegistry() *prometheus.Registry {
+	return n.metricsRegistry
+}
+
 func (n *Node) Switch() *p2p.Switch {
 	return n.sw
 }
@@ -380,15 +626,24 @@ func (n *Node) MempoolReactor() *mempl.MempoolReactor {
 	return n.mempoolReactor
 }
 
+func (n *Node) EvidencePool() *evidence.EvidencePool {
+	return n.evidencePool
+}
+
 func (n *Node) EventSwitch() types.EventSwitch {
 	return n.evsw
 }
 
 // XXX: for convenience
-func (n *Node) PrivValidator() *types.PrivValidator {
+func (n *Node) PrivValidator() types.ValidatorSigner {
 	return n.privValidator
 }
 
+// PrivNodeID returns the node's p2p identity.
+func (n *Node) PrivNodeID() *PrivNodeID {
+	return n.privNodeID
+}
+
 func (n *Node) GenesisDoc() *types.GenesisDoc {
 	return n.genesisDoc
 }
@@ -404,7 +659,7 @@ func (n *Node) makeNodeInfo() *p2p.NodeInfo {
 	}
 
 	nodeInfo := &p2p.NodeInfo{
-		PubKey:  n.privKey.PubKey().Unwrap().(crypto.PubKeyEd25519),
+		PubKey:  n.privNodeID.PubKeyEd25519(),
 		Moniker: n.config.Moniker,
 		Network: n.consensusState.GetState().ChainID,
 		Version: version.Version,
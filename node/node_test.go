@@ -4,6 +4,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	cfg "github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tmlibs/log"
 )
@@ -32,3 +36,25 @@ func TestNodeStartStop(t *testing.T) {
 		t.Fatal("timed out waiting for shutdown")
 	}
 }
+
+func TestNodeMetrics(t *testing.T) {
+	config := cfg.DefaultInstrumentationConfig()
+	config.Prometheus = true
+
+	// nodes in the same process register their metrics in their own registry
+	for i := 1; i <= 2; i++ {
+		registry := prometheus.NewRegistry()
+		csMetrics, _, _, _, _ := makeMetrics(config, registry)
+		csMetrics.Height.Set(float64(i))
+
+		families, err := registry.Gather()
+		require.Nil(t, err)
+		var height float64
+		for _, family := range families {
+			if family.GetName() == "tendermint_consensus_height" {
+				height = family.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		assert.Equal(t, float64(i), height)
+	}
+}
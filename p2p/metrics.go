@@ -0,0 +1,39 @@
+package p2p
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics contains the metrics exposed by the p2p package.
+type Metrics struct {
+	// Number of connected peers.
+	Peers prometheus.Gauge
+	// Current send and receive rates of each peer, in bytes per second.
+	PeerSendBytesRate *prometheus.GaugeVec
+	PeerRecvBytesRate *prometheus.GaugeVec
+}
+
+// PrometheusMetrics returns the peer metrics, registered with registry.
+func PrometheusMetrics(registry prometheus.Registerer) *Metrics {
+	m := NopMetrics()
+	registry.MustRegister(m.Peers, m.PeerSendBytesRate, m.PeerRecvBytesRate)
+	return m
+}
+
+// NopMetrics returns unregistered peer metrics.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		Peers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "p2p",
+			Name: "peers", Help: "Number of connected peers.",
+		}),
+		PeerSendBytesRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "p2p",
+			Name: "peer_send_bytes_rate", Help: "Current send rate to the peer, in bytes per second.",
+		}, []string{"peer_id"}),
+		PeerRecvBytesRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "tendermint", Subsystem: "p2p",
+			Name: "peer_recv_bytes_rate", Help: "Current receive rate from the peer, in bytes per second.",
+		}, []string{"peer_id"}),
+	}
+}
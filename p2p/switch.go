@@ -15,6 +15,9 @@ import (
 const (
 	reconnectAttempts = 30
 	reconnectInterval = 3 * time.Second
+
+	// how often to record peer metrics
+	metricsIntervalSeconds = 10
 )
 
 type Reactor interface {
@@ -73,6 +76,8 @@ type Switch struct {
 
 	filterConnByAddr   func(net.Addr) error
 	filterConnByPubKey func(crypto.PubKeyEd25519) error
+
+	metrics *Metrics
 }
 
 var (
@@ -89,12 +94,19 @@ func NewSwitch(config *cfg.P2PConfig) *Switch {
 		peers:        NewPeerSet(),
 		dialing:      cmn.NewCMap(),
 		nodeInfo:     nil,
+		metrics:      NopMetrics(),
 	}
 	sw.peerConfig.MConfig.flushThrottle = time.Duration(config.FlushThrottleTimeout) * time.Millisecond // TODO: collapse the peerConfig into the config ?
 	sw.BaseService = *cmn.NewBaseService(nil, "P2P Switch", sw)
 	return sw
 }
 
+// SetMetrics sets the metrics to report to.
+// NOTE: Not goroutine safe.
+func (sw *Switch) SetMetrics(metrics *Metrics) {
+	sw.metrics = metrics
+}
+
 // AddReactor adds the given reactor to the switch.
 // NOTE: Not goroutine safe.
 func (sw *Switch) AddReactor(name string, reactor Reactor) Reactor {
@@ -181,6 +193,7 @@ func (sw *Switch) OnStart() error {
 	for _, listener := range sw.listeners {
 		go sw.listenerRoutine(listener)
 	}
+	go sw.metricsRoutine()
 	return nil
 }
 
@@ -247,6 +260,7 @@ func (sw *Switch) AddPeer(peer *Peer) error {
 	if err := sw.peers.Add(peer); err != nil {
 		return err
 	}
+	sw.metrics.Peers.Set(float64(sw.peers.Size()))
 
 	sw.Logger.Info("Added peer", "peer", peer)
 	return nil
@@ -439,12 +453,35 @@ func (sw *Switch) StopPeerGracefully(peer *Peer) {
 
 func (sw *Switch) stopAndRemovePeer(peer *Peer, reason interface{}) {
 	sw.peers.Remove(peer)
+	sw.metrics.Peers.Set(float64(sw.peers.Size()))
+	sw.metrics.PeerSendBytesRate.DeleteLabelValues(peer.Key)
+	sw.metrics.PeerRecvBytesRate.DeleteLabelValues(peer.Key)
 	peer.Stop()
 	for _, reactor := range sw.reactors {
 		reactor.RemovePeer(peer, reason)
 	}
 }
 
+// metricsRoutine periodically records the send and receive rates of all peers.
+func (sw *Switch) metricsRoutine() {
+	ticker := time.NewTicker(metricsIntervalSeconds * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			peers := sw.peers.List()
+			sw.metrics.Peers.Set(float64(len(peers)))
+			for _, peer := range peers {
+				status := peer.Connection().Status()
+				sw.metrics.PeerSendBytesRate.WithLabelValues(peer.Key).Set(float64(status.SendMonitor.CurRate))
+				sw.metrics.PeerRecvBytesRate.WithLabelValues(peer.Key).Set(float64(status.RecvMonitor.CurRate))
+			}
+		case <-sw.Quit:
+			return
+		}
+	}
+}
+
 func (sw *Switch) listenerRoutine(l Listener) {
 	for {
 		inConn, ok := <-l.Connections()
@@ -0,0 +1,237 @@
+package privval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	crypto "github.com/tendermint/go-crypto"
+	cmn "github.com/tendermint/tmlibs/common"
+
+	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/types"
+)
+
+const (
+	dialTimeout      = 3 * time.Second
+	handshakeTimeout = 5 * time.Second
+	requestTimeout   = 5 * time.Second
+)
+
+var _ types.ValidatorSigner = (*SocketClient)(nil)
+
+// SocketClient is a types.ValidatorSigner which forwards all signing requests
+// to a remote signer (eg. a SocketServer) over a TCP or unix socket.
+// The remote signer is responsible for double signing protection, so the key
+// never has to live on the validator host.
+//
+// TCP connections are encrypted and authenticated with a p2p.SecretConnection:
+// the client proves it holds privKey, and only talks to a signer with the
+// pinned signerKey.
+type SocketClient struct {
+	cmn.BaseService
+
+	addr      string
+	privKey   crypto.PrivKeyEd25519
+	signerKey crypto.PubKeyEd25519
+
+	mtx    sync.Mutex
+	conn   net.Conn
+	pubKey crypto.PubKey
+}
+
+// NewSocketClient returns a SocketClient for the signer at the given address,
+// eg. "tcp://127.0.0.1:46659" or "unix:///var/run/signer.sock".
+// Over TCP, it authenticates with privKey and only accepts a signer with the
+// signerKey. The client must be started before it can be used.
+func NewSocketClient(addr string, privKey crypto.PrivKeyEd25519, signerKey crypto.PubKeyEd25519) *SocketClient {
+	sc := &SocketClient{
+		addr:      addr,
+		privKey:   privKey,
+		signerKey: signerKey,
+	}
+	sc.BaseService = *cmn.NewBaseService(nil, "SocketClient", sc)
+	return sc
+}
+
+// OnStart implements cmn.Service.
+// It connects to the remote signer and fetches its public key.
+func (sc *SocketClient) OnStart() error {
+	if err := sc.BaseService.OnStart(); err != nil {
+		return err
+	}
+
+	res, err := sc.call(&PubKeyMsg{})
+	if err != nil {
+		return err
+	}
+	pubKeyMsg, ok := res.(*PubKeyMsg)
+	if !ok {
+		return unexpectedMsgErr(res)
+	}
+	if pubKeyMsg.PubKey.Empty() {
+		return errors.New("Remote signer returned an empty PubKey")
+	}
+
+	sc.mtx.Lock()
+	sc.pubKey = pubKeyMsg.PubKey
+	sc.mtx.Unlock()
+
+	sc.Logger.Info("Connected to remote signer", "addr", sc.addr, "pubKey", pubKeyMsg.PubKey)
+	return nil
+}
+
+// OnStop implements cmn.Service.
+func (sc *SocketClient) OnStop() {
+	sc.BaseService.OnStop()
+
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	sc.closeConn()
+}
+
+// GetAddress implements types.ValidatorSigner.
+func (sc *SocketClient) GetAddress() []byte {
+	return sc.GetPubKey().Address()
+}
+
+// GetPubKey implements types.ValidatorSigner.
+func (sc *SocketClient) GetPubKey() crypto.PubKey {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	return sc.pubKey
+}
+
+// SignVote implements types.ValidatorSigner.
+func (sc *SocketClient) SignVote(chainID string, vote *types.Vote) error {
+	res, err := sc.call(&SignVoteMsg{ChainID: chainID, Vote: vote})
+	if err != nil {
+		return fmt.Errorf("Error signing vote: %v", err)
+	}
+	signed, ok := res.(*SignVoteMsg)
+	if !ok || signed.Vote == nil {
+		return fmt.Errorf("Error signing vote: %v", unexpectedMsgErr(res))
+	}
+	// the signer may have used the timestamp of a vote it already signed
+	// for the same height/round/step
+	signedVote := vote.Copy()
+	signedVote.Timestamp = signed.Vote.Timestamp
+	if err := sc.verify(types.SignBytes(chainID, signedVote), signed.Vote.Signature); err != nil {
+		return fmt.Errorf("Error signing vote: %v", err)
+	}
+	vote.Timestamp = signed.Vote.Timestamp
+	vote.Signature = signed.Vote.Signature
+	return nil
+}
+
+// SignProposal implements types.ValidatorSigner.
+func (sc *SocketClient) SignProposal(chainID string, proposal *types.Proposal) error {
+	res, err := sc.call(&SignProposalMsg{ChainID: chainID, Proposal: proposal})
+	if err != nil {
+		return fmt.Errorf("Error signing proposal: %v", err)
+	}
+	signed, ok := res.(*SignProposalMsg)
+	if !ok || signed.Proposal == nil {
+		return fmt.Errorf("Error signing proposal: %v", unexpectedMsgErr(res))
+	}
+	if err := sc.verify(types.SignBytes(chainID, proposal), signed.Proposal.Signature); err != nil {
+		return fmt.Errorf("Error signing proposal: %v", err)
+	}
+	proposal.Signature = signed.Proposal.Signature
+	return nil
+}
+
+// SignHeartbeat implements types.ValidatorSigner.
+func (sc *SocketClient) SignHeartbeat(chainID string, heartbeat *types.Heartbeat) error {
+	res, err := sc.call(&SignHeartbeatMsg{ChainID: chainID, Heartbeat: heartbeat})
+	if err != nil {
+		return fmt.Errorf("Error signing heartbeat: %v", err)
+	}
+	signed, ok := res.(*SignHeartbeatMsg)
+	if !ok || signed.Heartbeat == nil {
+		return fmt.Errorf("Error signing heartbeat: %v", unexpectedMsgErr(res))
+	}
+	if err := sc.verify(types.SignBytes(chainID, heartbeat), signed.Heartbeat.Signature); err != nil {
+		return fmt.Errorf("Error signing heartbeat: %v", err)
+	}
+	heartbeat.Signature = signed.Heartbeat.Signature
+	return nil
+}
+
+// verify checks the signature returned by the remote signer,
+// so we never gossip a signature made with the wrong key or over the wrong bytes.
+func (sc *SocketClient) verify(signBytes []byte, sig crypto.Signature) error {
+	if !sc.GetPubKey().VerifyBytes(signBytes, sig) {
+		return errors.New("Remote signer returned an invalid signature")
+	}
+	return nil
+}
+
+// call sends the request and waits for the response. If the connection is
+// broken, it is closed and re-established on the next call.
+func (sc *SocketClient) call(req SocketMessage) (SocketMessage, error) {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+
+	if sc.conn == nil {
+		protocol, address := protocolAndAddress(sc.addr)
+		conn, err := net.DialTimeout(protocol, address, dialTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to connect to remote signer at %v: %v", sc.addr, err)
+		}
+		if protocol != "unix" {
+			if conn, err = sc.authenticate(conn); err != nil {
+				return nil, fmt.Errorf("Failed to authenticate remote signer at %v: %v", sc.addr, err)
+			}
+		}
+		sc.conn = conn
+	}
+
+	if err := sc.conn.SetDeadline(time.Now().Add(requestTimeout)); err != nil {
+		sc.closeConn()
+		return nil, err
+	}
+	if err := writeMsg(sc.conn, req); err != nil {
+		sc.closeConn()
+		return nil, err
+	}
+	res, err := readMsg(sc.conn)
+	if err != nil {
+		sc.closeConn()
+		return nil, err
+	}
+	return res, nil
+}
+
+// authenticate upgrades the connection to a SecretConnection, and checks the
+// signer has the pinned key. The connection is closed on error.
+func (sc *SocketClient) authenticate(conn net.Conn) (net.Conn, error) {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	secretConn, err := p2p.MakeSecretConnection(conn, sc.privKey)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if remotePubKey := secretConn.RemotePubKey(); !bytes.Equal(remotePubKey[:], sc.signerKey[:]) {
+		conn.Close()
+		return nil, fmt.Errorf("Expected signer key %X, got %X", sc.signerKey[:], remotePubKey[:])
+	}
+	return secretConn, nil
+}
+
+// closeConn closes the connection, if any. The caller must hold mtx.
+func (sc *SocketClient) closeConn() {
+	if sc.conn == nil {
+		return
+	}
+	if err := sc.conn.Close(); err != nil {
+		sc.Logger.Error("Error closing connection to remote signer", "err", err)
+	}
+	sc.conn = nil
+}
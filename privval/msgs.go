@@ -0,0 +1,115 @@
+package privval
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	crypto "github.com/tendermint/go-crypto"
+	wire "github.com/tendermint/go-wire"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+const (
+	maxSocketMessageSize = 1048576 // 1MB
+
+	msgTypePubKey        = byte(0x01)
+	msgTypeSignVote      = byte(0x10)
+	msgTypeSignProposal  = byte(0x11)
+	msgTypeSignHeartbeat = byte(0x12)
+	msgTypeError         = byte(0x20)
+)
+
+// SocketMessage is a message sent between a SocketClient and a SocketServer.
+// Every request is answered with a message of the same type, or an ErrorMsg.
+type SocketMessage interface{}
+
+var _ = wire.RegisterInterface(
+	struct{ SocketMessage }{},
+	wire.ConcreteType{&PubKeyMsg{}, msgTypePubKey},
+	wire.ConcreteType{&SignVoteMsg{}, msgTypeSignVote},
+	wire.ConcreteType{&SignProposalMsg{}, msgTypeSignProposal},
+	wire.ConcreteType{&SignHeartbeatMsg{}, msgTypeSignHeartbeat},
+	wire.ConcreteType{&ErrorMsg{}, msgTypeError},
+)
+
+// PubKeyMsg requests (with an empty PubKey) or returns the signer's public key.
+type PubKeyMsg struct {
+	PubKey crypto.PubKey
+}
+
+// SignVoteMsg requests a signature for a vote, or returns the signed vote.
+type SignVoteMsg struct {
+	ChainID string
+	Vote    *types.Vote
+}
+
+// SignProposalMsg requests a signature for a proposal, or returns the signed proposal.
+type SignProposalMsg struct {
+	ChainID  string
+	Proposal *types.Proposal
+}
+
+// SignHeartbeatMsg requests a signature for a heartbeat, or returns the signed heartbeat.
+type SignHeartbeatMsg struct {
+	ChainID   string
+	Heartbeat *types.Heartbeat
+}
+
+// ErrorMsg is returned by the signer when it refuses or fails to sign.
+type ErrorMsg struct {
+	Error string
+}
+
+func readMsg(r io.Reader) (SocketMessage, error) {
+	var n int
+	var err error
+	msg := wire.ReadBinary(struct{ SocketMessage }{}, r, maxSocketMessageSize, &n, &err)
+	if err != nil {
+		return nil, err
+	}
+	return msg.(struct{ SocketMessage }).SocketMessage, nil
+}
+
+func writeMsg(w io.Writer, msg SocketMessage) error {
+	var n int
+	var err error
+	wire.WriteBinary(struct{ SocketMessage }{msg}, w, &n, &err)
+	return err
+}
+
+// protocolAndAddress splits an address into the protocol and address components.
+// For instance, "tcp://127.0.0.1:8080" will be split into "tcp" and "127.0.0.1:8080".
+// If the address has no protocol prefix, the default is "tcp".
+func protocolAndAddress(listenAddr string) (string, string) {
+	protocol, address := "tcp", listenAddr
+	parts := strings.SplitN(address, "://", 2)
+	if len(parts) == 2 {
+		protocol, address = parts[0], parts[1]
+	}
+	return protocol, address
+}
+
+// ParsePubKey parses a hex encoded ed25519 public key, as used to pin the keys
+// of the SocketClient and SocketServer.
+func ParsePubKey(hexKey string) (crypto.PubKeyEd25519, error) {
+	var pubKey crypto.PubKeyEd25519
+	keyBytes, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return pubKey, fmt.Errorf("Invalid public key %v: %v", hexKey, err)
+	}
+	if len(keyBytes) != len(pubKey) {
+		return pubKey, fmt.Errorf("Invalid public key %v: expected %d bytes, got %d", hexKey, len(pubKey), len(keyBytes))
+	}
+	copy(pubKey[:], keyBytes)
+	return pubKey, nil
+}
+
+func unexpectedMsgErr(msg SocketMessage) error {
+	if errMsg, ok := msg.(*ErrorMsg); ok {
+		return fmt.Errorf("Remote signer error: %v", errMsg.Error)
+	}
+	return fmt.Errorf("Unexpected response from remote signer: %T", msg)
+}
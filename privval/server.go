@@ -0,0 +1,193 @@
+package privval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	crypto "github.com/tendermint/go-crypto"
+	cmn "github.com/tendermint/tmlibs/common"
+
+	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/types"
+)
+
+// SocketServer serves signing requests from SocketClients on a TCP or unix socket.
+// Requests are signed by the given types.ValidatorSigner; with a types.PrivValidator
+// the server keeps its own LastHeight/LastRound/LastStep and refuses to double sign,
+// no matter what the clients ask for.
+//
+// TCP connections are encrypted and authenticated with a p2p.SecretConnection:
+// the server proves it holds privKey, and only clients with one of the
+// authorized keys are served. Unix sockets are left to the file permissions.
+type SocketServer struct {
+	cmn.BaseService
+
+	addr           string
+	privKey        crypto.PrivKeyEd25519
+	authorizedKeys []crypto.PubKeyEd25519
+	privVal        types.ValidatorSigner
+	listener       net.Listener
+}
+
+// NewSocketServer returns a SocketServer which will listen on the given address,
+// eg. "tcp://127.0.0.1:46659" or "unix:///var/run/signer.sock".
+// Over TCP, it authenticates with privKey and only serves the clients with one
+// of the authorizedKeys.
+func NewSocketServer(addr string, privKey crypto.PrivKeyEd25519, authorizedKeys []crypto.PubKeyEd25519,
+	privVal types.ValidatorSigner) *SocketServer {
+	ss := &SocketServer{
+		addr:           addr,
+		privKey:        privKey,
+		authorizedKeys: authorizedKeys,
+		privVal:        privVal,
+	}
+	ss.BaseService = *cmn.NewBaseService(nil, "SocketServer", ss)
+	return ss
+}
+
+// OnStart implements cmn.Service.
+func (ss *SocketServer) OnStart() error {
+	if err := ss.BaseService.OnStart(); err != nil {
+		return err
+	}
+	protocol, address := protocolAndAddress(ss.addr)
+	if protocol != "unix" && len(ss.authorizedKeys) == 0 {
+		return errors.New("Refusing to listen on a TCP socket without authorized keys")
+	}
+	ln, err := net.Listen(protocol, address)
+	if err != nil {
+		return err
+	}
+	ss.listener = ln
+	go ss.acceptConnectionsRoutine()
+	return nil
+}
+
+// OnStop implements cmn.Service.
+func (ss *SocketServer) OnStop() {
+	ss.BaseService.OnStop()
+	if err := ss.listener.Close(); err != nil {
+		ss.Logger.Error("Error closing listener", "err", err)
+	}
+}
+
+// Addr returns the address the server is listening on.
+func (ss *SocketServer) Addr() net.Addr {
+	return ss.listener.Addr()
+}
+
+func (ss *SocketServer) acceptConnectionsRoutine() {
+	for {
+		conn, err := ss.listener.Accept()
+		if err != nil {
+			if !ss.IsRunning() {
+				return // Ignore error from listener closing.
+			}
+			ss.Logger.Error("Failed to accept connection", "err", err)
+			continue
+		}
+		ss.Logger.Info("Accepted a new connection", "remote", conn.RemoteAddr())
+		go ss.handleConnection(conn)
+	}
+}
+
+func (ss *SocketServer) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	if protocol, _ := protocolAndAddress(ss.addr); protocol != "unix" {
+		sc, err := ss.authenticate(conn)
+		if err != nil {
+			ss.Logger.Error("Refused connection", "remote", conn.RemoteAddr(), "err", err)
+			return
+		}
+		conn = sc
+	}
+
+	for {
+		if !ss.IsRunning() {
+			return
+		}
+		req, err := readMsg(conn)
+		if err != nil {
+			if err != io.EOF {
+				ss.Logger.Error("Error reading request", "err", err)
+			}
+			return
+		}
+		res := ss.handleRequest(req)
+		if err := writeMsg(conn, res); err != nil {
+			ss.Logger.Error("Error writing response", "err", err)
+			return
+		}
+	}
+}
+
+// authenticate upgrades the connection to a SecretConnection, and checks the
+// client has one of the authorized keys.
+func (ss *SocketServer) authenticate(conn net.Conn) (*p2p.SecretConnection, error) {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return nil, err
+	}
+	sc, err := p2p.MakeSecretConnection(conn, ss.privKey)
+	if err != nil {
+		return nil, err
+	}
+	remotePubKey := sc.RemotePubKey()
+	if !ss.isAuthorized(remotePubKey) {
+		return nil, fmt.Errorf("Unauthorized key %X", remotePubKey[:])
+	}
+	// requests can come at any time
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+func (ss *SocketServer) isAuthorized(pubKey crypto.PubKeyEd25519) bool {
+	for _, key := range ss.authorizedKeys {
+		if bytes.Equal(key[:], pubKey[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ss *SocketServer) handleRequest(req SocketMessage) SocketMessage {
+	var err error
+	switch req := req.(type) {
+	case *PubKeyMsg:
+		return &PubKeyMsg{ss.privVal.GetPubKey()}
+	case *SignVoteMsg:
+		if req.Vote == nil {
+			return &ErrorMsg{"Missing vote"}
+		}
+		if !types.IsVoteTypeValid(req.Vote.Type) {
+			return &ErrorMsg{cmn.Fmt("Invalid vote type %X", req.Vote.Type)}
+		}
+		if err = ss.privVal.SignVote(req.ChainID, req.Vote); err == nil {
+			return req
+		}
+	case *SignProposalMsg:
+		if req.Proposal == nil {
+			return &ErrorMsg{"Missing proposal"}
+		}
+		if err = ss.privVal.SignProposal(req.ChainID, req.Proposal); err == nil {
+			return req
+		}
+	case *SignHeartbeatMsg:
+		if req.Heartbeat == nil {
+			return &ErrorMsg{"Missing heartbeat"}
+		}
+		if err = ss.privVal.SignHeartbeat(req.ChainID, req.Heartbeat); err == nil {
+			return req
+		}
+	default:
+		return &ErrorMsg{cmn.Fmt("Unknown request type %T", req)}
+	}
+	ss.Logger.Error("Refused to sign", "req", req, "err", err)
+	return &ErrorMsg{err.Error()}
+}
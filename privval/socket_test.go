@@ -0,0 +1,180 @@
+package privval
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/tmlibs/log"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+const testChainID = "test_chain_id"
+
+func pubKeyOf(privKey crypto.PrivKeyEd25519) crypto.PubKeyEd25519 {
+	return privKey.PubKey().Unwrap().(crypto.PubKeyEd25519)
+}
+
+func newTestSigner(t *testing.T, addr string) (*SocketServer, *SocketClient, *types.PrivValidator, func()) {
+	dir, err := ioutil.TempDir("", "privval_test")
+	require.Nil(t, err)
+
+	privVal := types.GenPrivValidator()
+	privVal.SetFile(filepath.Join(dir, "priv_validator.json"))
+
+	serverKey, clientKey := crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()
+	server := NewSocketServer(addr, serverKey, []crypto.PubKeyEd25519{pubKeyOf(clientKey)}, privVal)
+	server.SetLogger(log.TestingLogger())
+	_, err = server.Start()
+	require.Nil(t, err)
+
+	listenAddr := server.Addr()
+	client := NewSocketClient(fmt.Sprintf("%s://%s", listenAddr.Network(), listenAddr.String()),
+		clientKey, pubKeyOf(serverKey))
+	client.SetLogger(log.TestingLogger())
+	_, err = client.Start()
+	require.Nil(t, err)
+
+	return server, client, privVal, func() {
+		client.Stop()
+		server.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func newVote(privVal *types.PrivValidator, height, round int, blockHash string) *types.Vote {
+	return &types.Vote{
+		ValidatorAddress: privVal.GetAddress(),
+		ValidatorIndex:   0,
+		Height:           height,
+		Round:            round,
+		Timestamp:        time.Now(),
+		Type:             types.VoteTypePrevote,
+		BlockID:          types.BlockID{Hash: []byte(blockHash)},
+	}
+}
+
+func TestSocketSignVote(t *testing.T) {
+	assert := assert.New(t)
+
+	_, client, privVal, cleanup := newTestSigner(t, "tcp://127.0.0.1:0")
+	defer cleanup()
+
+	assert.Equal(privVal.GetPubKey(), client.GetPubKey())
+	assert.Equal(privVal.GetAddress(), client.GetAddress())
+
+	vote := newVote(privVal, 1, 0, "blockhash")
+	require.Nil(t, client.SignVote(testChainID, vote))
+	assert.True(privVal.PubKey.VerifyBytes(types.SignBytes(testChainID, vote), vote.Signature))
+
+	// the signer kept track of what it signed
+	assert.Equal(1, privVal.LastHeight)
+
+	// signing the same vote again is fine
+	same := newVote(privVal, 1, 0, "blockhash")
+	require.Nil(t, client.SignVote(testChainID, same))
+	assert.Equal(vote.Signature, same.Signature)
+
+	// a conflicting vote is refused
+	conflicting := newVote(privVal, 1, 0, "otherhash")
+	assert.NotNil(client.SignVote(testChainID, conflicting))
+	assert.True(conflicting.Signature.Empty())
+
+	// so is a height regression
+	assert.NotNil(client.SignVote(testChainID, newVote(privVal, 0, 0, "blockhash")))
+
+	// and moving on works
+	assert.Nil(client.SignVote(testChainID, newVote(privVal, 2, 0, "blockhash")))
+}
+
+func TestSocketSignProposalAndHeartbeat(t *testing.T) {
+	assert := assert.New(t)
+
+	_, client, privVal, cleanup := newTestSigner(t, "tcp://127.0.0.1:0")
+	defer cleanup()
+
+	proposal := &types.Proposal{
+		Height:           1,
+		Round:            0,
+		BlockPartsHeader: types.PartSetHeader{Total: 1, Hash: []byte("parts")},
+		POLRound:         -1,
+	}
+	require.Nil(t, client.SignProposal(testChainID, proposal))
+	assert.True(privVal.PubKey.VerifyBytes(types.SignBytes(testChainID, proposal), proposal.Signature))
+
+	heartbeat := &types.Heartbeat{
+		ValidatorAddress: privVal.GetAddress(),
+		Height:           1,
+		Sequence:         1,
+	}
+	require.Nil(t, client.SignHeartbeat(testChainID, heartbeat))
+	assert.True(privVal.PubKey.VerifyBytes(types.SignBytes(testChainID, heartbeat), heartbeat.Signature))
+}
+
+func TestSocketUnix(t *testing.T) {
+	sock := filepath.Join(os.TempDir(), fmt.Sprintf("privval_test_%d.sock", time.Now().UnixNano()))
+	defer os.Remove(sock)
+
+	_, client, privVal, cleanup := newTestSigner(t, "unix://"+sock)
+	defer cleanup()
+
+	vote := newVote(privVal, 1, 0, "blockhash")
+	require.Nil(t, client.SignVote(testChainID, vote))
+	assert.True(t, privVal.PubKey.VerifyBytes(types.SignBytes(testChainID, vote), vote.Signature))
+}
+
+func TestSocketReconnect(t *testing.T) {
+	_, client, privVal, cleanup := newTestSigner(t, "tcp://127.0.0.1:0")
+	defer cleanup()
+
+	// drop the connection; the next request should redial
+	client.mtx.Lock()
+	client.closeConn()
+	client.mtx.Unlock()
+
+	vote := newVote(privVal, 1, 0, "blockhash")
+	require.Nil(t, client.SignVote(testChainID, vote))
+}
+
+func TestSocketAuthentication(t *testing.T) {
+	privVal := types.GenPrivValidator()
+	serverKey, clientKey := crypto.GenPrivKeyEd25519(), crypto.GenPrivKeyEd25519()
+
+	// a TCP signer needs authorized keys
+	server := NewSocketServer("tcp://127.0.0.1:0", serverKey, nil, privVal)
+	server.SetLogger(log.TestingLogger())
+	_, err := server.Start()
+	assert.NotNil(t, err)
+
+	server = NewSocketServer("tcp://127.0.0.1:0", serverKey, []crypto.PubKeyEd25519{pubKeyOf(clientKey)}, privVal)
+	server.SetLogger(log.TestingLogger())
+	_, err = server.Start()
+	require.Nil(t, err)
+	defer server.Stop()
+	addr := fmt.Sprintf("tcp://%s", server.Addr().String())
+
+	// an unauthorized client is refused
+	client := NewSocketClient(addr, crypto.GenPrivKeyEd25519(), pubKeyOf(serverKey))
+	client.SetLogger(log.TestingLogger())
+	_, err = client.Start()
+	assert.NotNil(t, err)
+
+	// and an authorized one doesn't talk to a signer with another key
+	client = NewSocketClient(addr, clientKey, pubKeyOf(crypto.GenPrivKeyEd25519()))
+	client.SetLogger(log.TestingLogger())
+	_, err = client.Start()
+	assert.NotNil(t, err)
+
+	client = NewSocketClient(addr, clientKey, pubKeyOf(serverKey))
+	client.SetLogger(log.TestingLogger())
+	_, err = client.Start()
+	require.Nil(t, err)
+	client.Stop()
+}
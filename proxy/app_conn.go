@@ -1,8 +1,13 @@
 package proxy
 
 import (
+	"time"
+
+	"github.com/pkg/errors"
+
 	abcicli "github.com/tendermint/abci/client"
 	"github.com/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
 )
 
 //----------------------------------------------------------------------------------------
@@ -12,11 +17,31 @@ type AppConnConsensus interface {
 	SetResponseCallback(abcicli.Callback)
 	Error() error
 
-	InitChainSync(validators []*types.Validator) (err error)
+	// InitChainSync sends the genesis to the app, and returns the app hash
+	// after the app_state is loaded. Only a GenesisInitializer gets the
+	// chainID, genesisTime and appState, and returns an app hash. Other apps
+	// get InitChain with the validators, and an error if there is an appState,
+	// since they would start without it.
+	InitChainSync(chainID string, genesisTime time.Time, validators []*types.Validator, appState []byte) (appHash []byte, err error)
+
+	// PrepareProposalSync returns the txs for the block we propose at height,
+	// given the txs from the mempool. They are unchanged if the app isn't a
+	// ProposalPreparer.
+	PrepareProposalSync(height int, txs [][]byte) ([][]byte, error)
+	// ProcessProposalSync returns whether the app accepts the proposed block.
+	// It does if the app isn't a ProposalProcessor.
+	ProcessProposalSync(hash []byte, header *types.Header, txs [][]byte) (bool, error)
 
 	BeginBlockSync(hash []byte, header *types.Header) (err error)
 	DeliverTxAsync(tx []byte) *abcicli.ReqRes
+	// TxTagsSync returns the tags of a delivered tx. There are none if the
+	// app isn't a TxTagger.
+	TxTagsSync(tx []byte, res *types.ResponseDeliverTx) ([]*tmtypes.TxTag, error)
 	EndBlockSync(height uint64) (types.ResponseEndBlock, error)
+	// ConsensusParamUpdatesSync returns the changes to the consensus params
+	// after the block at height. There are none if the app isn't a
+	// ParamsUpdater.
+	ConsensusParamUpdatesSync(height int) (*tmtypes.ConsensusParams, error)
 	CommitSync() (res types.Result)
 }
 
@@ -44,15 +69,47 @@ type AppConnQuery interface {
 // Implements AppConnConsensus (subset of abcicli.Client)
 
 type appConnConsensus struct {
-	appConn abcicli.Client
+	appConn     abcicli.Client
+	initializer GenesisInitializer
+	preparer    ProposalPreparer
+	processor   ProposalProcessor
+	updater     ParamsUpdater
+	tagger      TxTagger
+	metrics     *Metrics
 }
 
 func NewAppConnConsensus(appConn abcicli.Client) *appConnConsensus {
 	return &appConnConsensus{
 		appConn: appConn,
+		metrics: NopMetrics(),
 	}
 }
 
+// SetGenesisInitializer sets the app to send the genesis to.
+func (app *appConnConsensus) SetGenesisInitializer(initializer GenesisInitializer) {
+	app.initializer = initializer
+}
+
+// SetProposalPreparer sets the app to prepare our proposals with.
+func (app *appConnConsensus) SetProposalPreparer(preparer ProposalPreparer) {
+	app.preparer = preparer
+}
+
+// SetProposalProcessor sets the app to check the proposals of others with.
+func (app *appConnConsensus) SetProposalProcessor(processor ProposalProcessor) {
+	app.processor = processor
+}
+
+// SetParamsUpdater sets the app to get consensus params changes from.
+func (app *appConnConsensus) SetParamsUpdater(updater ParamsUpdater) {
+	app.updater = updater
+}
+
+// SetTxTagger sets the app to get the tags of delivered txs from.
+func (app *appConnConsensus) SetTxTagger(tagger TxTagger) {
+	app.tagger = tagger
+}
+
 func (app *appConnConsensus) SetResponseCallback(cb abcicli.Callback) {
 	app.appConn.SetResponseCallback(cb)
 }
@@ -61,11 +118,35 @@ func (app *appConnConsensus) Error() error {
 	return app.appConn.Error()
 }
 
-func (app *appConnConsensus) InitChainSync(validators []*types.Validator) (err error) {
-	return app.appConn.InitChainSync(validators)
+func (app *appConnConsensus) InitChainSync(chainID string, genesisTime time.Time, validators []*types.Validator, appState []byte) ([]byte, error) {
+	defer app.metrics.timeMethod("init_chain")()
+	if app.initializer != nil {
+		return app.initializer.InitGenesis(chainID, genesisTime, validators, appState), nil
+	}
+	if len(appState) > 0 && string(appState) != "null" {
+		return nil, errors.New("The genesis has an app_state, but the app can't load it. Only in-process apps implementing GenesisInitializer can")
+	}
+	return nil, app.appConn.InitChainSync(validators)
+}
+
+func (app *appConnConsensus) PrepareProposalSync(height int, txs [][]byte) ([][]byte, error) {
+	if app.preparer == nil {
+		return txs, nil
+	}
+	defer app.metrics.timeMethod("prepare_proposal")()
+	return app.preparer.PrepareProposal(height, txs), nil
+}
+
+func (app *appConnConsensus) ProcessProposalSync(hash []byte, header *types.Header, txs [][]byte) (bool, error) {
+	if app.processor == nil {
+		return true, nil
+	}
+	defer app.metrics.timeMethod("process_proposal")()
+	return app.processor.ProcessProposal(hash, header, txs), nil
 }
 
 func (app *appConnConsensus) BeginBlockSync(hash []byte, header *types.Header) (err error) {
+	defer app.metrics.timeMethod("begin_block")()
 	return app.appConn.BeginBlockSync(hash, header)
 }
 
@@ -73,11 +154,27 @@ func (app *appConnConsensus) DeliverTxAsync(tx []byte) *abcicli.ReqRes {
 	return app.appConn.DeliverTxAsync(tx)
 }
 
+func (app *appConnConsensus) TxTagsSync(tx []byte, res *types.ResponseDeliverTx) ([]*tmtypes.TxTag, error) {
+	if app.tagger == nil {
+		return nil, nil
+	}
+	return app.tagger.TxTags(tx, res), nil
+}
+
 func (app *appConnConsensus) EndBlockSync(height uint64) (types.ResponseEndBlock, error) {
+	defer app.metrics.timeMethod("end_block")()
 	return app.appConn.EndBlockSync(height)
 }
 
+func (app *appConnConsensus) ConsensusParamUpdatesSync(height int) (*tmtypes.ConsensusParams, error) {
+	if app.updater == nil {
+		return nil, nil
+	}
+	return app.updater.UpdateConsensusParams(height), nil
+}
+
 func (app *appConnConsensus) CommitSync() (res types.Result) {
+	defer app.metrics.timeMethod("commit")()
 	return app.appConn.CommitSync()
 }
 
@@ -86,11 +183,13 @@ func (app *appConnConsensus) CommitSync() (res types.Result) {
 
 type appConnMempool struct {
 	appConn abcicli.Client
+	metrics *Metrics
 }
 
 func NewAppConnMempool(appConn abcicli.Client) *appConnMempool {
 	return &appConnMempool{
 		appConn: appConn,
+		metrics: NopMetrics(),
 	}
 }
 
@@ -107,6 +206,7 @@ func (app *appConnMempool) FlushAsync() *abcicli.ReqRes {
 }
 
 func (app *appConnMempool) FlushSync() error {
+	defer app.metrics.timeMethod("flush")()
 	return app.appConn.FlushSync()
 }
 
@@ -119,11 +219,13 @@ func (app *appConnMempool) CheckTxAsync(tx []byte) *abcicli.ReqRes {
 
 type appConnQuery struct {
 	appConn abcicli.Client
+	metrics *Metrics
 }
 
 func NewAppConnQuery(appConn abcicli.Client) *appConnQuery {
 	return &appConnQuery{
 		appConn: appConn,
+		metrics: NopMetrics(),
 	}
 }
 
@@ -132,13 +234,16 @@ func (app *appConnQuery) Error() error {
 }
 
 func (app *appConnQuery) EchoSync(msg string) (res types.Result) {
+	defer app.metrics.timeMethod("echo")()
 	return app.appConn.EchoSync(msg)
 }
 
 func (app *appConnQuery) InfoSync() (types.ResponseInfo, error) {
+	defer app.metrics.timeMethod("info")()
 	return app.appConn.InfoSync()
 }
 
 func (app *appConnQuery) QuerySync(reqQuery types.RequestQuery) (types.ResponseQuery, error) {
+	defer app.metrics.timeMethod("query")()
 	return app.appConn.QuerySync(reqQuery)
 }
@@ -34,6 +34,22 @@ func (l *localClientCreator) NewABCIClient() (abcicli.Client, error) {
 	return abcicli.NewLocalClient(l.mtx, l.app), nil
 }
 
+// localApp returns the app behind the ClientCreator if it is in process,
+// with the mutex its connections share.
+//
+// In-process apps can implement interfaces for what ABCI has no messages for
+// yet: Snapshotter, ProposalPreparer, ProposalProcessor, GenesisInitializer,
+// TxPrioritizer, ParamsUpdater and TxTagger.
+// They're wrapped to lock the mutex, like the ABCI calls.
+// TODO: add these to ABCI, so out of process apps can use them too.
+func localApp(clientCreator ClientCreator) (interface{}, *sync.Mutex, bool) {
+	local, ok := clientCreator.(*localClientCreator)
+	if !ok {
+		return nil, nil, false
+	}
+	return local.app, local.mtx, true
+}
+
 //---------------------------------------------------------------
 // remote proxy opens new connections to an external app process
 
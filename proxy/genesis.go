@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tendermint/abci/types"
+)
+
+// GenesisInitializer is implemented by apps which want to load their initial
+// state, eg. balances, from the app_state of the genesis.
+// Only in-process apps can implement it for now (see localApp).
+type GenesisInitializer interface {
+	// InitGenesis is called instead of InitChain, once, when the app has no
+	// blocks yet. appState is the raw JSON of the app_state in the genesis,
+	// or nil if it has none. It returns the app hash after the app state is
+	// loaded, or nil to keep the app_hash of the genesis.
+	InitGenesis(chainID string, genesisTime time.Time, validators []*types.Validator, appState []byte) []byte
+}
+
+// NewGenesisInitializer returns the app behind the ClientCreator if it is in
+// process and implements GenesisInitializer. Calls share the mutex of the
+// app's other connections.
+func NewGenesisInitializer(clientCreator ClientCreator) (GenesisInitializer, bool) {
+	app, mtx, ok := localApp(clientCreator)
+	if !ok {
+		return nil, false
+	}
+	initializer, ok := app.(GenesisInitializer)
+	if !ok {
+		return nil, false
+	}
+	return &localGenesisInitializer{mtx: mtx, app: initializer}, true
+}
+
+type localGenesisInitializer struct {
+	mtx *sync.Mutex
+	app GenesisInitializer
+}
+
+func (g *localGenesisInitializer) InitGenesis(chainID string, genesisTime time.Time, validators []*types.Validator, appState []byte) []byte {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.app.InitGenesis(chainID, genesisTime, validators, appState)
+}
@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics contains the metrics exposed by the proxy package.
+type Metrics struct {
+	// Duration of synchronous ABCI calls by method, in seconds.
+	MethodTimingSeconds *prometheus.HistogramVec
+}
+
+// PrometheusMetrics registers the ABCI call timings with registry.
+func PrometheusMetrics(registry prometheus.Registerer) *Metrics {
+	m := NopMetrics()
+	registry.MustRegister(m.MethodTimingSeconds)
+	return m
+}
+
+// NopMetrics returns unregistered ABCI call timings.
+func NopMetrics() *Metrics {
+	return &Metrics{
+		MethodTimingSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "tendermint", Subsystem: "abci_connection",
+			Name: "method_timing_seconds", Help: "Duration of synchronous ABCI calls.",
+			Buckets: []float64{.0001, .0004, .002, .009, .02, .1, .65, 2, 6, 25},
+		}, []string{"method"}),
+	}
+}
+
+// timeMethod starts timing an ABCI call. The returned function records the
+// duration, eg. `defer m.timeMethod("commit")()`.
+func (m *Metrics) timeMethod(method string) func() {
+	start := time.Now()
+	return func() {
+		m.MethodTimingSeconds.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	}
+}
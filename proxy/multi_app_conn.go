@@ -17,8 +17,8 @@ type AppConns interface {
 	Query() AppConnQuery
 }
 
-func NewAppConns(clientCreator ClientCreator, handshaker Handshaker) AppConns {
-	return NewMultiAppConn(clientCreator, handshaker)
+func NewAppConns(clientCreator ClientCreator, handshaker Handshaker, metrics *Metrics) AppConns {
+	return NewMultiAppConn(clientCreator, handshaker, metrics)
 }
 
 //-----------------------------
@@ -42,13 +42,15 @@ type multiAppConn struct {
 	queryConn     *appConnQuery
 
 	clientCreator ClientCreator
+	metrics       *Metrics
 }
 
 // Make all necessary abci connections to the application
-func NewMultiAppConn(clientCreator ClientCreator, handshaker Handshaker) *multiAppConn {
+func NewMultiAppConn(clientCreator ClientCreator, handshaker Handshaker, metrics *Metrics) *multiAppConn {
 	multiAppConn := &multiAppConn{
 		handshaker:    handshaker,
 		clientCreator: clientCreator,
+		metrics:       metrics,
 	}
 	multiAppConn.BaseService = *cmn.NewBaseService(nil, "multiAppConn", multiAppConn)
 	return multiAppConn
@@ -80,6 +82,7 @@ func (app *multiAppConn) OnStart() error {
 		return errors.Wrap(err, "Error starting ABCI client (query connection)")
 	}
 	app.queryConn = NewAppConnQuery(querycli)
+	app.queryConn.metrics = app.metrics
 
 	// mempool connection
 	memcli, err := app.clientCreator.NewABCIClient()
@@ -91,6 +94,7 @@ func (app *multiAppConn) OnStart() error {
 		return errors.Wrap(err, "Error starting ABCI client (mempool connection)")
 	}
 	app.mempoolConn = NewAppConnMempool(memcli)
+	app.mempoolConn.metrics = app.metrics
 
 	// consensus connection
 	concli, err := app.clientCreator.NewABCIClient()
@@ -102,6 +106,27 @@ func (app *multiAppConn) OnStart() error {
 		return errors.Wrap(err, "Error starting ABCI client (consensus connection)")
 	}
 	app.consensusConn = NewAppConnConsensus(concli)
+	app.consensusConn.metrics = app.metrics
+	if initializer, ok := NewGenesisInitializer(app.clientCreator); ok {
+		app.consensusConn.SetGenesisInitializer(initializer)
+	}
+	if preparer, ok := NewProposalPreparer(app.clientCreator); ok {
+		app.consensusConn.SetProposalPreparer(preparer)
+	} else {
+		app.Logger.Info("The app can't prepare our proposals, only in-process apps implementing ProposalPreparer can. We propose the txs of the mempool as they are")
+	}
+	if processor, ok := NewProposalProcessor(app.clientCreator); ok {
+		app.consensusConn.SetProposalProcessor(processor)
+	} else {
+		app.Logger.Info("The app can't check proposals before we prevote, only in-process apps implementing ProposalProcessor can. We accept all valid proposals")
+	}
+
+	if updater, ok := NewParamsUpdater(app.clientCreator); ok {
+		app.consensusConn.SetParamsUpdater(updater)
+	}
+	if tagger, ok := NewTxTagger(app.clientCreator); ok {
+		app.consensusConn.SetTxTagger(tagger)
+	}
 
 	// ensure app is synced to the latest state
 	if app.handshaker != nil {
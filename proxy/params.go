@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+// ParamsUpdater is implemented by apps which want to change the consensus
+// params, eg. the max block size, as the chain goes.
+// Only in-process apps can implement it for now (see localApp).
+type ParamsUpdater interface {
+	// UpdateConsensusParams is called after EndBlock, with the height of the
+	// block. It returns the params to change from the next block on, with
+	// the fields to keep left at zero, or nil to change none. Invalid params
+	// halt the chain, like an app hash mismatch would.
+	UpdateConsensusParams(height int) *types.ConsensusParams
+}
+
+// NewParamsUpdater returns the app behind the ClientCreator if it is in
+// process and implements ParamsUpdater. Calls share the mutex of the app's
+// other connections.
+func NewParamsUpdater(clientCreator ClientCreator) (ParamsUpdater, bool) {
+	app, mtx, ok := localApp(clientCreator)
+	if !ok {
+		return nil, false
+	}
+	updater, ok := app.(ParamsUpdater)
+	if !ok {
+		return nil, false
+	}
+	return &localParamsUpdater{mtx: mtx, app: updater}, true
+}
+
+type localParamsUpdater struct {
+	mtx *sync.Mutex
+	app ParamsUpdater
+}
+
+func (u *localParamsUpdater) UpdateConsensusParams(height int) *types.ConsensusParams {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return u.app.UpdateConsensusParams(height)
+}
@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/tendermint/abci/types"
+)
+
+// TxPrioritizer is implemented by apps which want the mempool to reap their
+// txs by priority, eg. by fee, rather than in arrival order.
+// Only in-process apps can implement it for now (see localApp).
+type TxPrioritizer interface {
+	// TxPriority is called for each tx which passes CheckTx, again when it
+	// is re-checked after a commit. It returns the priority of the tx, and
+	// optionally its sender, whose txs are reaped in arrival order.
+	TxPriority(tx []byte, res *types.ResponseCheckTx) (priority int64, sender string)
+}
+
+// NewTxPrioritizer returns the app behind the ClientCreator if it is in
+// process and implements TxPrioritizer. Calls share the mutex of the app's
+// other connections.
+func NewTxPrioritizer(clientCreator ClientCreator) (TxPrioritizer, bool) {
+	app, mtx, ok := localApp(clientCreator)
+	if !ok {
+		return nil, false
+	}
+	prioritizer, ok := app.(TxPrioritizer)
+	if !ok {
+		return nil, false
+	}
+	return &localTxPrioritizer{mtx: mtx, app: prioritizer}, true
+}
+
+type localTxPrioritizer struct {
+	mtx *sync.Mutex
+	app TxPrioritizer
+}
+
+func (p *localTxPrioritizer) TxPriority(tx []byte, res *types.ResponseCheckTx) (int64, string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.app.TxPriority(tx, res)
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/tendermint/abci/types"
+)
+
+// ProposalPreparer is implemented by apps which want a say in the blocks
+// their validator proposes, eg. to order the txs or to add their own.
+// Only in-process apps can implement it for now (see localApp).
+type ProposalPreparer interface {
+	// PrepareProposal is only called on the proposer, with the txs reaped
+	// from the mempool for the block at the given height. It returns the
+	// txs of the block, in order. It can reorder, drop or add txs, but the
+	// proposer drops txs from the end if the block gets too big.
+	PrepareProposal(height int, txs [][]byte) [][]byte
+}
+
+// NewProposalPreparer returns the app behind the ClientCreator if it is in
+// process and implements ProposalPreparer. Calls share the mutex of the
+// app's other connections.
+func NewProposalPreparer(clientCreator ClientCreator) (ProposalPreparer, bool) {
+	app, mtx, ok := localApp(clientCreator)
+	if !ok {
+		return nil, false
+	}
+	preparer, ok := app.(ProposalPreparer)
+	if !ok {
+		return nil, false
+	}
+	return &localProposalPreparer{mtx: mtx, app: preparer}, true
+}
+
+type localProposalPreparer struct {
+	mtx *sync.Mutex
+	app ProposalPreparer
+}
+
+func (p *localProposalPreparer) PrepareProposal(height int, txs [][]byte) [][]byte {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.app.PrepareProposal(height, txs)
+}
+
+// ProposalProcessor is implemented by apps which want to check the blocks
+// proposed by others before their validator prevotes for them.
+// Only in-process apps can implement it for now (see localApp).
+type ProposalProcessor interface {
+	// ProcessProposal is called on every validator with the complete
+	// proposal block, once it is valid for tendermint. If it returns false,
+	// the validator prevotes nil. It must be deterministic, or honest
+	// validators may not be able to agree on a block.
+	ProcessProposal(hash []byte, header *types.Header, txs [][]byte) bool
+}
+
+// NewProposalProcessor returns the app behind the ClientCreator if it is in
+// process and implements ProposalProcessor. Calls share the mutex of the
+// app's other connections.
+func NewProposalProcessor(clientCreator ClientCreator) (ProposalProcessor, bool) {
+	app, mtx, ok := localApp(clientCreator)
+	if !ok {
+		return nil, false
+	}
+	processor, ok := app.(ProposalProcessor)
+	if !ok {
+		return nil, false
+	}
+	return &localProposalProcessor{mtx: mtx, app: processor}, true
+}
+
+type localProposalProcessor struct {
+	mtx *sync.Mutex
+	app ProposalProcessor
+}
+
+func (p *localProposalProcessor) ProcessProposal(hash []byte, header *types.Header, txs [][]byte) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.app.ProcessProposal(hash, header, txs)
+}
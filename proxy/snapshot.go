@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"sync"
+)
+
+// Snapshot describes an app state snapshot at a given height, split into
+// Chunks chunks. Format and Metadata are app-specific, and Hash identifies
+// the snapshot contents (it is not verified by tendermint, the restored
+// app hash is).
+type Snapshot struct {
+	Height   int    `json:"height"`
+	Format   uint32 `json:"format"`
+	Chunks   uint32 `json:"chunks"`
+	Hash     []byte `json:"hash"`
+	Metadata []byte `json:"metadata"`
+}
+
+// Snapshotter is implemented by apps which can serve and restore state
+// snapshots, so new nodes can state sync instead of replaying every block.
+// Only in-process apps can implement it for now (see localApp).
+type Snapshotter interface {
+	// ListSnapshots returns the snapshots the app can serve.
+	ListSnapshots() ([]*Snapshot, error)
+
+	// LoadSnapshotChunk returns the chunk with the given index of a snapshot.
+	LoadSnapshotChunk(height int, format uint32, index uint32) ([]byte, error)
+
+	// OfferSnapshot asks the app to start restoring the snapshot, which
+	// should result in the given (trusted) app hash. It returns an error
+	// if the app doesn't want the snapshot.
+	OfferSnapshot(snapshot *Snapshot, appHash []byte) error
+
+	// ApplySnapshotChunk applies the chunks of the offered snapshot,
+	// which are given in order.
+	ApplySnapshotChunk(index uint32, chunk []byte) error
+}
+
+// NewSnapshotter returns the app behind the ClientCreator if it is in
+// process and implements Snapshotter. Calls share the mutex of the app's
+// other connections.
+func NewSnapshotter(clientCreator ClientCreator) (Snapshotter, bool) {
+	app, mtx, ok := localApp(clientCreator)
+	if !ok {
+		return nil, false
+	}
+	snapshotter, ok := app.(Snapshotter)
+	if !ok {
+		return nil, false
+	}
+	return &localSnapshotter{mtx: mtx, app: snapshotter}, true
+}
+
+type localSnapshotter struct {
+	mtx *sync.Mutex
+	app Snapshotter
+}
+
+func (s *localSnapshotter) ListSnapshots() ([]*Snapshot, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.app.ListSnapshots()
+}
+
+func (s *localSnapshotter) LoadSnapshotChunk(height int, format uint32, index uint32) ([]byte, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.app.LoadSnapshotChunk(height, format, index)
+}
+
+func (s *localSnapshotter) OfferSnapshot(snapshot *Snapshot, appHash []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.app.OfferSnapshot(snapshot, appHash)
+}
+
+func (s *localSnapshotter) ApplySnapshotChunk(index uint32, chunk []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.app.ApplySnapshotChunk(index, chunk)
+}
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+// TxTagger is implemented by apps which want their txs to be searchable by
+// tags, eg. by the accounts they touch (see the tx_search rpc route).
+// Only in-process apps can implement it for now (see localApp).
+type TxTagger interface {
+	// TxTags is called right after DeliverTx, with the tx and its result.
+	// It returns the tags to index the tx by. Keys "tx.hash" and
+	// "tx.height" are reserved.
+	TxTags(tx []byte, res *types.ResponseDeliverTx) []*tmtypes.TxTag
+}
+
+// NewTxTagger returns the app behind the ClientCreator if it is in process
+// and implements TxTagger. Calls share the mutex of the app's other
+// connections.
+func NewTxTagger(clientCreator ClientCreator) (TxTagger, bool) {
+	app, mtx, ok := localApp(clientCreator)
+	if !ok {
+		return nil, false
+	}
+	tagger, ok := app.(TxTagger)
+	if !ok {
+		return nil, false
+	}
+	return &localTxTagger{mtx: mtx, app: tagger}, true
+}
+
+type localTxTagger struct {
+	mtx *sync.Mutex
+	app TxTagger
+}
+
+func (t *localTxTagger) TxTags(tx []byte, res *types.ResponseDeliverTx) []*tmtypes.TxTag {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.app.TxTags(tx, res)
+}
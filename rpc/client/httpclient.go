@@ -133,6 +133,18 @@ func (c *HTTP) BlockchainInfo(minHeight, maxHeight int) (*ctypes.ResultBlockchai
 	return result, nil
 }
 
+func (c *HTTP) Blocks(minHeight, maxHeight, cursor, limit int, metasOnly bool) (*ctypes.ResultBlocks, error) {
+	result := new(ctypes.ResultBlocks)
+	_, err := c.rpc.Call("blocks",
+		map[string]interface{}{"minHeight": minHeight, "maxHeight": maxHeight,
+			"cursor": cursor, "limit": limit, "metasOnly": metasOnly},
+		result)
+	if err != nil {
+		return nil, errors.Wrap(err, "Blocks")
+	}
+	return result, nil
+}
+
 func (c *HTTP) Genesis() (*ctypes.ResultGenesis, error) {
 	result := new(ctypes.ResultGenesis)
 	_, err := c.rpc.Call("genesis", map[string]interface{}{}, result)
@@ -151,6 +163,24 @@ func (c *HTTP) Block(height int) (*ctypes.ResultBlock, error) {
 	return result, nil
 }
 
+func (c *HTTP) BlockByHash(hash []byte) (*ctypes.ResultBlock, error) {
+	result := new(ctypes.ResultBlock)
+	_, err := c.rpc.Call("block_by_hash", map[string]interface{}{"hash": hash}, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlockByHash")
+	}
+	return result, nil
+}
+
+func (c *HTTP) BlockResults(height int) (*ctypes.ResultBlockResults, error) {
+	result := new(ctypes.ResultBlockResults)
+	_, err := c.rpc.Call("block_results", map[string]interface{}{"height": height}, result)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlockResults")
+	}
+	return result, nil
+}
+
 func (c *HTTP) Commit(height int) (*ctypes.ResultCommit, error) {
 	result := new(ctypes.ResultCommit)
 	_, err := c.rpc.Call("commit", map[string]interface{}{"height": height}, result)
@@ -173,9 +203,22 @@ func (c *HTTP) Tx(hash []byte, prove bool) (*ctypes.ResultTx, error) {
 	return result, nil
 }
 
-func (c *HTTP) Validators() (*ctypes.ResultValidators, error) {
+func (c *HTTP) TxSearch(query string, prove bool) ([]*ctypes.ResultTx, error) {
+	results := new([]*ctypes.ResultTx)
+	params := map[string]interface{}{
+		"query": query,
+		"prove": prove,
+	}
+	_, err := c.rpc.Call("tx_search", params, results)
+	if err != nil {
+		return nil, errors.Wrap(err, "TxSearch")
+	}
+	return *results, nil
+}
+
+func (c *HTTP) Validators(height int) (*ctypes.ResultValidators, error) {
 	result := new(ctypes.ResultValidators)
-	_, err := c.rpc.Call("validators", map[string]interface{}{}, result)
+	_, err := c.rpc.Call("validators", map[string]interface{}{"height": height}, result)
 	if err != nil {
 		return nil, errors.Wrap(err, "Validators")
 	}
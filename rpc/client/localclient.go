@@ -89,6 +89,10 @@ func (c Local) BlockchainInfo(minHeight, maxHeight int) (*ctypes.ResultBlockchai
 	return core.BlockchainInfo(minHeight, maxHeight)
 }
 
+func (c Local) Blocks(minHeight, maxHeight, cursor, limit int, metasOnly bool) (*ctypes.ResultBlocks, error) {
+	return core.Blocks(minHeight, maxHeight, cursor, limit, metasOnly)
+}
+
 func (c Local) Genesis() (*ctypes.ResultGenesis, error) {
 	return core.Genesis()
 }
@@ -97,14 +101,26 @@ func (c Local) Block(height int) (*ctypes.ResultBlock, error) {
 	return core.Block(height)
 }
 
+func (c Local) BlockByHash(hash []byte) (*ctypes.ResultBlock, error) {
+	return core.BlockByHash(hash)
+}
+
+func (c Local) BlockResults(height int) (*ctypes.ResultBlockResults, error) {
+	return core.BlockResults(height)
+}
+
 func (c Local) Commit(height int) (*ctypes.ResultCommit, error) {
 	return core.Commit(height)
 }
 
-func (c Local) Validators() (*ctypes.ResultValidators, error) {
-	return core.Validators()
+func (c Local) Validators(height int) (*ctypes.ResultValidators, error) {
+	return core.Validators(height)
 }
 
 func (c Local) Tx(hash []byte, prove bool) (*ctypes.ResultTx, error) {
 	return core.Tx(hash, prove)
 }
+
+func (c Local) TxSearch(query string, prove bool) ([]*ctypes.ResultTx, error) {
+	return core.TxSearch(query, prove)
+}
@@ -1,6 +1,7 @@
 package client_test
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -87,7 +88,7 @@ func TestGenesisAndValidators(t *testing.T) {
 		gval := gen.Genesis.Validators[0]
 
 		// get the current validators
-		vals, err := c.Validators()
+		vals, err := c.Validators(0)
 		require.Nil(t, err, "%d: %+v", i, err)
 		require.Equal(t, 1, len(vals.Validators))
 		val := vals.Validators[0]
@@ -95,6 +96,13 @@ func TestGenesisAndValidators(t *testing.T) {
 		// make sure the current set is also the genesis set
 		assert.Equal(t, gval.Amount, val.VotingPower)
 		assert.Equal(t, gval.PubKey, val.PubKey)
+
+		// and the set at height 1
+		vals, err = c.Validators(1)
+		require.Nil(t, err, "%d: %+v", i, err)
+		require.Equal(t, 1, len(vals.Validators))
+		assert.Equal(t, 1, vals.BlockHeight)
+		assert.Equal(t, gval.PubKey, vals.Validators[0].PubKey)
 	}
 }
 
@@ -169,6 +177,32 @@ func TestAppCalls(t *testing.T) {
 		require.Nil(err, "%d: %+v", i, err)
 		assert.Equal(block.Block.LastCommit, commit2.Commit)
 
+		// the block can be found by hash too
+		hblock, err := c.BlockByHash(block.BlockMeta.BlockID.Hash)
+		require.Nil(err, "%d: %+v", i, err)
+		assert.EqualValues(apph, hblock.Block.Height)
+
+		// and paged through, one block at a time
+		blocks, err := c.Blocks(txh, apph, 0, 1, false)
+		require.Nil(err, "%d: %+v", i, err)
+		if assert.Equal(1, len(blocks.Blocks)) {
+			assert.EqualValues(txh, blocks.Blocks[0].Height)
+		}
+		assert.Equal(apph, blocks.NextCursor)
+		blocks, err = c.Blocks(txh, apph, blocks.NextCursor, 1, true)
+		require.Nil(err, "%d: %+v", i, err)
+		if assert.Equal(1, len(blocks.BlockMetas)) {
+			assert.EqualValues(apph, blocks.BlockMetas[0].Header.Height)
+		}
+		assert.Empty(blocks.Blocks)
+		assert.Equal(0, blocks.NextCursor)
+
+		// and the tx result is kept with the block results
+		results, err := c.BlockResults(txh)
+		require.Nil(err, "%d: %+v", i, err)
+		assert.Equal(txh, results.Height)
+		assert.NotEmpty(results.Results.DeliverTx)
+
 		// and we got a proof that works!
 		pres, err := c.ABCIQuery("/key", k, true)
 		if assert.Nil(err) && assert.True(pres.Code.IsOK()) {
@@ -273,3 +307,50 @@ func TestTx(t *testing.T) {
 		}
 	}
 }
+
+func TestTxSearch(t *testing.T) {
+	// first we broadcast a tx
+	c := getHTTPClient()
+	_, _, tx := merktest.MakeTxKV()
+	bres, err := c.BroadcastTxCommit(tx)
+	require.Nil(t, err, "%+v", err)
+
+	txHeight := bres.Height
+	txHash := bres.Hash
+
+	for i, c := range GetClients() {
+		t.Logf("client %d", i)
+
+		// now we query for the tx.
+		// since there's only one tx, we know index=0.
+		results, err := c.TxSearch(fmt.Sprintf("tx.hash='%X'", []byte(txHash)), true)
+		require.Nil(t, err, "%+v", err)
+		require.Len(t, results, 1)
+
+		ptx := results[0]
+		assert.Equal(t, txHeight, ptx.Height)
+		assert.EqualValues(t, tx, ptx.Tx)
+		assert.Equal(t, 0, ptx.Index)
+		assert.True(t, ptx.TxResult.Code.IsOK())
+
+		// time to verify the proof
+		proof := ptx.Proof
+		if assert.EqualValues(t, tx, proof.Data) {
+			assert.True(t, proof.Proof.Verify(proof.Index, proof.Total, txHash, proof.RootHash))
+		}
+
+		// we query by height, the tx must be among the results
+		results, err = c.TxSearch(fmt.Sprintf("tx.height=%d", txHeight), false)
+		require.Nil(t, err, "%+v", err)
+		require.NotEmpty(t, results)
+
+		// query for a non-existing tag
+		results, err = c.TxSearch("app.creator='Cosmoshi Netowoko'", false)
+		require.Nil(t, err, "%+v", err)
+		assert.Len(t, results, 0)
+
+		// malformed query
+		_, err = c.TxSearch("app.creator=", false)
+		require.NotNil(t, err)
+	}
+}
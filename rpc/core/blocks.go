@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	sm "github.com/tendermint/tendermint/state"
 	"github.com/tendermint/tendermint/types"
 	. "github.com/tendermint/tmlibs/common"
 )
@@ -22,12 +23,18 @@ func BlockchainInfo(minHeight, maxHeight int) (*ctypes.ResultBlockchainInfo, err
 	} else {
 		minHeight = MaxInt(minHeight, maxHeight-20)
 	}
+	// older blocks may have been pruned
+	minHeight = MaxInt(minHeight, blockStore.Base())
 
 	logger.Debug("BlockchainInfoHandler", "maxHeight", maxHeight, "minHeight", minHeight)
 
 	blockMetas := []*types.BlockMeta{}
 	for height := maxHeight; height >= minHeight; height-- {
 		blockMeta := blockStore.LoadBlockMeta(height)
+		if blockMeta == nil {
+			// kept by keep_every below the base, or not saved yet
+			continue
+		}
 		blockMetas = append(blockMetas, blockMeta)
 	}
 
@@ -36,6 +43,50 @@ func BlockchainInfo(minHeight, maxHeight int) (*ctypes.ResultBlockchainInfo, err
 
 //-----------------------------------------------------------------------------
 
+const (
+	defaultBlocksLimit = 20
+	maxBlocksLimit     = 100
+)
+
+// Blocks returns the blocks, or only their metas, from minHeight to maxHeight
+// in ascending order, at most limit at a time.
+// To get the next page, call it again with cursor set to the returned
+// NextCursor, which is 0 after the last page.
+func Blocks(minHeight, maxHeight, cursor, limit int, metasOnly bool) (*ctypes.ResultBlocks, error) {
+	storeHeight := blockStore.Height()
+	if maxHeight == 0 || maxHeight > storeHeight {
+		maxHeight = storeHeight
+	}
+	// older blocks may have been pruned
+	minHeight = MaxInt(minHeight, blockStore.Base())
+	minHeight = MaxInt(minHeight, cursor)
+	if limit <= 0 {
+		limit = defaultBlocksLimit
+	}
+	limit = MinInt(limit, maxBlocksLimit)
+
+	logger.Debug("BlocksHandler", "minHeight", minHeight, "maxHeight", maxHeight, "limit", limit)
+
+	res := &ctypes.ResultBlocks{LastHeight: storeHeight, BlockMetas: []*types.BlockMeta{}}
+	height := minHeight
+	for ; height <= maxHeight && len(res.BlockMetas) < limit; height++ {
+		blockMeta := blockStore.LoadBlockMeta(height)
+		if blockMeta == nil {
+			continue
+		}
+		res.BlockMetas = append(res.BlockMetas, blockMeta)
+		if !metasOnly {
+			res.Blocks = append(res.Blocks, blockStore.LoadBlock(height))
+		}
+	}
+	if height <= maxHeight {
+		res.NextCursor = height
+	}
+	return res, nil
+}
+
+//-----------------------------------------------------------------------------
+
 func Block(height int) (*ctypes.ResultBlock, error) {
 	if height == 0 {
 		return nil, fmt.Errorf("Height must be greater than 0")
@@ -46,6 +97,19 @@ func Block(height int) (*ctypes.ResultBlock, error) {
 
 	blockMeta := blockStore.LoadBlockMeta(height)
 	block := blockStore.LoadBlock(height)
+	if blockMeta == nil || block == nil {
+		return nil, errBlockPruned(height)
+	}
+	return &ctypes.ResultBlock{blockMeta, block}, nil
+}
+
+// BlockByHash returns the block with the given hash.
+func BlockByHash(hash []byte) (*ctypes.ResultBlock, error) {
+	block := blockStore.LoadBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("Block %X not found", hash)
+	}
+	blockMeta := blockStore.LoadBlockMeta(block.Height)
 	return &ctypes.ResultBlock{blockMeta, block}, nil
 }
 
@@ -60,7 +124,11 @@ func Commit(height int) (*ctypes.ResultCommit, error) {
 		return nil, fmt.Errorf("Height must be less than or equal to the current blockchain height")
 	}
 
-	header := blockStore.LoadBlockMeta(height).Header
+	blockMeta := blockStore.LoadBlockMeta(height)
+	if blockMeta == nil {
+		return nil, errBlockPruned(height)
+	}
+	header := blockMeta.Header
 
 	// If the next block has not been committed yet,
 	// use a non-canonical commit
@@ -73,3 +141,27 @@ func Commit(height int) (*ctypes.ResultCommit, error) {
 	commit := blockStore.LoadBlockCommit(height)
 	return &ctypes.ResultCommit{header, commit, true}, nil
 }
+
+//-----------------------------------------------------------------------------
+
+// BlockResults returns the results of executing the block at the given
+// height: the DeliverTx results and the EndBlock validator changes.
+func BlockResults(height int) (*ctypes.ResultBlockResults, error) {
+	if height == 0 {
+		return nil, fmt.Errorf("Height must be greater than 0")
+	}
+	if height > blockStore.Height() {
+		return nil, fmt.Errorf("Height must be less than or equal to the current blockchain height")
+	}
+
+	results := sm.LoadABCIResponses(stateDB, height)
+	if results == nil {
+		return nil, fmt.Errorf("Results for height %d are not available", height)
+	}
+	return &ctypes.ResultBlockResults{height, results}, nil
+}
+
+// errBlockPruned is returned for heights no longer in the block store.
+func errBlockPruned(height int) error {
+	return fmt.Errorf("Block at height %d is not available, lowest height is %d", height, blockStore.Base())
+}
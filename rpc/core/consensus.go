@@ -4,12 +4,23 @@ import (
 	"github.com/tendermint/go-wire"
 	cm "github.com/tendermint/tendermint/consensus"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	sm "github.com/tendermint/tendermint/state"
 	"github.com/tendermint/tendermint/types"
 )
 
-func Validators() (*ctypes.ResultValidators, error) {
-	blockHeight, validators := consensusState.GetValidators()
-	return &ctypes.ResultValidators{blockHeight, validators}, nil
+// Validators returns the validator set for the block at the given height,
+// or the current one if height is 0.
+func Validators(height int) (*ctypes.ResultValidators, error) {
+	if height == 0 {
+		blockHeight, validators := consensusState.GetValidators()
+		return &ctypes.ResultValidators{blockHeight, validators}, nil
+	}
+
+	valSet, err := sm.LoadValidators(stateDB, height)
+	if err != nil {
+		return nil, err
+	}
+	return &ctypes.ResultValidators{height, valSet.Validators}, nil
 }
 
 func DumpConsensusState() (*ctypes.ResultDumpConsensusState, error) {
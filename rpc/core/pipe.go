@@ -7,6 +7,7 @@ import (
 	"github.com/tendermint/tendermint/proxy"
 	"github.com/tendermint/tendermint/state/txindex"
 	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tmlibs/db"
 	"github.com/tendermint/tmlibs/log"
 )
 
@@ -47,6 +48,7 @@ var (
 	genDoc           *types.GenesisDoc // cache the genesis structure
 	addrBook         *p2p.AddrBook
 	txIndexer        txindex.TxIndexer
+	stateDB          dbm.DB
 	consensusReactor *consensus.ConsensusReactor
 
 	logger log.Logger
@@ -92,6 +94,10 @@ func SetTxIndexer(indexer txindex.TxIndexer) {
 	txIndexer = indexer
 }
 
+func SetStateDB(db dbm.DB) {
+	stateDB = db
+}
+
 func SetConsensusReactor(conR *consensus.ConsensusReactor) {
 	consensusReactor = conR
 }
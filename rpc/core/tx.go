@@ -5,6 +5,7 @@ import (
 
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 	"github.com/tendermint/tendermint/state/txindex/null"
+	tmquery "github.com/tendermint/tendermint/state/txindex/query"
 	"github.com/tendermint/tendermint/types"
 )
 
@@ -33,6 +34,9 @@ func Tx(hash []byte, prove bool) (*ctypes.ResultTx, error) {
 	var proof types.TxProof
 	if prove {
 		block := blockStore.LoadBlock(height)
+		if block == nil {
+			return nil, errBlockPruned(height)
+		}
 		proof = block.Data.Txs.Proof(index)
 	}
 
@@ -44,3 +48,51 @@ func Tx(hash []byte, prove bool) (*ctypes.ResultTx, error) {
 		Proof:    proof,
 	}, nil
 }
+
+// TxSearch allows you to query for multiple transactions results. The query
+// is a list of conditions joined by AND, e.g.
+// "account.owner = 'Ivan' AND tx.height > 100". Conditions are matched
+// against the tags the app gives txs (see proxy.TxTagger); "tx.height" and
+// "tx.hash" are reserved.
+func TxSearch(query string, prove bool) ([]*ctypes.ResultTx, error) {
+
+	// if index is disabled, return error
+	if _, ok := txIndexer.(*null.TxIndex); ok {
+		return nil, fmt.Errorf("Transaction indexing is disabled.")
+	}
+
+	q, err := tmquery.New(query)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := txIndexer.Search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	apiResults := make([]*ctypes.ResultTx, len(results))
+	for i, r := range results {
+		height := int(r.Height) // XXX
+		index := int(r.Index)
+
+		var proof types.TxProof
+		if prove {
+			block := blockStore.LoadBlock(height)
+			if block == nil {
+				return nil, errBlockPruned(height)
+			}
+			proof = block.Data.Txs.Proof(index)
+		}
+
+		apiResults[i] = &ctypes.ResultTx{
+			Height:   height,
+			Index:    index,
+			TxResult: r.Result.Result(),
+			Tx:       r.Tx,
+			Proof:    proof,
+		}
+	}
+
+	return apiResults, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/tendermint/go-wire/data"
 
 	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/state"
 	"github.com/tendermint/tendermint/types"
 )
 
@@ -25,6 +26,18 @@ type ResultBlock struct {
 	Block     *types.Block     `json:"block"`
 }
 
+type ResultBlocks struct {
+	LastHeight int                `json:"last_height"`
+	BlockMetas []*types.BlockMeta `json:"block_metas"`
+	Blocks     []*types.Block     `json:"blocks,omitempty"`
+	NextCursor int                `json:"next_cursor"`
+}
+
+type ResultBlockResults struct {
+	Height  int                  `json:"height"`
+	Results *state.ABCIResponses `json:"results"`
+}
+
 type ResultCommit struct {
 	Header          *types.Header `json:"header"`
 	Commit          *types.Commit `json:"commit"`
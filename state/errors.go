@@ -33,12 +33,28 @@ type (
 		Got      *State
 		Expected *State
 	}
+
+	ErrNoValSetForHeight struct {
+		Height int
+	}
+
+	ErrNoConsensusParamsForHeight struct {
+		Height int
+	}
 )
 
 func (e ErrUnknownBlock) Error() string {
 	return cmn.Fmt("Could not find block #%d", e.Height)
 }
 
+func (e ErrNoValSetForHeight) Error() string {
+	return cmn.Fmt("Could not find validator set for height #%d", e.Height)
+}
+
+func (e ErrNoConsensusParamsForHeight) Error() string {
+	return cmn.Fmt("Could not find consensus params for height #%d", e.Height)
+}
+
 func (e ErrBlockHashMismatch) Error() string {
 	return cmn.Fmt("App block hash (%X) does not match core block hash (%X) for height %d", e.AppHash, e.CoreHash, e.Height)
 }
@@ -3,10 +3,13 @@ package state
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	fail "github.com/ebuchman/fail-test"
 	abci "github.com/tendermint/abci/types"
 	crypto "github.com/tendermint/go-crypto"
+	wire "github.com/tendermint/go-wire"
 	"github.com/tendermint/tendermint/proxy"
 	"github.com/tendermint/tendermint/state/txindex"
 	"github.com/tendermint/tendermint/types"
@@ -47,6 +50,7 @@ func execBlockOnProxyApp(eventCache types.Fireable, proxyAppConn proxy.AppConnCo
 	abciResponses := NewABCIResponses(block)
 
 	// Execute transactions and get hash
+	var tagsErr error
 	proxyCb := func(req *abci.Request, res *abci.Response) {
 		switch r := res.Value.(type) {
 		case *abci.Response_DeliverTx:
@@ -65,6 +69,11 @@ func execBlockOnProxyApp(eventCache types.Fireable, proxyAppConn proxy.AppConnCo
 			}
 
 			abciResponses.DeliverTx[txIndex] = txResult
+			tags, err := proxyAppConn.TxTagsSync(req.GetDeliverTx().Tx, txResult)
+			if err != nil && tagsErr == nil {
+				tagsErr = err
+			}
+			abciResponses.DeliverTxTags[txIndex] = append(abciResponses.DeliverTxTags[txIndex], tags...)
 			txIndex++
 
 			// NOTE: if we count we can access the tx from the block instead of
@@ -83,6 +92,8 @@ func execBlockOnProxyApp(eventCache types.Fireable, proxyAppConn proxy.AppConnCo
 	proxyAppConn.SetResponseCallback(proxyCb)
 
 	// Begin block
+	// TODO: pass block.Evidence to the app so it can punish byzantine
+	// validators, once abci's BeginBlock supports it.
 	err := proxyAppConn.BeginBlockSync(block.Hash(), types.TM2PB.Header(block.Header))
 	if err != nil {
 		logger.Error("Error in proxyAppConn.BeginBlock", "err", err)
@@ -103,6 +114,17 @@ func execBlockOnProxyApp(eventCache types.Fireable, proxyAppConn proxy.AppConnCo
 		logger.Error("Error in proxyAppConn.EndBlock", "err", err)
 		return nil, err
 	}
+	// all the DeliverTx responses are in by now
+	if tagsErr != nil {
+		logger.Error("Error in proxyAppConn.TxTags", "err", tagsErr)
+		return nil, tagsErr
+	}
+
+	abciResponses.ConsensusParamUpdates, err = proxyAppConn.ConsensusParamUpdatesSync(block.Height)
+	if err != nil {
+		logger.Error("Error in proxyAppConn.ConsensusParamUpdates", "err", err)
+		return nil, err
+	}
 
 	valDiff := abciResponses.EndBlock.Diffs
 
@@ -181,11 +203,28 @@ func (s *State) validateBlock(block *types.Block) error {
 		return err
 	}
 
+	// Validate block size.
+	params := s.ConsensusParams.BlockSize
+	if block.NumTxs > params.MaxTxs {
+		return fmt.Errorf("Block has too many txs. Expected at most %v, got %v", params.MaxTxs, block.NumTxs)
+	}
+	if size := len(wire.BinaryBytes(block)); size > params.MaxBytes {
+		return fmt.Errorf("Block is too big. Expected at most %v bytes, got %v", params.MaxBytes, size)
+	}
+
 	// Validate block LastCommit.
 	if block.Height == 1 {
 		if len(block.LastCommit.Precommits) != 0 {
 			return errors.New("Block at height 1 (first block) should have no LastCommit precommits")
 		}
+
+		// Validate block Time.
+		// NOTE: the first block has no LastCommit, so its time is the proposer's,
+		// but the chain doesn't start before the genesis time.
+		genesisTime := s.LastBlockTime.Truncate(time.Millisecond)
+		if block.Time.Before(genesisTime) {
+			return fmt.Errorf("Block time %v is before the genesis time %v", block.Time, genesisTime)
+		}
 	} else {
 		if len(block.LastCommit.Precommits) != s.LastValidators.Size() {
 			return errors.New(cmn.Fmt("Invalid block commit size. Expected %v, got %v",
@@ -196,19 +235,130 @@ func (s *State) validateBlock(block *types.Block) error {
 		if err != nil {
 			return err
 		}
+
+		// Validate block Time.
+		if !block.Time.After(s.LastBlockTime) {
+			return fmt.Errorf("Block time %v is not after the last block time %v", block.Time, s.LastBlockTime)
+		}
+		medianTime := MedianTime(block.LastCommit, s.LastValidators)
+		if !block.Time.Equal(medianTime) {
+			return fmt.Errorf("Invalid block time. Expected %v, got %v", medianTime, block.Time)
+		}
+	}
+
+	// Validate all evidence.
+	evidence := block.Evidence.Evidence
+	if maxNum := s.ConsensusParams.Evidence.MaxNum; len(evidence) > maxNum {
+		return fmt.Errorf("Block has too much evidence. Expected at most %v, got %v", maxNum, len(evidence))
+	}
+	for i, ev := range evidence {
+		if evidence[:i].Has(ev) {
+			return types.NewEvidenceInvalidErr(ev, errors.New("Evidence is in the block twice"))
+		}
+		if _, err := s.VerifyEvidence(ev); err != nil {
+			return types.NewEvidenceInvalidErr(ev, err)
+		}
 	}
 
 	return nil
 }
 
+// MedianTime returns the median of the timestamps of the precommits in the
+// commit, weighted by the voting power of their validators. It's the time of
+// the next block, so it can't be set by the proposer: as long as more than
+// half of the voting power is honest, it's between the times of honest
+// validators.
+func MedianTime(commit *types.Commit, validators *types.ValidatorSet) time.Time {
+	weightedTimes := make([]weightedTime, 0, len(commit.Precommits))
+	var totalPower int64
+	for i, vote := range commit.Precommits {
+		if vote == nil {
+			continue
+		}
+		_, val := validators.GetByIndex(i)
+		weightedTimes = append(weightedTimes, weightedTime{vote.Timestamp, val.VotingPower})
+		totalPower += val.VotingPower
+	}
+	sort.Sort(weightedTimesByTime(weightedTimes))
+
+	median := totalPower / 2
+	for _, wt := range weightedTimes {
+		if median <= wt.power {
+			return wt.time
+		}
+		median -= wt.power
+	}
+	return time.Time{}
+}
+
+type weightedTime struct {
+	time  time.Time
+	power int64
+}
+
+type weightedTimesByTime []weightedTime
+
+func (wts weightedTimesByTime) Len() int           { return len(wts) }
+func (wts weightedTimesByTime) Less(i, j int) bool { return wts[i].time.Before(wts[j].time) }
+func (wts weightedTimesByTime) Swap(i, j int)      { wts[i], wts[j] = wts[j], wts[i] }
+
+// VerifyEvidence verifies the evidence fully by checking it is internally
+// consistent and signed by a validator in the validator set at its height,
+// and that it is neither too old nor already committed.
+// It returns the voting power of the validator, used to prioritize evidence.
+func (s *State) VerifyEvidence(evidence types.Evidence) (priority int64, err error) {
+	if err := evidence.ValidateBasic(); err != nil {
+		return 0, err
+	}
+	height := evidence.Height()
+
+	if maxAge := s.ConsensusParams.Evidence.MaxAge; height <= s.LastBlockHeight+1-maxAge {
+		return 0, fmt.Errorf("Evidence from height %d is too old. Max age is %d blocks", height, maxAge)
+	}
+	if committedHeight := s.evidenceCommittedAt(evidence); committedHeight != 0 && committedHeight <= s.LastBlockHeight {
+		return 0, fmt.Errorf("Evidence was already committed at height %d", committedHeight)
+	}
+
+	var valset *types.ValidatorSet
+	switch {
+	case height == s.LastBlockHeight+1:
+		valset = s.Validators
+	case height == s.LastBlockHeight:
+		valset = s.LastValidators
+	case height > s.LastBlockHeight+1:
+		return 0, fmt.Errorf("Evidence from height %d can not be verified at height %d", height, s.LastBlockHeight)
+	default:
+		valset, err = LoadValidators(s.db, height)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := evidence.Verify(s.ChainID); err != nil {
+		return 0, err
+	}
+
+	// The address must have been an active validator at the height
+	addr, idx := evidence.Address(), evidence.Index()
+	valIdx, val := valset.GetByAddress(addr)
+	if val == nil {
+		return 0, fmt.Errorf("Address %X was not a validator at height %d", addr, height)
+	} else if idx != valIdx {
+		return 0, fmt.Errorf("Address %X was validator %d at height %d, not %d", addr, valIdx, height, idx)
+	}
+
+	return val.VotingPower, nil
+}
+
 //-----------------------------------------------------------------------------
 // ApplyBlock validates & executes the block, updates state w/ ABCI responses,
 // then commits and updates the mempool atomically, then saves state.
 // Transaction results are optionally indexed.
+// Finally, the evidence in the block is marked as committed in the evidence pool.
 
 // Validate, execute, and commit block against app, save block and state
 func (s *State) ApplyBlock(eventCache types.Fireable, proxyAppConn proxy.AppConnConsensus,
-	block *types.Block, partsHeader types.PartSetHeader, mempool types.Mempool) error {
+	block *types.Block, partsHeader types.PartSetHeader, mempool types.Mempool, evpool types.EvidencePool) error {
 
 	abciResponses, err := s.ValExecBlock(eventCache, proxyAppConn, block)
 	if err != nil {
@@ -226,7 +376,9 @@ func (s *State) ApplyBlock(eventCache types.Fireable, proxyAppConn proxy.AppConn
 	fail.Fail() // XXX
 
 	// now update the block and validators
-	s.SetBlockAndValidators(block.Header, partsHeader, abciResponses)
+	if err := s.SetBlockAndValidators(block.Header, partsHeader, abciResponses); err != nil {
+		return fmt.Errorf("Invalid updates from the application: %v", err)
+	}
 
 	// lock mempool, commit state, update mempoool
 	err = s.CommitStateUpdateMempool(proxyAppConn, block, mempool)
@@ -236,9 +388,13 @@ func (s *State) ApplyBlock(eventCache types.Fireable, proxyAppConn proxy.AppConn
 
 	fail.Fail() // XXX
 
-	// save the state
+	// save the state, and the evidence as committed
+	s.saveCommittedEvidence(block)
 	s.Save()
 
+	// update the evidence pool
+	evpool.Update(block)
+
 	return nil
 }
 
@@ -281,6 +437,7 @@ func (s *State) indexTxs(abciResponses *ABCIResponses) {
 			Index:  uint32(i),
 			Tx:     tx,
 			Result: *d,
+			Tags:   abciResponses.DeliverTxTags[i],
 		})
 	}
 	s.TxIndexer.AddBatch(batch)
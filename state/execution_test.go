@@ -2,13 +2,17 @@ package state
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tendermint/abci/example/dummy"
+	abci "github.com/tendermint/abci/types"
 	crypto "github.com/tendermint/go-crypto"
 	"github.com/tendermint/tendermint/proxy"
 	"github.com/tendermint/tendermint/state/txindex"
+	"github.com/tendermint/tendermint/state/txindex/kv"
+	"github.com/tendermint/tendermint/state/txindex/query"
 	"github.com/tendermint/tendermint/types"
 	dbm "github.com/tendermint/tmlibs/db"
 	"github.com/tendermint/tmlibs/log"
@@ -23,7 +27,7 @@ var (
 
 func TestApplyBlock(t *testing.T) {
 	cc := proxy.NewLocalClientCreator(dummy.NewDummyApplication())
-	proxyApp := proxy.NewAppConns(cc, nil)
+	proxyApp := proxy.NewAppConns(cc, nil, proxy.NopMetrics())
 	_, err := proxyApp.Start()
 	require.Nil(t, err)
 	defer proxyApp.Stop()
@@ -36,7 +40,7 @@ func TestApplyBlock(t *testing.T) {
 	// make block
 	block := makeBlock(1, state)
 
-	err = state.ApplyBlock(nil, proxyApp.Consensus(), block, block.MakePartSet(testPartSize).Header(), types.MockMempool{})
+	err = state.ApplyBlock(nil, proxyApp.Consensus(), block, block.MakePartSet(testPartSize).Header(), types.MockMempool{}, types.MockEvidencePool{})
 
 	require.Nil(t, err)
 	assert.Equal(t, nTxsPerBlock, indexer.Indexed) // test indexing works
@@ -44,6 +48,152 @@ func TestApplyBlock(t *testing.T) {
 	// TODO check state and mempool
 }
 
+// paramsApp changes the max txs of the blocks after the given height
+type paramsApp struct {
+	*dummy.DummyApplication
+	height int
+	maxTxs int
+}
+
+func (app *paramsApp) UpdateConsensusParams(height int) *types.ConsensusParams {
+	if height != app.height {
+		return nil
+	}
+	return &types.ConsensusParams{BlockSize: types.BlockSizeParams{MaxTxs: app.maxTxs}}
+}
+
+func TestApplyBlockParamUpdates(t *testing.T) {
+	app := &paramsApp{DummyApplication: dummy.NewDummyApplication(), height: 1, maxTxs: 5}
+	proxyApp := proxy.NewAppConns(proxy.NewLocalClientCreator(app), nil, proxy.NopMetrics())
+	_, err := proxyApp.Start()
+	require.Nil(t, err)
+	defer proxyApp.Stop()
+
+	state := state()
+	state.SetLogger(log.TestingLogger())
+	state.TxIndexer = &dummyIndexer{0}
+	defaultParams := state.ConsensusParams
+
+	block := makeBlock(1, state)
+	err = state.ApplyBlock(nil, proxyApp.Consensus(), block, block.MakePartSet(testPartSize).Header(), types.MockMempool{}, types.MockEvidencePool{})
+	require.Nil(t, err)
+	assert.Equal(t, 5, state.ConsensusParams.BlockSize.MaxTxs)
+	assert.Equal(t, 2, state.LastHeightConsensusParamsChanged)
+
+	// the other params are unchanged
+	expected := defaultParams
+	expected.BlockSize.MaxTxs = 5
+	assert.Equal(t, expected, state.ConsensusParams)
+}
+
+// tagsApp tags every tx with its second byte
+type tagsApp struct {
+	*dummy.DummyApplication
+}
+
+func (app *tagsApp) TxTags(tx []byte, res *abci.ResponseDeliverTx) []*types.TxTag {
+	return []*types.TxTag{{Key: "app.byte", ValueInt: int64(tx[1]), IsInt: true}}
+}
+
+func TestApplyBlockTxTags(t *testing.T) {
+	app := &tagsApp{dummy.NewDummyApplication()}
+	proxyApp := proxy.NewAppConns(proxy.NewLocalClientCreator(app), nil, proxy.NopMetrics())
+	_, err := proxyApp.Start()
+	require.Nil(t, err)
+	defer proxyApp.Stop()
+
+	state := state()
+	state.SetLogger(log.TestingLogger())
+	state.TxIndexer = kv.NewTxIndex(dbm.NewMemDB())
+
+	block := makeBlock(1, state)
+	err = state.ApplyBlock(nil, proxyApp.Consensus(), block, block.MakePartSet(testPartSize).Header(), types.MockMempool{}, types.MockEvidencePool{})
+	require.Nil(t, err)
+
+	// the txs are indexed by the tags the app gave them
+	results, err := state.TxIndexer.Search(query.MustParse("app.byte = 3"))
+	require.Nil(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, block.Txs[3], results[0].Tx)
+		assert.Equal(t, uint32(3), results[0].Index)
+	}
+}
+
+func TestValidateFirstBlockTime(t *testing.T) {
+	state := state()
+	genesisTime := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	state.LastBlockTime = genesisTime
+
+	// the first block can't be before the genesis time
+	block := makeBlock(1, state)
+	assert.NotNil(t, state.ValidateBlock(block))
+
+	block.Time = genesisTime
+	assert.Nil(t, state.ValidateBlock(block))
+}
+
+func TestValidateBlockEvidence(t *testing.T) {
+	cc := proxy.NewLocalClientCreator(dummy.NewDummyApplication())
+	proxyApp := proxy.NewAppConns(cc, nil, proxy.NopMetrics())
+	_, err := proxyApp.Start()
+	require.Nil(t, err)
+	defer proxyApp.Stop()
+
+	state := state()
+	state.SetLogger(log.TestingLogger())
+	ev, ev2 := makeEvidence(1, "a", "b"), makeEvidence(1, "c", "d")
+
+	// the same evidence can't be in a block twice
+	block := makeBlockWithEvidence(1, state, ev, ev)
+	assert.NotNil(t, state.ValidateBlock(block))
+
+	// nor can there be more than MaxNum pieces
+	state.ConsensusParams.Evidence.MaxNum = 1
+	block = makeBlockWithEvidence(1, state, ev, ev2)
+	assert.NotNil(t, state.ValidateBlock(block))
+	state.ConsensusParams.Evidence.MaxNum = 10
+
+	block = makeBlockWithEvidence(1, state, ev)
+	require.Nil(t, state.ValidateBlock(block))
+	err = state.ApplyBlock(nil, proxyApp.Consensus(), block, block.MakePartSet(testPartSize).Header(), types.MockMempool{}, types.MockEvidencePool{})
+	require.Nil(t, err)
+
+	// committed evidence can't be committed again
+	_, err = state.VerifyEvidence(ev)
+	assert.NotNil(t, err)
+	_, err = state.VerifyEvidence(ev2)
+	assert.Nil(t, err)
+
+	// nor can evidence older than MaxAge
+	state.ConsensusParams.Evidence.MaxAge = 1
+	_, err = state.VerifyEvidence(ev2)
+	assert.NotNil(t, err)
+}
+
+func TestMedianTime(t *testing.T) {
+	base := time.Now()
+	// the time each validator votes at, by voting power,
+	// where the validator with 40 doesn't vote
+	offsets := map[int64]time.Duration{10: 4 * time.Second, 20: time.Second, 30: 3 * time.Second}
+
+	vals := []*types.Validator{}
+	for _, power := range []int64{10, 20, 30, 40} {
+		vals = append(vals, types.NewValidator(crypto.GenPrivKeyEd25519().PubKey(), power))
+	}
+	valSet := types.NewValidatorSet(vals)
+
+	commit := &types.Commit{Precommits: make([]*types.Vote, valSet.Size())}
+	for i := 0; i < valSet.Size(); i++ {
+		_, val := valSet.GetByIndex(i)
+		if offset, ok := offsets[val.VotingPower]; ok {
+			commit.Precommits[i] = &types.Vote{ValidatorIndex: i, Timestamp: base.Add(offset)}
+		}
+	}
+
+	// 20 (+1s) is less than half of the 60 that voted, 20+30 (+3s) is more
+	assert.Equal(t, base.Add(3*time.Second), MedianTime(commit, valSet))
+}
+
 //----------------------------------------------------------------------------
 
 // make some bogus txs
@@ -56,7 +206,8 @@ func makeTxs(blockNum int) (txs []types.Tx) {
 
 func state() *State {
 	return MakeGenesisState(dbm.NewMemDB(), &types.GenesisDoc{
-		ChainID: chainID,
+		GenesisTime: time.Now(),
+		ChainID:     chainID,
 		Validators: []types.GenesisValidator{
 			types.GenesisValidator{privKey.PubKey(), 10000, "test"},
 		},
@@ -69,11 +220,37 @@ func makeBlock(num int, state *State) *types.Block {
 	prevParts := types.PartSetHeader{}
 	valHash := state.Validators.Hash()
 	prevBlockID := types.BlockID{prevHash, prevParts}
-	block, _ := types.MakeBlock(num, chainID, makeTxs(num), new(types.Commit),
-		prevBlockID, valHash, state.AppHash, testPartSize)
+	block, _ := types.MakeBlock(num, chainID, makeTxs(num), nil, new(types.Commit),
+		prevBlockID, valHash, state.AppHash, time.Now(), testPartSize)
+	return block
+}
+
+func makeBlockWithEvidence(num int, state *State, evidence ...types.Evidence) *types.Block {
+	prevBlockID := types.BlockID{state.LastBlockID.Hash, types.PartSetHeader{}}
+	block, _ := types.MakeBlock(num, chainID, makeTxs(num), evidence, new(types.Commit),
+		prevBlockID, state.Validators.Hash(), state.AppHash, time.Now(), testPartSize)
 	return block
 }
 
+// makeEvidence returns evidence of our validator prevoting for both blocks.
+func makeEvidence(height int, hashA, hashB string) types.Evidence {
+	makeVote := func(hash string) *types.Vote {
+		vote := &types.Vote{
+			ValidatorAddress: privKey.PubKey().Address(),
+			ValidatorIndex:   0,
+			Height:           height,
+			Round:            0,
+			Timestamp:        time.Now(),
+			Type:             types.VoteTypePrevote,
+			BlockID:          types.BlockID{Hash: []byte(hash)},
+		}
+		vote.Signature = privKey.Sign(types.SignBytes(chainID, vote))
+		return vote
+	}
+	conflict := &types.ErrVoteConflictingVotes{VoteA: makeVote(hashA), VoteB: makeVote(hashB)}
+	return types.NewDuplicateVoteEvidence(privKey.PubKey(), conflict)
+}
+
 // dummyIndexer increments counter every time we index transaction.
 type dummyIndexer struct {
 	Indexed int
@@ -82,6 +259,13 @@ type dummyIndexer struct {
 func (indexer *dummyIndexer) Get(hash []byte) (*types.TxResult, error) {
 	return nil, nil
 }
+
+func (indexer *dummyIndexer) Search(q *query.Query) ([]*types.TxResult, error) {
+	return nil, nil
+}
+func (indexer *dummyIndexer) Prune(retainHeight int, keepEvery int) (int, error) {
+	return 0, nil
+}
 func (indexer *dummyIndexer) AddBatch(batch *txindex.Batch) error {
 	indexer.Indexed += batch.Size()
 	return nil
@@ -2,6 +2,8 @@ package state
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"sync"
 	"time"
@@ -41,6 +43,11 @@ type State struct {
 	Validators      *types.ValidatorSet
 	LastValidators  *types.ValidatorSet // block.LastCommit validated against this
 
+	// Consensus parameters used for validating blocks.
+	// Changes from the app after EndBlock, and updated after Commit.
+	ConsensusParams                  types.ConsensusParams
+	LastHeightConsensusParamsChanged int
+
 	// AppHash is updated after Commit
 	AppHash []byte
 
@@ -84,38 +91,82 @@ func (s *State) Copy() *State {
 		LastBlockTime:   s.LastBlockTime,
 		Validators:      s.Validators.Copy(),
 		LastValidators:  s.LastValidators.Copy(),
-		AppHash:         s.AppHash,
-		TxIndexer:       s.TxIndexer, // pointer here, not value
-		logger:          s.logger,
+
+		ConsensusParams:                  s.ConsensusParams,
+		LastHeightConsensusParamsChanged: s.LastHeightConsensusParamsChanged,
+
+		AppHash:   s.AppHash,
+		TxIndexer: s.TxIndexer, // pointer here, not value
+		logger:    s.logger,
 	}
 }
 
 func (s *State) Save() {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
+	s.saveValidatorsInfo()
+	s.saveConsensusParamsInfo()
 	s.db.SetSync(stateKey, s.Bytes())
 }
 
+func calcABCIResponsesKey(height int) []byte {
+	return []byte(cmn.Fmt("abciResponsesKey:%v", height))
+}
+
 // Sets the ABCIResponses in the state and writes them to disk
-// in case we crash after app.Commit and before s.Save()
+// in case we crash after app.Commit and before s.Save().
+// They're also kept by height, for the block_results rpc.
 func (s *State) SaveABCIResponses(abciResponses *ABCIResponses) {
-	// save the validators to the db
-	s.db.SetSync(abciResponsesKey, abciResponses.Bytes())
+	buf := abciResponses.Bytes()
+	s.db.Set(calcABCIResponsesKey(abciResponses.Height), buf)
+	s.db.SetSync(abciResponsesKey, buf)
 }
 
+// LoadABCIResponses returns the ABCIResponses of the latest block.
 func (s *State) LoadABCIResponses() *ABCIResponses {
-	abciResponses := new(ABCIResponses)
+	abciResponses := loadABCIResponses(s.db, abciResponsesKey)
+	if abciResponses == nil {
+		return new(ABCIResponses)
+	}
+	return abciResponses
+}
 
-	buf := s.db.Get(abciResponsesKey)
-	if len(buf) != 0 {
-		r, n, err := bytes.NewReader(buf), new(int), new(error)
-		wire.ReadBinaryPtr(abciResponses, r, 0, n, err)
-		if *err != nil {
-			// DATA HAS BEEN CORRUPTED OR THE SPEC HAS CHANGED
-			cmn.Exit(cmn.Fmt("LoadABCIResponses: Data has been corrupted or its spec has changed: %v\n", *err))
+// LoadABCIResponses returns the ABCIResponses of the block at the given
+// height, or nil if they're not in the db (eg. they were pruned).
+func LoadABCIResponses(db dbm.DB, height int) *ABCIResponses {
+	return loadABCIResponses(db, calcABCIResponsesKey(height))
+}
+
+// PruneABCIResponses removes the ABCIResponses from height `from` up to
+// retainHeight, except every keepEvery'th block's (if keepEvery > 0).
+// It returns the number of heights pruned.
+func PruneABCIResponses(db dbm.DB, from, retainHeight, keepEvery int) int {
+	pruned := 0
+	batch := db.NewBatch()
+	for height := from; height < retainHeight; height++ {
+		if keepEvery > 0 && height%keepEvery == 0 {
+			continue
 		}
-		// TODO: ensure that buf is completely read.
+		batch.Delete(calcABCIResponsesKey(height))
+		pruned++
+	}
+	batch.Write()
+	return pruned
+}
+
+func loadABCIResponses(db dbm.DB, key []byte) *ABCIResponses {
+	buf := db.Get(key)
+	if len(buf) == 0 {
+		return nil
 	}
+	abciResponses := new(ABCIResponses)
+	r, n, err := bytes.NewReader(buf), new(int), new(error)
+	wire.ReadBinaryPtr(abciResponses, r, 0, n, err)
+	if *err != nil {
+		// DATA HAS BEEN CORRUPTED OR THE SPEC HAS CHANGED
+		cmn.Exit(cmn.Fmt("LoadABCIResponses: Data has been corrupted or its spec has changed: %v\n", *err))
+	}
+	// TODO: ensure that buf is completely read.
 	return abciResponses
 }
 
@@ -133,8 +184,18 @@ func (s *State) Bytes() []byte {
 }
 
 // Mutate state variables to match block and validators
-// after running EndBlock
-func (s *State) SetBlockAndValidators(header *types.Header, blockPartsHeader types.PartSetHeader, abciResponses *ABCIResponses) {
+// after running EndBlock. It returns an error, and leaves the state
+// unchanged, if the app updates the consensus params to invalid ones.
+func (s *State) SetBlockAndValidators(header *types.Header, blockPartsHeader types.PartSetHeader, abciResponses *ABCIResponses) error {
+
+	// update the params with the latest abciResponses
+	nextParams := s.ConsensusParams
+	if abciResponses.ConsensusParamUpdates != nil {
+		nextParams = s.ConsensusParams.Update(abciResponses.ConsensusParamUpdates)
+		if err := nextParams.Validate(); err != nil {
+			return fmt.Errorf("Error updating consensus params: %v", err)
+		}
+	}
 
 	// copy the valset so we can apply changes from EndBlock
 	// and update s.LastValidators and s.Validators
@@ -151,9 +212,15 @@ func (s *State) SetBlockAndValidators(header *types.Header, blockPartsHeader typ
 	// Update validator accums and set state variables
 	nextValSet.IncrementAccum(1)
 
+	if abciResponses.ConsensusParamUpdates != nil {
+		s.LastHeightConsensusParamsChanged = header.Height + 1
+	}
+
 	s.setBlockAndValidators(header.Height,
 		types.BlockID{header.Hash(), blockPartsHeader}, header.Time,
 		prevValSet, nextValSet)
+	s.ConsensusParams = nextParams
+	return nil
 }
 
 func (s *State) setBlockAndValidators(
@@ -187,19 +254,34 @@ func GetState(stateDB dbm.DB, genesisFile string) *State {
 // ABCIResponses holds intermediate state during block processing
 
 type ABCIResponses struct {
-	Height int
+	Height int `json:"height"`
+
+	DeliverTx []*abci.ResponseDeliverTx `json:"deliver_tx"`
+	EndBlock  abci.ResponseEndBlock     `json:"end_block"`
+
+	// DeliverTxTags are the tags of each tx, from the app after DeliverTx
+	// (see proxy.TxTagger).
+	DeliverTxTags [][]*types.TxTag `json:"deliver_tx_tags"`
 
-	DeliverTx []*abci.ResponseDeliverTx
-	EndBlock  abci.ResponseEndBlock
+	// ConsensusParamUpdates are applied to the params of the next block.
+	// They come from the app after EndBlock (see proxy.ParamsUpdater).
+	// TODO: read them from abci's ResponseEndBlock once it has them.
+	ConsensusParamUpdates *types.ConsensusParams `json:"consensus_param_updates,omitempty"`
 
 	txs types.Txs // reference for indexing results by hash
 }
 
 func NewABCIResponses(block *types.Block) *ABCIResponses {
+	// empty rather than nil tags, as that's how go-wire reads them back
+	deliverTxTags := make([][]*types.TxTag, block.NumTxs)
+	for i := range deliverTxTags {
+		deliverTxTags[i] = []*types.TxTag{}
+	}
 	return &ABCIResponses{
-		Height:    block.Height,
-		DeliverTx: make([]*abci.ResponseDeliverTx, block.NumTxs),
-		txs:       block.Data.Txs,
+		Height:        block.Height,
+		DeliverTx:     make([]*abci.ResponseDeliverTx, block.NumTxs),
+		DeliverTxTags: deliverTxTags,
+		txs:           block.Data.Txs,
 	}
 }
 
@@ -213,6 +295,213 @@ func (a *ABCIResponses) Bytes() []byte {
 	return buf.Bytes()
 }
 
+//-----------------------------------------------------------------------------
+// ValidatorsInfo
+
+// The full validator set is also stored every valSetCheckpointInterval
+// heights, so loading it doesn't increment the accums too many times.
+const valSetCheckpointInterval = 100000
+
+func calcValidatorsKey(height int) []byte {
+	return []byte(cmn.Fmt("validatorsKey:%v", height))
+}
+
+// ValidatorsInfo is stored for every height, with the full validator set
+// only at heights where it changed, and at checkpoints.
+type ValidatorsInfo struct {
+	ValidatorSet      *types.ValidatorSet
+	LastHeightChanged int
+}
+
+// Bytes serializes the ValidatorsInfo.
+func (vi *ValidatorsInfo) Bytes() []byte {
+	buf, n, err := new(bytes.Buffer), new(int), new(error)
+	wire.WriteBinary(*vi, buf, n, err)
+	if *err != nil {
+		cmn.PanicCrisis(*err)
+	}
+	return buf.Bytes()
+}
+
+// LoadValidators returns the validator set for the block at the given height,
+// or an ErrNoValSetForHeight if it's not in the db.
+func LoadValidators(db dbm.DB, height int) (*types.ValidatorSet, error) {
+	info := loadValidatorsInfo(db, height)
+	if info == nil {
+		return nil, ErrNoValSetForHeight{height}
+	}
+	if info.ValidatorSet != nil {
+		return info.ValidatorSet, nil
+	}
+
+	storedHeight := lastStoredHeightFor(height, info.LastHeightChanged)
+	stored := loadValidatorsInfo(db, storedHeight)
+	if stored == nil || stored.ValidatorSet == nil {
+		cmn.PanicCrisis(cmn.Fmt("Couldn't find validators at height %d as last stored from height %d",
+			storedHeight, height))
+	}
+	// the accums are incremented every block since
+	valSet := stored.ValidatorSet
+	for h := storedHeight; h < height; h++ {
+		valSet.IncrementAccum(1)
+	}
+	return valSet, nil
+}
+
+// lastStoredHeightFor returns the height the full validator set for the
+// given height was stored at.
+func lastStoredHeightFor(height, lastHeightChanged int) int {
+	checkpointHeight := height - height%valSetCheckpointInterval
+	return cmn.MaxInt(checkpointHeight, lastHeightChanged)
+}
+
+func loadValidatorsInfo(db dbm.DB, height int) *ValidatorsInfo {
+	buf := db.Get(calcValidatorsKey(height))
+	if len(buf) == 0 {
+		return nil
+	}
+	info := new(ValidatorsInfo)
+	r, n, err := bytes.NewReader(buf), new(int), new(error)
+	wire.ReadBinaryPtr(info, r, 0, n, err)
+	if *err != nil {
+		// DATA HAS BEEN CORRUPTED OR THE SPEC HAS CHANGED
+		cmn.Exit(cmn.Fmt("LoadValidators: Data has been corrupted or its spec has changed: %v\n", *err))
+	}
+	return info
+}
+
+// saveValidatorsInfo stores the validators for the next block, and the
+// last block's if they're missing (eg. after state sync).
+// The full set is only stored if it changed since the last block, or at
+// checkpoints.
+func (s *State) saveValidatorsInfo() {
+	if s.LastBlockHeight > 0 && loadValidatorsInfo(s.db, s.LastBlockHeight) == nil {
+		info := &ValidatorsInfo{s.LastValidators, s.LastBlockHeight}
+		s.db.Set(calcValidatorsKey(s.LastBlockHeight), info.Bytes())
+	}
+
+	nextHeight := s.LastBlockHeight + 1
+	info := &ValidatorsInfo{s.Validators, nextHeight}
+	if s.LastBlockHeight > 0 && bytes.Equal(s.LastValidators.Hash(), s.Validators.Hash()) {
+		info.LastHeightChanged = loadValidatorsInfo(s.db, s.LastBlockHeight).LastHeightChanged
+		if nextHeight%valSetCheckpointInterval != 0 {
+			info.ValidatorSet = nil
+		}
+	}
+	s.db.Set(calcValidatorsKey(nextHeight), info.Bytes())
+}
+
+//-----------------------------------------------------------------------------
+// Committed evidence
+
+func calcEvidenceKey(evidence types.Evidence) []byte {
+	return []byte(cmn.Fmt("evidenceKey:%v:%X", evidence.Height(), evidence.Hash()))
+}
+
+// saveCommittedEvidence stores the height of the block which committed each
+// piece of its evidence, so it can't be committed again.
+func (s *State) saveCommittedEvidence(block *types.Block) {
+	for _, ev := range block.Evidence.Evidence {
+		s.db.Set(calcEvidenceKey(ev), wire.BinaryBytes(block.Height))
+	}
+}
+
+// evidenceCommittedAt returns the height of the block which committed the
+// evidence, or 0 if it wasn't committed.
+// NOTE: it may be the block we're replaying, so the caller must compare it
+// with LastBlockHeight.
+func (s *State) evidenceCommittedAt(evidence types.Evidence) int {
+	buf := s.db.Get(calcEvidenceKey(evidence))
+	if len(buf) == 0 {
+		return 0
+	}
+	var height int
+	err := wire.ReadBinaryBytes(buf, &height)
+	if err != nil {
+		// DATA HAS BEEN CORRUPTED OR THE SPEC HAS CHANGED
+		cmn.Exit(cmn.Fmt("LoadCommittedEvidence: Data has been corrupted or its spec has changed: %v\n", err))
+	}
+	return height
+}
+
+//-----------------------------------------------------------------------------
+// ConsensusParamsInfo
+
+func calcConsensusParamsKey(height int) []byte {
+	return []byte(cmn.Fmt("consensusParamsKey:%v", height))
+}
+
+// ConsensusParamsInfo is stored for every height, with the full params
+// only at heights where they changed.
+type ConsensusParamsInfo struct {
+	ConsensusParams   *types.ConsensusParams
+	LastHeightChanged int
+}
+
+// Bytes serializes the ConsensusParamsInfo.
+func (pi *ConsensusParamsInfo) Bytes() []byte {
+	buf, n, err := new(bytes.Buffer), new(int), new(error)
+	wire.WriteBinary(*pi, buf, n, err)
+	if *err != nil {
+		cmn.PanicCrisis(*err)
+	}
+	return buf.Bytes()
+}
+
+// LoadConsensusParams returns the consensus params for the block at the
+// given height, or an ErrNoConsensusParamsForHeight if they're not in the db.
+func LoadConsensusParams(db dbm.DB, height int) (types.ConsensusParams, error) {
+	empty := types.ConsensusParams{}
+
+	info := loadConsensusParamsInfo(db, height)
+	if info == nil {
+		return empty, ErrNoConsensusParamsForHeight{height}
+	}
+	if info.ConsensusParams != nil {
+		return *info.ConsensusParams, nil
+	}
+
+	changed := loadConsensusParamsInfo(db, info.LastHeightChanged)
+	if changed == nil || changed.ConsensusParams == nil {
+		cmn.PanicCrisis(cmn.Fmt("Couldn't find consensus params at height %d as last changed from height %d",
+			info.LastHeightChanged, height))
+	}
+	return *changed.ConsensusParams, nil
+}
+
+func loadConsensusParamsInfo(db dbm.DB, height int) *ConsensusParamsInfo {
+	buf := db.Get(calcConsensusParamsKey(height))
+	if len(buf) == 0 {
+		return nil
+	}
+	info := new(ConsensusParamsInfo)
+	r, n, err := bytes.NewReader(buf), new(int), new(error)
+	wire.ReadBinaryPtr(info, r, 0, n, err)
+	if *err != nil {
+		// DATA HAS BEEN CORRUPTED OR THE SPEC HAS CHANGED
+		cmn.Exit(cmn.Fmt("LoadConsensusParams: Data has been corrupted or its spec has changed: %v\n", *err))
+	}
+	return info
+}
+
+// saveConsensusParamsInfo stores the consensus params for the next block.
+// The full params are only stored if they changed at that height, or if
+// the height they last changed at is missing (eg. after state sync).
+func (s *State) saveConsensusParamsInfo() {
+	nextHeight := s.LastBlockHeight + 1
+	changed := s.LastHeightConsensusParamsChanged
+	if changed != nextHeight && loadConsensusParamsInfo(s.db, changed) == nil {
+		s.LastHeightConsensusParamsChanged = nextHeight
+	}
+
+	params := s.ConsensusParams
+	info := &ConsensusParamsInfo{&params, s.LastHeightConsensusParamsChanged}
+	if s.LastHeightConsensusParamsChanged != nextHeight {
+		info.ConsensusParams = nil
+	}
+	s.db.Set(calcConsensusParamsKey(nextHeight), info.Bytes())
+}
+
 //-----------------------------------------------------------------------------
 // Genesis
 
@@ -235,12 +524,13 @@ func MakeGenesisStateFromFile(db dbm.DB, genDocFile string) *State {
 //
 // Used in tests.
 func MakeGenesisState(db dbm.DB, genDoc *types.GenesisDoc) *State {
-	if len(genDoc.Validators) == 0 {
-		cmn.Exit(cmn.Fmt("The genesis file has no validators"))
+	if err := genDoc.ValidateAndComplete(); err != nil {
+		cmn.Exit(cmn.Fmt("Invalid genesis: %v", err))
 	}
 
-	if genDoc.GenesisTime.IsZero() {
-		genDoc.GenesisTime = time.Now()
+	params := types.DefaultConsensusParams()
+	if genDoc.ConsensusParams != nil {
+		params = genDoc.ConsensusParams
 	}
 
 	// Make validators slice
@@ -266,7 +556,74 @@ func MakeGenesisState(db dbm.DB, genDoc *types.GenesisDoc) *State {
 		LastBlockTime:   genDoc.GenesisTime,
 		Validators:      types.NewValidatorSet(validators),
 		LastValidators:  types.NewValidatorSet(nil),
-		AppHash:         genDoc.AppHash,
-		TxIndexer:       &null.TxIndex{}, // we do not need indexer during replay and in tests
+
+		ConsensusParams:                  *params,
+		LastHeightConsensusParamsChanged: 1,
+
+		AppHash:   genDoc.AppHash,
+		TxIndexer: &null.TxIndex{}, // we do not need indexer during replay and in tests
+	}
+}
+
+// ExportGenesisDoc returns the genesis of a new chain which starts from the
+// state after the block at the given height: the validators and consensus
+// params for the next block, and the app hash after the block. Its time is
+// the time of the block, so every node exports the same genesis. The chain
+// ID is the old one, the caller has to change it.
+// If height is 0, it exports from the latest block.
+func ExportGenesisDoc(db dbm.DB, store types.BlockStoreRPC, height int) (*types.GenesisDoc, error) {
+	s := LoadState(db)
+	if s == nil || s.LastBlockHeight == 0 {
+		return nil, errors.New("There are no blocks to export from")
 	}
+	if height == 0 {
+		height = s.LastBlockHeight
+	}
+	if height < 1 || height > s.LastBlockHeight {
+		return nil, fmt.Errorf("Can only export from heights 1 to %d, got %d", s.LastBlockHeight, height)
+	}
+
+	meta := store.LoadBlockMeta(height)
+	if meta == nil {
+		return nil, fmt.Errorf("The block at height %d is not in the block store", height)
+	}
+	appHash := s.AppHash
+	if height < s.LastBlockHeight {
+		// the app hash after a block is in the header of the next one
+		nextMeta := store.LoadBlockMeta(height + 1)
+		if nextMeta == nil {
+			return nil, fmt.Errorf("The block at height %d is not in the block store", height+1)
+		}
+		appHash = nextMeta.Header.AppHash
+	}
+	valSet, err := LoadValidators(db, height+1)
+	if err != nil {
+		return nil, err
+	}
+	params, err := LoadConsensusParams(db, height+1)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the names of the validators from the old genesis
+	names := make(map[string]string)
+	for _, val := range s.GenesisDoc.Validators {
+		names[string(val.PubKey.Address())] = val.Name
+	}
+	validators := make([]types.GenesisValidator, len(valSet.Validators))
+	for i, val := range valSet.Validators {
+		validators[i] = types.GenesisValidator{
+			PubKey: val.PubKey,
+			Amount: val.VotingPower,
+			Name:   names[string(val.Address)],
+		}
+	}
+
+	return &types.GenesisDoc{
+		GenesisTime:     meta.Header.Time,
+		ChainID:         s.ChainID,
+		ConsensusParams: &params,
+		Validators:      validators,
+		AppHash:         appHash,
+	}, nil
 }
@@ -3,11 +3,14 @@ package state
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	abci "github.com/tendermint/abci/types"
 	crypto "github.com/tendermint/go-crypto"
 	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/types"
 	dbm "github.com/tendermint/tmlibs/db"
 	"github.com/tendermint/tmlibs/log"
 )
@@ -75,4 +78,180 @@ func TestABCIResponsesSaveLoad(t *testing.T) {
 	state.SaveABCIResponses(abciResponses)
 	abciResponses2 := state.LoadABCIResponses()
 	assert.Equal(abciResponses, abciResponses2, fmt.Sprintf("ABCIResponses don't match: Got %v, Expected %v", abciResponses2, abciResponses))
+
+	// they're kept by height too
+	abciResponses3 := LoadABCIResponses(stateDB, block.Height)
+	assert.Equal(abciResponses, abciResponses3, fmt.Sprintf("ABCIResponses don't match: Got %v, Expected %v", abciResponses3, abciResponses))
+	assert.Nil(LoadABCIResponses(stateDB, block.Height+1))
+
+	// until they're pruned
+	assert.Equal(1, PruneABCIResponses(stateDB, block.Height, block.Height+1, 0))
+	assert.Nil(LoadABCIResponses(stateDB, block.Height))
+}
+
+func TestValidatorsSaveLoad(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	config := cfg.ResetTestRoot("state_")
+	stateDB := dbm.NewDB("state", config.DBBackend, config.DBDir())
+	state := GetState(stateDB, config.GenesisFile())
+	state.SetLogger(log.TestingLogger())
+
+	// the genesis validators are for the first block
+	_, err := LoadValidators(stateDB, 0)
+	assert.IsType(ErrNoValSetForHeight{}, err)
+	v1, err := LoadValidators(stateDB, 1)
+	require.Nil(err)
+	assert.Equal(1, v1.Size())
+
+	// a validator is added at height 3, for height 4
+	for height := 1; height <= 5; height++ {
+		header := &types.Header{ChainID: state.ChainID, Height: height}
+		abciResponses := &ABCIResponses{Height: height}
+		if height == 3 {
+			abciResponses.EndBlock.Diffs = []*abci.Validator{
+				{PubKey: crypto.GenPrivKeyEd25519().PubKey().Bytes(), Power: 10},
+			}
+		}
+		state.SetBlockAndValidators(header, types.PartSetHeader{}, abciResponses)
+		state.Save()
+	}
+
+	for height, size := range map[int]int{2: 1, 3: 1, 4: 2, 6: 2} {
+		vals, err := LoadValidators(stateDB, height)
+		require.Nil(err, "height %d: %+v", height, err)
+		assert.Equal(size, vals.Size(), "height %d", height)
+	}
+	_, err = LoadValidators(stateDB, 7)
+	assert.IsType(ErrNoValSetForHeight{}, err)
+
+	// the accums are the same as the state's
+	vals, err := LoadValidators(stateDB, 6)
+	require.Nil(err)
+	assert.Equal(state.Validators.Validators, vals.Validators)
+	vals, err = LoadValidators(stateDB, 5)
+	require.Nil(err)
+	assert.Equal(state.LastValidators.Validators, vals.Validators)
+}
+
+func TestConsensusParamsChangesSaveLoad(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	config := cfg.ResetTestRoot("state_")
+	stateDB := dbm.NewDB("state", config.DBBackend, config.DBDir())
+	state := GetState(stateDB, config.GenesisFile())
+	state.SetLogger(log.TestingLogger())
+
+	// the genesis params are for the first block
+	defaultParams := *types.DefaultConsensusParams()
+	params, err := LoadConsensusParams(stateDB, 1)
+	require.Nil(err)
+	assert.Equal(defaultParams, params)
+
+	// the max txs are changed at height 3, for height 4
+	for height := 1; height <= 5; height++ {
+		header := &types.Header{ChainID: state.ChainID, Height: height}
+		abciResponses := &ABCIResponses{Height: height}
+		if height == 3 {
+			abciResponses.ConsensusParamUpdates = &types.ConsensusParams{
+				BlockSize: types.BlockSizeParams{MaxTxs: 10},
+			}
+		}
+		require.Nil(state.SetBlockAndValidators(header, types.PartSetHeader{}, abciResponses))
+		state.Save()
+	}
+
+	// an invalid change is an error, and leaves the state unchanged
+	stateBytes := state.Bytes()
+	err = state.SetBlockAndValidators(&types.Header{ChainID: state.ChainID, Height: 6}, types.PartSetHeader{}, &ABCIResponses{
+		Height: 6,
+		ConsensusParamUpdates: &types.ConsensusParams{
+			BlockSize: types.BlockSizeParams{MaxBytes: types.MaxBlockSize + 1},
+		},
+	})
+	assert.NotNil(err)
+	assert.Equal(stateBytes, state.Bytes())
+
+	changedParams := defaultParams
+	changedParams.BlockSize.MaxTxs = 10
+	for height, expected := range map[int]types.ConsensusParams{
+		2: defaultParams, 3: defaultParams, 4: changedParams, 6: changedParams} {
+		params, err := LoadConsensusParams(stateDB, height)
+		require.Nil(err, "height %d: %+v", height, err)
+		assert.Equal(expected, params, "height %d", height)
+	}
+	assert.Equal(changedParams, state.ConsensusParams)
+	_, err = LoadConsensusParams(stateDB, 7)
+	assert.IsType(ErrNoConsensusParamsForHeight{}, err)
+}
+
+// blockMetaStore only serves the block metas
+type blockMetaStore struct {
+	types.BlockStoreRPC
+	metas map[int]*types.BlockMeta
+}
+
+func (bs blockMetaStore) LoadBlockMeta(height int) *types.BlockMeta {
+	return bs.metas[height]
+}
+
+func TestExportGenesisDoc(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	config := cfg.ResetTestRoot("state_")
+	stateDB := dbm.NewDB("state", config.DBBackend, config.DBDir())
+	state := GetState(stateDB, config.GenesisFile())
+	state.SetLogger(log.TestingLogger())
+	store := blockMetaStore{metas: make(map[int]*types.BlockMeta)}
+
+	_, err := ExportGenesisDoc(stateDB, store, 0)
+	assert.NotNil(err, "there are no blocks yet")
+
+	// a validator is added at height 3, for height 4,
+	// and the app hash after each block is its height
+	genTime := state.LastBlockTime
+	for height := 1; height <= 5; height++ {
+		header := &types.Header{
+			ChainID: state.ChainID,
+			Height:  height,
+			Time:    genTime.Add(time.Duration(height) * time.Second),
+			AppHash: state.AppHash,
+		}
+		abciResponses := &ABCIResponses{Height: height}
+		if height == 3 {
+			abciResponses.EndBlock.Diffs = []*abci.Validator{
+				{PubKey: crypto.GenPrivKeyEd25519().PubKey().Bytes(), Power: 10},
+			}
+		}
+		state.SetBlockAndValidators(header, types.PartSetHeader{}, abciResponses)
+		state.AppHash = []byte{byte(height)}
+		state.Save()
+		store.metas[height] = &types.BlockMeta{Header: header}
+	}
+
+	for height, nVals := range map[int]int{2: 1, 3: 2, 5: 2} {
+		genDoc, err := ExportGenesisDoc(stateDB, store, height)
+		require.Nil(err, "height %d: %+v", height, err)
+		assert.Equal(state.ChainID, genDoc.ChainID)
+		assert.Equal(store.metas[height].Header.Time, genDoc.GenesisTime, "height %d", height)
+		assert.EqualValues([]byte{byte(height)}, genDoc.AppHash, "height %d", height)
+		assert.Equal(nVals, len(genDoc.Validators), "height %d", height)
+		assert.Equal(state.ConsensusParams, *genDoc.ConsensusParams)
+	}
+
+	// the latest block by default, with the names of the genesis validators
+	genDoc, err := ExportGenesisDoc(stateDB, store, 0)
+	require.Nil(err, "%+v", err)
+	assert.EqualValues([]byte{5}, genDoc.AppHash)
+	genVal := state.GenesisDoc.Validators[0]
+	for _, val := range genDoc.Validators {
+		if val.PubKey.Equals(genVal.PubKey) {
+			assert.Equal(genVal.Name, val.Name)
+		} else {
+			assert.Equal("", val.Name)
+		}
+	}
+
+	_, err = ExportGenesisDoc(stateDB, store, 6)
+	assert.NotNil(err, "there's no block 6")
 }
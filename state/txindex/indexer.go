@@ -3,6 +3,7 @@ package txindex
 import (
 	"errors"
 
+	"github.com/tendermint/tendermint/state/txindex/query"
 	"github.com/tendermint/tendermint/types"
 )
 
@@ -19,6 +20,16 @@ type TxIndexer interface {
 	// Tx returns specified transaction or nil if the transaction is not indexed
 	// or stored.
 	Get(hash []byte) (*types.TxResult, error)
+
+	// Search allows you to query for transactions. Conditions in the query
+	// are matched against the tags returned by DeliverTx and the reserved
+	// types.TxHeightKey and types.TxHashKey tags.
+	Search(q *query.Query) ([]*types.TxResult, error)
+
+	// Prune removes the transactions of all blocks below retainHeight,
+	// except those of every keepEvery'th block (if keepEvery > 0).
+	// It returns the number of transactions removed.
+	Prune(retainHeight int, keepEvery int) (int, error)
 }
 
 //----------------------------------------------------
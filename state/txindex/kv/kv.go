@@ -2,22 +2,29 @@ package kv
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
-	db "github.com/tendermint/tmlibs/db"
 	"github.com/tendermint/go-wire"
+	tmdb "github.com/tendermint/tendermint/db"
 	"github.com/tendermint/tendermint/state/txindex"
+	"github.com/tendermint/tendermint/state/txindex/query"
 	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tmlibs/db"
 )
 
 // TxIndex is the simplest possible indexer, backed by Key-Value storage (levelDB).
-// It could only index transaction by its identifier.
+// It indexes transactions by their hash and by their tags (see types.TxTag),
+// plus the height of the block (see types.TxHeightKey).
 type TxIndex struct {
-	store db.DB
+	store dbm.DB
 }
 
 // NewTxIndex returns new instance of TxIndex.
-func NewTxIndex(store db.DB) *TxIndex {
+func NewTxIndex(store dbm.DB) *TxIndex {
 	return &TxIndex{store: store}
 }
 
@@ -48,9 +55,311 @@ func (txi *TxIndex) Get(hash []byte) (*types.TxResult, error) {
 func (txi *TxIndex) AddBatch(b *txindex.Batch) error {
 	storeBatch := txi.store.NewBatch()
 	for _, result := range b.Ops {
+		hash := result.Tx.Hash()
+
+		// index tx by tags
+		for _, tag := range result.Tags {
+			if tag == nil || tag.Key == "" {
+				continue
+			}
+			storeBatch.Set(keyForTag(tag, &result), hash)
+		}
+
+		// index tx by height
+		storeBatch.Set(keyForHeight(&result), hash)
+
+		// index tx by hash
 		rawBytes := wire.BinaryBytes(&result)
-		storeBatch.Set(result.Tx.Hash(), rawBytes)
+		storeBatch.Set(hash, rawBytes)
 	}
 	storeBatch.Write()
 	return nil
 }
+
+// Search performs a search using the given query. Conditions are ANDed
+// together; results are sorted by height and index.
+func (txi *TxIndex) Search(q *query.Query) ([]*types.TxResult, error) {
+	conditions := q.Conditions()
+
+	// if there is a hash condition, get the tx directly and check it against
+	// the other conditions
+	for i, c := range conditions {
+		if c.Tag != types.TxHashKey {
+			continue
+		}
+		hash, err := decodeHash(c)
+		if err != nil {
+			return nil, err
+		}
+		res, err := txi.Get(hash)
+		if err != nil || res == nil {
+			return []*types.TxResult{}, err
+		}
+		for j, other := range conditions {
+			if j == i {
+				continue
+			}
+			ok, err := matchesResult(other, res)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return []*types.TxResult{}, nil
+			}
+		}
+		return []*types.TxResult{res}, nil
+	}
+
+	var hashes map[string][]byte
+	for i, c := range conditions {
+		matches := txi.match(c)
+		if i == 0 {
+			hashes = matches
+			continue
+		}
+		// intersect
+		for k := range hashes {
+			if _, ok := matches[k]; !ok {
+				delete(hashes, k)
+			}
+		}
+	}
+
+	results := make([]*types.TxResult, 0, len(hashes))
+	for _, h := range hashes {
+		res, err := txi.Get(h)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get Tx{%X}: %v", h, err)
+		}
+		if res != nil {
+			results = append(results, res)
+		}
+	}
+	sort.Sort(byHeightAndIndex(results))
+
+	return results, nil
+}
+
+// Prune removes the txs below retainHeight, along with their tags.
+// Txs are found through the height tag (see types.TxHeightKey), whose keys
+// are ordered by height.
+func (txi *TxIndex) Prune(retainHeight int, keepEvery int) (int, error) {
+	prefix := intTagPrefix(types.TxHeightKey)
+	end := append(append([]byte{}, prefix...), encodeInt(int64(retainHeight))...)
+
+	var hashes [][]byte
+	it := tmdb.IteratorRange(txi.store, prefix, end)
+	for it.Next() {
+		height, ok := extractIntFromKey(it.Key()[len(prefix):])
+		if !ok || height >= int64(retainHeight) {
+			continue
+		}
+		if keepEvery > 0 && height%int64(keepEvery) == 0 {
+			continue
+		}
+		hashes = append(hashes, append([]byte{}, it.Value()...))
+	}
+	tmdb.Release(it)
+
+	storeBatch := txi.store.NewBatch()
+	for _, hash := range hashes {
+		result, err := txi.Get(hash)
+		if err != nil {
+			return 0, err
+		}
+		if result == nil {
+			continue
+		}
+		for _, tag := range result.Tags {
+			if tag == nil || tag.Key == "" {
+				continue
+			}
+			storeBatch.Delete(keyForTag(tag, result))
+		}
+		storeBatch.Delete(keyForHeight(result))
+		storeBatch.Delete(hash)
+	}
+	storeBatch.Write()
+	return len(hashes), nil
+}
+
+// match returns the hashes of all txs having a tag which satisfies the
+// condition. Only the keys of the tag which may match are read.
+func (txi *TxIndex) match(c query.Condition) map[string][]byte {
+	hashes := make(map[string][]byte)
+	add := func(it dbm.Iterator, matches func(key []byte) bool) {
+		defer tmdb.Release(it)
+		for it.Next() {
+			if matches(it.Key()) {
+				hash := it.Value()
+				hashes[string(hash)] = append([]byte{}, hash...)
+			}
+		}
+	}
+
+	stringPrefix := stringTagPrefix(c.Tag)
+	switch operand := c.Operand.(type) {
+	case string:
+		// strings can only be compared for equality
+		prefix := append(append([]byte{}, stringPrefix...), operand+"/"...)
+		add(tmdb.IteratorPrefix(txi.store, prefix), func(key []byte) bool {
+			value, ok := extractValueFromKey(key[len(stringPrefix):])
+			return ok && c.MatchesString(value)
+		})
+	case int64:
+		intPrefix := intTagPrefix(c.Tag)
+		start, end := intRange(intPrefix, c.Op, operand)
+		add(tmdb.IteratorRange(txi.store, start, end), func(key []byte) bool {
+			value, ok := extractIntFromKey(key[len(intPrefix):])
+			return ok && c.MatchesInt(value)
+		})
+		// string values are compared numerically too
+		add(tmdb.IteratorPrefix(txi.store, stringPrefix), func(key []byte) bool {
+			value, ok := extractValueFromKey(key[len(stringPrefix):])
+			return ok && c.MatchesString(value)
+		})
+	}
+	return hashes
+}
+
+// decodeHash returns the hash of a tx hash condition.
+func decodeHash(c query.Condition) ([]byte, error) {
+	s, ok := c.Operand.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a hex string", types.TxHashKey)
+	}
+	hash, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %v", types.TxHashKey, err)
+	}
+	return hash, nil
+}
+
+// matchesResult returns true if the tx satisfies the condition, the same way
+// match would find it in the index.
+func matchesResult(c query.Condition, result *types.TxResult) (bool, error) {
+	switch c.Tag {
+	case types.TxHashKey:
+		hash, err := decodeHash(c)
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(hash, result.Tx.Hash()), nil
+	case types.TxHeightKey:
+		return c.MatchesInt(int64(result.Height)), nil
+	}
+	for _, tag := range result.Tags {
+		if tag == nil || tag.Key != c.Tag {
+			continue
+		}
+		if tag.IsInt {
+			if c.MatchesInt(tag.ValueInt) {
+				return true, nil
+			}
+		} else if c.MatchesString(tag.ValueString) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Tag keys are "<tag>/s/<value>/<height>/<index>" for string values and
+// "<tag>/i/<value>/<height>/<index>" for integer values, where the value is
+// encoded with encodeInt so the keys are ordered by value. Height and index
+// make the key unique.
+
+func stringTagPrefix(tag string) []byte {
+	return []byte(tag + "/s/")
+}
+
+func intTagPrefix(tag string) []byte {
+	return []byte(tag + "/i/")
+}
+
+// keyForTag returns the key under which a tx is indexed for the given tag.
+func keyForTag(tag *types.TxTag, result *types.TxResult) []byte {
+	if tag.IsInt {
+		return keyForInt(tag.Key, tag.ValueInt, result)
+	}
+	return []byte(fmt.Sprintf("%s%s/%d/%d", stringTagPrefix(tag.Key), tag.ValueString, result.Height, result.Index))
+}
+
+// keyForHeight returns the key under which a tx is indexed by its height.
+func keyForHeight(result *types.TxResult) []byte {
+	return keyForInt(types.TxHeightKey, int64(result.Height), result)
+}
+
+func keyForInt(tag string, value int64, result *types.TxResult) []byte {
+	return []byte(fmt.Sprintf("%s%s/%d/%d", intTagPrefix(tag), encodeInt(value), result.Height, result.Index))
+}
+
+// encodeInt encodes i as fixed width hex, with the sign bit flipped so the
+// encodings sort like the integers.
+func encodeInt(i int64) string {
+	return fmt.Sprintf("%016X", uint64(i)^(1<<63))
+}
+
+func decodeInt(s string) (int64, bool) {
+	if len(s) != 16 {
+		return 0, false
+	}
+	u, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(u ^ (1 << 63)), true
+}
+
+// intRange returns the range of keys under prefix which may satisfy the
+// integer condition.
+func intRange(prefix []byte, op query.Operator, operand int64) (start, end []byte) {
+	valuePrefix := append(append([]byte{}, prefix...), encodeInt(operand)+"/"...)
+	start, end = prefix, tmdb.PrefixEnd(prefix)
+	switch op {
+	case query.OpEqual:
+		start, end = valuePrefix, tmdb.PrefixEnd(valuePrefix)
+	case query.OpGreater, query.OpGreaterEqual:
+		start = valuePrefix
+	case query.OpLess, query.OpLessEqual:
+		end = tmdb.PrefixEnd(valuePrefix)
+	}
+	return start, end
+}
+
+// extractValueFromKey strips the trailing height and index from a key (with
+// the tag prefix already removed).
+func extractValueFromKey(key []byte) (string, bool) {
+	s := string(key)
+	for i := 0; i < 2; i++ {
+		j := strings.LastIndex(s, "/")
+		if j == -1 {
+			return "", false
+		}
+		if _, err := strconv.ParseUint(s[j+1:], 10, 64); err != nil {
+			return "", false
+		}
+		s = s[:j]
+	}
+	return s, true
+}
+
+// extractIntFromKey returns the integer value of a key (with the tag prefix
+// already removed).
+func extractIntFromKey(key []byte) (int64, bool) {
+	value, ok := extractValueFromKey(key)
+	if !ok {
+		return 0, false
+	}
+	return decodeInt(value)
+}
+
+type byHeightAndIndex []*types.TxResult
+
+func (r byHeightAndIndex) Len() int      { return len(r) }
+func (r byHeightAndIndex) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byHeightAndIndex) Less(i, j int) bool {
+	if r[i].Height != r[j].Height {
+		return r[i].Height < r[j].Height
+	}
+	return r[i].Index < r[j].Index
+}
@@ -1,6 +1,7 @@
 package kv
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -9,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 	abci "github.com/tendermint/abci/types"
 	"github.com/tendermint/tendermint/state/txindex"
+	"github.com/tendermint/tendermint/state/txindex/query"
 	"github.com/tendermint/tendermint/types"
 	db "github.com/tendermint/tmlibs/db"
 )
@@ -17,7 +19,7 @@ func TestTxIndex(t *testing.T) {
 	indexer := &TxIndex{store: db.NewMemDB()}
 
 	tx := types.Tx("HELLO WORLD")
-	txResult := &types.TxResult{1, 0, tx, abci.ResponseDeliverTx{Data: []byte{0}, Code: abci.CodeType_OK, Log: ""}}
+	txResult := &types.TxResult{1, 0, tx, abci.ResponseDeliverTx{Data: []byte{0}, Code: abci.CodeType_OK, Log: ""}, []*types.TxTag{}}
 	hash := tx.Hash()
 
 	batch := txindex.NewBatch(1)
@@ -30,9 +32,98 @@ func TestTxIndex(t *testing.T) {
 	assert.Equal(t, txResult, loadedTxResult)
 }
 
+func TestTxSearch(t *testing.T) {
+	indexer := &TxIndex{store: db.NewMemDB()}
+
+	tags := []*types.TxTag{
+		{Key: "account.owner", ValueString: "Ivan"},
+		{Key: "account.number", ValueInt: 1, IsInt: true},
+	}
+	txResult1 := &types.TxResult{1, 0, types.Tx("HELLO WORLD"), abci.ResponseDeliverTx{Data: []byte{0}, Code: abci.CodeType_OK, Log: ""}, tags}
+	txResult2 := &types.TxResult{2, 0, types.Tx("GOODBYE WORLD"), abci.ResponseDeliverTx{Data: []byte{0}, Code: abci.CodeType_OK, Log: ""}, tags[:1]}
+	txResult3 := &types.TxResult{2, 1, types.Tx("HELLO AGAIN"), abci.ResponseDeliverTx{Data: []byte{0}, Code: abci.CodeType_OK, Log: ""}, []*types.TxTag{}}
+
+	batch := txindex.NewBatch(1)
+	batch.Add(*txResult1)
+	require.Nil(t, indexer.AddBatch(batch))
+
+	batch = txindex.NewBatch(2)
+	batch.Add(*txResult2)
+	batch.Add(*txResult3)
+	require.Nil(t, indexer.AddBatch(batch))
+
+	testCases := []struct {
+		q       string
+		results []*types.TxResult
+	}{
+		// search by hash
+		{fmt.Sprintf("tx.hash = '%X'", txResult1.Tx.Hash()), []*types.TxResult{txResult1}},
+		// search by hash, and the other conditions
+		{fmt.Sprintf("tx.hash = '%X' AND tx.height = 1 AND account.number = 1", txResult1.Tx.Hash()), []*types.TxResult{txResult1}},
+		{fmt.Sprintf("tx.hash = '%X' AND tx.height = 5", txResult1.Tx.Hash()), []*types.TxResult{}},
+		{fmt.Sprintf("tx.hash = '%X' AND account.number = 1", txResult2.Tx.Hash()), []*types.TxResult{}},
+		{fmt.Sprintf("tx.hash = '%X' AND tx.hash = '%X'", txResult1.Tx.Hash(), txResult2.Tx.Hash()), []*types.TxResult{}},
+		// search by exact match (one tag)
+		{"account.number = 1", []*types.TxResult{txResult1}},
+		// search by exact match (multiple tags)
+		{"account.number = 1 AND account.owner = 'Ivan'", []*types.TxResult{txResult1}},
+		// search by exact match (multiple tags, no match)
+		{"account.number = 1 AND account.owner = 'Igor'", []*types.TxResult{}},
+		// search by range
+		{"account.number >= 1 AND account.number <= 5", []*types.TxResult{txResult1}},
+		// search by string tag, results are ordered
+		{"account.owner = 'Ivan'", []*types.TxResult{txResult1, txResult2}},
+		// search by height
+		{"tx.height = 2", []*types.TxResult{txResult2, txResult3}},
+		{"account.owner = 'Ivan' AND tx.height > 1", []*types.TxResult{txResult2}},
+		{"tx.height >= 1 AND tx.height < 2", []*types.TxResult{txResult1}},
+		{"tx.height <= 2", []*types.TxResult{txResult1, txResult2, txResult3}},
+		// search for a non-existing tag
+		{"account.date >= 2017", []*types.TxResult{}},
+	}
+
+	for _, tc := range testCases {
+		results, err := indexer.Search(query.MustParse(tc.q))
+		require.Nil(t, err, tc.q)
+		assert.Equal(t, tc.results, results, tc.q)
+	}
+}
+
+func TestTxIndexPrune(t *testing.T) {
+	indexer := &TxIndex{store: db.NewMemDB()}
+
+	tags := []*types.TxTag{{Key: "account.number", ValueInt: -1, IsInt: true}}
+	var results []*types.TxResult
+	for height := uint64(1); height <= 5; height++ {
+		txResult := &types.TxResult{height, 0, types.Tx(fmt.Sprintf("TX%d", height)), abci.ResponseDeliverTx{Data: []byte{0}, Code: abci.CodeType_OK, Log: ""}, tags}
+		batch := txindex.NewBatch(1)
+		batch.Add(*txResult)
+		require.Nil(t, indexer.AddBatch(batch))
+		results = append(results, txResult)
+	}
+
+	// negative values are ordered too
+	found, err := indexer.Search(query.MustParse("account.number < 0"))
+	require.Nil(t, err)
+	assert.Equal(t, results, found)
+
+	// prune below height 4, but keep every second height
+	pruned, err := indexer.Prune(4, 2)
+	require.Nil(t, err)
+	assert.Equal(t, 2, pruned)
+
+	found, err = indexer.Search(query.MustParse("account.number = -1"))
+	require.Nil(t, err)
+	assert.Equal(t, []*types.TxResult{results[1], results[3], results[4]}, found)
+
+	res, err := indexer.Get(results[0].Tx.Hash())
+	require.Nil(t, err)
+	assert.Nil(t, res)
+}
+
 func benchmarkTxIndex(txsCount int, b *testing.B) {
 	tx := types.Tx("HELLO WORLD")
-	txResult := &types.TxResult{1, 0, tx, abci.ResponseDeliverTx{Data: []byte{0}, Code: abci.CodeType_OK, Log: ""}}
+	txResult := &types.TxResult{1, 0, tx, abci.ResponseDeliverTx{Data: []byte{0}, Code: abci.CodeType_OK, Log: ""}, []*types.TxTag{}}
 
 	dir, err := ioutil.TempDir("", "tx_index_db")
 	if err != nil {
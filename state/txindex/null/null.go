@@ -4,18 +4,31 @@ import (
 	"errors"
 
 	"github.com/tendermint/tendermint/state/txindex"
+	"github.com/tendermint/tendermint/state/txindex/query"
 	"github.com/tendermint/tendermint/types"
 )
 
 // TxIndex acts as a /dev/null.
 type TxIndex struct{}
 
-// Tx panics.
+var errDisabled = errors.New(`Indexing is disabled (set 'tx_index = "kv"' in config)`)
+
+// Tx returns an error.
 func (txi *TxIndex) Get(hash []byte) (*types.TxResult, error) {
-	return nil, errors.New(`Indexing is disabled (set 'tx_index = "kv"' in config)`)
+	return nil, errDisabled
 }
 
 // Batch returns nil.
 func (txi *TxIndex) AddBatch(batch *txindex.Batch) error {
 	return nil
 }
+
+// Prune does nothing.
+func (txi *TxIndex) Prune(retainHeight int, keepEvery int) (int, error) {
+	return 0, nil
+}
+
+// Search returns an error.
+func (txi *TxIndex) Search(q *query.Query) ([]*types.TxResult, error) {
+	return nil, errDisabled
+}
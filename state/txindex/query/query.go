@@ -0,0 +1,228 @@
+// Package query implements the small query language understood by the
+// transaction indexer.
+//
+// A query is one or more conditions joined by AND:
+//
+//	account.owner = 'Ivan' AND tx.height > 100
+//
+// Each condition compares a tag with an operand using one of =, <, <=, >, >=.
+// Operands are either single-quoted strings or (possibly negative) integers.
+// Strings can only be compared for equality.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operator is an operator that defines some kind of relation between tag and
+// operand (equality, etc.).
+type Operator uint8
+
+const (
+	// "<="
+	OpLessEqual Operator = iota
+	// ">="
+	OpGreaterEqual
+	// "<"
+	OpLess
+	// ">"
+	OpGreater
+	// "="
+	OpEqual
+)
+
+func (op Operator) String() string {
+	switch op {
+	case OpLessEqual:
+		return "<="
+	case OpGreaterEqual:
+		return ">="
+	case OpLess:
+		return "<"
+	case OpGreater:
+		return ">"
+	case OpEqual:
+		return "="
+	default:
+		return "?"
+	}
+}
+
+// Condition represents a single condition within a query and consists of tag
+// (e.g. "tx.height"), operator (e.g. "=") and operand (e.g. "42"). Operand is
+// either a string or an int64.
+type Condition struct {
+	Tag     string
+	Op      Operator
+	Operand interface{}
+}
+
+func (c Condition) String() string {
+	if s, ok := c.Operand.(string); ok {
+		return fmt.Sprintf("%s %v '%s'", c.Tag, c.Op, s)
+	}
+	return fmt.Sprintf("%s %v %v", c.Tag, c.Op, c.Operand)
+}
+
+// MatchesInt returns true if the given value satisfies the condition.
+// Conditions with a string operand never match integer values.
+func (c Condition) MatchesInt(value int64) bool {
+	operand, ok := c.Operand.(int64)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case OpLessEqual:
+		return value <= operand
+	case OpGreaterEqual:
+		return value >= operand
+	case OpLess:
+		return value < operand
+	case OpGreater:
+		return value > operand
+	case OpEqual:
+		return value == operand
+	}
+	return false
+}
+
+// MatchesString returns true if the given value satisfies the condition.
+// A string value is compared numerically if the operand is an integer.
+func (c Condition) MatchesString(value string) bool {
+	switch operand := c.Operand.(type) {
+	case string:
+		return c.Op == OpEqual && value == operand
+	case int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		return c.MatchesInt(i)
+	}
+	return false
+}
+
+// Query holds the query string and the conditions parsed from it.
+type Query struct {
+	str        string
+	conditions []Condition
+}
+
+// New parses the given string and returns a query or an error if the string
+// is not a valid query.
+func New(s string) (*Query, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("Empty query")
+	}
+
+	parts := strings.Split(s, " AND ")
+	conditions := make([]Condition, 0, len(parts))
+	for _, part := range parts {
+		c, err := parseCondition(part)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid query %q: %v", s, err)
+		}
+		conditions = append(conditions, c)
+	}
+
+	return &Query{str: s, conditions: conditions}, nil
+}
+
+// MustParse turns the given string into a query or panics; for tests or
+// others cases where you know the string is valid.
+func MustParse(s string) *Query {
+	q, err := New(s)
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse %s: %v", s, err))
+	}
+	return q
+}
+
+// String returns the original string.
+func (q *Query) String() string {
+	return q.str
+}
+
+// Conditions returns a list of conditions.
+func (q *Query) Conditions() []Condition {
+	return q.conditions
+}
+
+// operators are ordered so that two-character operators are tried first.
+var operators = []Operator{OpLessEqual, OpGreaterEqual, OpLess, OpGreater, OpEqual}
+
+func parseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+
+	// the tag ends at the first operator character
+	i := strings.IndexAny(s, "<>=")
+	if i == -1 {
+		return Condition{}, fmt.Errorf("Missing operator in %q", s)
+	}
+	tag := strings.TrimSpace(s[:i])
+	if !isValidTag(tag) {
+		return Condition{}, fmt.Errorf("Invalid tag %q", tag)
+	}
+
+	var op Operator
+	var rest string
+	found := false
+	for _, o := range operators {
+		if strings.HasPrefix(s[i:], o.String()) {
+			op, rest, found = o, s[i+len(o.String()):], true
+			break
+		}
+	}
+	if !found {
+		return Condition{}, fmt.Errorf("Invalid operator in %q", s)
+	}
+
+	operand, err := parseOperand(strings.TrimSpace(rest))
+	if err != nil {
+		return Condition{}, err
+	}
+	if _, ok := operand.(string); ok && op != OpEqual {
+		return Condition{}, fmt.Errorf("Operator %v is not supported for strings", op)
+	}
+
+	return Condition{Tag: tag, Op: op, Operand: operand}, nil
+}
+
+func parseOperand(s string) (interface{}, error) {
+	if s == "" {
+		return nil, fmt.Errorf("Missing operand")
+	}
+	if s[0] == '\'' {
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("Unterminated string %s", s)
+		}
+		str := s[1 : len(s)-1]
+		if strings.Contains(str, "'") {
+			return nil, fmt.Errorf("Invalid string %s", s)
+		}
+		return str, nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid operand %s: expected a quoted string or an integer", s)
+	}
+	return i, nil
+}
+
+func isValidTag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, r := range tag {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
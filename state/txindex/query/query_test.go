@@ -0,0 +1,63 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		query      string
+		valid      bool
+		conditions []Condition
+	}{
+		{"account.owner='Ivan'", true, []Condition{{"account.owner", OpEqual, "Ivan"}}},
+		{"account.owner = 'Ivan' AND tx.height > 100", true, []Condition{
+			{"account.owner", OpEqual, "Ivan"},
+			{"tx.height", OpGreater, int64(100)},
+		}},
+		{"tx.height>=5 AND tx.height<=10", true, []Condition{
+			{"tx.height", OpGreaterEqual, int64(5)},
+			{"tx.height", OpLessEqual, int64(10)},
+		}},
+		{"balance < -3", true, []Condition{{"balance", OpLess, int64(-3)}}},
+		{"", false, nil},
+		{"account.owner", false, nil},
+		{"account.owner =", false, nil},
+		{"account.owner = Ivan", false, nil},
+		{"account.owner = 'Ivan", false, nil},
+		{"account.owner > 'Ivan'", false, nil},
+		{"account owner = 'Ivan'", false, nil},
+		{"= 'Ivan'", false, nil},
+		{"tx.height => 5", false, nil},
+	}
+
+	for _, c := range cases {
+		q, err := New(c.query)
+		if !c.valid {
+			assert.NotNil(t, err, "Query was '%s'", c.query)
+			continue
+		}
+		require.Nil(t, err, "Query was '%s'", c.query)
+		assert.Equal(t, c.conditions, q.Conditions(), "Query was '%s'", c.query)
+	}
+}
+
+func TestConditionMatches(t *testing.T) {
+	q := MustParse("tx.height > 5 AND account.owner = 'Ivan' AND fee = 10")
+	conds := q.Conditions()
+
+	assert.True(t, conds[0].MatchesInt(6))
+	assert.False(t, conds[0].MatchesInt(5))
+	assert.True(t, conds[0].MatchesString("6"))
+	assert.False(t, conds[0].MatchesString("abc"))
+
+	assert.True(t, conds[1].MatchesString("Ivan"))
+	assert.False(t, conds[1].MatchesString("Igor"))
+	assert.False(t, conds[1].MatchesInt(1))
+
+	assert.True(t, conds[2].MatchesString("10"))
+	assert.True(t, conds[2].MatchesInt(10))
+}
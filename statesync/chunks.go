@@ -0,0 +1,132 @@
+package statesync
+
+import (
+	"sync"
+
+	"github.com/tendermint/tendermint/proxy"
+)
+
+// chunk is a snapshot chunk received from a peer.
+type chunk struct {
+	Height int
+	Format uint32
+	Index  uint32
+	Chunk  []byte
+	Sender string
+}
+
+// chunkQueue keeps track of the chunks of a snapshot being restored: which
+// ones are being fetched, and which ones have arrived.
+type chunkQueue struct {
+	mtx       sync.Mutex
+	snapshot  *proxy.Snapshot
+	chunks    map[uint32]*chunk
+	allocated map[uint32]bool
+	waiters   map[uint32][]chan struct{}
+	closed    bool
+}
+
+func newChunkQueue(snapshot *proxy.Snapshot) *chunkQueue {
+	return &chunkQueue{
+		snapshot:  snapshot,
+		chunks:    make(map[uint32]*chunk),
+		allocated: make(map[uint32]bool),
+		waiters:   make(map[uint32][]chan struct{}),
+	}
+}
+
+// Size returns the number of chunks in the snapshot.
+func (q *chunkQueue) Size() uint32 {
+	return q.snapshot.Chunks
+}
+
+// Add adds a chunk to the queue. It returns false if the chunk isn't
+// for this snapshot, or we already have it.
+func (q *chunkQueue) Add(c *chunk) bool {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.closed || c == nil {
+		return false
+	}
+	if c.Height != q.snapshot.Height || c.Format != q.snapshot.Format || c.Index >= q.snapshot.Chunks {
+		return false
+	}
+	if _, ok := q.chunks[c.Index]; ok {
+		return false
+	}
+	q.chunks[c.Index] = c
+	q.allocated[c.Index] = true
+	for _, ch := range q.waiters[c.Index] {
+		close(ch)
+	}
+	delete(q.waiters, c.Index)
+	return true
+}
+
+// Allocate returns the index of a chunk nobody is fetching yet, and marks
+// it as being fetched. It returns false if all chunks are allocated.
+func (q *chunkQueue) Allocate() (uint32, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.closed {
+		return 0, false
+	}
+	for i := uint32(0); i < q.snapshot.Chunks; i++ {
+		if !q.allocated[i] {
+			q.allocated[i] = true
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Retry marks a chunk as not being fetched, and drops it if we have it,
+// so it is fetched again.
+func (q *chunkQueue) Retry(index uint32) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	delete(q.chunks, index)
+	delete(q.allocated, index)
+}
+
+// Get returns the chunk with the given index, or nil if we don't have it.
+func (q *chunkQueue) Get(index uint32) *chunk {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return q.chunks[index]
+}
+
+// WaitFor returns a channel which is closed when the chunk with the given
+// index arrives, or the queue is closed.
+func (q *chunkQueue) WaitFor(index uint32) <-chan struct{} {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	ch := make(chan struct{})
+	if _, ok := q.chunks[index]; ok || q.closed {
+		close(ch)
+		return ch
+	}
+	q.waiters[index] = append(q.waiters[index], ch)
+	return ch
+}
+
+// Close releases all waiters. No chunks can be added afterwards.
+func (q *chunkQueue) Close() {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.closed = true
+	for _, waiters := range q.waiters {
+		for _, ch := range waiters {
+			close(ch)
+		}
+	}
+	q.waiters = nil
+}
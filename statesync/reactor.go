@@ -0,0 +1,301 @@
+package statesync
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	wire "github.com/tendermint/go-wire"
+	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/proxy"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+	cmn "github.com/tendermint/tmlibs/common"
+)
+
+const (
+	// SnapshotChannel exchanges snapshot metadata
+	SnapshotChannel = byte(0x60)
+	// ChunkChannel exchanges snapshot chunks
+	ChunkChannel = byte(0x61)
+
+	// number of recent snapshots to offer to peers
+	recentSnapshots = 10
+	// NOTE: keep in sync with the p2p RecvMessageCapacity default (21MB)
+	maxMsgSize = 16 * 1024 * 1024
+)
+
+// Reactor serves app snapshots to peers, and lets a new node restore the
+// app from a snapshot instead of replaying every block from genesis.
+type Reactor struct {
+	p2p.BaseReactor
+
+	app   proxy.Snapshotter
+	query proxy.AppConnQuery
+
+	mtx    sync.RWMutex
+	syncer *syncer // only set while syncing
+}
+
+// NewReactor returns a new state sync Reactor for the app.
+func NewReactor(app proxy.Snapshotter, query proxy.AppConnQuery) *Reactor {
+	r := &Reactor{
+		app:   app,
+		query: query,
+	}
+	r.BaseReactor = *p2p.NewBaseReactor("StateSyncReactor", r)
+	return r
+}
+
+// GetChannels implements Reactor.
+func (r *Reactor) GetChannels() []*p2p.ChannelDescriptor {
+	return []*p2p.ChannelDescriptor{
+		&p2p.ChannelDescriptor{
+			ID:                SnapshotChannel,
+			Priority:          3,
+			SendQueueCapacity: 10,
+		},
+		&p2p.ChannelDescriptor{
+			ID:                ChunkChannel,
+			Priority:          1,
+			SendQueueCapacity: 4,
+		},
+	}
+}
+
+// AddPeer implements Reactor.
+// If we're syncing, it asks the peer for its snapshots.
+func (r *Reactor) AddPeer(peer *p2p.Peer) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if r.syncer != nil {
+		peer.TrySend(SnapshotChannel, struct{ StateSyncMessage }{&snapshotsRequestMessage{}})
+	}
+}
+
+// RemovePeer implements Reactor.
+func (r *Reactor) RemovePeer(peer *p2p.Peer, reason interface{}) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if r.syncer != nil {
+		r.syncer.RemovePeer(peer.Key)
+	}
+}
+
+// Receive implements Reactor by handling 4 types of messages (look below).
+func (r *Reactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte) {
+	_, msg, err := DecodeMessage(msgBytes)
+	if err != nil {
+		r.Logger.Error("Error decoding message", "err", err)
+		return
+	}
+	r.Logger.Debug("Receive", "src", src, "chID", chID, "msg", msg)
+
+	switch msg := msg.(type) {
+	case *snapshotsRequestMessage:
+		snapshots, err := r.recentSnapshots()
+		if err != nil {
+			r.Logger.Error("Failed to list snapshots", "err", err)
+			return
+		}
+		for _, s := range snapshots {
+			src.TrySend(SnapshotChannel, struct{ StateSyncMessage }{&snapshotsResponseMessage{
+				Height:   s.Height,
+				Format:   s.Format,
+				Chunks:   s.Chunks,
+				Hash:     s.Hash,
+				Metadata: s.Metadata,
+			}})
+		}
+	case *snapshotsResponseMessage:
+		r.mtx.RLock()
+		defer r.mtx.RUnlock()
+		if r.syncer == nil {
+			return
+		}
+		r.syncer.AddSnapshot(src.Key, &proxy.Snapshot{
+			Height:   msg.Height,
+			Format:   msg.Format,
+			Chunks:   msg.Chunks,
+			Hash:     msg.Hash,
+			Metadata: msg.Metadata,
+		})
+	case *chunkRequestMessage:
+		chunk, err := r.app.LoadSnapshotChunk(msg.Height, msg.Format, msg.Index)
+		if err != nil {
+			r.Logger.Error("Failed to load snapshot chunk", "height", msg.Height, "format", msg.Format,
+				"chunk", msg.Index, "err", err)
+			return
+		}
+		src.TrySend(ChunkChannel, struct{ StateSyncMessage }{&chunkResponseMessage{
+			Height:  msg.Height,
+			Format:  msg.Format,
+			Index:   msg.Index,
+			Chunk:   chunk,
+			Missing: chunk == nil,
+		}})
+	case *chunkResponseMessage:
+		r.mtx.RLock()
+		defer r.mtx.RUnlock()
+		if r.syncer == nil {
+			return
+		}
+		if msg.Missing {
+			// the chunk is requested again once it times out
+			r.Logger.Info("Peer does not have snapshot chunk", "peer", src, "height", msg.Height, "chunk", msg.Index)
+			return
+		}
+		r.syncer.AddChunk(&chunk{
+			Height: msg.Height,
+			Format: msg.Format,
+			Index:  msg.Index,
+			Chunk:  msg.Chunk,
+			Sender: src.Key,
+		})
+	default:
+		r.Logger.Error(cmn.Fmt("Unknown message type %v", reflect.TypeOf(msg)))
+	}
+}
+
+// Sync restores the app from a snapshot offered by peers, and returns the
+// state and commit at the snapshot height to bootstrap the node with.
+// It waits discoveryTime for peers to offer snapshots before picking one,
+// and fetches chunks with the given number of fetchers in parallel.
+func (r *Reactor) Sync(stateProvider StateProvider, discoveryTime time.Duration, fetchers int) (*sm.State, *types.Commit, error) {
+	r.mtx.Lock()
+	if r.syncer != nil {
+		r.mtx.Unlock()
+		return nil, nil, errors.New("A state sync is already in progress")
+	}
+	r.syncer = newSyncer(r.Logger, r.app, r.query, stateProvider, r.requestChunk, fetchers)
+	r.mtx.Unlock()
+
+	// ask the peers we already have for their snapshots
+	r.Switch.Broadcast(SnapshotChannel, struct{ StateSyncMessage }{&snapshotsRequestMessage{}})
+
+	state, commit, err := r.syncer.SyncAny(discoveryTime, r.Quit)
+
+	r.mtx.Lock()
+	r.syncer = nil
+	r.mtx.Unlock()
+	return state, commit, err
+}
+
+func (r *Reactor) requestChunk(peerID string, snapshot *proxy.Snapshot, index uint32) {
+	peer := r.Switch.Peers().Get(peerID)
+	if peer == nil {
+		return // the fetcher retries after a timeout
+	}
+	peer.TrySend(ChunkChannel, struct{ StateSyncMessage }{&chunkRequestMessage{
+		Height: snapshot.Height,
+		Format: snapshot.Format,
+		Index:  index,
+	}})
+}
+
+// recentSnapshots returns the latest snapshots of the app, newest first.
+func (r *Reactor) recentSnapshots() ([]*proxy.Snapshot, error) {
+	snapshots, err := r.app.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	ranked := make(snapshotsByPriority, len(snapshots))
+	for i, s := range snapshots {
+		ranked[i] = rankedSnapshot{snapshot: s}
+	}
+	sort.Sort(ranked)
+	recent := []*proxy.Snapshot{}
+	for i := 0; i < len(ranked) && i < recentSnapshots; i++ {
+		recent = append(recent, ranked[i].snapshot)
+	}
+	return recent, nil
+}
+
+//-----------------------------------------------------------------------------
+// Messages
+
+const (
+	msgTypeSnapshotsRequest  = byte(0x01)
+	msgTypeSnapshotsResponse = byte(0x02)
+	msgTypeChunkRequest      = byte(0x10)
+	msgTypeChunkResponse     = byte(0x11)
+)
+
+// StateSyncMessage is a message sent and received by the Reactor.
+type StateSyncMessage interface{}
+
+var _ = wire.RegisterInterface(
+	struct{ StateSyncMessage }{},
+	wire.ConcreteType{&snapshotsRequestMessage{}, msgTypeSnapshotsRequest},
+	wire.ConcreteType{&snapshotsResponseMessage{}, msgTypeSnapshotsResponse},
+	wire.ConcreteType{&chunkRequestMessage{}, msgTypeChunkRequest},
+	wire.ConcreteType{&chunkResponseMessage{}, msgTypeChunkResponse},
+)
+
+// DecodeMessage decodes a StateSyncMessage.
+// TODO: ensure that bz is completely read.
+func DecodeMessage(bz []byte) (msgType byte, msg StateSyncMessage, err error) {
+	msgType = bz[0]
+	n := int(0)
+	r := bytes.NewReader(bz)
+	msg = wire.ReadBinary(struct{ StateSyncMessage }{}, r, maxMsgSize, &n, &err).(struct{ StateSyncMessage }).StateSyncMessage
+	if err != nil && n != len(bz) {
+		err = errors.New("DecodeMessage() had bytes left over")
+	}
+	return
+}
+
+//-------------------------------------
+
+// snapshotsRequestMessage asks a peer for its recent snapshots.
+type snapshotsRequestMessage struct {
+}
+
+func (m *snapshotsRequestMessage) String() string {
+	return "[snapshotsRequestMessage]"
+}
+
+//-------------------------------------
+
+// snapshotsResponseMessage offers a snapshot, one per message.
+type snapshotsResponseMessage struct {
+	Height   int
+	Format   uint32
+	Chunks   uint32
+	Hash     []byte
+	Metadata []byte
+}
+
+func (m *snapshotsResponseMessage) String() string {
+	return cmn.Fmt("[snapshotsResponseMessage %v/%v %X]", m.Height, m.Format, m.Hash)
+}
+
+//-------------------------------------
+
+type chunkRequestMessage struct {
+	Height int
+	Format uint32
+	Index  uint32
+}
+
+func (m *chunkRequestMessage) String() string {
+	return cmn.Fmt("[chunkRequestMessage %v/%v %v]", m.Height, m.Format, m.Index)
+}
+
+//-------------------------------------
+
+// NOTE: keep up-to-date with maxMsgSize
+type chunkResponseMessage struct {
+	Height  int
+	Format  uint32
+	Index   uint32
+	Chunk   []byte
+	Missing bool
+}
+
+func (m *chunkResponseMessage) String() string {
+	return cmn.Fmt("[chunkResponseMessage %v/%v %v (%v bytes)]", m.Height, m.Format, m.Index, len(m.Chunk))
+}
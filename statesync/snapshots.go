@@ -0,0 +1,142 @@
+package statesync
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/tendermint/tendermint/proxy"
+	cmn "github.com/tendermint/tmlibs/common"
+)
+
+// snapshotKey identifies a snapshot. Peers may offer snapshots with the
+// same height and format but different contents, so everything is included.
+type snapshotKey string
+
+func keyOf(s *proxy.Snapshot) snapshotKey {
+	return snapshotKey(cmn.Fmt("%d/%d/%d/%X/%X", s.Height, s.Format, s.Chunks, s.Hash, s.Metadata))
+}
+
+// snapshotPool keeps track of the snapshots offered by peers, and which
+// peers offer them.
+type snapshotPool struct {
+	mtx       sync.Mutex
+	snapshots map[snapshotKey]*proxy.Snapshot
+	peers     map[snapshotKey]map[string]bool // peers offering each snapshot
+	rejected  map[snapshotKey]bool
+}
+
+func newSnapshotPool() *snapshotPool {
+	return &snapshotPool{
+		snapshots: make(map[snapshotKey]*proxy.Snapshot),
+		peers:     make(map[snapshotKey]map[string]bool),
+		rejected:  make(map[snapshotKey]bool),
+	}
+}
+
+// Add adds a snapshot offered by the peer. It returns true if the snapshot
+// is new, and false if it was known or rejected before.
+func (p *snapshotPool) Add(peer string, snapshot *proxy.Snapshot) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	key := keyOf(snapshot)
+	if p.rejected[key] {
+		return false
+	}
+	if _, ok := p.peers[key]; !ok {
+		p.peers[key] = make(map[string]bool)
+	}
+	p.peers[key][peer] = true
+	if _, ok := p.snapshots[key]; ok {
+		return false
+	}
+	p.snapshots[key] = snapshot
+	return true
+}
+
+// Best returns the snapshot to try next: the highest one, preferring those
+// offered by more peers. It returns nil if there are none.
+func (p *snapshotPool) Best() *proxy.Snapshot {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	candidates := snapshotsByPriority{}
+	for key, snapshot := range p.snapshots {
+		if len(p.peers[key]) > 0 {
+			candidates = append(candidates, rankedSnapshot{snapshot, len(p.peers[key])})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Sort(candidates)
+	return candidates[0].snapshot
+}
+
+// GetPeer returns a random peer offering the snapshot, or "" if there are none.
+func (p *snapshotPool) GetPeer(snapshot *proxy.Snapshot) string {
+	peers := p.GetPeers(snapshot)
+	if len(peers) == 0 {
+		return ""
+	}
+	return peers[rand.Intn(len(peers))]
+}
+
+// GetPeers returns the peers offering the snapshot.
+func (p *snapshotPool) GetPeers(snapshot *proxy.Snapshot) []string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	peers := []string{}
+	for peer := range p.peers[keyOf(snapshot)] {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// Reject removes the snapshot, and makes sure it isn't added again.
+func (p *snapshotPool) Reject(snapshot *proxy.Snapshot) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	key := keyOf(snapshot)
+	p.rejected[key] = true
+	delete(p.snapshots, key)
+	delete(p.peers, key)
+}
+
+// RemovePeer removes the peer from all snapshots.
+func (p *snapshotPool) RemovePeer(peer string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, peers := range p.peers {
+		delete(peers, peer)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+type rankedSnapshot struct {
+	snapshot *proxy.Snapshot
+	numPeers int
+}
+
+// snapshotsByPriority sorts snapshots by height, format and number of
+// peers, highest first.
+type snapshotsByPriority []rankedSnapshot
+
+func (s snapshotsByPriority) Len() int      { return len(s) }
+func (s snapshotsByPriority) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s snapshotsByPriority) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	if a.snapshot.Height != b.snapshot.Height {
+		return a.snapshot.Height > b.snapshot.Height
+	}
+	if a.snapshot.Format != b.snapshot.Format {
+		return a.snapshot.Format > b.snapshot.Format
+	}
+	return a.numPeers > b.numPeers
+}
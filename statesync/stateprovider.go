@@ -0,0 +1,112 @@
+package statesync
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+
+	"github.com/tendermint/tendermint/lite"
+	liteclient "github.com/tendermint/tendermint/lite/client"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+)
+
+// StateProvider provides verified data for bootstrapping a node at the
+// height of a snapshot.
+type StateProvider interface {
+	// AppHash returns the app hash after the block at the given height
+	// was committed.
+	AppHash(height int) ([]byte, error)
+	// Commit returns the commit for the block at the given height.
+	Commit(height int) (*types.Commit, error)
+	// State returns the state after the block at the given height.
+	State(height int) (*sm.State, error)
+}
+
+// liteStateProvider is a StateProvider which gets its data from a node
+// over rpc, and verifies it with a lite.Certifier.
+type liteStateProvider struct {
+	cert    *lite.Certifier
+	source  lite.Provider
+	genesis *sm.State
+}
+
+// NewLiteStateProvider returns a StateProvider which gets headers from the
+// node at the given rpc address, and trusts the header with the given hash
+// at trustHeight. The returned states are based on the genesis state.
+func NewLiteStateProvider(server string, trustHeight int, trustHash []byte, genesis *sm.State) (StateProvider, error) {
+	source := liteclient.NewHTTPProvider(server)
+	trusted, err := source.GetByHeight(trustHeight)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get trusted commit at height %d", trustHeight)
+	}
+	if !bytes.Equal(trusted.Header.Hash(), trustHash) {
+		return nil, errors.Errorf("Header at height %d has hash %X, expected %X", trustHeight, trusted.Header.Hash(), trustHash)
+	}
+	cert, err := lite.NewCertifier(genesis.ChainID, trusted, source)
+	if err != nil {
+		return nil, err
+	}
+	return &liteStateProvider{cert: cert, source: source, genesis: genesis}, nil
+}
+
+// AppHash implements StateProvider.
+// The app hash after a block is in the header of the next one.
+func (p *liteStateProvider) AppHash(height int) ([]byte, error) {
+	fc, err := p.verifiedCommit(height + 1)
+	if err != nil {
+		return nil, err
+	}
+	return fc.Header.AppHash, nil
+}
+
+// Commit implements StateProvider.
+func (p *liteStateProvider) Commit(height int) (*types.Commit, error) {
+	fc, err := p.verifiedCommit(height)
+	if err != nil {
+		return nil, err
+	}
+	return fc.Commit.Commit, nil
+}
+
+// State implements StateProvider.
+// TODO: the accums of the validators aren't covered by the validators
+// hash, so they're taken from the rpc node as is.
+// TODO: the consensus params aren't in the header either, so the genesis
+// ones are used, which is wrong if the app changed them since.
+// Until both are verified, the node refuses to state sync.
+func (p *liteStateProvider) State(height int) (*sm.State, error) {
+	last, err := p.verifiedCommit(height)
+	if err != nil {
+		return nil, err
+	}
+	next, err := p.verifiedCommit(height + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	state := p.genesis.Copy()
+	state.LastBlockHeight = height
+	state.LastBlockID = last.Commit.Commit.BlockID
+	state.LastBlockTime = last.Header.Time
+	state.LastValidators = last.Validators.Copy()
+	state.Validators = next.Validators.Copy()
+	state.AppHash = next.Header.AppHash
+	return state, nil
+}
+
+// verifiedCommit returns the FullCommit at the given height, once its header
+// is certified and its validators match the header.
+func (p *liteStateProvider) verifiedCommit(height int) (lite.FullCommit, error) {
+	fc, err := p.source.GetByHeight(height)
+	if err != nil {
+		return lite.FullCommit{}, err
+	}
+	if err := fc.ValidateBasic(p.cert.ChainID()); err != nil {
+		return lite.FullCommit{}, err
+	}
+	if err := p.cert.Certify(fc.Commit); err != nil {
+		return lite.FullCommit{}, err
+	}
+	return fc, nil
+}
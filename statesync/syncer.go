@@ -0,0 +1,254 @@
+package statesync
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tendermint/tendermint/proxy"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tendermint/tmlibs/log"
+)
+
+const (
+	// how long to wait for a chunk before asking another peer
+	chunkTimeout = 10 * time.Second
+	// how long to wait for the next chunk to apply before giving up on the snapshot
+	chunkApplyTimeout = 2 * time.Minute
+	// how often idle fetchers check for chunks to refetch
+	chunkRefetchInterval = 1 * time.Second
+)
+
+var (
+	errNoSnapshots = errors.New("No suitable snapshots found")
+	errAbort       = errors.New("State sync aborted")
+)
+
+// syncer restores the app from a snapshot offered by peers: it fetches the
+// chunks in parallel, applies them in order, and checks the app ends up
+// with the app hash verified by the StateProvider.
+type syncer struct {
+	logger        log.Logger
+	stateProvider StateProvider
+	app           proxy.Snapshotter
+	query         proxy.AppConnQuery
+	snapshots     *snapshotPool
+	requestChunk  func(peer string, snapshot *proxy.Snapshot, index uint32)
+	fetchers      int
+
+	mtx    sync.Mutex
+	chunks *chunkQueue // chunks of the snapshot being restored, if any
+}
+
+func newSyncer(logger log.Logger, app proxy.Snapshotter, query proxy.AppConnQuery, stateProvider StateProvider,
+	requestChunk func(peer string, snapshot *proxy.Snapshot, index uint32), fetchers int) *syncer {
+	return &syncer{
+		logger:        logger,
+		stateProvider: stateProvider,
+		app:           app,
+		query:         query,
+		snapshots:     newSnapshotPool(),
+		requestChunk:  requestChunk,
+		fetchers:      fetchers,
+	}
+}
+
+// AddSnapshot adds a snapshot offered by a peer.
+func (s *syncer) AddSnapshot(peer string, snapshot *proxy.Snapshot) bool {
+	added := s.snapshots.Add(peer, snapshot)
+	if added {
+		s.logger.Info("Discovered new snapshot", "height", snapshot.Height, "format", snapshot.Format, "hash", snapshot.Hash)
+	}
+	return added
+}
+
+// AddChunk adds a chunk received from a peer to the snapshot being restored.
+// It returns false if we're not waiting for the chunk.
+func (s *syncer) AddChunk(c *chunk) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.chunks == nil {
+		return false
+	}
+	return s.chunks.Add(c)
+}
+
+// RemovePeer removes a peer from the syncer.
+func (s *syncer) RemovePeer(peer string) {
+	s.snapshots.RemovePeer(peer)
+}
+
+// SyncAny tries the best snapshots until one of them is restored, and
+// returns the state and commit to bootstrap the node with.
+// It waits discoveryTime for snapshots first, and again whenever it runs
+// out of them; if discoveryTime is 0, it gives up instead.
+func (s *syncer) SyncAny(discoveryTime time.Duration, quit <-chan struct{}) (*sm.State, *types.Commit, error) {
+	if discoveryTime > 0 {
+		s.logger.Info("Discovering snapshots", "duration", discoveryTime)
+		if !sleep(discoveryTime, quit) {
+			return nil, nil, errAbort
+		}
+	}
+
+	for {
+		snapshot := s.snapshots.Best()
+		if snapshot == nil {
+			if discoveryTime == 0 {
+				return nil, nil, errNoSnapshots
+			}
+			s.logger.Info("No snapshots to try, discovering more", "duration", discoveryTime)
+			if !sleep(discoveryTime, quit) {
+				return nil, nil, errAbort
+			}
+			continue
+		}
+
+		state, commit, err := s.Sync(snapshot, quit)
+		if err == nil {
+			return state, commit, nil
+		}
+		if errors.Cause(err) == errAbort {
+			return nil, nil, err
+		}
+		s.logger.Info("Snapshot rejected", "height", snapshot.Height, "format", snapshot.Format, "err", err)
+		s.snapshots.Reject(snapshot)
+	}
+}
+
+// Sync restores the app from the given snapshot. An error caused by
+// errAbort means the app may be left in a broken state, any other error
+// means the snapshot can't be used and another one may be tried.
+func (s *syncer) Sync(snapshot *proxy.Snapshot, quit <-chan struct{}) (*sm.State, *types.Commit, error) {
+	chunks := newChunkQueue(snapshot)
+	s.mtx.Lock()
+	s.chunks = chunks
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		s.chunks = nil
+		s.mtx.Unlock()
+		chunks.Close()
+	}()
+
+	// only try snapshots we can verify
+	appHash, err := s.stateProvider.AppHash(snapshot.Height)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to verify app hash")
+	}
+	if err := s.app.OfferSnapshot(snapshot, appHash); err != nil {
+		return nil, nil, errors.Wrap(err, "App refused snapshot")
+	}
+	s.logger.Info("Restoring snapshot", "height", snapshot.Height, "format", snapshot.Format,
+		"chunks", snapshot.Chunks, "appHash", appHash)
+
+	// fetch chunks in the background, and apply them as they come in
+	done := make(chan struct{})
+	defer close(done)
+	for i := 0; i < s.fetchers; i++ {
+		go s.fetchChunks(snapshot, chunks, done)
+	}
+	if err := s.applyChunks(chunks, quit); err != nil {
+		return nil, nil, err
+	}
+
+	// the app must have the app hash we trust now
+	if err := s.verifyApp(snapshot, appHash); err != nil {
+		return nil, nil, err
+	}
+
+	state, err := s.stateProvider.State(snapshot.Height)
+	if err != nil {
+		return nil, nil, errors.Wrapf(errAbort, "Failed to build state: %v", err)
+	}
+	commit, err := s.stateProvider.Commit(snapshot.Height)
+	if err != nil {
+		return nil, nil, errors.Wrapf(errAbort, "Failed to get commit: %v", err)
+	}
+	s.logger.Info("Snapshot restored", "height", snapshot.Height, "appHash", appHash)
+	return state, commit, nil
+}
+
+// fetchChunks requests chunks from peers offering the snapshot until done
+// is closed. Chunks which don't arrive in time are requested again,
+// hopefully from another peer.
+func (s *syncer) fetchChunks(snapshot *proxy.Snapshot, chunks *chunkQueue, done <-chan struct{}) {
+	for {
+		index, ok := chunks.Allocate()
+		if !ok {
+			// everything is being fetched, but some chunks may need refetching
+			if !sleep(chunkRefetchInterval, done) {
+				return
+			}
+			continue
+		}
+
+		peer := s.snapshots.GetPeer(snapshot)
+		if peer == "" {
+			s.logger.Info("No peers left for snapshot", "height", snapshot.Height, "format", snapshot.Format)
+			chunks.Retry(index)
+			if !sleep(chunkRefetchInterval, done) {
+				return
+			}
+			continue
+		}
+		s.logger.Debug("Requesting snapshot chunk", "height", snapshot.Height, "chunk", index, "peer", peer)
+		s.requestChunk(peer, snapshot, index)
+
+		select {
+		case <-chunks.WaitFor(index):
+		case <-time.After(chunkTimeout):
+			chunks.Retry(index)
+		case <-done:
+			return
+		}
+	}
+}
+
+// applyChunks applies the chunks in order as they come in.
+func (s *syncer) applyChunks(chunks *chunkQueue, quit <-chan struct{}) error {
+	for index := uint32(0); index < chunks.Size(); index++ {
+		select {
+		case <-chunks.WaitFor(index):
+		case <-time.After(chunkApplyTimeout):
+			return errors.Errorf("Timed out waiting for chunk %d", index)
+		case <-quit:
+			return errAbort
+		}
+		c := chunks.Get(index)
+		if c == nil {
+			return errors.Errorf("Chunk %d is missing", index)
+		}
+		if err := s.app.ApplySnapshotChunk(index, c.Chunk); err != nil {
+			return errors.Wrapf(err, "Failed to apply chunk %d from peer %s", index, c.Sender)
+		}
+	}
+	return nil
+}
+
+// verifyApp checks the app restored the snapshot to the expected app hash.
+func (s *syncer) verifyApp(snapshot *proxy.Snapshot, appHash []byte) error {
+	res, err := s.query.InfoSync()
+	if err != nil {
+		return errors.Wrapf(errAbort, "Failed to query app info: %v", err)
+	}
+	if int(res.LastBlockHeight) != snapshot.Height {
+		return errors.Errorf("App has height %d after restoring snapshot, expected %d", res.LastBlockHeight, snapshot.Height)
+	}
+	if !bytes.Equal(res.LastBlockAppHash, appHash) {
+		return errors.Errorf("App has hash %X after restoring snapshot, expected %X", res.LastBlockAppHash, appHash)
+	}
+	return nil
+}
+
+// sleep sleeps for the duration, and returns false if quit was closed first.
+func sleep(d time.Duration, quit <-chan struct{}) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-quit:
+		return false
+	}
+}
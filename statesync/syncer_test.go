@@ -0,0 +1,194 @@
+package statesync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/tendermint/proxy"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tendermint/tmlibs/log"
+)
+
+// testApp serves and restores snapshots whose chunks are just bytes; the
+// app hash is the hash of all chunks.
+type testApp struct {
+	mtx       sync.Mutex
+	snapshots map[int][][]byte // chunks by height
+	height    int
+	restored  []byte
+}
+
+func hashChunks(chunks [][]byte) []byte {
+	hash := sha256.Sum256(bytes.Join(chunks, nil))
+	return hash[:]
+}
+
+func (app *testApp) ListSnapshots() ([]*proxy.Snapshot, error) {
+	snapshots := []*proxy.Snapshot{}
+	for height, chunks := range app.snapshots {
+		snapshots = append(snapshots, &proxy.Snapshot{
+			Height: height,
+			Format: 1,
+			Chunks: uint32(len(chunks)),
+			Hash:   hashChunks(chunks),
+		})
+	}
+	return snapshots, nil
+}
+
+func (app *testApp) LoadSnapshotChunk(height int, format uint32, index uint32) ([]byte, error) {
+	chunks, ok := app.snapshots[height]
+	if !ok || index >= uint32(len(chunks)) {
+		return nil, nil
+	}
+	return chunks[index], nil
+}
+
+func (app *testApp) OfferSnapshot(snapshot *proxy.Snapshot, appHash []byte) error {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	if snapshot.Format != 1 {
+		return errors.New("unknown format")
+	}
+	app.height = snapshot.Height
+	app.restored = nil
+	return nil
+}
+
+func (app *testApp) ApplySnapshotChunk(index uint32, chunk []byte) error {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	app.restored = append(app.restored, chunk...)
+	return nil
+}
+
+func (app *testApp) Error() error                    { return nil }
+func (app *testApp) EchoSync(msg string) abci.Result { return abci.NewResultOK([]byte(msg), "") }
+func (app *testApp) QuerySync(abci.RequestQuery) (abci.ResponseQuery, error) {
+	return abci.ResponseQuery{}, nil
+}
+func (app *testApp) InfoSync() (abci.ResponseInfo, error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	hash := sha256.Sum256(app.restored)
+	return abci.ResponseInfo{LastBlockHeight: uint64(app.height), LastBlockAppHash: hash[:]}, nil
+}
+
+// testStateProvider trusts the given app hashes.
+type testStateProvider struct {
+	appHashes map[int][]byte
+}
+
+func (p *testStateProvider) AppHash(height int) ([]byte, error) {
+	hash, ok := p.appHashes[height]
+	if !ok {
+		return nil, errors.New("unknown height")
+	}
+	return hash, nil
+}
+
+func (p *testStateProvider) Commit(height int) (*types.Commit, error) {
+	return &types.Commit{}, nil
+}
+
+func (p *testStateProvider) State(height int) (*sm.State, error) {
+	return &sm.State{LastBlockHeight: height, AppHash: p.appHashes[height]}, nil
+}
+
+func TestSyncerSyncAny(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	good := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")}
+	evil := [][]byte{[]byte("x"), []byte("y")}
+
+	// the peer serves a good snapshot at 10, and a bad one at 20
+	peerApp := &testApp{snapshots: map[int][][]byte{10: good, 20: evil}}
+	app := &testApp{}
+	stateProvider := &testStateProvider{appHashes: map[int][]byte{
+		10: hashChunks(good),
+		20: hashChunks(good),
+		30: hashChunks(good),
+	}}
+
+	var s *syncer
+	requestChunk := func(peer string, snapshot *proxy.Snapshot, index uint32) {
+		data, err := peerApp.LoadSnapshotChunk(snapshot.Height, snapshot.Format, index)
+		require.Nil(err)
+		go s.AddChunk(&chunk{Height: snapshot.Height, Format: snapshot.Format, Index: index, Chunk: data, Sender: peer})
+	}
+	s = newSyncer(log.TestingLogger(), app, app, stateProvider, requestChunk, 2)
+
+	snapshots, err := peerApp.ListSnapshots()
+	require.Nil(err)
+	for _, snapshot := range snapshots {
+		assert.True(s.AddSnapshot("peer", snapshot))
+		assert.False(s.AddSnapshot("peer", snapshot))
+	}
+	// a snapshot nobody serves chunks for can't be used
+	assert.True(s.AddSnapshot("other", &proxy.Snapshot{Height: 30, Format: 2, Chunks: 1}))
+
+	// the evil snapshot at 20 doesn't restore the trusted app hash,
+	// the one at 30 is refused by the app, so we end up at 10
+	state, commit, err := s.SyncAny(0, nil)
+	require.Nil(err, "%+v", err)
+	assert.Equal(10, state.LastBlockHeight)
+	assert.NotNil(commit)
+	assert.Equal([]byte("abcde"), app.restored)
+
+	// rejected snapshots aren't added again
+	for _, snapshot := range snapshots {
+		if snapshot.Height == 20 {
+			assert.False(s.AddSnapshot("peer", snapshot))
+		}
+	}
+
+	// without peers there's nothing else to try
+	s.RemovePeer("peer")
+	_, _, err = s.SyncAny(0, nil)
+	assert.Equal(errNoSnapshots, err)
+}
+
+func TestChunkQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	q := newChunkQueue(&proxy.Snapshot{Height: 1, Format: 1, Chunks: 2})
+	i, ok := q.Allocate()
+	assert.True(ok)
+	assert.EqualValues(0, i)
+	i, ok = q.Allocate()
+	assert.True(ok)
+	assert.EqualValues(1, i)
+	_, ok = q.Allocate()
+	assert.False(ok)
+
+	// chunks for other snapshots or out of range are ignored
+	assert.False(q.Add(&chunk{Height: 2, Format: 1, Index: 0}))
+	assert.False(q.Add(&chunk{Height: 1, Format: 1, Index: 2}))
+
+	wait := q.WaitFor(0)
+	assert.True(q.Add(&chunk{Height: 1, Format: 1, Index: 0, Chunk: []byte{1}}))
+	assert.False(q.Add(&chunk{Height: 1, Format: 1, Index: 0, Chunk: []byte{2}}))
+	<-wait
+	assert.Equal([]byte{1}, q.Get(0).Chunk)
+
+	// retried chunks are fetched again
+	q.Retry(0)
+	assert.Nil(q.Get(0))
+	i, ok = q.Allocate()
+	assert.True(ok)
+	assert.EqualValues(0, i)
+
+	// closing releases the waiters
+	wait = q.WaitFor(1)
+	q.Close()
+	<-wait
+	assert.False(q.Add(&chunk{Height: 1, Format: 1, Index: 1}))
+}
@@ -23,18 +23,19 @@ const (
 type Block struct {
 	*Header    `json:"header"`
 	*Data      `json:"data"`
-	LastCommit *Commit `json:"last_commit"`
+	Evidence   EvidenceData `json:"evidence"`
+	LastCommit *Commit      `json:"last_commit"`
 }
 
 // MakeBlock returns a new block and corresponding part set from the given information
 // TODO: version
-func MakeBlock(height int, chainID string, txs []Tx, commit *Commit,
-	prevBlockID BlockID, valHash, appHash []byte, partSize int) (*Block, *PartSet) {
+func MakeBlock(height int, chainID string, txs []Tx, evidence []Evidence, commit *Commit,
+	prevBlockID BlockID, valHash, appHash []byte, blockTime time.Time, partSize int) (*Block, *PartSet) {
 	block := &Block{
 		Header: &Header{
 			ChainID:        chainID,
 			Height:         height,
-			Time:           time.Now(),
+			Time:           blockTime,
 			NumTxs:         len(txs),
 			LastBlockID:    prevBlockID,
 			ValidatorsHash: valHash,
@@ -44,6 +45,9 @@ func MakeBlock(height int, chainID string, txs []Tx, commit *Commit,
 		Data: &Data{
 			Txs: txs,
 		},
+		Evidence: EvidenceData{
+			Evidence: evidence,
+		},
 	}
 	block.FillHeader()
 	return block, block.MakePartSet(partSize)
@@ -58,13 +62,7 @@ func (b *Block) ValidateBasic(chainID string, lastBlockHeight int, lastBlockID B
 	if b.Height != lastBlockHeight+1 {
 		return errors.New(cmn.Fmt("Wrong Block.Header.Height. Expected %v, got %v", lastBlockHeight+1, b.Height))
 	}
-	/*	TODO: Determine bounds for Time
-		See blockchain/reactor "stopSyncingDurationMinutes"
-
-		if !b.Time.After(lastBlockTime) {
-			return errors.New("Invalid Block.Header.Time")
-		}
-	*/
+	// NOTE: the Time is validated against the LastCommit (or the genesis time) by the state.
 	if b.NumTxs != len(b.Data.Txs) {
 		return errors.New(cmn.Fmt("Wrong Block.Header.NumTxs. Expected %v, got %v", len(b.Data.Txs), b.NumTxs))
 	}
@@ -85,7 +83,16 @@ func (b *Block) ValidateBasic(chainID string, lastBlockHeight int, lastBlockID B
 	if !bytes.Equal(b.AppHash, appHash) {
 		return errors.New(cmn.Fmt("Wrong Block.Header.AppHash.  Expected %X, got %v", appHash, b.AppHash))
 	}
+	for _, ev := range b.Evidence.Evidence {
+		if err := ev.ValidateBasic(); err != nil {
+			return NewEvidenceInvalidErr(ev, err)
+		}
+	}
+	if !bytes.Equal(b.EvidenceHash, b.Evidence.Hash()) {
+		return errors.New(cmn.Fmt("Wrong Block.Header.EvidenceHash.  Expected %v, got %v", b.EvidenceHash, b.Evidence.Hash()))
+	}
 	// NOTE: the AppHash and ValidatorsHash are validated later.
+	// The Evidence itself is verified against the validator set by the state.
 	return nil
 }
 
@@ -97,6 +104,9 @@ func (b *Block) FillHeader() {
 	if b.DataHash == nil {
 		b.DataHash = b.Data.Hash()
 	}
+	if b.EvidenceHash == nil {
+		b.EvidenceHash = b.Evidence.Hash()
+	}
 }
 
 // Hash computes and returns the block hash.
@@ -142,9 +152,11 @@ func (b *Block) StringIndented(indent string) string {
 %s  %v
 %s  %v
 %s  %v
+%s  %v
 %s}#%v`,
 		indent, b.Header.StringIndented(indent+"  "),
 		indent, b.Data.StringIndented(indent+"  "),
+		indent, b.Evidence.StringIndented(indent+"  "),
 		indent, b.LastCommit.StringIndented(indent+"  "),
 		indent, b.Hash())
 }
@@ -171,6 +183,7 @@ type Header struct {
 	DataHash       data.Bytes `json:"data_hash"`        // transactions
 	ValidatorsHash data.Bytes `json:"validators_hash"`  // validators for the current block
 	AppHash        data.Bytes `json:"app_hash"`         // state after txs from the previous block
+	EvidenceHash   data.Bytes `json:"evidence_hash"`    // evidence included in the block
 }
 
 // Hash returns the hash of the header.
@@ -189,6 +202,7 @@ func (h *Header) Hash() data.Bytes {
 		"Data":        h.DataHash,
 		"Validators":  h.ValidatorsHash,
 		"App":         h.AppHash,
+		"Evidence":    h.EvidenceHash,
 	})
 }
 
@@ -207,6 +221,7 @@ func (h *Header) StringIndented(indent string) string {
 %s  Data:           %v
 %s  Validators:     %v
 %s  App:            %v
+%s  Evidence:       %v
 %s}#%v`,
 		indent, h.ChainID,
 		indent, h.Height,
@@ -217,6 +232,7 @@ func (h *Header) StringIndented(indent string) string {
 		indent, h.DataHash,
 		indent, h.ValidatorsHash,
 		indent, h.AppHash,
+		indent, h.EvidenceHash,
 		indent, h.Hash())
 }
 
@@ -413,6 +429,44 @@ func (data *Data) StringIndented(indent string) string {
 		indent, data.hash)
 }
 
+//-----------------------------------------------------------------------------
+
+// EvidenceData contains any evidence of malicious wrong-doing by validators
+type EvidenceData struct {
+	Evidence EvidenceList `json:"evidence"`
+
+	// Volatile
+	hash data.Bytes
+}
+
+// Hash returns the hash of the data.
+func (data *EvidenceData) Hash() data.Bytes {
+	if data.hash == nil {
+		data.hash = data.Evidence.Hash()
+	}
+	return data.hash
+}
+
+// StringIndented returns a string representation of the evidence.
+func (data *EvidenceData) StringIndented(indent string) string {
+	if data == nil {
+		return "nil-Evidence"
+	}
+	evStrings := make([]string, cmn.MinInt(len(data.Evidence), 21))
+	for i, ev := range data.Evidence {
+		if i == 20 {
+			evStrings[i] = fmt.Sprintf("... (%v total)", len(data.Evidence))
+			break
+		}
+		evStrings[i] = fmt.Sprintf("Evidence:%v", ev)
+	}
+	return fmt.Sprintf(`EvidenceData{
+%s  %v
+%s}#%v`,
+		indent, strings.Join(evStrings, "\n"+indent+"  "),
+		indent, data.hash)
+}
+
 //--------------------------------------------------------------------------------
 
 // BlockID defines the unique ID of a block as its Hash and its PartSetHeader
@@ -1,11 +1,17 @@
 package types
 
 import (
+	"time"
+
 	"github.com/tendermint/go-wire/data"
 )
 
 // canonical json is go-wire's json for structs with fields in alphabetical order
 
+// TimeFormat is the format of the times in the canonical json.
+// Times are signed with millisecond precision, like go-wire encodes them.
+const TimeFormat = "2006-01-02T15:04:05.000Z"
+
 type CanonicalJSONBlockID struct {
 	Hash        data.Bytes                 `json:"hash,omitempty"`
 	PartsHeader CanonicalJSONPartSetHeader `json:"parts,omitempty"`
@@ -25,10 +31,11 @@ type CanonicalJSONProposal struct {
 }
 
 type CanonicalJSONVote struct {
-	BlockID CanonicalJSONBlockID `json:"block_id"`
-	Height  int                  `json:"height"`
-	Round   int                  `json:"round"`
-	Type    byte                 `json:"type"`
+	BlockID   CanonicalJSONBlockID `json:"block_id"`
+	Height    int                  `json:"height"`
+	Round     int                  `json:"round"`
+	Timestamp string               `json:"timestamp"`
+	Type      byte                 `json:"type"`
 }
 
 type CanonicalJSONHeartbeat struct {
@@ -89,6 +96,7 @@ func CanonicalVote(vote *Vote) CanonicalJSONVote {
 		CanonicalBlockID(vote.BlockID),
 		vote.Height,
 		vote.Round,
+		CanonicalTime(vote.Timestamp),
 		vote.Type,
 	}
 }
@@ -102,3 +110,7 @@ func CanonicalHeartbeat(heartbeat *Heartbeat) CanonicalJSONHeartbeat {
 		heartbeat.ValidatorIndex,
 	}
 }
+
+func CanonicalTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
@@ -0,0 +1,241 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	crypto "github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire/data"
+	"github.com/tendermint/tmlibs/merkle"
+)
+
+// ErrEvidenceInvalid wraps a piece of evidence and the error denoting how or why it is invalid.
+type ErrEvidenceInvalid struct {
+	Evidence   Evidence
+	ErrorValue error
+}
+
+func NewEvidenceInvalidErr(ev Evidence, err error) *ErrEvidenceInvalid {
+	return &ErrEvidenceInvalid{ev, err}
+}
+
+// Error returns a string representation of the error.
+func (err *ErrEvidenceInvalid) Error() string {
+	return fmt.Sprintf("Invalid evidence: %v. Evidence: %v", err.ErrorValue, err.Evidence)
+}
+
+//-------------------------------------------
+
+// EvidenceInner is implemented by all kinds of evidence of validator misbehaviour.
+type EvidenceInner interface {
+	Height() int                 // height of the equivocation
+	Address() []byte             // address of the equivocating validator
+	Index() int                  // index of the validator in the validator set
+	Hash() []byte                // hash of the evidence
+	ValidateBasic() error        // check the evidence is well formed
+	Verify(chainID string) error // verify the evidence
+	String() string              // string format of the evidence
+}
+
+// Evidence wraps an EvidenceInner so it can be serialized with go-wire,
+// both to binary and to JSON.
+type Evidence struct {
+	EvidenceInner `json:"unwrap"`
+}
+
+func (ev Evidence) MarshalJSON() ([]byte, error) {
+	return evidenceMapper.ToJSON(ev.EvidenceInner)
+}
+
+func (ev *Evidence) UnmarshalJSON(data []byte) (err error) {
+	parsed, err := evidenceMapper.FromJSON(data)
+	if err == nil && parsed != nil {
+		ev.EvidenceInner = parsed.(EvidenceInner)
+	}
+	return
+}
+
+// Unwrap provides access to the underlying interface.
+func (ev Evidence) Unwrap() EvidenceInner {
+	return ev.EvidenceInner
+}
+
+// Empty returns true if there is no evidence wrapped.
+func (ev Evidence) Empty() bool {
+	return ev.EvidenceInner == nil
+}
+
+// ValidateBasic returns an error if there is no evidence wrapped or it is
+// malformed. It must be called before any other method on evidence received
+// from a peer or in a block.
+func (ev Evidence) ValidateBasic() error {
+	if ev.Empty() {
+		return fmt.Errorf("Empty evidence")
+	}
+	return ev.EvidenceInner.ValidateBasic()
+}
+
+// Equal returns true if both pieces of evidence have the same hash.
+func (ev Evidence) Equal(other Evidence) bool {
+	if ev.Empty() || other.Empty() {
+		return ev.Empty() == other.Empty()
+	}
+	return bytes.Equal(ev.Hash(), other.Hash())
+}
+
+const (
+	EvidenceTypeDuplicateVote = byte(0x01)
+
+	EvidenceNameDuplicateVote = "duplicate_vote"
+)
+
+var evidenceMapper = data.NewMapper(Evidence{}).
+	RegisterImplementation(DuplicateVoteEvidence{}, EvidenceNameDuplicateVote, EvidenceTypeDuplicateVote)
+
+//-------------------------------------------
+
+// EvidenceList is a list of Evidence.
+type EvidenceList []Evidence
+
+// Hash returns the simple merkle root hash of the EvidenceList.
+func (evl EvidenceList) Hash() []byte {
+	switch len(evl) {
+	case 0:
+		return nil
+	case 1:
+		return evl[0].Hash()
+	default:
+		hashes := make([][]byte, len(evl))
+		for i, ev := range evl {
+			hashes[i] = ev.Hash()
+		}
+		return merkle.SimpleHashFromHashes(hashes)
+	}
+}
+
+func (evl EvidenceList) String() string {
+	s := ""
+	for _, e := range evl {
+		s += fmt.Sprintf("%s\t\t", e)
+	}
+	return s
+}
+
+// Has returns true if the evidence is in the EvidenceList.
+func (evl EvidenceList) Has(evidence Evidence) bool {
+	for _, ev := range evl {
+		if ev.Equal(evidence) {
+			return true
+		}
+	}
+	return false
+}
+
+//-------------------------------------------
+
+// DuplicateVoteEvidence contains evidence a validator signed two conflicting votes.
+type DuplicateVoteEvidence struct {
+	PubKey crypto.PubKey `json:"pub_key"`
+	VoteA  *Vote         `json:"vote_a"`
+	VoteB  *Vote         `json:"vote_b"`
+}
+
+// NewDuplicateVoteEvidence creates the evidence for the conflicting votes
+// reported by a VoteSet, signed by the validator with the given pubkey.
+func NewDuplicateVoteEvidence(pubKey crypto.PubKey, conflict *ErrVoteConflictingVotes) Evidence {
+	voteA, voteB := conflict.VoteA, conflict.VoteB
+	// order the votes so the same conflict always yields the same evidence
+	if voteA.BlockID.Key() > voteB.BlockID.Key() {
+		voteA, voteB = voteB, voteA
+	}
+	return DuplicateVoteEvidence{
+		PubKey: pubKey,
+		VoteA:  voteA,
+		VoteB:  voteB,
+	}.Wrap()
+}
+
+// Wrap returns the DuplicateVoteEvidence wrapped in an Evidence.
+func (dve DuplicateVoteEvidence) Wrap() Evidence {
+	return Evidence{dve}
+}
+
+// String returns a string representation of the evidence.
+func (dve DuplicateVoteEvidence) String() string {
+	return fmt.Sprintf("VoteA: %v; VoteB: %v", dve.VoteA, dve.VoteB)
+}
+
+// Height returns the height this evidence refers to.
+func (dve DuplicateVoteEvidence) Height() int {
+	return dve.VoteA.Height
+}
+
+// Address returns the address of the validator.
+func (dve DuplicateVoteEvidence) Address() []byte {
+	return dve.PubKey.Address()
+}
+
+// Index returns the index of the validator.
+func (dve DuplicateVoteEvidence) Index() int {
+	return dve.VoteA.ValidatorIndex
+}
+
+// Hash returns the hash of the evidence.
+func (dve DuplicateVoteEvidence) Hash() []byte {
+	return merkle.SimpleHashFromBinary(dve)
+}
+
+// ValidateBasic returns an error if the two votes aren't conflicting.
+// To be conflicting, they must be from the same validator, for the same H/R/S, but for different blocks.
+func (dve DuplicateVoteEvidence) ValidateBasic() error {
+	if dve.PubKey.Empty() {
+		return fmt.Errorf("DuplicateVoteEvidence Error: missing PubKey")
+	}
+	if dve.VoteA == nil || dve.VoteB == nil {
+		return fmt.Errorf("DuplicateVoteEvidence Error: missing vote")
+	}
+
+	// H/R/S must be the same
+	if dve.VoteA.Height != dve.VoteB.Height ||
+		dve.VoteA.Round != dve.VoteB.Round ||
+		dve.VoteA.Type != dve.VoteB.Type {
+		return fmt.Errorf("DuplicateVoteEvidence Error: H/R/S does not match. Got %v and %v", dve.VoteA, dve.VoteB)
+	}
+
+	// Address must be the same
+	if !bytes.Equal(dve.VoteA.ValidatorAddress, dve.VoteB.ValidatorAddress) {
+		return fmt.Errorf("DuplicateVoteEvidence Error: Validator addresses do not match. Got %X and %X", dve.VoteA.ValidatorAddress, dve.VoteB.ValidatorAddress)
+	}
+	// XXX: Should we enforce index is the same ?
+	if dve.VoteA.ValidatorIndex != dve.VoteB.ValidatorIndex {
+		return fmt.Errorf("DuplicateVoteEvidence Error: Validator indices do not match. Got %d and %d", dve.VoteA.ValidatorIndex, dve.VoteB.ValidatorIndex)
+	}
+	if !bytes.Equal(dve.PubKey.Address(), dve.VoteA.ValidatorAddress) {
+		return fmt.Errorf("DuplicateVoteEvidence Error: PubKey does not match the validator address %X", dve.VoteA.ValidatorAddress)
+	}
+
+	// BlockIDs must be different
+	if dve.VoteA.BlockID.Equals(dve.VoteB.BlockID) {
+		return fmt.Errorf("DuplicateVoteEvidence Error: BlockIDs are the same (%v) - not a real duplicate vote!", dve.VoteA.BlockID)
+	}
+
+	return nil
+}
+
+// Verify returns an error if the evidence isn't well formed (see
+// ValidateBasic) or the votes weren't both signed by the validator.
+func (dve DuplicateVoteEvidence) Verify(chainID string) error {
+	if err := dve.ValidateBasic(); err != nil {
+		return err
+	}
+
+	// Signatures must be valid
+	if !dve.PubKey.VerifyBytes(SignBytes(chainID, dve.VoteA), dve.VoteA.Signature) {
+		return fmt.Errorf("DuplicateVoteEvidence Error verifying VoteA: %v", ErrVoteInvalidSignature)
+	}
+	if !dve.PubKey.VerifyBytes(SignBytes(chainID, dve.VoteB), dve.VoteB.Signature) {
+		return fmt.Errorf("DuplicateVoteEvidence Error verifying VoteB: %v", ErrVoteInvalidSignature)
+	}
+
+	return nil
+}
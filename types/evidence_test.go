@@ -0,0 +1,120 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeVote(val *PrivValidator, chainID string, valIndex, height, round, step int, blockID BlockID) *Vote {
+	v := &Vote{
+		ValidatorAddress: val.PubKey.Address(),
+		ValidatorIndex:   valIndex,
+		Height:           height,
+		Round:            round,
+		Timestamp:        time.Now(),
+		Type:             byte(step),
+		BlockID:          blockID,
+	}
+	v.Signature = val.Sign(SignBytes(chainID, v))
+	return v
+}
+
+func TestEvidence(t *testing.T) {
+	val := GenPrivValidator()
+	val2 := GenPrivValidator()
+	blockID := makeBlockID("blockhash", 1000, "partshash")
+	blockID2 := makeBlockID("blockhash2", 1000, "partshash")
+	blockID3 := makeBlockID("blockhash", 10000, "partshash")
+	blockID4 := makeBlockID("blockhash", 10000, "partshash2")
+
+	chainID := "mychain"
+
+	vote1 := makeVote(val, chainID, 0, 10, 2, 1, blockID)
+	badVote := makeVote(val, chainID, 0, 10, 2, 1, blockID2)
+	badVote.Signature = val2.Sign(SignBytes(chainID, badVote))
+
+	cases := []struct {
+		vote1 *Vote
+		vote2 *Vote
+		valid bool
+	}{
+		{vote1, makeVote(val, chainID, 0, 10, 2, 1, blockID2), true},     // different block ids
+		{vote1, makeVote(val, chainID, 0, 10, 2, 1, blockID3), true},     // different part set totals
+		{vote1, makeVote(val, chainID, 0, 10, 2, 1, blockID4), true},     // different part set hashes
+		{vote1, makeVote(val, chainID, 0, 10, 2, 1, blockID), false},     // same block id
+		{vote1, makeVote(val, "mychain2", 0, 10, 2, 1, blockID2), false}, // wrong chain id
+		{vote1, makeVote(val, chainID, 1, 10, 2, 1, blockID2), false},    // wrong val index
+		{vote1, makeVote(val, chainID, 0, 11, 2, 1, blockID2), false},    // wrong height
+		{vote1, makeVote(val, chainID, 0, 10, 3, 1, blockID2), false},    // wrong round
+		{vote1, makeVote(val, chainID, 0, 10, 2, 2, blockID2), false},    // wrong step
+		{vote1, makeVote(val2, chainID, 0, 10, 2, 1, blockID2), false},   // wrong validator
+		{vote1, badVote, false}, // signed by wrong key
+	}
+
+	for i, c := range cases {
+		ev := DuplicateVoteEvidence{
+			PubKey: val.PubKey,
+			VoteA:  c.vote1,
+			VoteB:  c.vote2,
+		}
+		err := ev.Verify(chainID)
+		if c.valid {
+			assert.Nil(t, err, "case %d: evidence should be valid, got %v", i, err)
+		} else {
+			assert.NotNil(t, err, "case %d: evidence should be invalid", i)
+		}
+	}
+}
+
+func TestEvidenceValidateBasic(t *testing.T) {
+	val := GenPrivValidator()
+	chainID := "mychain"
+	voteA := makeVote(val, chainID, 0, 10, 2, 1, makeBlockID("blockhash", 1000, "partshash"))
+	voteB := makeVote(val, chainID, 0, 10, 2, 1, makeBlockID("blockhash2", 1000, "partshash"))
+
+	cases := []struct {
+		ev    Evidence
+		valid bool
+	}{
+		{DuplicateVoteEvidence{val.PubKey, voteA, voteB}.Wrap(), true},
+		{Evidence{}, false}, // empty
+		{DuplicateVoteEvidence{val.PubKey, nil, voteB}.Wrap(), false},   // no vote a
+		{DuplicateVoteEvidence{val.PubKey, voteA, nil}.Wrap(), false},   // no vote b
+		{DuplicateVoteEvidence{val.PubKey, voteA, voteA}.Wrap(), false}, // same vote
+	}
+	for i, c := range cases {
+		err := c.ev.ValidateBasic()
+		assert.Equal(t, c.valid, err == nil, "case %d: %v", i, err)
+	}
+}
+
+func TestEvidenceHashAndOrder(t *testing.T) {
+	val := GenPrivValidator()
+	chainID := "mychain"
+	voteA := makeVote(val, chainID, 0, 10, 2, 1, makeBlockID("blockhash", 1000, "partshash"))
+	voteB := makeVote(val, chainID, 0, 10, 2, 1, makeBlockID("blockhash2", 1000, "partshash"))
+
+	// the order the conflicting votes are reported in doesn't matter
+	ev1 := NewDuplicateVoteEvidence(val.PubKey, &ErrVoteConflictingVotes{VoteA: voteA, VoteB: voteB})
+	ev2 := NewDuplicateVoteEvidence(val.PubKey, &ErrVoteConflictingVotes{VoteA: voteB, VoteB: voteA})
+	assert.True(t, ev1.Equal(ev2))
+	assert.Nil(t, ev1.Verify(chainID))
+	assert.Equal(t, 10, ev1.Height())
+
+	evl := EvidenceList{ev1}
+	assert.True(t, evl.Has(ev2))
+	assert.Equal(t, ev1.Hash(), evl.Hash())
+	assert.Nil(t, EvidenceList{}.Hash())
+}
+
+func makeBlockID(hash string, partSetSize int, partSetHash string) BlockID {
+	return BlockID{
+		Hash: []byte(hash),
+		PartsHeader: PartSetHeader{
+			Total: partSetSize,
+			Hash:  []byte(partSetHash),
+		},
+	}
+}
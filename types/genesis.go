@@ -29,10 +29,12 @@ type GenesisValidator struct {
 
 // GenesisDoc defines the initial conditions for a tendermint blockchain, in particular its validator set.
 type GenesisDoc struct {
-	GenesisTime time.Time          `json:"genesis_time"`
-	ChainID     string             `json:"chain_id"`
-	Validators  []GenesisValidator `json:"validators"`
-	AppHash     data.Bytes         `json:"app_hash"`
+	GenesisTime     time.Time          `json:"genesis_time"`
+	ChainID         string             `json:"chain_id"`
+	ConsensusParams *ConsensusParams   `json:"consensus_params,omitempty"` // DefaultConsensusParams() if nil
+	Validators      []GenesisValidator `json:"validators"`
+	AppHash         data.Bytes         `json:"app_hash"`
+	AppState        json.RawMessage    `json:"app_state,omitempty"` // passed to the app at genesis
 }
 
 // SaveAs is a utility method for saving GenensisDoc as a JSON file.
@@ -54,14 +56,57 @@ func (genDoc *GenesisDoc) ValidatorHash() []byte {
 	return vset.Hash()
 }
 
+// ValidateAndComplete checks that the GenesisDoc is valid.
+// The GenesisTime may be missing in genesis docs written before `init` set
+// it. It's left zero then, the same on every node, so the time of the first
+// block isn't checked. Likewise, consensus_params written before the
+// evidence params get the default ones.
+func (genDoc *GenesisDoc) ValidateAndComplete() error {
+	if genDoc.ChainID == "" {
+		return errors.New("Genesis doc must include non-empty chain_id")
+	}
+	if genDoc.ConsensusParams != nil {
+		if genDoc.ConsensusParams.Evidence == (EvidenceParams{}) {
+			genDoc.ConsensusParams.Evidence = DefaultConsensusParams().Evidence
+		}
+		if err := genDoc.ConsensusParams.Validate(); err != nil {
+			return errors.Wrap(err, "Genesis doc has invalid consensus_params")
+		}
+	}
+
+	if len(genDoc.Validators) == 0 {
+		return errors.New("Genesis doc must include at least one validator")
+	}
+	seen := make(map[string]bool)
+	for i, v := range genDoc.Validators {
+		if v.PubKey.Empty() {
+			return errors.Errorf("Genesis doc validator %d (%v) has no pub_key", i, v.Name)
+		}
+		if v.Amount <= 0 {
+			return errors.Errorf("Genesis doc validator %d (%v) must have a positive amount, got %d", i, v.Name, v.Amount)
+		}
+		address := string(v.PubKey.Address())
+		if seen[address] {
+			return errors.Errorf("Genesis doc validator %d (%v) has the same pub_key as another validator: %X", i, v.Name, v.PubKey.Address())
+		}
+		seen[address] = true
+	}
+	return nil
+}
+
 //------------------------------------------------------------
 // Make genesis state from file
 
-// GenesisDocFromJSON unmarshalls JSON data into a GenesisDoc.
+// GenesisDocFromJSON unmarshalls JSON data into a GenesisDoc, and validates it.
 func GenesisDocFromJSON(jsonBlob []byte) (*GenesisDoc, error) {
 	genDoc := GenesisDoc{}
-	err := json.Unmarshal(jsonBlob, &genDoc)
-	return &genDoc, err
+	if err := json.Unmarshal(jsonBlob, &genDoc); err != nil {
+		return nil, err
+	}
+	if err := genDoc.ValidateAndComplete(); err != nil {
+		return nil, err
+	}
+	return &genDoc, nil
 }
 
 // GenesisDocFromFile reads JSON data from a file and unmarshalls it into a GenesisDoc.
@@ -72,10 +117,7 @@ func GenesisDocFromFile(genDocFile string) (*GenesisDoc, error) {
 	}
 	genDoc, err := GenesisDocFromJSON(jsonBlob)
 	if err != nil {
-		return nil, errors.Wrap(err, "Error reading GenesisDoc")
-	}
-	if genDoc.ChainID == "" {
-		return nil, errors.Errorf("Genesis doc %v must include non-empty chain_id", genDocFile)
+		return nil, errors.Wrapf(err, "Error reading GenesisDoc %v", genDocFile)
 	}
 	return genDoc, nil
 }
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	crypto "github.com/tendermint/go-crypto"
+)
+
+func TestGenesisValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	pubKey1 := crypto.GenPrivKeyEd25519().PubKey()
+	pubKey2 := crypto.GenPrivKeyEd25519().PubKey()
+	makeGenDoc := func(chainID string, vals ...GenesisValidator) *GenesisDoc {
+		return &GenesisDoc{GenesisTime: time.Now(), ChainID: chainID, Validators: vals}
+	}
+
+	cases := []struct {
+		genDoc *GenesisDoc
+		valid  bool
+	}{
+		{makeGenDoc("test-chain", GenesisValidator{pubKey1, 10, "a"}), true},
+		{makeGenDoc("test-chain", GenesisValidator{pubKey1, 10, "a"}, GenesisValidator{pubKey2, 1, "b"}), true},
+		{makeGenDoc("", GenesisValidator{pubKey1, 10, "a"}), false},                                              // no chain id
+		{makeGenDoc("test-chain"), false},                                                                        // no validators
+		{makeGenDoc("test-chain", GenesisValidator{crypto.PubKey{}, 10, "a"}), false},                            // no pub key
+		{makeGenDoc("test-chain", GenesisValidator{pubKey1, 0, "a"}), false},                                     // zero power
+		{makeGenDoc("test-chain", GenesisValidator{pubKey1, -1, "a"}), false},                                    // negative power
+		{makeGenDoc("test-chain", GenesisValidator{pubKey1, 10, "a"}, GenesisValidator{pubKey1, 1, "b"}), false}, // duplicate pub key
+	}
+	for i, tc := range cases {
+		err := tc.genDoc.ValidateAndComplete()
+		assert.Equal(tc.valid, err == nil, "case %d: %v", i, err)
+	}
+
+	// no genesis time, as written by older versions of init
+	genDoc := makeGenDoc("test-chain", GenesisValidator{pubKey1, 10, "a"})
+	genDoc.GenesisTime = time.Time{}
+	assert.Nil(genDoc.ValidateAndComplete())
+	assert.True(genDoc.GenesisTime.IsZero())
+
+	// invalid consensus params
+	genDoc = makeGenDoc("test-chain", GenesisValidator{pubKey1, 10, "a"})
+	genDoc.ConsensusParams = DefaultConsensusParams()
+	genDoc.ConsensusParams.BlockSize.MaxTxs = 0
+	assert.NotNil(genDoc.ValidateAndComplete())
+
+	// consensus params without the evidence params, as written by older versions
+	genDoc = makeGenDoc("test-chain", GenesisValidator{pubKey1, 10, "a"})
+	genDoc.ConsensusParams = DefaultConsensusParams()
+	genDoc.ConsensusParams.Evidence = EvidenceParams{}
+	assert.Nil(genDoc.ValidateAndComplete())
+	assert.Equal(DefaultConsensusParams().Evidence, genDoc.ConsensusParams.Evidence)
+}
+
+func TestGenesisDocFromJSON(t *testing.T) {
+	pubKey := crypto.GenPrivKeyEd25519().PubKey()
+	genDoc := &GenesisDoc{
+		ChainID:    "test-chain",
+		Validators: []GenesisValidator{{pubKey, 10, "a"}},
+	}
+	genDocBytes, err := json.Marshal(genDoc)
+	require.Nil(t, err)
+
+	// a missing genesis time stays missing
+	genDoc2, err := GenesisDocFromJSON(genDocBytes)
+	require.Nil(t, err, "%+v", err)
+	assert.True(t, genDoc2.GenesisTime.IsZero())
+
+	// and a set one is kept, like the app state
+	genDoc.GenesisTime = time.Date(2017, 10, 1, 0, 0, 0, 0, time.UTC)
+	genDoc.AppState = json.RawMessage(`{"balances":{"alice":100}}`)
+	genDocBytes, err = json.Marshal(genDoc)
+	require.Nil(t, err)
+	genDoc2, err = GenesisDocFromJSON(genDocBytes)
+	require.Nil(t, err, "%+v", err)
+	assert.True(t, genDoc.GenesisTime.Equal(genDoc2.GenesisTime))
+	assert.Equal(t, genDoc.Validators, genDoc2.Validators)
+	assert.JSONEq(t, string(genDoc.AppState), string(genDoc2.AppState))
+
+	// invalid docs are rejected
+	genDoc.Validators[0].Amount = 0
+	genDocBytes, err = json.Marshal(genDoc)
+	require.Nil(t, err)
+	_, err = GenesisDocFromJSON(genDocBytes)
+	assert.NotNil(t, err)
+}
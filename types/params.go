@@ -0,0 +1,98 @@
+package types
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ConsensusParams contains consensus critical parameters
+// that determine the validity of blocks.
+// They're set in the genesis, and can be updated by the app.
+// The timeouts stay in the ConsensusConfig of each node: a block is valid or
+// not however long we waited for it, so nodes with different timeouts still
+// agree, and each can tune them to its own network.
+type ConsensusParams struct {
+	BlockSize   BlockSizeParams   `json:"block_size_params"`
+	BlockGossip BlockGossipParams `json:"block_gossip_params"`
+	Evidence    EvidenceParams    `json:"evidence_params"`
+}
+
+// BlockSizeParams contain limits on the block size.
+type BlockSizeParams struct {
+	MaxBytes int `json:"max_bytes"` // NOTE: must not be 0 nor greater than MaxBlockSize
+	MaxTxs   int `json:"max_txs"`
+}
+
+// BlockGossipParams determine consensus critical elements of how blocks are gossiped.
+type BlockGossipParams struct {
+	BlockPartSizeBytes int `json:"block_part_size_bytes"` // NOTE: must not be 0
+}
+
+// EvidenceParams determine which evidence of byzantine behaviour can be committed.
+type EvidenceParams struct {
+	MaxAge int `json:"max_age"` // only evidence from the last MaxAge blocks is valid
+	MaxNum int `json:"max_num"` // maximum number of pieces of evidence in a block
+}
+
+// DefaultConsensusParams returns a default ConsensusParams.
+func DefaultConsensusParams() *ConsensusParams {
+	return &ConsensusParams{
+		BlockSize: BlockSizeParams{
+			MaxBytes: MaxBlockSize,
+			MaxTxs:   10000,
+		},
+		BlockGossip: BlockGossipParams{
+			BlockPartSizeBytes: DefaultBlockPartSize,
+		},
+		Evidence: EvidenceParams{
+			MaxAge: 100000,
+			MaxNum: 100,
+		},
+	}
+}
+
+// Validate returns an error if the params are invalid.
+func (params *ConsensusParams) Validate() error {
+	if params.BlockSize.MaxBytes <= 0 {
+		return errors.Errorf("BlockSize.MaxBytes must be greater than 0. Got %d", params.BlockSize.MaxBytes)
+	}
+	if params.BlockSize.MaxBytes > MaxBlockSize {
+		return errors.Errorf("BlockSize.MaxBytes is too big. %d > %d", params.BlockSize.MaxBytes, MaxBlockSize)
+	}
+	if params.BlockSize.MaxTxs <= 0 {
+		return errors.Errorf("BlockSize.MaxTxs must be greater than 0. Got %d", params.BlockSize.MaxTxs)
+	}
+	if params.BlockGossip.BlockPartSizeBytes <= 0 {
+		return errors.Errorf("BlockGossip.BlockPartSizeBytes must be greater than 0. Got %d", params.BlockGossip.BlockPartSizeBytes)
+	}
+	if params.Evidence.MaxAge <= 0 {
+		return errors.Errorf("Evidence.MaxAge must be greater than 0. Got %d", params.Evidence.MaxAge)
+	}
+	if params.Evidence.MaxNum <= 0 {
+		return errors.Errorf("Evidence.MaxNum must be greater than 0. Got %d", params.Evidence.MaxNum)
+	}
+	return nil
+}
+
+// Update returns a copy of the params with the non-zero fields of
+// updates applied. The params themselves are not modified.
+func (params ConsensusParams) Update(updates *ConsensusParams) ConsensusParams {
+	if updates == nil {
+		return params
+	}
+	if updates.BlockSize.MaxBytes != 0 {
+		params.BlockSize.MaxBytes = updates.BlockSize.MaxBytes
+	}
+	if updates.BlockSize.MaxTxs != 0 {
+		params.BlockSize.MaxTxs = updates.BlockSize.MaxTxs
+	}
+	if updates.BlockGossip.BlockPartSizeBytes != 0 {
+		params.BlockGossip.BlockPartSizeBytes = updates.BlockGossip.BlockPartSizeBytes
+	}
+	if updates.Evidence.MaxAge != 0 {
+		params.Evidence.MaxAge = updates.Evidence.MaxAge
+	}
+	if updates.Evidence.MaxNum != 0 {
+		params.Evidence.MaxNum = updates.Evidence.MaxNum
+	}
+	return params
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsensusParamsValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(DefaultConsensusParams().Validate())
+
+	makeParams := func(maxBytes, maxTxs, partSize int) *ConsensusParams {
+		return &ConsensusParams{
+			BlockSize:   BlockSizeParams{MaxBytes: maxBytes, MaxTxs: maxTxs},
+			BlockGossip: BlockGossipParams{BlockPartSizeBytes: partSize},
+			Evidence:    EvidenceParams{MaxAge: 1, MaxNum: 1},
+		}
+	}
+	cases := []struct {
+		params *ConsensusParams
+		valid  bool
+	}{
+		{makeParams(1, 1, 1), true},
+		{makeParams(MaxBlockSize, 1, 1), true},
+		{makeParams(0, 1, 1), false},
+		{makeParams(MaxBlockSize+1, 1, 1), false},
+		{makeParams(1, 0, 1), false},
+		{makeParams(1, 1, 0), false},
+		{makeParams(-1, 1, 1), false},
+	}
+	for i, tc := range cases {
+		err := tc.params.Validate()
+		assert.Equal(tc.valid, err == nil, "case %d: %v", i, err)
+	}
+
+	params := makeParams(1, 1, 1)
+	params.Evidence.MaxAge = 0
+	assert.NotNil(params.Validate())
+	params = makeParams(1, 1, 1)
+	params.Evidence.MaxNum = 0
+	assert.NotNil(params.Validate())
+}
+
+func TestConsensusParamsUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	params := *DefaultConsensusParams()
+	assert.Equal(params, params.Update(nil))
+	assert.Equal(params, params.Update(&ConsensusParams{}))
+
+	updated := params.Update(&ConsensusParams{BlockSize: BlockSizeParams{MaxTxs: 5}})
+	assert.Equal(5, updated.BlockSize.MaxTxs)
+	assert.Equal(params.BlockSize.MaxBytes, updated.BlockSize.MaxBytes)
+	assert.Equal(params.BlockGossip, updated.BlockGossip)
+	// the original isn't modified
+	assert.Equal(DefaultConsensusParams().BlockSize.MaxTxs, params.BlockSize.MaxTxs)
+}
@@ -0,0 +1,154 @@
+package types
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	crypto "github.com/tendermint/go-crypto"
+	data "github.com/tendermint/go-wire/data"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// PrivValidatorPassphraseEnv is the env var holding the passphrase of an
+	// encrypted priv_validator.json.
+	PrivValidatorPassphraseEnv = "TM_PRIV_VALIDATOR_PASSPHRASE"
+
+	// PrivValidatorPassphraseFDEnv is the env var holding the number of an open
+	// file descriptor (eg. a pipe) to read the passphrase from instead, so it
+	// isn't kept in the environment.
+	PrivValidatorPassphraseFDEnv = "TM_PRIV_VALIDATOR_PASSPHRASE_FD"
+)
+
+const (
+	kdfScrypt = "scrypt"
+
+	// scrypt parameters recommended for interactive logins in 2017
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	saltSize = 32
+)
+
+// ErrWrongPassphrase is returned when an encrypted key can't be decrypted
+// with the given passphrase.
+var ErrWrongPassphrase = errors.New("Wrong passphrase")
+
+// EncryptedPrivKey is a private key encrypted with a passphrase.
+// The key is derived from the passphrase with scrypt, and the private key is
+// sealed with it using nacl/secretbox (XSalsa20-Poly1305), which
+// authenticates the ciphertext.
+type EncryptedPrivKey struct {
+	KDF        string     `json:"kdf"`
+	N          int        `json:"n"`
+	R          int        `json:"r"`
+	P          int        `json:"p"`
+	Salt       data.Bytes `json:"salt"`
+	Nonce      data.Bytes `json:"nonce"`
+	Ciphertext data.Bytes `json:"ciphertext"`
+}
+
+// EncryptPrivKey encrypts the private key with the passphrase.
+func EncryptPrivKey(privKey crypto.PrivKey, passphrase string) (*EncryptedPrivKey, error) {
+	if passphrase == "" {
+		return nil, errors.New("Passphrase must not be empty")
+	}
+	ek := &EncryptedPrivKey{
+		KDF:   kdfScrypt,
+		N:     scryptN,
+		R:     scryptR,
+		P:     scryptP,
+		Salt:  crypto.CRandBytes(saltSize),
+		Nonce: crypto.CRandBytes(24),
+	}
+	key, err := ek.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], ek.Nonce)
+	ek.Ciphertext = secretbox.Seal(nil, privKey.Bytes(), &nonce, key)
+	return ek, nil
+}
+
+// Decrypt returns the private key, or ErrWrongPassphrase if the passphrase
+// doesn't match.
+func (ek *EncryptedPrivKey) Decrypt(passphrase string) (crypto.PrivKey, error) {
+	if len(ek.Nonce) != 24 {
+		return crypto.PrivKey{}, errors.Errorf("Invalid nonce size %d", len(ek.Nonce))
+	}
+	key, err := ek.deriveKey(passphrase)
+	if err != nil {
+		return crypto.PrivKey{}, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], ek.Nonce)
+	plaintext, ok := secretbox.Open(nil, ek.Ciphertext, &nonce, key)
+	if !ok {
+		return crypto.PrivKey{}, ErrWrongPassphrase
+	}
+	privKey, err := crypto.PrivKeyFromBytes(plaintext)
+	if err != nil {
+		return crypto.PrivKey{}, errors.Wrap(err, "Error decoding decrypted key")
+	}
+	return privKey, nil
+}
+
+func (ek *EncryptedPrivKey) deriveKey(passphrase string) (*[32]byte, error) {
+	if ek.KDF != kdfScrypt {
+		return nil, errors.Errorf("Unknown key derivation function %q", ek.KDF)
+	}
+	derived, err := scrypt.Key([]byte(passphrase), ek.Salt, ek.N, ek.R, ek.P, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error deriving key from passphrase")
+	}
+	var key [32]byte
+	copy(key[:], derived)
+	return &key, nil
+}
+
+var (
+	passphraseFromFDOnce sync.Once
+	passphraseFromFD     string
+	passphraseFromFDErr  error
+)
+
+// PrivValidatorPassphrase returns the passphrase to decrypt the priv
+// validator key with, from the PrivValidatorPassphraseFDEnv file descriptor
+// if it's set, or else the PrivValidatorPassphraseEnv env var.
+// The file descriptor is only read once, up to the first newline.
+func PrivValidatorPassphrase() (string, error) {
+	if fdStr := os.Getenv(PrivValidatorPassphraseFDEnv); fdStr != "" {
+		passphraseFromFDOnce.Do(func() {
+			passphraseFromFD, passphraseFromFDErr = readPassphraseFromFD(fdStr)
+		})
+		return passphraseFromFD, passphraseFromFDErr
+	}
+	if passphrase := os.Getenv(PrivValidatorPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", errors.Errorf("No passphrase given. Set %v or %v", PrivValidatorPassphraseEnv, PrivValidatorPassphraseFDEnv)
+}
+
+func readPassphraseFromFD(fdStr string) (string, error) {
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil || fd < 0 {
+		return "", errors.Errorf("Invalid %v %q", PrivValidatorPassphraseFDEnv, fdStr)
+	}
+	f := os.NewFile(uintptr(fd), "passphrase")
+	if f == nil {
+		return "", errors.Errorf("Invalid %v %q", PrivValidatorPassphraseFDEnv, fdStr)
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.Wrap(err, "Error reading passphrase")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
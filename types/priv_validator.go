@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	crypto "github.com/tendermint/go-crypto"
 	data "github.com/tendermint/go-wire/data"
@@ -34,6 +37,10 @@ func voteToStep(vote *Vote) int8 {
 	}
 }
 
+// PrivValidator signs with a key kept on local disk. The key is saved in one
+// file, and the state of the last signature, used to prevent double signing,
+// in another one (see SignStateFile), which is rewritten on every signature.
+// Older key files also had the Last* fields, and are migrated on load.
 type PrivValidator struct {
 	Address       data.Bytes       `json:"address"`
 	PubKey        crypto.PubKey    `json:"pub_key"`
@@ -44,13 +51,52 @@ type PrivValidator struct {
 	LastSignBytes data.Bytes       `json:"last_signbytes,omitempty"` // so we dont lose signatures
 
 	// PrivKey should be empty if a Signer other than the default is being used.
-	PrivKey crypto.PrivKey `json:"priv_key"`
-	Signer  `json:"-"`
+	// If EncryptedPrivKey is set, only it is written to disk.
+	PrivKey          crypto.PrivKey    `json:"priv_key"`
+	EncryptedPrivKey *EncryptedPrivKey `json:"encrypted_priv_key,omitempty"`
+	Signer           `json:"-"`
 
 	// For persistence.
 	// Overloaded for testing.
-	filePath string
-	mtx      sync.Mutex
+	filePath          string
+	signStateFilePath string
+	mtx               sync.Mutex
+}
+
+// SignState is the state of a PrivValidator's last signature.
+type SignState struct {
+	Height    int              `json:"height"`
+	Round     int              `json:"round"`
+	Step      int8             `json:"step"`
+	Signature crypto.Signature `json:"signature,omitempty"` // so we dont lose signatures
+	SignBytes data.Bytes       `json:"signbytes,omitempty"` // so we dont lose signatures
+}
+
+// privValidatorKey is what's saved in the key file.
+type privValidatorKey struct {
+	Address          data.Bytes        `json:"address"`
+	PubKey           crypto.PubKey     `json:"pub_key"`
+	PrivKey          crypto.PrivKey    `json:"priv_key"`
+	EncryptedPrivKey *EncryptedPrivKey `json:"encrypted_priv_key,omitempty"`
+}
+
+// SignStateFile returns the path to the sign state file of the given key
+// file, eg. priv_validator_state.json for priv_validator.json.
+func SignStateFile(filePath string) string {
+	return strings.TrimSuffix(filePath, ".json") + "_state.json"
+}
+
+// ValidatorSigner signs votes, proposals and heartbeats on behalf of a
+// validator, refusing to sign anything that could lead to double signing.
+// It is implemented by PrivValidator, which keeps the key on local disk,
+// and by privval.SocketClient, which forwards requests to a remote signer.
+type ValidatorSigner interface {
+	GetAddress() []byte
+	GetPubKey() crypto.PubKey
+
+	SignVote(chainID string, vote *Vote) error
+	SignProposal(chainID string, proposal *Proposal) error
+	SignHeartbeat(chainID string, heartbeat *Heartbeat) error
 }
 
 // This is used to sign votes.
@@ -106,6 +152,9 @@ func GenPrivValidator() *PrivValidator {
 	}
 }
 
+// LoadPrivValidator loads the PrivValidator from the key file and its sign
+// state file. If its key is encrypted, it's decrypted with the
+// PrivValidatorPassphrase().
 func LoadPrivValidator(filePath string) *PrivValidator {
 	privValJSONBytes, err := ioutil.ReadFile(filePath)
 	if err != nil {
@@ -117,9 +166,36 @@ func LoadPrivValidator(filePath string) *PrivValidator {
 		Exit(Fmt("Error reading PrivValidator from %v: %v\n", filePath, err))
 	}
 
+	if privVal.EncryptedPrivKey != nil {
+		passphrase, err := PrivValidatorPassphrase()
+		if err != nil {
+			Exit(Fmt("PrivValidator key in %v is encrypted: %v\n", filePath, err))
+		}
+		if err := privVal.decrypt(passphrase); err != nil {
+			Exit(Fmt("Error decrypting PrivValidator key in %v: %v\n", filePath, err))
+		}
+	}
+
 	privVal.filePath = filePath
+	privVal.signStateFilePath = SignStateFile(filePath)
 	privVal.Signer = NewDefaultSigner(privVal.PrivKey)
 	privVal.setPubKeyAndAddress()
+
+	if _, err := os.Stat(privVal.signStateFilePath); err == nil {
+		signStateJSONBytes, err := ioutil.ReadFile(privVal.signStateFilePath)
+		if err != nil {
+			Exit(err.Error())
+		}
+		signState := SignState{}
+		if err := json.Unmarshal(signStateJSONBytes, &signState); err != nil {
+			Exit(Fmt("Error reading PrivValidator sign state from %v: %v\n", privVal.signStateFilePath, err))
+		}
+		privVal.setSignState(signState)
+	} else {
+		// a new sign state file, or an old key file with the Last* fields in it,
+		// which are moved to the sign state file
+		privVal.save()
+	}
 	return &privVal
 }
 
@@ -138,10 +214,12 @@ func LoadOrGenPrivValidator(filePath string, logger log.Logger) *PrivValidator {
 	return privValidator
 }
 
+// SetFile sets the key file, and the sign state file next to it.
 func (privVal *PrivValidator) SetFile(filePath string) {
 	privVal.mtx.Lock()
 	defer privVal.mtx.Unlock()
 	privVal.filePath = filePath
+	privVal.signStateFilePath = SignStateFile(filePath)
 }
 
 func (privVal *PrivValidator) Save() {
@@ -150,6 +228,59 @@ func (privVal *PrivValidator) Save() {
 	privVal.save()
 }
 
+// Encrypt encrypts the private key with the passphrase, so it's no longer
+// saved in plaintext. The PrivValidator can still sign.
+func (privVal *PrivValidator) Encrypt(passphrase string) error {
+	privVal.mtx.Lock()
+	defer privVal.mtx.Unlock()
+	ek, err := EncryptPrivKey(privVal.PrivKey, passphrase)
+	if err != nil {
+		return err
+	}
+	privVal.EncryptedPrivKey = ek
+	return nil
+}
+
+// RemoveEncryption makes the private key be saved in plaintext again.
+func (privVal *PrivValidator) RemoveEncryption() {
+	privVal.mtx.Lock()
+	defer privVal.mtx.Unlock()
+	privVal.EncryptedPrivKey = nil
+}
+
+// IsEncrypted returns true if the private key is saved encrypted.
+func (privVal *PrivValidator) IsEncrypted() bool {
+	return privVal.EncryptedPrivKey != nil
+}
+
+func (privVal *PrivValidator) decrypt(passphrase string) error {
+	privKey, err := privVal.EncryptedPrivKey.Decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	if !privKey.PubKey().Equals(privVal.PubKey) {
+		return errors.New("Decrypted key doesn't match the pub_key")
+	}
+	privVal.PrivKey = privKey
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. It returns the contents of the key
+// file, which don't include the sign state.
+// The plaintext private key is left out if it's encrypted.
+func (privVal *PrivValidator) MarshalJSON() ([]byte, error) {
+	key := privValidatorKey{
+		Address:          privVal.Address,
+		PubKey:           privVal.PubKey,
+		PrivKey:          privVal.PrivKey,
+		EncryptedPrivKey: privVal.EncryptedPrivKey,
+	}
+	if key.EncryptedPrivKey != nil {
+		key.PrivKey = crypto.PrivKey{}
+	}
+	return json.Marshal(key)
+}
+
 func (privVal *PrivValidator) save() {
 	if privVal.filePath == "" {
 		PanicSanity("Cannot save PrivValidator: filePath not set")
@@ -159,13 +290,83 @@ func (privVal *PrivValidator) save() {
 		// `@; BOOM!!!
 		PanicCrisis(err)
 	}
-	err = WriteFileAtomic(privVal.filePath, jsonBytes, 0600)
+	// the sign state first, in case the key file still has the Last* fields
+	if err := privVal.saveSignState(privVal.signState()); err != nil {
+		// `@; BOOM!!!
+		PanicCrisis(err)
+	}
+	err = writeFileSynced(privVal.filePath, jsonBytes, 0400)
 	if err != nil {
 		// `@; BOOM!!!
 		PanicCrisis(err)
 	}
 }
 
+func (privVal *PrivValidator) signState() SignState {
+	return SignState{
+		Height:    privVal.LastHeight,
+		Round:     privVal.LastRound,
+		Step:      privVal.LastStep,
+		Signature: privVal.LastSignature,
+		SignBytes: privVal.LastSignBytes,
+	}
+}
+
+func (privVal *PrivValidator) setSignState(signState SignState) {
+	privVal.LastHeight = signState.Height
+	privVal.LastRound = signState.Round
+	privVal.LastStep = signState.Step
+	privVal.LastSignature = signState.Signature
+	privVal.LastSignBytes = signState.SignBytes
+}
+
+// saveSignState atomically replaces the sign state file, and only returns
+// once it's synced to disk.
+func (privVal *PrivValidator) saveSignState(signState SignState) error {
+	if privVal.signStateFilePath == "" {
+		PanicSanity("Cannot save PrivValidator sign state: signStateFilePath not set")
+	}
+	jsonBytes, err := json.Marshal(signState)
+	if err != nil {
+		return err
+	}
+	return writeFileSynced(privVal.signStateFilePath, jsonBytes, 0600)
+}
+
+// writeFileSynced atomically replaces the file by renaming a temp file over
+// it, and syncs both the file and the rename to disk.
+func writeFileSynced(filePath string, newBytes []byte, mode os.FileMode) error {
+	dir := filepath.Dir(filePath)
+	f, err := ioutil.TempFile(dir, filepath.Base(filePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(newBytes)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
 // NOTE: Unsafe!
 func (privVal *PrivValidator) Reset() {
 	privVal.LastHeight = 0
@@ -180,10 +381,26 @@ func (privVal *PrivValidator) GetAddress() []byte {
 	return privVal.Address
 }
 
+func (privVal *PrivValidator) GetPubKey() crypto.PubKey {
+	return privVal.PubKey
+}
+
+// SignVote signs the vote, unless it could lead to double signing.
+// If we already signed a vote for the same height/round/step which only
+// differs by its timestamp, eg. because we crashed before sending it, the
+// vote gets the same timestamp and signature.
 func (privVal *PrivValidator) SignVote(chainID string, vote *Vote) error {
 	privVal.mtx.Lock()
 	defer privVal.mtx.Unlock()
-	signature, err := privVal.signBytesHRS(vote.Height, vote.Round, voteToStep(vote), SignBytes(chainID, vote))
+	signBytes := SignBytes(chainID, vote)
+	step := voteToStep(vote)
+	if privVal.LastHeight == vote.Height && privVal.LastRound == vote.Round && privVal.LastStep == step {
+		if timestamp, ok := votesOnlyDifferByTimestamp(privVal.LastSignBytes, signBytes); ok {
+			vote.Timestamp = timestamp
+			signBytes = SignBytes(chainID, vote)
+		}
+	}
+	signature, err := privVal.signBytesHRS(vote.Height, vote.Round, step, signBytes)
 	if err != nil {
 		return errors.New(Fmt("Error signing vote: %v", err))
 	}
@@ -240,16 +457,36 @@ func (privVal *PrivValidator) signBytesHRS(height, round int, step int8, signByt
 	// Sign
 	sig = privVal.Sign(signBytes)
 
-	// Persist height/round/step
-	privVal.LastHeight = height
-	privVal.LastRound = round
-	privVal.LastStep = step
-	privVal.LastSignature = sig
-	privVal.LastSignBytes = signBytes
-	privVal.save()
+	// Persist height/round/step before releasing the signature,
+	// so we never sign for the same height/round/step twice
+	signState := SignState{height, round, step, sig, signBytes}
+	if err := privVal.saveSignState(signState); err != nil {
+		return crypto.Signature{}, errors.New(Fmt("Error saving sign state: %v", err))
+	}
+	privVal.setSignState(signState)
 
 	return sig, nil
+}
+
+// votesOnlyDifferByTimestamp returns the timestamp of the last vote if the
+// sign bytes of both votes only differ by their timestamps.
+func votesOnlyDifferByTimestamp(lastSignBytes, newSignBytes []byte) (time.Time, bool) {
+	var lastVote, newVote CanonicalJSONOnceVote
+	if err := json.Unmarshal(lastSignBytes, &lastVote); err != nil {
+		return time.Time{}, false
+	}
+	if err := json.Unmarshal(newSignBytes, &newVote); err != nil {
+		return time.Time{}, false
+	}
+	lastTime, err := time.Parse(TimeFormat, lastVote.Vote.Timestamp)
+	if err != nil {
+		return time.Time{}, false
+	}
 
+	lastVote.Vote.Timestamp, newVote.Vote.Timestamp = "", ""
+	lastBytes, _ := json.Marshal(lastVote)
+	newBytes, _ := json.Marshal(newVote)
+	return lastTime, bytes.Equal(lastBytes, newBytes)
 }
 
 func (privVal *PrivValidator) SignHeartbeat(chainID string, heartbeat *Heartbeat) error {
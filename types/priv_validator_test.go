@@ -4,7 +4,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -58,3 +62,115 @@ func TestLoadValidator(t *testing.T) {
 	require.Nil(err, "%+v", err)
 	assert.JSONEq(serialized, string(out))
 }
+
+func TestLoadEncryptedValidator(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	dir, err := ioutil.TempDir("", "priv_validator")
+	require.Nil(err, "%+v", err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "priv_validator.json")
+
+	privVal := GenPrivValidator()
+	privVal.SetFile(filePath)
+	require.Nil(privVal.Encrypt("secret"))
+	privVal.Save()
+
+	// the plaintext key isn't written
+	jsonBytes, err := ioutil.ReadFile(filePath)
+	require.Nil(err, "%+v", err)
+	var fields map[string]interface{}
+	require.Nil(json.Unmarshal(jsonBytes, &fields))
+	assert.Nil(fields["priv_key"])
+	assert.NotNil(fields["encrypted_priv_key"])
+
+	// it's decrypted with the passphrase from the env
+	os.Setenv(PrivValidatorPassphraseEnv, "secret")
+	defer os.Unsetenv(PrivValidatorPassphraseEnv)
+	loaded := LoadPrivValidator(filePath)
+	assert.True(loaded.IsEncrypted())
+	assert.Equal(privVal.PrivKey, loaded.PrivKey)
+
+	// and can still sign
+	vote := &Vote{ValidatorAddress: loaded.Address, Height: 1, Type: VoteTypePrevote}
+	require.Nil(loaded.SignVote("mychain", vote))
+	assert.True(loaded.PubKey.VerifyBytes(SignBytes("mychain", vote), vote.Signature))
+
+	// but not with another passphrase
+	_, err = loaded.EncryptedPrivKey.Decrypt("wrong")
+	assert.Equal(ErrWrongPassphrase, err)
+
+	// and can be saved in plaintext again
+	loaded.RemoveEncryption()
+	loaded.Save()
+	os.Unsetenv(PrivValidatorPassphraseEnv)
+	loaded = LoadPrivValidator(filePath)
+	assert.False(loaded.IsEncrypted())
+	assert.Equal(privVal.PrivKey, loaded.PrivKey)
+	assert.Equal(1, loaded.LastHeight)
+}
+
+func TestSignStateFile(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	dir, err := ioutil.TempDir("", "priv_validator")
+	require.Nil(err, "%+v", err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "priv_validator.json")
+	stateFilePath := filepath.Join(dir, "priv_validator_state.json")
+	assert.Equal(stateFilePath, SignStateFile(filePath))
+
+	// an old key file, with the sign state in it
+	privVal := GenPrivValidator()
+	keyJSONBytes, err := json.Marshal(privVal)
+	require.Nil(err, "%+v", err)
+	var oldFields map[string]interface{}
+	require.Nil(json.Unmarshal(keyJSONBytes, &oldFields))
+	oldFields["last_height"] = 5
+	oldFields["last_step"] = stepPrevote
+	oldJSONBytes, err := json.Marshal(oldFields)
+	require.Nil(err, "%+v", err)
+	require.Nil(ioutil.WriteFile(filePath, oldJSONBytes, 0600))
+
+	// is migrated on load
+	loaded := LoadPrivValidator(filePath)
+	assert.Equal(5, loaded.LastHeight)
+	var keyFields, stateFields map[string]interface{}
+	keyJSONBytes, err = ioutil.ReadFile(filePath)
+	require.Nil(err, "%+v", err)
+	require.Nil(json.Unmarshal(keyJSONBytes, &keyFields))
+	assert.Nil(keyFields["last_height"])
+	assert.NotNil(keyFields["priv_key"])
+	stateJSONBytes, err := ioutil.ReadFile(stateFilePath)
+	require.Nil(err, "%+v", err)
+	require.Nil(json.Unmarshal(stateJSONBytes, &stateFields))
+	assert.EqualValues(5, stateFields["height"])
+
+	// signing only updates the sign state file
+	vote := &Vote{ValidatorAddress: loaded.Address, Height: 6, Type: VoteTypePrevote}
+	require.Nil(loaded.SignVote("mychain", vote))
+	newKeyJSONBytes, err := ioutil.ReadFile(filePath)
+	require.Nil(err, "%+v", err)
+	assert.Equal(keyJSONBytes, newKeyJSONBytes)
+
+	// and a regression from the reloaded state fails
+	loaded = LoadPrivValidator(filePath)
+	assert.Equal(6, loaded.LastHeight)
+	assert.Equal(vote.Signature, loaded.LastSignature)
+	conflicting := &Vote{ValidatorAddress: loaded.Address, Height: 6, Round: 0, Type: VoteTypePrevote,
+		BlockID: BlockID{Hash: []byte("other block")}}
+	assert.NotNil(loaded.SignVote("mychain", conflicting))
+	old := &Vote{ValidatorAddress: loaded.Address, Height: 5, Type: VoteTypePrecommit}
+	assert.NotNil(loaded.SignVote("mychain", old))
+
+	// but signing the same vote again returns the same signature
+	same := &Vote{ValidatorAddress: loaded.Address, Height: 6, Type: VoteTypePrevote}
+	require.Nil(loaded.SignVote("mychain", same))
+	assert.Equal(vote.Signature, same.Signature)
+
+	// and so does one that only differs by its timestamp, with the old timestamp
+	later := &Vote{ValidatorAddress: loaded.Address, Height: 6, Type: VoteTypePrevote, Timestamp: time.Now()}
+	require.Nil(loaded.SignVote("mychain", later))
+	assert.Equal(vote.Signature, later.Signature)
+	assert.True(vote.Timestamp.Equal(later.Timestamp))
+}
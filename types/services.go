@@ -41,14 +41,36 @@ func (m MockMempool) Flush()                                       {}
 func (m MockMempool) TxsAvailable() <-chan int                     { return make(chan int) }
 func (m MockMempool) EnableTxsAvailable()                          {}
 
+//------------------------------------------------------
+// evidence pool
+
+// EvidencePool defines the EvidencePool interface used by the ConsensusState.
+// UNSTABLE
+type EvidencePool interface {
+	PendingEvidence() []Evidence
+	AddEvidence(Evidence) error
+	Update(*Block)
+}
+
+// MockEvidencePool is an empty implementation of an EvidencePool, useful for testing.
+// UNSTABLE
+type MockEvidencePool struct {
+}
+
+func (m MockEvidencePool) PendingEvidence() []Evidence { return nil }
+func (m MockEvidencePool) AddEvidence(Evidence) error  { return nil }
+func (m MockEvidencePool) Update(*Block)               {}
+
 //------------------------------------------------------
 // blockstore
 
 type BlockStoreRPC interface {
+	Base() int
 	Height() int
 
 	LoadBlockMeta(height int) *BlockMeta
 	LoadBlock(height int) *Block
+	LoadBlockByHash(hash []byte) *Block
 	LoadBlockPart(height int, index int) *Part
 
 	LoadBlockCommit(height int) *Commit
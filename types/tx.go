@@ -116,4 +116,24 @@ type TxResult struct {
 	Index  uint32                 `json:"index"`
 	Tx     Tx                     `json:"tx"`
 	Result abci.ResponseDeliverTx `json:"result"`
+	Tags   []*TxTag               `json:"tags"`
 }
+
+// TxTag is a key/value pair the app gives a tx when it's delivered, so it
+// can be searched for (see proxy.TxTagger). Its value is ValueInt if IsInt
+// is set, or ValueString otherwise.
+// TODO: use the tags of abci's ResponseDeliverTx once it has them.
+type TxTag struct {
+	Key         string `json:"key"`
+	ValueString string `json:"value_string,omitempty"`
+	ValueInt    int64  `json:"value_int,omitempty"`
+	IsInt       bool   `json:"is_int,omitempty"`
+}
+
+const (
+	// TxHashKey is a reserved tag, used to search for a transaction by hash.
+	TxHashKey = "tx.hash"
+	// TxHeightKey is a reserved tag, used to search for transactions by the
+	// height of the block they were included in.
+	TxHeightKey = "tx.height"
+)
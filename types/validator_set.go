@@ -263,30 +263,61 @@ func (valSet *ValidatorSet) VerifyCommit(chainID string, blockID BlockID, height
 	}
 }
 
-// Verify that +2/3 of this set had signed the given signBytes.
-// Unlike VerifyCommit(), this function can verify commits with differeent sets.
+// VerifyCommitAny verifies that +2/3 of this set had signed the given commit.
+// Unlike VerifyCommit(), the commit may have been signed by a different set:
+// precommits are matched to this set by validator address rather than index,
+// and precommits from validators not in this set are ignored.
+// This lets a light client trust a new validator set, as long as it was
+// endorsed by +2/3 of the power of the set it already trusts.
 func (valSet *ValidatorSet) VerifyCommitAny(chainID string, blockID BlockID, height int, commit *Commit) error {
-	panic("Not yet implemented")
-	/*
-			Start like:
-
-		FOR_LOOP:
-			for _, val := range vals {
-				if len(precommits) == 0 {
-					break FOR_LOOP
-				}
-				next := precommits[0]
-				switch bytes.Compare(val.Address(), next.ValidatorAddress) {
-				case -1:
-					continue FOR_LOOP
-				case 0:
-					signBytes := tm.SignBytes(next)
-					...
-				case 1:
-					... // error?
-				}
-			}
-	*/
+	if height != commit.Height() {
+		return fmt.Errorf("Invalid commit -- wrong height: %v vs %v", height, commit.Height())
+	}
+
+	talliedVotingPower := int64(0)
+	round := commit.Round()
+	seen := make(map[int]bool)
+
+	for idx, precommit := range commit.Precommits {
+		// may be nil if validator skipped.
+		if precommit == nil {
+			continue
+		}
+		if precommit.Height != height {
+			return fmt.Errorf("Invalid commit -- wrong height: %v vs %v", height, precommit.Height)
+		}
+		if precommit.Round != round {
+			return fmt.Errorf("Invalid commit -- wrong round: %v vs %v", round, precommit.Round)
+		}
+		if precommit.Type != VoteTypePrecommit {
+			return fmt.Errorf("Invalid commit -- not precommit @ index %v", idx)
+		}
+		// Only validators in this set count
+		valIdx, val := valSet.GetByAddress(precommit.ValidatorAddress)
+		if val == nil {
+			continue
+		}
+		if seen[valIdx] {
+			return fmt.Errorf("Invalid commit -- double vote from %X", precommit.ValidatorAddress)
+		}
+		seen[valIdx] = true
+		// Validate signature
+		precommitSignBytes := SignBytes(chainID, precommit)
+		if !val.PubKey.VerifyBytes(precommitSignBytes, precommit.Signature) {
+			return fmt.Errorf("Invalid commit -- invalid signature: %v", precommit)
+		}
+		if !blockID.Equals(precommit.BlockID) {
+			continue // Not an error, but doesn't count
+		}
+		// Good precommit!
+		talliedVotingPower += val.VotingPower
+	}
+
+	if talliedVotingPower > valSet.TotalVotingPower()*2/3 {
+		return nil
+	}
+	return fmt.Errorf("Invalid commit -- insufficient old voting power: got %v, needed %v",
+		talliedVotingPower, (valSet.TotalVotingPower()*2/3 + 1))
 }
 
 func (valSet *ValidatorSet) ToBytes() []byte {
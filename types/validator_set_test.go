@@ -189,6 +189,79 @@ func TestProposerSelection3(t *testing.T) {
 	}
 }
 
+// makeCommit signs a commit for blockID with all the privVals that are in valSet.
+func makeCommit(chainID string, height int, blockID BlockID, valSet *ValidatorSet, privVals []*PrivValidator) *Commit {
+	precommits := make([]*Vote, valSet.Size())
+	for _, privVal := range privVals {
+		idx, val := valSet.GetByAddress(privVal.Address)
+		if val == nil {
+			continue
+		}
+		vote := &Vote{
+			ValidatorAddress: privVal.Address,
+			ValidatorIndex:   idx,
+			Height:           height,
+			Round:            0,
+			Type:             VoteTypePrecommit,
+			BlockID:          blockID,
+		}
+		vote.Signature = privVal.Sign(SignBytes(chainID, vote))
+		precommits[idx] = vote
+	}
+	return &Commit{BlockID: blockID, Precommits: precommits}
+}
+
+func TestVerifyCommitAny(t *testing.T) {
+	chainID := "test_chain_id"
+	height := 10
+	blockID := BlockID{Hash: []byte("blockhash")}
+
+	oldSet, oldPrivVals := RandValidatorSet(4, 10)
+
+	// the new set keeps 3 of the 4 old validators and adds one
+	newVal, newPrivVal := RandValidator(false, 10)
+	newSet := oldSet.Copy()
+	newSet.Remove(oldPrivVals[0].Address)
+	newSet.Add(newVal)
+	newPrivVals := append([]*PrivValidator{newPrivVal}, oldPrivVals[1:]...)
+
+	commit := makeCommit(chainID, height, blockID, newSet, newPrivVals)
+	if err := newSet.VerifyCommit(chainID, blockID, height, commit); err != nil {
+		t.Fatalf("Expected commit to be valid for the signing set: %v", err)
+	}
+	// 30 of the 40 old voting power signed
+	if err := oldSet.VerifyCommitAny(chainID, blockID, height, commit); err != nil {
+		t.Fatalf("Expected commit to be valid for the old set: %v", err)
+	}
+	// VerifyCommit can't be used with a different set
+	if err := oldSet.VerifyCommit(chainID, blockID, height, commit); err == nil {
+		t.Fatalf("Expected VerifyCommit to fail with a different set")
+	}
+	if err := oldSet.VerifyCommitAny(chainID, blockID, height+1, commit); err == nil {
+		t.Fatalf("Expected commit to be invalid for the wrong height")
+	}
+
+	// the next set only keeps 2 of the old validators, so only 20 of the 40
+	// old voting power signed
+	nextVal, nextPrivVal := RandValidator(false, 10)
+	nextSet := newSet.Copy()
+	nextSet.Remove(oldPrivVals[1].Address)
+	nextSet.Add(nextVal)
+	nextPrivVals := append([]*PrivValidator{newPrivVal, nextPrivVal}, oldPrivVals[2:]...)
+
+	commit = makeCommit(chainID, height, blockID, nextSet, nextPrivVals)
+	if err := nextSet.VerifyCommit(chainID, blockID, height, commit); err != nil {
+		t.Fatalf("Expected commit to be valid for the signing set: %v", err)
+	}
+	if err := oldSet.VerifyCommitAny(chainID, blockID, height, commit); err == nil {
+		t.Fatalf("Expected commit to have insufficient old voting power")
+	}
+	// but the intermediate set still trusts it
+	if err := newSet.VerifyCommitAny(chainID, blockID, height, commit); err != nil {
+		t.Fatalf("Expected commit to be valid for the intermediate set: %v", err)
+	}
+}
+
 func BenchmarkValidatorSetCopy(b *testing.B) {
 	b.StopTimer()
 	vset := NewValidatorSet([]*Validator{})
@@ -2,6 +2,7 @@ package types
 
 import (
 	"testing"
+	"time"
 )
 
 func TestVoteSignable(t *testing.T) {
@@ -10,6 +11,7 @@ func TestVoteSignable(t *testing.T) {
 		ValidatorIndex:   56789,
 		Height:           12345,
 		Round:            23456,
+		Timestamp:        time.Date(2017, 12, 25, 3, 0, 1, 234000000, time.UTC),
 		Type:             byte(2),
 		BlockID: BlockID{
 			Hash: []byte("hash"),
@@ -22,7 +24,7 @@ func TestVoteSignable(t *testing.T) {
 	signBytes := SignBytes("test_chain_id", vote)
 	signStr := string(signBytes)
 
-	expected := `{"chain_id":"test_chain_id","vote":{"block_id":{"hash":"68617368","parts":{"hash":"70617274735F68617368","total":1000000}},"height":12345,"round":23456,"type":2}}`
+	expected := `{"chain_id":"test_chain_id","vote":{"block_id":{"hash":"68617368","parts":{"hash":"70617274735F68617368","total":1000000}},"height":12345,"round":23456,"timestamp":"2017-12-25T03:00:01.234Z","type":2}}`
 	if signStr != expected {
 		// NOTE: when this fails, you probably want to fix up consensus/replay_test too
 		t.Errorf("Got unexpected sign string for Vote. Expected:\n%v\nGot:\n%v", expected, signStr)